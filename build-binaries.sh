@@ -9,6 +9,10 @@ fi
 package_split=(${package//\// })
 package_name=${package_split[${#package_split[@]}-1]}
 
+commit=$(git rev-parse HEAD 2>/dev/null)
+build_date=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+ldflags="-X github.com/microcks/microcks-cli/version.Commit=$commit -X github.com/microcks/microcks-cli/version.BuildDate=$build_date"
+
 platforms=("linux/amd64" "linux/arm64" "linux/386" "windows/amd64" "windows/386" "darwin/amd64" "darwin/arm64")
 
 for platform in "${platforms[@]}"
@@ -21,7 +25,7 @@ do
         output_name+='.exe'
     fi  
 
-    env GOOS=$GOOS GOARCH=$GOARCH go build -o ./build/_output/$output_name $package
+    env GOOS=$GOOS GOARCH=$GOARCH go build -ldflags="$ldflags" -o ./build/_output/$output_name $package
     if [ $? -ne 0 ]; then
         echo 'An error has occurred! Aborting the script execution...'
         exit 1
@@ -0,0 +1,101 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"flag"
+	"fmt"
+)
+
+// flagAlias declares a deprecated flag name kept working as an alias of its replacement.
+type flagAlias struct {
+	deprecated  string
+	replacement string
+}
+
+// clientFlagAliases holds the deprecated names of shared client flags. Commands declare
+// their own table and register it after their flags.
+var clientFlagAliases = []flagAlias{
+	{"maxResponseSize", "max-response-size"},
+}
+
+// warnedAliases records the deprecated names already warned about, so that warning is written once.
+var warnedAliases = map[string]bool{}
+
+// deprecatedValue forwards values of a deprecated flag to its replacement, warning on first use.
+type deprecatedValue struct {
+	flag.Value
+	alias flagAlias
+}
+
+func (v *deprecatedValue) Set(value string) error {
+	warnDeprecated(v.alias, "--"+v.alias.deprecated+" flag")
+	return v.Value.Set(value)
+}
+
+func (v *deprecatedValue) String() string {
+	// flag package calls String on zero values when printing defaults.
+	if v == nil || v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+// IsBoolFlag keeps deprecated boolean flags usable without value.
+func (v *deprecatedValue) IsBoolFlag() bool {
+	b, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// registerAliases declares the deprecated names of aliases on fs. Replacement flags must already be registered.
+func registerAliases(fs *flag.FlagSet, aliases []flagAlias) {
+	for _, alias := range aliases {
+		target := fs.Lookup(alias.replacement)
+		if target == nil {
+			panic("flag alias " + alias.deprecated + " refers to unknown flag " + alias.replacement)
+		}
+		fs.Var(&deprecatedValue{Value: target.Value, alias: alias}, alias.deprecated,
+			fmt.Sprintf("DEPRECATED: use --%s instead", alias.replacement))
+	}
+}
+
+// deprecatedAlias returns the alias declared by flag f if it is a deprecated one.
+func deprecatedAlias(f *flag.Flag) (flagAlias, bool) {
+	if v, ok := f.Value.(*deprecatedValue); ok {
+		return v.alias, true
+	}
+	return flagAlias{}, false
+}
+
+// deprecatedNames returns the deprecated names of flag name in fs.
+func deprecatedNames(fs *flag.FlagSet, name string) []string {
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if alias, ok := deprecatedAlias(f); ok && alias.replacement == name {
+			names = append(names, alias.deprecated)
+		}
+	})
+	return names
+}
+
+// warnDeprecated writes a deprecation warning for the use of alias, once per run.
+func warnDeprecated(alias flagAlias, use string) {
+	if warnedAliases[alias.deprecated] {
+		return
+	}
+	warnedAliases[alias.deprecated] = true
+	console.Warnf("Warning: %s is deprecated and will be removed in a future release, use --%s instead", use, alias.replacement)
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestFlagAliases(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		env          string
+		flag         string
+		want         string
+		wantWarnings int
+	}{
+		{name: "replacement flag", args: []string{"--max-size=10"}, flag: "max-size", want: "10"},
+		{name: "deprecated flag", args: []string{"--maxBytes=10"}, flag: "max-size", want: "10", wantWarnings: 1},
+		{name: "deprecated flag passed twice", args: []string{"--maxBytes=10", "--maxBytes=20"}, flag: "max-size", want: "20", wantWarnings: 1},
+		{name: "deprecated bool flag", args: []string{"--dryRun"}, flag: "dry-run", want: "true", wantWarnings: 1},
+		{name: "deprecated environment variable", env: "MICROCKS_MAX_BYTES", flag: "max-size", want: "30", wantWarnings: 1},
+		{name: "replacement environment variable", env: "MICROCKS_MAX_SIZE", flag: "max-size", want: "30"},
+		{name: "not used", flag: "max-size", want: "0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			configureConsole(&out, &out, false, false, slog.LevelInfo, false)
+			warnedAliases = map[string]bool{}
+			if len(test.env) > 0 {
+				t.Setenv(test.env, "30")
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.Int("max-size", 0, "")
+			fs.Bool("dry-run", false, "")
+			registerAliases(fs, []flagAlias{{"maxBytes", "max-size"}, {"dryRun", "dry-run"}})
+			if _, err := parseArgs(fs, test.args, io.Discard); err != nil {
+				t.Fatalf("parseArgs() error = %v", err)
+			}
+
+			if got := fs.Lookup(test.flag).Value.String(); got != test.want {
+				t.Errorf("--%s = %s, want %s", test.flag, got, test.want)
+			}
+			if warnings := strings.Count(out.String(), "is deprecated"); warnings != test.wantWarnings {
+				t.Errorf("got %d deprecation warnings, want %d:\n%s", warnings, test.wantWarnings, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+)
+
+// Statuses of Allure results.
+const (
+	allurePassed = "passed"
+	allureFailed = "failed"
+	allureBroken = "broken"
+)
+
+// allureLabel is a label of an Allure result, used by reports to group and filter results.
+type allureLabel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// allureParameter is a parameter of an Allure result, part of its history identity.
+type allureParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type allureLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Type string `json:"type"`
+}
+
+type allureStatusDetails struct {
+	Message string `json:"message,omitempty"`
+	Trace   string `json:"trace,omitempty"`
+}
+
+// allureStep is a step of an Allure result, one per request or event checked by Microcks.
+type allureStep struct {
+	Name          string               `json:"name"`
+	Status        string               `json:"status"`
+	StatusDetails *allureStatusDetails `json:"statusDetails,omitempty"`
+	Stage         string               `json:"stage"`
+	Start         int64                `json:"start"`
+	Stop          int64                `json:"stop"`
+}
+
+// allureTestResult is the content of an Allure 2 <uuid>-result.json file.
+type allureTestResult struct {
+	UUID          string               `json:"uuid"`
+	HistoryID     string               `json:"historyId"`
+	FullName      string               `json:"fullName"`
+	Name          string               `json:"name"`
+	Status        string               `json:"status"`
+	StatusDetails *allureStatusDetails `json:"statusDetails,omitempty"`
+	Stage         string               `json:"stage"`
+	Start         int64                `json:"start"`
+	Stop          int64                `json:"stop"`
+	Labels        []allureLabel        `json:"labels"`
+	Parameters    []allureParameter    `json:"parameters,omitempty"`
+	Links         []allureLink         `json:"links,omitempty"`
+	Steps         []allureStep         `json:"steps,omitempty"`
+}
+
+// allureContainer is the content of an Allure 2 <uuid>-container.json file, grouping the results of a test.
+type allureContainer struct {
+	UUID     string   `json:"uuid"`
+	Name     string   `json:"name"`
+	Children []string `json:"children"`
+	Start    int64    `json:"start"`
+	Stop     int64    `json:"stop"`
+}
+
+// writeAllureResults writes in dir an Allure result for each operation of a test, and a container
+// grouping them. Operations are timed one after the other from the start of the test. A failed test
+// without any failed operation, like a test still in progress or that could not run, is reported as a
+// broken result named after the service.
+func writeAllureResults(dir string, result *testResult) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Cannot write Allure results: %w", err)
+	}
+	name, version, _ := strings.Cut(result.ServiceRef, ":")
+	labels := []allureLabel{
+		{"framework", "microcks"},
+		{"suite", result.ServiceRef},
+		{"service", name},
+		{"version", version},
+		{"runner", result.RunnerType},
+	}
+	base := allureTestResult{
+		Stage:      "finished",
+		Labels:     labels,
+		Parameters: []allureParameter{{"endpoint", result.TestEndpoint}},
+	}
+	if len(result.URL) > 0 {
+		base.Links = []allureLink{{Name: "Microcks test " + result.TestResultID, URL: result.URL, Type: "link"}}
+	}
+
+	var results []allureTestResult
+	failed := false
+	start := startMillis(result)
+	container := allureContainer{UUID: config.NewUUID(), Name: result.ServiceRef + " on " + result.TestEndpoint, Start: start, Stop: start}
+	if result.details != nil {
+		for _, testCase := range result.details.TestCaseResults {
+			operation := base
+			operation.Name = testCase.OperationName
+			operation.Status = allurePassed
+			operation.Start, operation.Stop = container.Stop, container.Stop+testCase.ElapsedTime
+			stepStart := operation.Start
+			var messages []string
+			for _, testStep := range testCase.TestStepResults {
+				step := allureStep{Name: testStep.RequestName, Status: allurePassed, Stage: "finished", Start: stepStart, Stop: stepStart + testStep.ElapsedTime}
+				if len(step.Name) == 0 {
+					step.Name = testStep.EventMessageName
+				}
+				if !testStep.Success {
+					step.Status = allureFailed
+					if len(testStep.Message) > 0 {
+						step.StatusDetails = &allureStatusDetails{Message: testStep.Message}
+						messages = append(messages, step.Name+": "+testStep.Message)
+					}
+				}
+				stepStart = step.Stop
+				operation.Steps = append(operation.Steps, step)
+			}
+			if !testCase.Success {
+				failed = true
+				operation.Status = allureFailed
+				message := "Operation failed"
+				if len(messages) > 0 {
+					message = strings.Join(messages, "\n")
+				}
+				operation.StatusDetails = &allureStatusDetails{Message: message}
+			}
+			container.Stop = operation.Stop
+			results = append(results, operation)
+		}
+	}
+	if !result.Success && !failed {
+		broken := base
+		broken.Name = result.ServiceRef
+		broken.Status = allureBroken
+		broken.StatusDetails = &allureStatusDetails{Message: result.failure()}
+		broken.Start, broken.Stop = container.Stop, container.Stop
+		results = append(results, broken)
+	}
+
+	for _, test := range results {
+		test.UUID = config.NewUUID()
+		test.FullName = result.ServiceRef + " " + test.Name
+		// History identifies the same operation across runs, on the same endpoint.
+		history := md5.Sum([]byte(test.FullName + " " + result.TestEndpoint))
+		test.HistoryID = hex.EncodeToString(history[:])
+		if err := writeAllureFile(dir, test.UUID+"-result.json", test); err != nil {
+			return err
+		}
+		container.Children = append(container.Children, test.UUID)
+	}
+	return writeAllureFile(dir, container.UUID+"-container.json", container)
+}
+
+// startMillis returns the start of a test in milliseconds since epoch, now if unknown.
+func startMillis(result *testResult) int64 {
+	if result.details != nil && result.details.TestDate > 0 {
+		return result.details.TestDate
+	}
+	return time.Now().UnixMilli()
+}
+
+func writeAllureFile(dir string, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cannot write Allure results: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return fmt.Errorf("Cannot write Allure results: %w", err)
+	}
+	return nil
+}
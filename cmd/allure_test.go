@@ -0,0 +1,86 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+func TestWriteAllureResultsUUIDs(t *testing.T) {
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	result := &testResult{
+		TestResultID: "65f1d2c3e4b5a6978890abcd",
+		ServiceRef:   "Beer Catalog API:0.9",
+		TestEndpoint: "http://beers:8080/api",
+		RunnerType:   "OPEN_API_SCHEMA",
+		details: &connectors.TestResult{
+			TestDate: 1710425399001,
+			TestCaseResults: []connectors.TestCaseResult{
+				{OperationName: "GET /beer", Success: true, ElapsedTime: 12},
+				{OperationName: "GET /beer/{name}", ElapsedTime: 31},
+			},
+		},
+	}
+	dir := t.TempDir()
+	if err := writeAllureResults(dir, result); err != nil {
+		t.Fatalf("writeAllureResults() error = %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results, children []string
+	var containers int
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var written struct {
+			UUID     string   `json:"uuid"`
+			Children []string `json:"children"`
+		}
+		if err := json.Unmarshal(content, &written); err != nil {
+			t.Fatalf("invalid %s: %v", file.Name(), err)
+		}
+		if !uuidV4.MatchString(written.UUID) {
+			t.Errorf("%s has uuid %q, want a version 4 UUID", file.Name(), written.UUID)
+		}
+		switch {
+		case file.Name() == written.UUID+"-result.json":
+			results = append(results, written.UUID)
+		case file.Name() == written.UUID+"-container.json":
+			containers++
+			children = written.Children
+		default:
+			t.Errorf("%s is not named after its uuid %s", file.Name(), written.UUID)
+		}
+	}
+	sort.Strings(results)
+	sort.Strings(children)
+	if containers != 1 || len(results) != 2 || results[0] == results[1] || strings.Join(children, ",") != strings.Join(results, ",") {
+		t.Errorf("wrote %d containers of %v and results %v, want one container of 2 distinct results", containers, children, results)
+	}
+}
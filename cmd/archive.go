@@ -0,0 +1,286 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/version"
+)
+
+// archiveFormatVersion is the version of the layout of test archives, increased on breaking changes.
+const archiveFormatVersion = 1
+
+// Paths of the entries of test archives.
+const (
+	archiveManifestPath   = "manifest.json"
+	archiveTestResultPath = "test-result.json"
+	archiveServiceDir     = "service/"
+	archiveMessagesDir    = "messages/"
+)
+
+// archiveUnsafeChars matches the characters of operation and resource names not kept in entry paths.
+var archiveUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// archiveEntry is a file of a test archive, listed in its manifest.
+type archiveEntry struct {
+	Path      string `json:"path"`
+	Operation string `json:"operation,omitempty"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	// content holds the entries built in memory, spool is the temporary file of streamed ones.
+	content []byte
+	spool   string
+}
+
+// archiveManifest describes a test archive. RetrievedAt is the only value changing between two
+// archives of the same test.
+type archiveManifest struct {
+	FormatVersion int            `json:"formatVersion"`
+	TestResultID  string         `json:"testResultId"`
+	ServiceID     string         `json:"serviceId"`
+	CLIVersion    string         `json:"cliVersion"`
+	ServerVersion string         `json:"serverVersion"`
+	RetrievedAt   string         `json:"retrievedAt"`
+	Files         []archiveEntry `json:"files"`
+}
+
+// executeArchive writes the test having identifier args[0], with the messages of its test cases and
+// the contracts of the tested service, into a gzip tarball.
+func (c *testCommand) executeArchive(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		return usageErrorf("test archive require a <testResultId> arg. Check Usage.")
+	}
+	testResultID := args[0]
+	file := c.file
+	if len(file) == 0 {
+		file = testResultID + ".tar.gz"
+	}
+
+	cf := &c.cf
+	cf.setup(stdout, stderr)
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	info, err := mc.GetServerInfo(ctx)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving server info", err)
+	}
+	testResult, err := mc.GetFullTestResult(ctx, testResultID)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving test result", err)
+	}
+	if testResult.InProgress {
+		console.Warnf("Test %s is still in progress, its archive may miss results and messages", testResultID)
+	}
+	resources, err := mc.GetServiceResources(ctx, testResult.ServiceID)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving service resources", err)
+	}
+
+	// Messages are spooled to temporary files as tar headers need their size.
+	spoolDir, err := os.MkdirTemp("", "microcks-archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(spoolDir)
+
+	content, err := json.MarshalIndent(testResult, "", "  ")
+	if err != nil {
+		return err
+	}
+	entries := []archiveEntry{newArchiveEntry(archiveTestResultPath, content)}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Name < resources[j].Name })
+	for _, resource := range resources {
+		entries = append(entries, newArchiveEntry(archiveServiceDir+archiveName(resource.Name), []byte(resource.Content)))
+	}
+	for i, testCase := range testResult.TestCaseResults {
+		path := fmt.Sprintf("%s%03d-%s.json", archiveMessagesDir, i+1, archiveName(testCase.OperationName))
+		entry, err := spoolArchiveEntry(spoolDir, path, func(w io.Writer) (int64, error) {
+			return mc.CopyTestCaseMessages(ctx, testResult, testCase.OperationName, w)
+		})
+		if err != nil {
+			return requestError("Got error when invoking Microcks client retrieving messages of "+testCase.OperationName, err)
+		}
+		entry.Operation = testCase.OperationName
+		entries = append(entries, entry)
+	}
+
+	manifest := archiveManifest{
+		FormatVersion: archiveFormatVersion,
+		TestResultID:  testResult.ID,
+		ServiceID:     testResult.ServiceID,
+		CLIVersion:    version.Version,
+		ServerVersion: info.Version,
+		RetrievedAt:   time.Now().UTC().Format(time.RFC3339),
+		Files:         entries,
+	}
+	content, err = json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	entries = append([]archiveEntry{newArchiveEntry(archiveManifestPath, content)}, entries...)
+
+	// Entries get the date of the test so that archives of a same test only differ by their manifest.
+	size, err := writeArchive(file, time.UnixMilli(testResult.TestDate), entries)
+	if err != nil {
+		return fmt.Errorf("Cannot write archive %s: %w", file, err)
+	}
+	return cf.render(&testArchiveResult{
+		File:         file,
+		TestResultID: testResult.ID,
+		Files:        len(entries),
+		Size:         size,
+		RequestID:    config.RequestID,
+	})
+}
+
+// archiveName turns name into a portable entry file name.
+func archiveName(name string) string {
+	name = archiveUnsafeChars.ReplaceAllString(name, "_")
+	if len(name) == 0 || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+func newArchiveEntry(path string, content []byte) archiveEntry {
+	sum := sha256.Sum256(content)
+	return archiveEntry{Path: path, Size: int64(len(content)), SHA256: hex.EncodeToString(sum[:]), content: content}
+}
+
+// spoolArchiveEntry builds the entry at path from the content written by write into a temporary file
+// of dir, hashing it on the fly.
+func spoolArchiveEntry(dir string, path string, write func(w io.Writer) (int64, error)) (archiveEntry, error) {
+	file, err := os.CreateTemp(dir, "entry-*")
+	if err != nil {
+		return archiveEntry{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := write(io.MultiWriter(file, hash))
+	if err != nil {
+		return archiveEntry{}, err
+	}
+	return archiveEntry{Path: path, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil)), spool: file.Name()}, file.Close()
+}
+
+// archiveLayout lists the prefixes of entry paths in the order entries are written.
+var archiveLayout = []string{archiveManifestPath, archiveTestResultPath, archiveServiceDir, archiveMessagesDir}
+
+// archiveRank returns the position in archiveLayout of the prefix of path, entries of unknown paths
+// going last.
+func archiveRank(path string) int {
+	for i, prefix := range archiveLayout {
+		if strings.HasPrefix(path, prefix) {
+			return i
+		}
+	}
+	return len(archiveLayout)
+}
+
+// writeArchive writes entries in a gzip tarball at path, replacing it only once complete, and returns
+// its size. Entries are sorted following archiveLayout then by path, owned by root with a fixed mode
+// and modTime, and the gzip header holds neither name nor time, so that the same entries always give
+// the same bytes whatever their order.
+func writeArchive(path string, modTime time.Time, entries []archiveEntry) (int64, error) {
+	entries = append([]archiveEntry(nil), entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if ri, rj := archiveRank(entries[i].Path), archiveRank(entries[j].Path); ri != rj {
+			return ri < rj
+		}
+		return entries[i].Path < entries[j].Path
+	})
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.Path,
+			Size:     entry.Size,
+			Mode:     0644,
+			ModTime:  modTime.Truncate(time.Second),
+			Format:   tar.FormatUSTAR,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return 0, err
+		}
+		if err := writeArchiveEntry(tw, entry); err != nil {
+			return 0, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(file.Name(), path)
+}
+
+// writeArchiveEntry writes the content of entry to w, streaming spooled ones.
+func writeArchiveEntry(w io.Writer, entry archiveEntry) error {
+	if len(entry.spool) == 0 {
+		_, err := w.Write(entry.content)
+		return err
+	}
+	spool, err := os.Open(entry.spool)
+	if err != nil {
+		return err
+	}
+	defer spool.Close()
+	_, err = io.Copy(w, spool)
+	return err
+}
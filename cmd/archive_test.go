@@ -0,0 +1,120 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteArchiveReproducible(t *testing.T) {
+	testDate := time.Date(2024, 3, 14, 10, 12, 3, 512000000, time.UTC)
+	// build writes an archive of the same entries, given in order, with spooled messages written at
+	// fileTime, and returns its content.
+	build := func(order []int, fileTime time.Time) []byte {
+		dir := t.TempDir()
+		entries := []archiveEntry{
+			newArchiveEntry(archiveManifestPath, []byte(`{"formatVersion": 1}`)),
+			newArchiveEntry(archiveTestResultPath, []byte(`{"id": "65f1"}`)),
+			newArchiveEntry(archiveServiceDir+"beer-catalog-api-openapi.yaml", []byte("openapi: 3.0.2\n")),
+			newArchiveEntry(archiveServiceDir+"Beer_Catalog_API-0.9.postman.json", []byte(`{"item": []}`)),
+		}
+		for i, operation := range []string{"GET /beer", "GET /beer/{name}"} {
+			path := fmt.Sprintf("%s%03d-%s.json", archiveMessagesDir, i+1, archiveName(operation))
+			entry, err := spoolArchiveEntry(dir, path, func(w io.Writer) (int64, error) {
+				n, err := io.WriteString(w, strings.Repeat(`{"request": "`+operation+`"}`, 1000))
+				return int64(n), err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(entry.spool, fileTime, fileTime); err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, entry)
+		}
+		ordered := make([]archiveEntry, len(order))
+		for i, j := range order {
+			ordered[i] = entries[j]
+		}
+		path := filepath.Join(dir, "65f1.tar.gz")
+		size, err := writeArchive(path, testDate, ordered)
+		if err != nil {
+			t.Fatalf("writeArchive() error = %v", err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(content)) != size {
+			t.Errorf("writeArchive() = %d, want size %d of archive", size, len(content))
+		}
+		return content
+	}
+
+	first := build([]int{0, 1, 2, 3, 4, 5}, time.Now())
+	second := build([]int{5, 3, 1, 4, 0, 2}, time.Now().Add(-48*time.Hour))
+	if sha256.Sum256(first) != sha256.Sum256(second) {
+		t.Fatalf("archives of the same entries differ:\n%x\n%x", first, second)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gz.Name != "" || !gz.ModTime.IsZero() {
+		t.Errorf("gzip header has name %q and time %s, want none", gz.Name, gz.ModTime)
+	}
+	tr := tar.NewReader(gz)
+	var paths []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, header.Name)
+		if header.Uid != 0 || header.Gid != 0 || header.Uname != "" || header.Gname != "" || header.Mode != 0o644 {
+			t.Errorf("%s is owned by %d:%d (%s:%s) with mode %o, want root and 644", header.Name, header.Uid, header.Gid, header.Uname, header.Gname, header.Mode)
+		}
+		if !header.ModTime.Equal(testDate.Truncate(time.Second)) {
+			t.Errorf("%s was modified at %s, want date of test", header.Name, header.ModTime)
+		}
+	}
+	want := []string{
+		archiveManifestPath,
+		archiveTestResultPath,
+		archiveServiceDir + "Beer_Catalog_API-0.9.postman.json",
+		archiveServiceDir + "beer-catalog-api-openapi.yaml",
+		archiveMessagesDir + "001-GET_beer.json",
+		archiveMessagesDir + "002-GET_beer_name_.json",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("archive entries = %v, want %v", paths, want)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ExpandArgFiles replaces the arguments beginning with @ by the arguments read from the file they
+// name, like Java @argfiles. Arguments of files are separated by whitespaces or newlines, may be
+// quoted with single or double quotes to hold whitespaces, and # starts a comment running to the end
+// of line.
+// A leading @@ stands for a literal @, and arguments following a -- terminator are kept as is.
+// Argument files cannot reference other argument files.
+func ExpandArgFiles(args []string) ([]string, error) {
+	var expanded []string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return append(expanded, args[i:]...), nil
+		case strings.HasPrefix(arg, "@@"):
+			expanded = append(expanded, arg[1:])
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			fileArgs, err := readArgFile(arg[1:])
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, fileArgs...)
+		default:
+			expanded = append(expanded, arg)
+		}
+	}
+	return expanded, nil
+}
+
+// readArgFile reads the arguments of the argument file at path.
+func readArgFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, usageErrorf("Cannot read argument file: %s", err)
+	}
+	args, err := splitArgs(string(data))
+	if err != nil {
+		return nil, usageErrorf("Invalid argument file %s: %s", path, err)
+	}
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "@@"):
+			args[i] = arg[1:]
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			return nil, usageErrorf("Invalid argument file %s: it references argument file %s, argument files cannot be nested", path, arg)
+		}
+	}
+	return args, nil
+}
+
+// splitArgs splits content in arguments. Quotes may appear anywhere in an argument (eg.
+// --operationsHeaders='{"globals": []}'); a backslash escapes the next character in double quotes.
+func splitArgs(content string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	runes := []rune(content)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case r == '#' && !inArg:
+			// Comment runs to the end of line.
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '"' || r == '\'':
+			inArg = true
+			start := i
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if r == '"' && runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				current.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated quote at offset %d", start)
+			}
+		default:
+			inArg = true
+			current.WriteRune(r)
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandArgFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "whitespaces and newlines",
+			files: map[string]string{"args": "test\t'Beer Catalog API:0.9'\n\n  http://beers   HTTP\r\n"},
+			args:  []string{"@DIR/args", "--verbose"},
+			want:  []string{"test", "Beer Catalog API:0.9", "http://beers", "HTTP", "--verbose"},
+		},
+		{
+			name:  "quotes",
+			files: map[string]string{"args": `--operationsHeaders='{"globals": [{"name": "x", "values": "a b"}]}' "--name=a \"b\" c\\d" 'it''s' ""`},
+			args:  []string{"@DIR/args"},
+			want:  []string{`--operationsHeaders={"globals": [{"name": "x", "values": "a b"}]}`, `--name=a "b" c\d`, "its", ""},
+		},
+		{
+			name:  "single quotes keep backslashes",
+			files: map[string]string{"args": `'C:\specs\beer.yaml'`},
+			args:  []string{"@DIR/args"},
+			want:  []string{`C:\specs\beer.yaml`},
+		},
+		{
+			name:  "comments",
+			files: map[string]string{"args": "# Generated by the pipeline\nimport # artifacts\nbeer.yaml:true#not a comment\n'# quoted'\n# last line"},
+			args:  []string{"@DIR/args"},
+			want:  []string{"import", "beer.yaml:true#not", "a", "comment", "# quoted"},
+		},
+		{
+			name:  "several files",
+			files: map[string]string{"a": "import", "b": "beer.yaml pastry.yaml"},
+			args:  []string{"@DIR/a", "@DIR/b", "--watch"},
+			want:  []string{"import", "beer.yaml", "pastry.yaml", "--watch"},
+		},
+		{
+			name:  "empty file",
+			files: map[string]string{"args": "  # nothing\n"},
+			args:  []string{"test", "@DIR/args"},
+			want:  []string{"test"},
+		},
+		{
+			name:  "escaped at",
+			files: map[string]string{"args": "@@team"},
+			args:  []string{"@@owner", "@DIR/args", "@"},
+			want:  []string{"@owner", "@team", "@"},
+		},
+		{
+			name: "after terminator",
+			args: []string{"import", "--", "@DIR/args", "@@x"},
+			want: []string{"import", "--", "@DIR/args", "@@x"},
+		},
+		{
+			name:    "missing file",
+			args:    []string{"@DIR/missing"},
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			files:   map[string]string{"args": `--name="Beer`},
+			args:    []string{"@DIR/args"},
+			wantErr: true,
+		},
+		{
+			name:    "nested file",
+			files:   map[string]string{"a": "import @DIR/b", "b": "beer.yaml"},
+			args:    []string{"@DIR/a"},
+			wantErr: true,
+		},
+		{
+			name:    "recursive file",
+			files:   map[string]string{"args": "import @DIR/args"},
+			args:    []string{"@DIR/args"},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range test.files {
+				content = strings.ReplaceAll(content, "DIR", dir)
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var args, want []string
+			for _, arg := range test.args {
+				args = append(args, strings.ReplaceAll(arg, "DIR", dir))
+			}
+			for _, arg := range test.want {
+				want = append(want, strings.ReplaceAll(arg, "DIR", dir))
+			}
+
+			got, err := ExpandArgFiles(args)
+			var usageErr *UsageError
+			if test.wantErr != errors.As(err, &usageErr) {
+				t.Fatalf("ExpandArgFiles() error = %v, want usage error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, want) {
+				t.Errorf("ExpandArgFiles() = %q, want %q", got, want)
+			}
+		})
+	}
+}
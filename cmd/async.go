@@ -0,0 +1,161 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// asyncRunner is the runner of tests checking events published on a broker.
+const asyncRunner = "ASYNC_API_SCHEMA"
+
+// asyncBindings gives the endpoint scheme of each AsyncAPI binding supported by Microcks.
+var asyncBindings = map[string]string{
+	"KAFKA":        "kafka",
+	"MQTT":         "mqtt",
+	"WS":           "ws",
+	"AMQP":         "amqp",
+	"NATS":         "nats",
+	"GOOGLEPUBSUB": "googlepubsub",
+	"SQS":          "sqs",
+	"SNS":          "sns",
+}
+
+// amqpDestinationTypes are the prefixes of AMQP endpoint paths telling the kind of destination.
+var amqpDestinationTypes = map[string]string{
+	"q": "queue",
+	"d": "direct exchange",
+	"f": "fanout exchange",
+	"t": "topic exchange",
+	"h": "headers exchange",
+}
+
+var (
+	kafkaTopicPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+	natsSubjectToken  = regexp.MustCompile(`^[^\s.*>]+$`)
+)
+
+// asyncSchemes lists the schemes allowed for async endpoints, for error messages.
+func asyncSchemes() string {
+	schemes := []string{"wss"}
+	for _, scheme := range asyncBindings {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return strings.Join(schemes, ", ")
+}
+
+// buildAsyncEndpoint assembles an async endpoint from a broker address, a topic and an optional
+// binding. The binding gives the scheme when broker has none, it must match it otherwise.
+func buildAsyncEndpoint(broker, topic, binding string) (string, error) {
+	if len(broker) == 0 || len(topic) == 0 {
+		return "", usageErrorf("--broker and --topic flags are both required to build the test endpoint. Check Usage.")
+	}
+	scheme, address := "", broker
+	if i := strings.Index(broker, "://"); i >= 0 {
+		scheme, address = broker[:i], broker[i+3:]
+	}
+	if len(binding) > 0 {
+		bindingScheme, ok := asyncBindings[strings.ToUpper(binding)]
+		if !ok {
+			return "", usageErrorf("Invalid value for --binding flag: '%s' is not one of KAFKA, MQTT, WS, AMQP, NATS, GOOGLEPUBSUB, SQS, SNS", binding)
+		}
+		if len(scheme) > 0 && scheme != bindingScheme && !(scheme == "wss" && bindingScheme == "ws") {
+			return "", usageErrorf("--binding %s does not match the '%s' scheme of --broker %s", binding, scheme, broker)
+		}
+		if len(scheme) == 0 {
+			scheme = bindingScheme
+		}
+	}
+	if len(scheme) == 0 {
+		return "", usageErrorf("--broker %s has no scheme, add one like kafka://%s or set --binding flag", broker, broker)
+	}
+	return scheme + "://" + strings.TrimSuffix(address, "/") + "/" + strings.TrimPrefix(topic, "/"), nil
+}
+
+// validateAsyncEndpoint checks an endpoint of ASYNC_API_SCHEMA tests has a supported scheme, a host
+// and a topic respecting the rules of its protocol, so that mistakes are reported before the test runs.
+func validateAsyncEndpoint(endpoint string) error {
+	if err := checkAsyncEndpoint(endpoint); err != nil {
+		return usageErrorf("Invalid endpoint '%s' for %s runner: %s", endpoint, asyncRunner, err)
+	}
+	return nil
+}
+
+func checkAsyncEndpoint(endpoint string) error {
+	if !strings.Contains(endpoint, "://") {
+		return fmt.Errorf("not of the form <scheme>://<host>[:<port>]/<topic> with scheme one of %s", asyncSchemes())
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("not a valid URL: %s", err)
+	}
+	scheme := u.Scheme
+	if scheme == "wss" {
+		scheme = "ws"
+	}
+	if _, ok := asyncBindings[strings.ToUpper(scheme)]; !ok {
+		return fmt.Errorf("unsupported scheme '%s', expecting one of %s", u.Scheme, asyncSchemes())
+	}
+	if len(u.Hostname()) == 0 {
+		return fmt.Errorf("missing broker host after %s://", u.Scheme)
+	}
+	if port := u.Port(); len(port) == 0 && strings.HasSuffix(u.Host, ":") {
+		return fmt.Errorf("empty port after host %s", u.Hostname())
+	}
+	if len(u.Fragment) > 0 || strings.HasSuffix(endpoint, "#") {
+		return fmt.Errorf("unexpected '#' starting a URL fragment, encode it as %%23")
+	}
+	topic := strings.TrimPrefix(u.Path, "/")
+	if len(topic) == 0 {
+		return fmt.Errorf("missing topic, expecting %s://%s/<topic>", u.Scheme, u.Host)
+	}
+
+	switch scheme {
+	case "kafka":
+		if !kafkaTopicPattern.MatchString(topic) {
+			return fmt.Errorf("Kafka topic '%s' must be 1 to 249 characters among letters, digits, '.', '_' and '-'", topic)
+		}
+	case "mqtt":
+		for i, level := range strings.Split(topic, "/") {
+			if strings.Contains(level, "+") && level != "+" {
+				return fmt.Errorf("MQTT topic '%s' has '+' wildcard not occupying a whole level", topic)
+			}
+			if strings.Contains(level, "#") && (level != "#" || i != strings.Count(topic, "/")) {
+				return fmt.Errorf("MQTT topic '%s' has '#' wildcard not being the last level", topic)
+			}
+		}
+	case "amqp":
+		parts := strings.SplitN(topic, "/", 2)
+		if _, ok := amqpDestinationTypes[parts[0]]; !ok || len(parts) < 2 || len(parts[1]) == 0 {
+			return fmt.Errorf("AMQP path '/%s' must be /<type>/<destination> with type one of q (queue), d (direct exchange), f (fanout exchange), t (topic exchange), h (headers exchange)", topic)
+		}
+	case "nats":
+		for _, token := range strings.Split(topic, ".") {
+			if !natsSubjectToken.MatchString(token) {
+				return fmt.Errorf("NATS subject '%s' must be non empty tokens separated by '.', without whitespace or wildcards", topic)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// azureDevOpsEnv is set to True by Azure Pipelines agents.
+const azureDevOpsEnv = "TF_BUILD"
+
+// runningOnAzureDevOps tells if the CLI runs in an Azure Pipelines job.
+func runningOnAzureDevOps() bool {
+	return strings.EqualFold(os.Getenv(azureDevOpsEnv), "true")
+}
+
+var (
+	// vsoDataEscaper escapes the message of Azure Pipelines logging commands.
+	vsoDataEscaper = strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A")
+	// vsoPropertyEscaper escapes the property values of Azure Pipelines logging commands.
+	vsoPropertyEscaper = strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A", ";", "%3B", "]", "%5D")
+)
+
+// vsoCommand formats an Azure Pipelines logging command with properties given as name and value pairs.
+func vsoCommand(command string, properties [][2]string, message string) string {
+	var b strings.Builder
+	b.WriteString("##vso[" + command)
+	for i, property := range properties {
+		if i == 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(property[0] + "=" + vsoPropertyEscaper.Replace(property[1]) + ";")
+	}
+	b.WriteString("]" + vsoDataEscaper.Replace(message))
+	return b.String()
+}
+
+// writeAzureDevOpsTest reports the outcome of a test using Azure Pipelines logging commands: an error
+// issue per failed operation and the failure of the task if the test did not succeed.
+func writeAzureDevOpsTest(w io.Writer, result *testResult) {
+	if result.Success {
+		return
+	}
+	writeAzureDevOpsIssues(w, result)
+	writeAzureDevOpsFailure(w, result.failure())
+}
+
+// writeAzureDevOpsIssues writes an error issue per failed operation of a test, or a single one if the
+// test could not run.
+func writeAzureDevOpsIssues(w io.Writer, result *testResult) {
+	if len(result.Error) > 0 {
+		fmt.Fprintln(w, vsoCommand("task.logissue", [][2]string{{"type", "error"}}, result.failure()))
+		return
+	}
+	if result.details == nil {
+		return
+	}
+	for _, testCase := range result.details.TestCaseResults {
+		if testCase.Success {
+			continue
+		}
+		message := fmt.Sprintf("Operation '%s' of %s failed", testCase.OperationName, result.ServiceRef)
+		for _, step := range testCase.TestStepResults {
+			if !step.Success && len(step.Message) > 0 {
+				message += ": " + step.Message
+				break
+			}
+		}
+		fmt.Fprintln(w, vsoCommand("task.logissue", [][2]string{{"type", "error"}}, message))
+	}
+}
+
+// writeAzureDevOpsFailure fails the task with message.
+func writeAzureDevOpsFailure(w io.Writer, message string) {
+	fmt.Fprintln(w, vsoCommand("task.complete", [][2]string{{"result", "Failed"}}, message))
+}
+
+// writeAzureDevOpsUploads uploads the report files written by the command with the logs of the task, so
+// that they can be downloaded from the pipeline run. Empty paths are skipped.
+func writeAzureDevOpsUploads(w io.Writer, paths ...string) {
+	for _, path := range paths {
+		if len(path) == 0 {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		fmt.Fprintln(w, vsoCommand("task.uploadfile", nil, path))
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestVsoCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		command    string
+		properties [][2]string
+		message    string
+		want       string
+	}{
+		{"no property", "task.uploadfile", nil, "/tmp/report.xml", "##vso[task.uploadfile]/tmp/report.xml"},
+		{"property", "task.logissue", [][2]string{{"type", "error"}}, "failed", "##vso[task.logissue type=error;]failed"},
+		{"message line breaks", "task.logissue", [][2]string{{"type", "error"}}, "line 1\r\nline 2", "##vso[task.logissue type=error;]line 1%0D%0Aline 2"},
+		{"message percent", "task.complete", [][2]string{{"result", "Failed"}}, "100% failed; ] kept", "##vso[task.complete result=Failed;]100%AZP25 failed; ] kept"},
+		{"property separators", "task.logissue", [][2]string{{"type", "error"}, {"sourcepath", "a;b]c\nd%"}}, "m", "##vso[task.logissue type=error;sourcepath=a%3Bb%5Dc%0Ad%AZP25;]m"},
+		{"injected command", "task.logissue", [][2]string{{"type", "error"}}, "x\n##vso[task.complete result=Succeeded]", "##vso[task.logissue type=error;]x%0A##vso[task.complete result=Succeeded]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := vsoCommand(test.command, test.properties, test.message); got != test.want {
+				t.Errorf("vsoCommand() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAzureDevOpsUploads(t *testing.T) {
+	tests := []struct {
+		name       string
+		reportFile bool
+		outputFile bool
+		disabled   bool
+		want       []string
+	}{
+		{name: "report file", reportFile: true, want: []string{"report.xml"}},
+		{name: "output file", outputFile: true, want: []string{"result.json"}},
+		{name: "both", reportFile: true, outputFile: true, want: []string{"report.xml", "result.json"}},
+		{name: "none", want: nil},
+		{name: "disabled", reportFile: true, disabled: true, want: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			t.Setenv(azureDevOpsEnv, "True")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+			srv.ScriptTest("Beer Catalog API:0.9", microckstest.TestScript{Success: true})
+
+			dir := t.TempDir()
+			args := []string{"Beer Catalog API:0.9", "http://beers", "HTTP", "--poll-strategy=fixed", "--pollInterval=100ms",
+				"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}
+			if test.reportFile {
+				args = append(args, "--reportFormat=junit", "--reportFile="+filepath.Join(dir, "report.xml"))
+			}
+			if test.outputFile {
+				args = append(args, "--output-file="+filepath.Join(dir, "result.json"))
+			}
+			if test.disabled {
+				args = append(args, "--azure-devops=false")
+			}
+			var stdout, stderr bytes.Buffer
+			if err := NewTestCommand().Execute(context.Background(), args, &stdout, &stderr); err != nil {
+				t.Fatalf("Execute() error = %v, stderr: %s", err, stderr.String())
+			}
+
+			var got []string
+			for _, line := range strings.Split(stdout.String(), "\n") {
+				if path, found := strings.CutPrefix(line, "##vso[task.uploadfile]"); found {
+					got = append(got, path)
+				}
+			}
+			var want []string
+			for _, file := range test.want {
+				want = append(want, filepath.Join(dir, file))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("uploaded files %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// capability is something a command needs from the Microcks server.
+type capability struct {
+	// name tells what needs it, in messages.
+	name string
+	// minVersion is the first Microcks version providing it, empty if all do.
+	minVersion string
+	// feature is the optional Microcks feature that must be enabled, empty if none.
+	feature string
+}
+
+// runnerCapabilities are the capabilities needed by test runners not supported by all Microcks versions.
+var runnerCapabilities = map[string]capability{
+	asyncRunner:      {name: "testing with ASYNC_API_SCHEMA runner", minVersion: "1.0.0", feature: "async-api"},
+	"GRPC_PROTOBUF":  {name: "testing with GRPC_PROTOBUF runner", minVersion: "1.3.0"},
+	"GRAPHQL_SCHEMA": {name: "testing with GRAPHQL_SCHEMA runner", minVersion: "1.5.0"},
+}
+
+// requireRunner checks that the Microcks server of mc provides the capability needed by runner, if any.
+// Server version and features are fetched on first check only. Checks are skipped with --skip-version-check.
+func (f *clientFlags) requireRunner(ctx context.Context, mc connectors.MicrocksClient, runner string) error {
+	needed, ok := runnerCapabilities[runner]
+	if !ok || f.skipVersionCheck {
+		return nil
+	}
+	info, err := mc.GetServerInfo(ctx)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving version", err)
+	}
+	return checkCapability(info, needed)
+}
+
+// checkCapability returns an UnsupportedServerError if info tells that the server lacks needed.
+// Servers not reporting their version or features are assumed to provide them.
+func checkCapability(info *connectors.ServerInfo, needed capability) error {
+	if len(needed.minVersion) > 0 {
+		if len(info.Version) == 0 {
+			console.Debugf("Microcks does not report its version, assuming it supports %s", needed.name)
+		} else {
+			supported, err := versionAtLeast(info.Version, needed.minVersion)
+			if err != nil {
+				return &UnsupportedServerError{msg: fmt.Sprintf("cannot tell if Microcks supports %s: %s, use --skip-version-check to bypass version checks", needed.name, err)}
+			}
+			if !supported {
+				return &UnsupportedServerError{msg: fmt.Sprintf("%s requires Microcks >= %s (server reports %s)", needed.name, needed.minVersion, info.Version)}
+			}
+		}
+	}
+	if _, known := info.Features[needed.feature]; known && !info.FeatureEnabled(needed.feature) {
+		return &UnsupportedServerError{msg: fmt.Sprintf("%s requires the %s feature, which is disabled on Microcks", needed.name, needed.feature)}
+	}
+	return nil
+}
+
+// versionAtLeast tells if version is greater than or equal to min. Versions are dot separated numbers,
+// possibly prefixed with v and followed by a -suffix or +build metadata that are ignored: a pre-release
+// like 1.9.0-SNAPSHOT is considered as providing the capabilities of 1.9.0. Missing numbers are zeros.
+func versionAtLeast(version string, min string) (bool, error) {
+	v, err := parseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	m, err := parseVersion(min)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < len(v) || i < len(m); i++ {
+		var vi, mi int
+		if i < len(v) {
+			vi = v[i]
+		}
+		if i < len(m) {
+			mi = m[i]
+		}
+		if vi != mi {
+			return vi > mi, nil
+		}
+	}
+	return true, nil
+}
+
+// parseVersion returns the numbers of version, see versionAtLeast.
+func parseVersion(version string) ([]int, error) {
+	core := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(core, "-+"); i >= 0 {
+		core = core[:i]
+	}
+	var numbers []int
+	for _, part := range strings.Split(core, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("unsupported version '%s'", version)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
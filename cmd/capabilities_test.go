@@ -0,0 +1,58 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import "testing"
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		min     string
+		want    bool
+		wantErr bool
+	}{
+		{version: "1.9.0", min: "1.9.0", want: true},
+		{version: "1.9.1", min: "1.9.0", want: true},
+		{version: "1.8.9", min: "1.9.0", want: false},
+		{version: "1.10.0", min: "1.9.0", want: true},
+		{version: "2.0.0", min: "1.10.0", want: true},
+		{version: "1.9.0-SNAPSHOT", min: "1.9.0", want: true},
+		{version: "1.10.0-SNAPSHOT", min: "1.9.1", want: true},
+		{version: "1.8.1-SNAPSHOT", min: "1.9.0", want: false},
+		{version: "1.9.0-rc.1+build.5", min: "1.9.0", want: true},
+		{version: "1.9.0+20240101", min: "1.9.0", want: true},
+		{version: "v1.9.0", min: "1.9", want: true},
+		{version: " 1.9 ", min: "1.9.0", want: true},
+		{version: "1.9", min: "1.9.1", want: false},
+		{version: "1", min: "1.0.0", want: true},
+		{version: "nightly", min: "1.9.0", wantErr: true},
+		{version: "1.x.0", min: "1.9.0", wantErr: true},
+		{version: "", min: "1.9.0", wantErr: true},
+		{version: "1..0", min: "1.9.0", wantErr: true},
+		{version: "-SNAPSHOT", min: "1.9.0", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.version+" >= "+test.min, func(t *testing.T) {
+			got, err := versionAtLeast(test.version, test.min)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("versionAtLeast() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("versionAtLeast(%q, %q) = %v, want %v", test.version, test.min, got, test.want)
+			}
+		})
+	}
+}
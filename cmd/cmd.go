@@ -15,7 +15,172 @@
  */
 package cmd
 
-// Command define single method interface
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Command define single method interface. Execute runs the command with args following the command
+// name, writing its output on stdout and stderr. It never exits the process but returns an error
+// that main maps to an exit code.
 type Command interface {
-	Execute()
+	Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error
+}
+
+// usagePrinter is implemented by commands able to print their own usage.
+type usagePrinter interface {
+	printUsage(w io.Writer)
+}
+
+// commandSpec describes a registered command.
+type commandSpec struct {
+	name    string
+	short   string
+	factory func() Command
+}
+
+// commands is the registry of available commands, in the order they're listed in help.
+var commands []commandSpec
+
+func init() {
+	commands = []commandSpec{
+		{"version", "check this CLI version", NewVersionCommand},
+		{"help", "display this help message", NewHelpCommand},
+		{"test", "launch new test on Microcks server", NewTestCommand},
+		{"import", "import API artifacts on Microcks server", NewImportCommand},
+		{"run", "run import and test steps described in a file", NewRunCommand},
+		{"services", "list services known by Microcks", NewServicesCommand},
+		{"export", "export services as a repository snapshot", NewExportCommand},
+		{"mock", "print the mock URLs of services", NewMockCommand},
+		{"secret", "apply secrets declared in a file", NewSecretCommand},
+		{"config", "view microcks-cli configuration", NewConfigCommand},
+		{"context", "list, select and define named contexts", NewContextCommand},
+		{"doctor", "diagnose connectivity and authentication problems", NewDoctorCommand},
+		{"completion", "generate shell completion script", NewCompletionCommand},
+	}
+}
+
+// hiddenCommands is the registry of commands used internally, not listed in help nor completed.
+var hiddenCommands = []commandSpec{
+	{completeCommandName, "print completion values retrieved from Microcks", newCompleteCommand},
+}
+
+// LookupCommand finds and builds the command registered with name.
+func LookupCommand(name string) (Command, bool) {
+	for _, spec := range append(commands, hiddenCommands...) {
+		if spec.name == name {
+			return spec.factory(), true
+		}
+	}
+	return nil, false
+}
+
+// usage describes how to invoke a command.
+type usage struct {
+	name        string
+	synopsis    string
+	description string
+	args        [][2]string
+	examples    []string
+}
+
+// newFlagSet creates a FlagSet that prints the command usage on --help or on flag errors.
+func newFlagSet(u usage) *flag.FlagSet {
+	fs := flag.NewFlagSet(u.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		u.print(fs.Output(), fs)
+	}
+	return fs
+}
+
+// print writes the full usage of command, including flags defaults if fs is provided.
+func (u usage) print(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, u.description)
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintf(w, "  microcks-cli %s\n", u.synopsis)
+	if len(u.args) > 0 {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Args:")
+		width := 0
+		for _, arg := range u.args {
+			if len(arg[0]) > width {
+				width = len(arg[0])
+			}
+		}
+		for _, arg := range u.args {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, arg[0], arg[1])
+		}
+	}
+	if fs != nil {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Flags:")
+		fs.PrintDefaults()
+	}
+	if len(u.examples) > 0 {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Examples:")
+		for _, example := range u.examples {
+			fmt.Fprintf(w, "  %s\n", strings.Replace(example, "\n", "\n  ", -1))
+		}
+	}
+	fmt.Fprintln(w, "")
+}
+
+// parseArgs parses flags from args, allowing them to be interleaved with positional arguments
+// in any order. Flags not passed are then looked up in environment variables and configuration file.
+// It returns the positional arguments in the order they appear. Flag errors are printed with usage on stderr.
+func parseArgs(fs *flag.FlagSet, args []string, stderr io.Writer) ([]string, error) {
+	fs.SetOutput(stderr)
+	var positionals []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, err
+			}
+			return nil, &ReportedError{Err: &UsageError{msg: err.Error()}}
+		}
+		remaining := fs.Args()
+		if len(remaining) == 0 {
+			break
+		}
+		// Everything after a "--" terminator is positional.
+		consumed := len(args) - len(remaining)
+		if consumed > 0 && args[consumed-1] == "--" {
+			positionals = append(positionals, remaining...)
+			break
+		}
+		positionals = append(positionals, remaining[0])
+		args = remaining[1:]
+	}
+	// Complete with environment variables and configuration file for flags not passed.
+	if err := resolveSettings(fs); err != nil {
+		return nil, err
+	}
+	return positionals, nil
+}
+
+// checkArgs ensures positionals match the arguments expected by command.
+func (u usage) checkArgs(positionals []string) error {
+	if len(positionals) < len(u.args) {
+		return usageErrorf("%s command require %s arg. Check Usage.", u.name, u.args[len(positionals)][0])
+	}
+	if len(positionals) > len(u.args) {
+		return usageErrorf("%s command got unexpected arg '%s'. Check Usage.", u.name, positionals[len(u.args)])
+	}
+	return nil
+}
+
+// wantsHelp tells if one of args is a help flag.
+func wantsHelp(args []string) bool {
+	for _, arg := range args {
+		if arg == "-h" || arg == "-help" || arg == "--help" {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,71 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		want        []string
+		wantVerbose bool
+		wantName    string
+		wantErr     bool
+	}{
+		{name: "flags before positionals", args: []string{"--verbose", "--name=x", "a", "b"}, want: []string{"a", "b"}, wantVerbose: true, wantName: "x"},
+		{name: "flags after positionals", args: []string{"a", "b", "--verbose", "--name", "x"}, want: []string{"a", "b"}, wantVerbose: true, wantName: "x"},
+		{name: "interleaved", args: []string{"a", "-name=x", "b", "-verbose"}, want: []string{"a", "b"}, wantVerbose: true, wantName: "x"},
+		{name: "terminator", args: []string{"a", "--", "--verbose", "b"}, want: []string{"a", "--verbose", "b"}},
+		{name: "terminator first", args: []string{"--", "-a"}, want: []string{"-a"}},
+		{name: "flags before terminator", args: []string{"--name=x", "--", "--name=y"}, want: []string{"--name=y"}, wantName: "x"},
+		{name: "stdin", args: []string{"-", "--verbose"}, want: []string{"-"}, wantVerbose: true},
+		{name: "no positionals", args: []string{"--verbose"}, wantVerbose: true},
+		{name: "unknown flag after positionals", args: []string{"a", "--unknown"}, wantErr: true},
+		{name: "missing flag value", args: []string{"a", "--name"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			verbose := fs.Bool("verbose", false, "")
+			name := fs.String("name", "", "")
+
+			got, err := parseArgs(fs, test.args, io.Discard)
+			if test.wantErr {
+				var usageErr *UsageError
+				if !errors.As(err, &usageErr) {
+					t.Fatalf("parseArgs() error = %v, want UsageError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseArgs() = %q, want %q", got, test.want)
+			}
+			if *verbose != test.wantVerbose || *name != test.wantName {
+				t.Errorf("flags verbose = %v, name = %q, want %v, %q", *verbose, *name, test.wantVerbose, test.wantName)
+			}
+		})
+	}
+}
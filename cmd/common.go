@@ -0,0 +1,517 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/connectors/keycloak"
+	"github.com/microcks/microcks-cli/pkg/output"
+	"github.com/microcks/microcks-cli/pkg/transport"
+	"github.com/microcks/microcks-cli/version"
+)
+
+// clientFlags gathers the flags shared by commands talking to Microcks server.
+type clientFlags struct {
+	fs     *flag.FlagSet
+	stdout io.Writer
+	stderr io.Writer
+	// silent is set by CI modes writing their own output: only errors and structured results are kept.
+	silent bool
+	// skipAuth is set by commands not authenticating, like dry runs: only the Microcks URL is mandatory.
+	skipAuth bool
+
+	configPath           string
+	contextName          string
+	output               output.Format
+	errors               string
+	quiet                bool
+	noInput              bool
+	noColor              bool
+	logLevel             slog.Level
+	logFormat            string
+	microcksURL          string
+	keycloakClientID     string
+	keycloakClientSecret string
+	clientAssertionKey   string
+	clientAssertionKid   string
+	insecureTLS          bool
+	caCertPaths          string
+	tlsMinVersion        uint16
+	tlsCipherSuites      []uint16
+	verbose              bool
+	requestID            string
+	requestIDHeader      string
+	rateLimit            float64
+	rateBurst            int
+	maxResponseSize      int64
+	compressUploads      bool
+	timing               bool
+	timeout              time.Duration
+	noCache              bool
+	tektonResultsDir     string
+	outputsFile          string
+	outputFile           string
+	skipVersionCheck     bool
+	record               string
+	replay               string
+	// cache stores the GET responses of clients, defaults to httpCache.
+	cache connectors.CacheStore
+}
+
+// httpCache is the in-memory cache of GET responses shared by all clients of the process.
+var httpCache = transport.NewMemoryCache()
+
+var (
+	cassettesMutex sync.Mutex
+	// cassettes holds the cassettes in use by path, shared by all clients of the process.
+	cassettes = map[string]*transport.Cassette{}
+)
+
+var (
+	rateLimitersMutex sync.Mutex
+	// rateLimiters holds the rate limiters in use by rate and burst, shared by all clients of the process
+	// so that they also pace parallel steps.
+	rateLimiters = map[[2]float64]connectors.RateLimiter{}
+)
+
+// rateLimiter returns the rate limiter allowing limit requests per second with bursts of burst requests.
+func rateLimiter(limit float64, burst int) connectors.RateLimiter {
+	rateLimitersMutex.Lock()
+	defer rateLimitersMutex.Unlock()
+	key := [2]float64{limit, float64(burst)}
+	limiter, ok := rateLimiters[key]
+	if !ok {
+		limiter = transport.NewTokenBucket(limit, burst, console.connectors)
+		rateLimiters[key] = limiter
+	}
+	return limiter
+}
+
+// register declares the shared flags on a command FlagSet.
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	f.fs = fs
+	fs.StringVar(&f.configPath, "config", "", "Path of configuration file (default to ./.microcks.yaml or ~/.microcks/config.yaml)")
+	f.output = output.Text
+	fs.Func("output", "Output format of command result (one of: text, wide, json, yaml, env, exec:<plugin>)", func(value string) (err error) {
+		f.output, err = output.ParseFormat(value)
+		return err
+	})
+	f.errors = "text"
+	fs.Func("errors", "Format of errors written on stderr (one of: text, json). Implied json with --output=json (default text)", func(value string) error {
+		if value != "text" && value != "json" {
+			return fmt.Errorf("unsupported errors format '%s', valid ones are: text, json", value)
+		}
+		f.errors = value
+		return nil
+	})
+	fs.BoolVar(&f.quiet, "quiet", false, "Suppress non-essential output, keeping only errors and command result")
+	fs.BoolVar(&f.noInput, "no-input", false, "Never prompt for missing mandatory values, even when run in a terminal")
+	fs.BoolVar(&f.noColor, "no-color", false, "Disable colored output (also disabled by NO_COLOR env or when not writing to a terminal)")
+	fs.StringVar(&f.contextName, "context", "", "Name of the configuration file context to use (default to current context)")
+	fs.StringVar(&f.microcksURL, "microcksURL", "", "Microcks API URL (comma separated list for failover)")
+	fs.StringVar(&f.keycloakClientID, "keycloakClientId", "", "Keycloak Realm Service Account ClientId")
+	fs.StringVar(&f.keycloakClientSecret, "keycloakClientSecret", "", "Keycloak Realm Service Account ClientSecret")
+	fs.StringVar(&f.clientAssertionKey, "clientAssertionKey", "", "Path of the PEM private key signing JWT assertions sent to Keycloak instead of ClientSecret")
+	fs.StringVar(&f.clientAssertionKid, "clientAssertionKid", "", "Key ID of --clientAssertionKey, sent as kid header of assertions")
+	fs.BoolVar(&f.insecureTLS, "insecure", false, "Whether to accept insecure HTTPS connection")
+	fs.StringVar(&f.caCertPaths, "caCerts", "", "Comma separated paths of CRT files to add to Root CAs")
+	fs.BoolVar(&f.verbose, "verbose", false, "Produce dumps of HTTP exchanges (alias of --log-level=trace)")
+	f.logLevel = slog.LevelInfo
+	fs.Func("log-level", "Log level (one of: error, warn, info, debug, trace) (default info)", func(value string) (err error) {
+		f.logLevel, err = config.ParseLogLevel(value)
+		return err
+	})
+	f.logFormat = "text"
+	fs.Func("log-format", "Log format (one of: text, json). JSON logs are written on stderr (default text)", func(value string) error {
+		if value != "text" && value != "json" {
+			return fmt.Errorf("unsupported log format '%s', valid ones are: text, json", value)
+		}
+		f.logFormat = value
+		return nil
+	})
+	fs.StringVar(&f.requestID, "requestId", "", "Run ID sent with every API call (default to REQUEST_ID env or a generated UUID)")
+	fs.StringVar(&f.requestIDHeader, "requestIdHeader", config.DefaultRequestIDHeader, "Name of the header carrying the run ID")
+	fs.Float64Var(&f.rateLimit, "rate-limit", 0, "Maximum number of API requests per second (0 means unlimited)")
+	fs.IntVar(&f.rateBurst, "rate-burst", 1, "Number of API requests allowed in a burst above --rate-limit")
+	fs.Func("tls-min-version", "Minimum TLS version to negotiate (one of: 1.2, 1.3)", func(value string) (err error) {
+		f.tlsMinVersion, err = config.ParseTLSVersion(value)
+		return err
+	})
+	fs.Func("tls-ciphers", "Comma separated IANA names of cipher suites to enable with TLS 1.2", func(value string) (err error) {
+		f.tlsCipherSuites, err = config.ParseCipherSuites(value)
+		return err
+	})
+	fs.BoolVar(&f.compressUploads, "compress-uploads", false, "Whether to gzip encode artifact uploads (falls back to raw upload if unsupported)")
+	fs.Int64Var(&f.maxResponseSize, "max-response-size", config.DefaultMaxResponseBytes, "Maximum size in bytes of API responses read in memory (0 means unbounded)")
+	fs.DurationVar(&f.timeout, "timeout", 0, "Maximum duration of the whole command, interrupting pending API requests (eg. 5m, 0 means no limit)")
+	fs.BoolVar(&f.timing, "timing", false, "Record the phases of every API request and print a summary of them on stderr")
+	fs.BoolVar(&f.noCache, "no-cache", false, "Always download API responses, without reusing nor revalidating cached ones")
+	fs.StringVar(&f.tektonResultsDir, "tekton-results-dir", "", "Directory where to write command results as Tekton results (default to /tekton/results when running in Tekton)")
+	fs.StringVar(&f.outputsFile, "outputs-file", "", "File where to append command results as name=value outputs (default to $GITHUB_OUTPUT when running in GitHub Actions)")
+	fs.StringVar(&f.outputFile, "output-file", "", "File where to write command result as JSON, whatever the --output format, for later pipeline stages")
+	fs.BoolVar(&f.skipVersionCheck, "skip-version-check", false, "Do not check that Microcks version and features support the command (eg. for pre-release servers)")
+	fs.StringVar(&f.record, "record", "", "Cassette file where to record API requests and responses, with secrets redacted")
+	fs.StringVar(&f.replay, "replay", "", "Cassette file whose recorded responses answer API requests, without any network")
+	registerAliases(fs, clientFlagAliases)
+}
+
+// setup binds the output streams of command and configures console from output and logging flags.
+func (f *clientFlags) setup(stdout, stderr io.Writer) {
+	f.stdout, f.stderr = stdout, stderr
+
+	// Keep stdout for the result only when a structured output is required.
+	level := f.logLevel
+	if f.verbose {
+		level = config.LevelTrace
+	}
+	configureConsole(stdout, stderr, f.output.IsStructured(), f.quiet || f.silent, level, f.logFormat == "json")
+	if f.noColor {
+		output.NoColor = true
+	}
+	jsonErrors = f.errors == "json" || f.output == output.JSON
+	errorsOutput = stderr
+	output.PluginEnv = pluginEnv(f.fs.Name())
+}
+
+// decorationsOutput returns where CI decorations, like logging commands of CI servers, are written:
+// stdout unless it is kept for a structured result. Unlike progress messages, they're never quiet.
+func (f *clientFlags) decorationsOutput() io.Writer {
+	if f.output.IsStructured() {
+		return f.stderr
+	}
+	return f.stdout
+}
+
+// pluginEnv returns the environment variables describing the running command to formatter plugins.
+func pluginEnv(command string) []string {
+	return []string{"MICROCKS_COMMAND=" + command, "MICROCKS_CLI_VERSION=" + version.Version}
+}
+
+// mandatorySettings lists the flags that must have a value, with the label used when prompting for them.
+var mandatorySettings = [][2]string{
+	{"microcksURL", "Microcks API URL"},
+	{"keycloakClientId", "Keycloak Service Account ClientId"},
+	{"keycloakClientSecret", "Keycloak Service Account ClientSecret"},
+}
+
+// validate checks presence of mandatory flags. When run in a terminal, missing values are prompted for.
+func (f *clientFlags) validate() error {
+	var p prompter
+	for _, setting := range mandatorySettings {
+		flg := f.fs.Lookup(setting[0])
+		if len(flg.Value.String()) > 0 {
+			continue
+		}
+		if setting[0] == "keycloakClientSecret" && len(f.clientAssertionKey) > 0 {
+			// Service account authenticates with signed assertions instead.
+			continue
+		}
+		if setting[0] != "microcksURL" && (f.skipAuth || len(f.replay) > 0) {
+			// Replayed tokens do not depend on credentials either.
+			continue
+		}
+		if p == nil {
+			if p = newPrompter(f.stderr, f.noInput); p == nil {
+				return usageErrorf("--%s flag is mandatory. Check Usage.", setting[0])
+			}
+		}
+		value, err := f.promptSetting(p, setting[0], setting[1])
+		if err != nil {
+			return err
+		}
+		if err := f.fs.Set(setting[0], value); err != nil {
+			return usageErrorf("Invalid value for --%s flag: %s", setting[0], err)
+		}
+		settingSources[setting[0]] = "prompt"
+	}
+	return nil
+}
+
+// promptAttempts is the number of answers a mandatory value is asked for before giving up on invalid ones.
+const promptAttempts = 3
+
+// promptChecks check the answers prompted for mandatory values, invalid ones being asked again.
+var promptChecks = map[string]func(string) error{
+	"microcksURL": checkMicrocksURLs,
+}
+
+// promptSetting asks p for the value of the flag name until a valid one is given, failing when none is.
+func (f *clientFlags) promptSetting(p prompter, name string, label string) (string, error) {
+	for attempt := 1; ; attempt++ {
+		value, err := p.Prompt(label, configFileValue(name), secretFlags[name])
+		if err != nil {
+			return "", fmt.Errorf("Cannot read %s: %w", label, err)
+		}
+		if len(value) == 0 {
+			return "", usageErrorf("--%s flag is mandatory. Check Usage.", name)
+		}
+		check, ok := promptChecks[name]
+		if !ok {
+			return value, nil
+		}
+		if err = check(value); err == nil {
+			return value, nil
+		}
+		if attempt == promptAttempts {
+			return "", usageErrorf("Invalid value for --%s flag: %s", name, err)
+		}
+		fmt.Fprintf(f.stderr, "Invalid %s: %s\n", label, err)
+	}
+}
+
+// checkMicrocksURLs checks that value is a comma separated list of absolute http or https URLs.
+func checkMicrocksURLs(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		u, err := url.Parse(part)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return fmt.Errorf("'%s' is not an absolute http or https URL", part)
+		}
+	}
+	return nil
+}
+
+// apply propagates the flags shared by all the clients of the run: timing and run ID.
+func (f *clientFlags) apply() {
+	if f.timing {
+		timingEnabled = true
+	}
+	if len(f.tektonResultsDir) == 0 {
+		f.tektonResultsDir = defaultTektonResultsDir()
+	}
+	if len(f.outputsFile) == 0 {
+		f.outputsFile = os.Getenv(githubOutputEnv)
+	}
+
+	// Resolve run ID: flag first, then environment, then generate one.
+	config.RequestID = f.requestID
+	if len(config.RequestID) == 0 {
+		config.RequestID = os.Getenv("REQUEST_ID")
+	}
+	if len(config.RequestID) == 0 {
+		config.RequestID = config.NewRequestID()
+	}
+	if len(f.requestIDHeader) > 0 {
+		config.RequestIDHeader = f.requestIDHeader
+	}
+	console.Printf("Microcks CLI run ID: %s\n", config.RequestID)
+
+	for _, name := range connectionSettings {
+		if flg := f.fs.Lookup(name); flg != nil {
+			console.Debugf("Connection setting %s=%s (from %s)", name, displayValue(flg), settingSources[name])
+		}
+	}
+}
+
+// tlsOptions returns the TLS options given by flags.
+func (f *clientFlags) tlsOptions() config.TLSOptions {
+	return config.TLSOptions{Insecure: f.insecureTLS, CaCertPaths: f.caCertPaths, MinVersion: f.tlsMinVersion, CipherSuites: f.tlsCipherSuites}
+}
+
+// connectorsConfig builds the configuration of Microcks and Keycloak clients from flags.
+func (f *clientFlags) connectorsConfig() connectors.Config {
+	cfg := connectors.Config{
+		Verbose:          f.verbose,
+		Logger:           console.connectors,
+		RequestID:        config.RequestID,
+		RequestIDHeader:  config.RequestIDHeader,
+		MaxResponseBytes: f.maxResponseSize,
+		CompressUploads:  f.compressUploads,
+	}
+	if tlsOptions := f.tlsOptions(); tlsOptions.IsCustom() {
+		cfg.TLSConfig = tlsOptions.TLSConfig()
+	}
+	if f.rateLimit > 0 {
+		cfg.RateLimiter = rateLimiter(f.rateLimit, f.rateBurst)
+	}
+	if f.timing {
+		cfg.OnTiming = recordTiming
+	}
+	if !f.noCache {
+		cfg.Cache = f.cache
+		if cfg.Cache == nil {
+			cfg.Cache = httpCache
+		}
+		cfg.CacheIdentity = f.cacheIdentity(f.microcksURL, "")
+	}
+	return cfg
+}
+
+// cacheIdentity identifies the cached responses of clients of the Microcks at microcksURL authenticated
+// on the Keycloak realm at realmURL. Tokens are renewed by each run, entries belong to the service account
+// of the realm instead, never shared with the ones of another server or realm having the same client id.
+func (f *clientFlags) cacheIdentity(microcksURL string, realmURL string) string {
+	return "microcksURL=" + microcksURL + ";realm=" + realmURL + ";keycloakClientId=" + f.keycloakClientID
+}
+
+// withTimeout returns ctx bounded by the --timeout duration, if any. Its cause tells the deadline is
+// the one of --timeout.
+func (f *clientFlags) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.timeout > 0 {
+		return context.WithTimeoutCause(ctx, f.timeout, fmt.Errorf("--timeout of %s elapsed: %w", f.timeout, context.DeadlineExceeded))
+	}
+	return context.WithCancel(ctx)
+}
+
+// connectionSettings lists the flags reported in debug logs with their source.
+var connectionSettings = []string{"microcksURL", "keycloakClientId", "keycloakClientSecret", "clientAssertionKey", "insecure", "caCerts"}
+
+// clientAssertionPassphraseEnv is the environment variable holding the passphrase of an encrypted --clientAssertionKey.
+const clientAssertionPassphraseEnv = "MICROCKS_CLIENT_ASSERTION_PASSPHRASE"
+
+// keycloakOptions returns the options of Keycloak token providers: the configuration of requests
+// and, with --clientAssertionKey, the signed assertions authenticating the service account.
+func (f *clientFlags) keycloakOptions(cfg connectors.Config) ([]keycloak.Option, error) {
+	opts := []keycloak.Option{keycloak.WithClientConfig(cfg)}
+	if len(f.clientAssertionKey) == 0 {
+		return opts, nil
+	}
+	key, err := config.LoadPrivateKey(f.clientAssertionKey, []byte(os.Getenv(clientAssertionPassphraseEnv)))
+	if err != nil {
+		if errors.Is(err, config.ErrPassphrase) {
+			return nil, fmt.Errorf("Cannot read --clientAssertionKey: %w, set it with %s env variable", err, clientAssertionPassphraseEnv)
+		}
+		return nil, fmt.Errorf("Cannot read --clientAssertionKey: %w", err)
+	}
+	assertion := keycloak.ClientAssertion{Key: key, KeyID: f.clientAssertionKid}
+	return append(opts, keycloak.WithClientAssertion(assertion)), nil
+}
+
+// render writes the command result on stdout using the required output format.
+func (f *clientFlags) render(result interface{}) error {
+	if timed, ok := result.(timedResult); ok && f.timing {
+		timed.setTimings(newTimingReport())
+	}
+	if !f.silent || f.output.IsStructured() {
+		if err := output.Render(f.stdout, f.output, result); err != nil {
+			return fmt.Errorf("Cannot render result: %w", err)
+		}
+	}
+	if len(f.outputFile) > 0 {
+		if err := writeResultFile(f.outputFile, result); err != nil {
+			return err
+		}
+	}
+	if outputs, ok := result.(outputsResult); ok && len(f.outputsFile) > 0 {
+		if err := writeOutputs(f.outputsFile, outputs); err != nil {
+			return err
+		}
+	}
+	if tekton, ok := result.(tektonResult); ok && len(f.tektonResultsDir) > 0 {
+		return writeTektonResults(f.tektonResultsDir, tekton)
+	}
+	return nil
+}
+
+// cassette returns the cassette of --record or --replay, if any, opening it on first use.
+func (f *clientFlags) cassette() (*transport.Cassette, error) {
+	if len(f.record) > 0 && len(f.replay) > 0 {
+		return nil, usageErrorf("--record and --replay flags are mutually exclusive. Check Usage.")
+	}
+	path := f.record + f.replay
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	cassettesMutex.Lock()
+	defer cassettesMutex.Unlock()
+	if cassette, ok := cassettes[path]; ok {
+		return cassette, nil
+	}
+	cassette := transport.NewCassette(path)
+	if len(f.replay) > 0 {
+		var err error
+		if cassette, err = transport.LoadCassette(path); err != nil {
+			return nil, fmt.Errorf("Cannot read --replay cassette: %w", err)
+		}
+		console.Debugf("Replaying API interactions from %s", path)
+	} else {
+		console.Debugf("Recording API interactions to %s", path)
+	}
+	cassettes[path] = cassette
+	return cassette, nil
+}
+
+// connect builds a MicrocksClient on the first reachable Microcks URL and authenticates it.
+// Failover to next URL only happens on connection-level errors, never on application responses.
+func (f *clientFlags) connect(ctx context.Context) (connectors.MicrocksClient, error) {
+	cfg := f.connectorsConfig()
+	cassette, err := f.cassette()
+	if err != nil {
+		return nil, err
+	}
+	if cassette != nil {
+		// Every interaction must go through the cassette.
+		cfg.Cassette, cfg.Cache = cassette, nil
+	}
+	microcksURLs := strings.Split(f.microcksURL, ",")
+	for i, microcksURL := range microcksURLs {
+		microcksURL = strings.TrimSpace(microcksURL)
+		kc, err := keycloak.DiscoverConfig(ctx, microcksURL, keycloak.WithClientConfig(cfg))
+		if err != nil {
+			if connectors.IsConnectionError(err) && i < len(microcksURLs)-1 {
+				console.Printf("Microcks at %s is unreachable (%s), trying next one\n", microcksURL, err)
+				continue
+			}
+			return nil, requestError("Got error when invoking Microcks client retrieving config", err)
+		}
+		if len(microcksURLs) > 1 {
+			console.Printf("Using Microcks at %s\n", microcksURL)
+		}
+		// Stick with this instance for the rest of the run.
+		f.microcksURL = microcksURL
+
+		var provider connectors.AuthProvider = connectors.StaticToken("unauthentifed-token")
+		if cfg.Cache != nil {
+			var realmURL string
+			if kc.Enabled {
+				realmURL = kc.RealmURL()
+			}
+			cfg.CacheIdentity = f.cacheIdentity(microcksURL, realmURL)
+		}
+		if !kc.Enabled {
+			console.Debugf("Keycloak is disabled on Microcks, using unauthenticated mode")
+		} else {
+			console.Debugf("Getting token from Keycloak realm at %s", kc.RealmURL())
+			opts, err := f.keycloakOptions(cfg)
+			if err != nil {
+				return nil, err
+			}
+			provider = keycloak.NewClientCredentials(kc.RealmURL(), f.keycloakClientID, f.keycloakClientSecret, opts...)
+			// Get a first token now so that bad credentials are reported before anything else. It is
+			// refreshed by the provider when expiring during long runs.
+			if _, err := provider.Token(ctx); err != nil {
+				return nil, requestError("Got error when invoking Keycloak client", err)
+			}
+		}
+		mc := connectors.NewMicrocksClient(microcksURL, connectors.WithConfig(cfg), connectors.WithAuthProvider(provider))
+		return mc, nil
+	}
+	return nil, usageErrorf("--microcksURL flag is mandatory. Check Usage.")
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/transport"
+)
+
+func TestClientFlagsCache(t *testing.T) {
+	type call struct {
+		clientID string
+		// status is the one of the response sent by the server, 0 if no request was sent.
+		status int
+	}
+	tests := []struct {
+		name         string
+		flags        []string
+		cacheControl string
+		calls        []call
+	}{
+		{"disabled", []string{"--no-cache"}, "max-age=60", []call{{"a", http.StatusOK}, {"a", http.StatusOK}}},
+		{"miss then revalidated", nil, "", []call{{"a", http.StatusOK}, {"a", http.StatusNotModified}, {"a", http.StatusNotModified}}},
+		{"miss then fresh", nil, "max-age=60", []call{{"a", http.StatusOK}, {"a", 0}}},
+		{"isolated per client", nil, "", []call{{"a", http.StatusOK}, {"b", http.StatusOK}, {"a", http.StatusNotModified}, {"b", http.StatusNotModified}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var statuses []int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := http.StatusOK
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					status = http.StatusNotModified
+				}
+				mu.Lock()
+				statuses = append(statuses, status)
+				mu.Unlock()
+				w.Header().Set("ETag", `"v1"`)
+				if len(test.cacheControl) > 0 {
+					w.Header().Set("Cache-Control", test.cacheControl)
+				}
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte(`{"id": "service-1", "name": "Beer Catalog API", "version": "0.9"}`))
+				}
+			}))
+			defer srv.Close()
+
+			// Shared by the clients of the run, like httpCache.
+			store := transport.NewMemoryCache()
+			served := func() []int {
+				mu.Lock()
+				defer mu.Unlock()
+				return append([]int(nil), statuses...)
+			}
+			for i, call := range test.calls {
+				sent := len(served())
+				var cf clientFlags
+				cf.register(flag.NewFlagSet("test", flag.ContinueOnError))
+				if err := cf.fs.Parse(append([]string{"--keycloakClientId=" + call.clientID}, test.flags...)); err != nil {
+					t.Fatal(err)
+				}
+				cf.cache = store
+				mc := connectors.NewMicrocksClient(srv.URL, connectors.WithConfig(cf.connectorsConfig()))
+				service, err := mc.GetService(context.Background(), "service-1")
+				if err != nil {
+					t.Fatalf("call #%d: GetService() error = %v", i+1, err)
+				}
+				if service.Name != "Beer Catalog API" {
+					t.Errorf("call #%d: got service %q", i+1, service.Name)
+				}
+				status := 0
+				if statuses := served(); len(statuses) > sent {
+					status = statuses[sent]
+				}
+				if status != call.status {
+					t.Errorf("call #%d by %s: server answered %d, want %d", i+1, call.clientID, status, call.status)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheIdentity(t *testing.T) {
+	identity := func(clientID string, microcksURL string, realmURL string) string {
+		var cf clientFlags
+		cf.keycloakClientID = clientID
+		return cf.cacheIdentity(microcksURL, realmURL)
+	}
+	reference := identity("a", "http://microcks/api/", "http://keycloak/realms/microcks")
+	tests := []struct {
+		name     string
+		identity string
+		wantSame bool
+	}{
+		{"same client", identity("a", "http://microcks/api/", "http://keycloak/realms/microcks"), true},
+		{"other client", identity("b", "http://microcks/api/", "http://keycloak/realms/microcks"), false},
+		{"other Microcks", identity("a", "http://staging/api/", "http://keycloak/realms/microcks"), false},
+		{"other realm", identity("a", "http://microcks/api/", "http://keycloak/realms/staging"), false},
+		{"other Keycloak", identity("a", "http://microcks/api/", "http://sso/realms/microcks"), false},
+		{"unauthenticated", identity("a", "http://microcks/api/", ""), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if same := test.identity == reference; same != test.wantSame {
+				t.Errorf("identity %q same as %q = %v, want %v", test.identity, reference, same, test.wantSame)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// compareLatest is the value of --compare-with selecting the last completed test of the same service
+// and endpoint.
+const compareLatest = "latest"
+
+// errBaselineFound stops walking the tests of a service once the baseline is found.
+var errBaselineFound = errors.New("baseline found")
+
+// testComparison compares the failed operations of a test with the ones of a previous test, its baseline.
+type testComparison struct {
+	BaselineID string `json:"baselineTestResultId" yaml:"baselineTestResultId"`
+	// NewlyFailing are the failed operations that passed, or were not tested, in the baseline.
+	NewlyFailing []string `json:"newlyFailing" yaml:"newlyFailing"`
+	// AlreadyFailing are the failed operations that failed in the baseline too.
+	AlreadyFailing []string `json:"alreadyFailing" yaml:"alreadyFailing"`
+	// Fixed are the operations that failed in the baseline and passed.
+	Fixed []string `json:"fixed" yaml:"fixed"`
+}
+
+// resolveBaseline returns the test designated by --compare-with for a test of serviceRef on testEndpoint:
+// the test having this identifier, or the last completed test of the service on the same endpoint other
+// than excludedID with latest. It returns nil, with a warning, if there is no such test yet.
+func (c *testCommand) resolveBaseline(ctx context.Context, mc connectors.MicrocksClient, serviceRef string, testEndpoint string, excludedID string) (*connectors.TestResult, error) {
+	if c.compareWith != compareLatest {
+		baseline, err := mc.GetFullTestResult(ctx, c.compareWith)
+		if err != nil {
+			return nil, requestError("Got error when invoking Microcks client retrieving test to compare with", err)
+		}
+		if baseline.InProgress {
+			return nil, usageErrorf("Test %s to compare with is still in progress", c.compareWith)
+		}
+		return baseline, nil
+	}
+
+	i := strings.LastIndex(serviceRef, ":")
+	if i < 0 {
+		return nil, usageErrorf("Service reference '%s' should be <apiName:apiVersion>", serviceRef)
+	}
+	service, err := mc.GetServiceByRef(ctx, serviceRef[:i], serviceRef[i+1:])
+	if err != nil {
+		return nil, serviceError("Got error when invoking Microcks client getting Service", serviceRef, err)
+	}
+	listTests := func(ctx context.Context, opts connectors.ListOptions) (*connectors.Page[connectors.TestResult], error) {
+		return mc.ListTestResults(ctx, service.ID, opts)
+	}
+	var baseline *connectors.TestResult
+	// Tests come the most recent first, so the first completed one on the endpoint is the latest.
+	err = connectors.ForEach(ctx, listTests, 20, func(test connectors.TestResult) error {
+		if test.InProgress || test.ID == excludedID || test.TestedEndpoint != testEndpoint {
+			return nil
+		}
+		baseline = &test
+		return errBaselineFound
+	})
+	if err != nil && !errors.Is(err, errBaselineFound) {
+		return nil, serviceError("Got error when invoking Microcks client listing Tests", serviceRef, err)
+	}
+	if baseline == nil {
+		console.Warnf("No previous test of %s on %s to compare with, all failed operations are regressions", serviceRef, testEndpoint)
+	}
+	return baseline, nil
+}
+
+// applyComparison compares the operations of a completed test with the ones of baseline, making it succeed
+// if none of its failed operations is newly failing. Tests whose operations are unknown keep the verdict of
+// Microcks.
+func (r *testResult) applyComparison(baseline *connectors.TestResult) {
+	if r.InProgress || r.details == nil || len(r.details.TestCaseResults) == 0 {
+		return
+	}
+	failedBefore := map[string]bool{}
+	for _, testCase := range baseline.TestCaseResults {
+		if !testCase.Success {
+			failedBefore[testCase.OperationName] = true
+		}
+	}
+	comparison := &testComparison{BaselineID: baseline.ID, NewlyFailing: []string{}, AlreadyFailing: []string{}, Fixed: []string{}}
+	for _, testCase := range r.details.TestCaseResults {
+		switch {
+		case !testCase.Success && failedBefore[testCase.OperationName]:
+			comparison.AlreadyFailing = append(comparison.AlreadyFailing, testCase.OperationName)
+		case !testCase.Success:
+			comparison.NewlyFailing = append(comparison.NewlyFailing, testCase.OperationName)
+		case failedBefore[testCase.OperationName]:
+			comparison.Fixed = append(comparison.Fixed, testCase.OperationName)
+		}
+	}
+	r.Comparison = comparison
+	r.Success = len(comparison.NewlyFailing) == 0
+}
+
+// renderComparison writes the comparison of a test with its baseline, listing the regressions.
+func (r *testResult) renderComparison(w io.Writer) {
+	if r.Comparison == nil {
+		return
+	}
+	styles := output.Styles(w)
+	fmt.Fprintf(w, "Compared to test %s: %d newly failing, %d already failing and %d fixed operations\n",
+		r.Comparison.BaselineID, len(r.Comparison.NewlyFailing), len(r.Comparison.AlreadyFailing), len(r.Comparison.Fixed))
+	for _, name := range r.Comparison.NewlyFailing {
+		fmt.Fprintf(w, "  %s %s\n", styles.Verdict(false, "NEW"), styles.Bold(name))
+	}
+	for _, name := range r.Comparison.Fixed {
+		fmt.Fprintf(w, "  %s %s\n", styles.Verdict(true, "FIXED"), styles.Bold(name))
+	}
+}
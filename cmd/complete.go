@@ -0,0 +1,253 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/transport"
+)
+
+// completeCommandName is the name of the hidden command called by completion scripts to retrieve
+// values from Microcks server.
+const completeCommandName = "__complete"
+
+// Kinds of values retrieved from Microcks server by completion scripts.
+const (
+	completeServices   = "services"
+	completeOperations = "operations"
+	completeSecrets    = "secrets"
+)
+
+// Environment variables tuning the retrieval of completion values.
+const (
+	completionCacheDirEnv = "MICROCKS_COMPLETION_CACHE_DIR"
+	completionCacheTTLEnv = "MICROCKS_COMPLETION_CACHE_TTL"
+	completionTimeoutEnv  = "MICROCKS_COMPLETION_TIMEOUT"
+)
+
+const (
+	defaultCompletionCacheTTL = 5 * time.Minute
+	defaultCompletionTimeout  = 2 * time.Second
+)
+
+type completeCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+}
+
+// newCompleteCommand build the hidden command printing dynamic completion values
+func newCompleteCommand() Command {
+	c := new(completeCommand)
+	c.fs = newFlagSet(usage{name: completeCommandName})
+	c.cf.register(c.fs)
+	return c
+}
+
+// Execute prints the values of a kind, one per line. args are the kind of values, the command being
+// completed and the words of the command line following it. Connection settings are taken from these
+// words, environment and configuration file. It never fails: any error or timeout results in no values
+// so that completion never blocks the shell.
+func (c *completeCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) < 2 {
+		return nil
+	}
+	command, found := LookupCommand(args[1])
+	if !found {
+		return nil
+	}
+	holder, ok := command.(flagSetHolder)
+	if !ok {
+		return nil
+	}
+	positionals := c.parseWords(holder.flagSet(), args[2:])
+	if err := resolveSettings(c.fs); err != nil || len(c.cf.microcksURL) == 0 {
+		return nil
+	}
+	c.cf.setup(io.Discard, io.Discard)
+	c.cf.apply()
+
+	timeout := defaultCompletionTimeout
+	if value, err := time.ParseDuration(os.Getenv(completionTimeoutEnv)); err == nil {
+		timeout = value
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	values := make(chan []string, 1)
+	go func() {
+		values <- c.values(ctx, args[0], positionals)
+	}()
+	select {
+	case result := <-values:
+		for _, value := range result {
+			fmt.Fprintln(stdout, value)
+		}
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// parseWords sets the client flags found in words, knowing flags of the completed command from fs,
+// and returns the positional arguments. Invalid or unknown flags are ignored.
+func (c *completeCommand) parseWords(fs *flag.FlagSet, words []string) []string {
+	var positionals []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "-") || word == "-" {
+			positionals = append(positionals, word)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(word, "-"), "=")
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && (!ok || !b.IsBoolFlag()) {
+			if i+1 == len(words) {
+				break
+			}
+			i++
+			value = words[i]
+		} else if !hasValue {
+			value = "true"
+		}
+		if c.fs.Lookup(name) != nil {
+			c.fs.Set(name, value)
+		}
+	}
+	return positionals
+}
+
+// values retrieves the values of kind, using cached ones if fresh enough.
+func (c *completeCommand) values(ctx context.Context, kind string, positionals []string) []string {
+	var values []string
+	switch kind {
+	case completeServices:
+		for _, service := range c.services(ctx) {
+			values = append(values, service.Name+":"+service.Version)
+		}
+	case completeOperations:
+		if len(positionals) == 0 {
+			return nil
+		}
+		for _, service := range c.services(ctx) {
+			if service.Name+":"+service.Version != positionals[0] {
+				continue
+			}
+			for _, operation := range service.Operations {
+				// Operations are filtered using a JSON array of names.
+				value, _ := json.Marshal([]string{operation.Name})
+				values = append(values, string(value))
+			}
+		}
+	case completeSecrets:
+		var secrets []connectors.Secret
+		c.cached(ctx, completeSecrets, &secrets, func(mc connectors.MicrocksClient) (interface{}, error) {
+			return connectors.ListAll(ctx, mc.ListSecrets, 0)
+		})
+		for _, secret := range secrets {
+			values = append(values, secret.Name)
+		}
+	}
+	return values
+}
+
+func (c *completeCommand) services(ctx context.Context) []connectors.Service {
+	var services []connectors.Service
+	c.cached(ctx, completeServices, &services, func(mc connectors.MicrocksClient) (interface{}, error) {
+		return connectors.ListAll(ctx, mc.ListServices, 0)
+	})
+	return services
+}
+
+// completionCacheEntry is the content of a completion cache file.
+type completionCacheEntry struct {
+	FetchedAt time.Time       `json:"fetchedAt"`
+	Values    json.RawMessage `json:"values"`
+}
+
+// cached decodes into v the values of kind from cache file if fresh enough, otherwise from the result
+// of fetch that is then cached.
+func (c *completeCommand) cached(ctx context.Context, kind string, v interface{}, fetch func(mc connectors.MicrocksClient) (interface{}, error)) {
+	ttl := defaultCompletionCacheTTL
+	if value, err := time.ParseDuration(os.Getenv(completionCacheTTLEnv)); err == nil {
+		ttl = value
+	}
+	path := c.cachePath(kind)
+
+	var entry completionCacheEntry
+	if data, err := os.ReadFile(path); ttl > 0 && err == nil && json.Unmarshal(data, &entry) == nil {
+		if time.Since(entry.FetchedAt) < ttl && json.Unmarshal(entry.Values, v) == nil {
+			return
+		}
+	}
+
+	// Expired values are fetched again, but unchanged lists are not downloaded twice.
+	if dir := c.cacheDir(); len(dir) > 0 {
+		c.cf.cache = transport.NewDiskCache(filepath.Join(dir, "http"))
+	}
+	mc, err := c.cf.connect(ctx)
+	if err != nil {
+		return
+	}
+	values, err := fetch(mc)
+	if err != nil {
+		return
+	}
+	entry.FetchedAt = time.Now()
+	if entry.Values, err = json.Marshal(values); err != nil || json.Unmarshal(entry.Values, v) != nil {
+		return
+	}
+	if ttl > 0 && len(path) > 0 {
+		if data, err := json.Marshal(entry); err == nil && os.MkdirAll(filepath.Dir(path), 0700) == nil {
+			os.WriteFile(path, data, 0600)
+		}
+	}
+}
+
+// cacheDir returns the directory of completion cache files, empty if there's none.
+func (c *completeCommand) cacheDir() string {
+	dir := os.Getenv(completionCacheDirEnv)
+	if len(dir) == 0 {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(cacheDir, "microcks-cli", "completion")
+	}
+	return dir
+}
+
+// cachePath computes the cache file of kind values for the Microcks server and client in use.
+func (c *completeCommand) cachePath(kind string) string {
+	dir := c.cacheDir()
+	if len(dir) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(c.cf.microcksURL + "\n" + c.cf.keycloakClientID))
+	return filepath.Join(dir, kind+"-"+hex.EncodeToString(sum[:8])+".json")
+}
@@ -0,0 +1,435 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+var completionUsage = usage{
+	name:        "completion",
+	synopsis:    "completion bash|zsh|fish|powershell",
+	description: "Generate the shell completion script of microcks-cli.",
+	args: [][2]string{
+		{"<shell>", "Shell to generate script for (one of: bash, zsh, fish, powershell)"},
+	},
+	examples: []string{
+		"# Bash, current session or permanently through ~/.bashrc\nsource <(microcks-cli completion bash)",
+		"# Zsh, current session or permanently through ~/.zshrc\nsource <(microcks-cli completion zsh)",
+		"# Fish\nmicrocks-cli completion fish | source",
+		"# PowerShell\nmicrocks-cli completion powershell | Out-String | Invoke-Expression",
+	},
+}
+
+// shells lists the shells supported by completion command.
+var shells = []string{"bash", "zsh", "fish", "powershell"}
+
+// flagSetHolder is implemented by commands having flags.
+type flagSetHolder interface {
+	flagSet() *flag.FlagSet
+}
+
+// completionFlagValues holds the values completed for enum flags.
+var completionFlagValues = map[string][]string{
+	"errors":          {"text", "json"},
+	"log-level":       {"error", "warn", "info", "debug", "trace"},
+	"log-format":      {"text", "json"},
+	"tls-min-version": {"1.2", "1.3"},
+	"sort-by":         {"name", "version", "lastUpdate", "operations"},
+	// Values retrieved from Microcks by the hidden __complete command.
+	"filteredOperations": {"@" + completeOperations},
+	"secretName":         {"@" + completeSecrets},
+}
+
+// completionFileFlags holds the names of flags whose values are file paths.
+var completionFileFlags = map[string]bool{"config": true, "caCerts": true, "f": true, "file": true, "operationsHeadersFile": true, "oAuth2ContextFile": true, "secretFile": true, "output-file": true}
+
+type completionCommand struct {
+}
+
+// NewCompletionCommand build a new CompletionCommand implementation
+func NewCompletionCommand() Command {
+	return new(completionCommand)
+}
+
+// Execute implementation on completionCommand structure
+func (c *completionCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+	if err := completionUsage.checkArgs(args); err != nil {
+		return err
+	}
+
+	specs := completionSpecs()
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(stdout, specs)
+	case "zsh":
+		fmt.Fprintln(stdout, "#compdef microcks-cli")
+		fmt.Fprintln(stdout, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(stdout, specs)
+	case "fish":
+		writeFishCompletion(stdout, specs)
+	case "powershell":
+		writePowerShellCompletion(stdout, specs)
+	default:
+		return usageErrorf("completion command does not support '%s' shell, valid ones are: %s. Check Usage.", args[0], strings.Join(shells, ", "))
+	}
+	return nil
+}
+
+func (c *completionCommand) printUsage(w io.Writer) {
+	completionUsage.print(w, nil)
+}
+
+// commandCompletion describes what can be completed for a command.
+type commandCompletion struct {
+	name  string
+	short string
+	// flags holds flag names, sorted.
+	flags []string
+	// valueFlags holds the names of flags expecting a value.
+	valueFlags []string
+	// flagValues holds the values completed for enum flags. A single value starting with @ is the
+	// kind of values retrieved from Microcks.
+	flagValues map[string][]string
+	// fileFlags holds the names of flags completed with file paths.
+	fileFlags []string
+	// args holds the values completed for each positional argument, like flagValues. Files are
+	// completed if there's none.
+	args [][]string
+}
+
+// completionSpecs builds the completion specifications from the command registry and flag sets
+// so that generated scripts cannot drift from actual commands.
+func completionSpecs() []commandCompletion {
+	var names []string
+	for _, spec := range commands {
+		names = append(names, spec.name)
+	}
+	var runners []string
+	for runner := range runnerChoices {
+		runners = append(runners, runner)
+	}
+	sort.Strings(runners)
+	var formats []string
+	for _, format := range output.Formats {
+		formats = append(formats, string(format))
+	}
+
+	positionals := map[string][][]string{
+		"help":       {names},
+		"test":       {{"@" + completeServices}, {}, runners},
+		"config":     {{"view", "set", "validate"}, config.SettingKeys()},
+		"context":    {{"list", "use", "set"}},
+		"services":   {{"list"}},
+		"export":     {{"@" + completeServices}},
+		"secret":     {{"apply"}},
+		"completion": {shells},
+	}
+
+	var specs []commandCompletion
+	for _, spec := range commands {
+		completion := commandCompletion{name: spec.name, short: spec.short, flagValues: map[string][]string{}, args: positionals[spec.name]}
+		if holder, ok := spec.factory().(flagSetHolder); ok {
+			holder.flagSet().VisitAll(func(f *flag.Flag) {
+				if _, ok := deprecatedAlias(f); ok {
+					return
+				}
+				completion.flags = append(completion.flags, f.Name)
+				if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+					return
+				}
+				completion.valueFlags = append(completion.valueFlags, f.Name)
+				if completionFileFlags[f.Name] {
+					completion.fileFlags = append(completion.fileFlags, f.Name)
+				}
+				if f.Name == "output" {
+					completion.flagValues[f.Name] = formats
+				} else if values, ok := completionFlagValues[f.Name]; ok {
+					completion.flagValues[f.Name] = values
+				}
+			})
+		}
+		specs = append(specs, completion)
+	}
+	return specs
+}
+
+func prefixed(prefix string, values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = prefix + value
+	}
+	return result
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// bashCompletionHelpers are the functions of bash completion script not depending on commands.
+// Command line is split on whitespaces rather than using COMP_WORDS, split on COMP_WORDBREAKS characters
+// such as ':' or '=', so that service references, URLs and --flag=value are single words. Completed values
+// are then trimmed to the part after the last word break, as replaced by bash.
+const bashCompletionHelpers = `_microcks_cli_reply() {
+    local cur="$1" word="${COMP_WORDS[COMP_CWORD]}" candidate strip
+    shift
+    [[ "${word}" == "=" || "${word}" == ":" ]] && word=""
+    strip=$(( ${#cur} - ${#word} ))
+    (( strip < 0 )) && strip=0
+    COMPREPLY=()
+    for candidate in "$@"; do
+        if [[ -n "${candidate}" && "${candidate}" == "${cur}"* ]]; then
+            COMPREPLY+=( "$(printf '%q' "${candidate:strip}")" )
+        fi
+    done
+}
+
+# Values starting with @ are retrieved from Microcks using the connection settings of command line.
+_microcks_cli_values() {
+    local cur="$1" values="$2" value
+    local -a candidates
+    if [[ "${values}" == @* ]]; then
+        while IFS= read -r value; do
+            candidates+=( "${value}" )
+        done < <("${words[0]}" __complete "${values#@}" "${cmd}" "${words[@]:2:cword-2}" 2>/dev/null)
+    else
+        candidates=( ${values} )
+    fi
+    _microcks_cli_reply "${cur}" "${candidates[@]}"
+}
+`
+
+func writeBashCompletion(w io.Writer, specs []commandCompletion) {
+	var names []string
+	flagValues := map[string][]string{}
+	for _, spec := range specs {
+		names = append(names, spec.name)
+		for name, values := range spec.flagValues {
+			flagValues[name] = values
+		}
+	}
+
+	fmt.Fprintln(w, "# bash completion for microcks-cli")
+	io.WriteString(w, bashCompletionHelpers+"\n")
+	fmt.Fprintln(w, "_microcks_cli_flag_values() {")
+	fmt.Fprintln(w, "    case \"$1\" in")
+	for _, name := range sortedKeys(flagValues) {
+		fmt.Fprintf(w, "    %s) echo \"%s\" ;;\n", name, strings.Join(flagValues[name], " "))
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "_microcks_cli() {")
+	fmt.Fprintln(w, "    local line=\"${COMP_LINE:0:COMP_POINT}\" cword cur flag cmd flags value_flags i word name")
+	fmt.Fprintln(w, "    local -a words args positionals")
+	fmt.Fprintln(w, "    if [[ -n \"${ZSH_VERSION:-}\" ]]; then")
+	fmt.Fprintln(w, "        setopt localoptions ksharrays shwordsplit")
+	fmt.Fprintln(w, "        read -rA words <<< \"${line}\"")
+	fmt.Fprintln(w, "    else")
+	fmt.Fprintln(w, "        read -ra words <<< \"${line}\"")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "    [[ \"${line}\" =~ [[:space:]]$ ]] && words+=( \"\" )")
+	fmt.Fprintln(w, "    cword=$(( ${#words[@]} - 1 ))")
+	fmt.Fprintln(w, "    cur=\"${words[cword]}\"")
+	fmt.Fprintln(w, "    COMPREPLY=()")
+	fmt.Fprintln(w, "    if (( cword <= 1 )); then")
+	fmt.Fprintf(w, "        _microcks_cli_reply \"${cur}\" %s --help --version\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "        return 0")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "    cmd=\"${words[1]}\"")
+	fmt.Fprintln(w, "    case \"${cmd}\" in")
+	for _, spec := range specs {
+		fmt.Fprintf(w, "    %s)\n", spec.name)
+		fmt.Fprintf(w, "        flags=\"%s\"\n", strings.Join(prefixed("--", spec.flags), " "))
+		fmt.Fprintf(w, "        value_flags=\" %s \"\n", strings.Join(spec.valueFlags, " "))
+		var args []string
+		for _, values := range spec.args {
+			args = append(args, "\""+strings.Join(values, " ")+"\"")
+		}
+		fmt.Fprintf(w, "        args=( %s )\n", strings.Join(args, " "))
+		fmt.Fprintln(w, "        ;;")
+	}
+	fmt.Fprintln(w, "    *)")
+	fmt.Fprintln(w, "        return 0 ;;")
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "    # Complete the value of a flag, given as --flag=value or --flag value.")
+	fmt.Fprintln(w, "    if [[ \"${cur}\" == -*=* ]]; then")
+	fmt.Fprintln(w, "        flag=\"${cur%%=*}\"")
+	fmt.Fprintln(w, "        cur=\"${cur#*=}\"")
+	fmt.Fprintln(w, "    elif [[ \"${words[cword-1]}\" == -* && \"${words[cword-1]}\" != *=* ]]; then")
+	fmt.Fprintln(w, "        flag=\"${words[cword-1]}\"")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "    flag=\"${flag#-}\"")
+	fmt.Fprintln(w, "    flag=\"${flag#-}\"")
+	fmt.Fprintln(w, "    if [[ -n \"${flag}\" && \"${value_flags}\" == *\" ${flag} \"* ]]; then")
+	fmt.Fprintln(w, "        _microcks_cli_values \"${cur}\" \"$(_microcks_cli_flag_values \"${flag}\")\"")
+	fmt.Fprintln(w, "        return 0")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "    if [[ \"${cur}\" == -* ]]; then")
+	fmt.Fprintln(w, "        _microcks_cli_reply \"${cur}\" ${flags}")
+	fmt.Fprintln(w, "        return 0")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "    # Complete a positional argument, skipping flags and their values.")
+	fmt.Fprintln(w, "    for (( i=2; i < cword; i++ )); do")
+	fmt.Fprintln(w, "        word=\"${words[i]}\"")
+	fmt.Fprintln(w, "        if [[ \"${word}\" == -* ]]; then")
+	fmt.Fprintln(w, "            name=\"${word#-}\"")
+	fmt.Fprintln(w, "            name=\"${name#-}\"")
+	fmt.Fprintln(w, "            if [[ \"${word}\" != *=* && \"${value_flags}\" == *\" ${name} \"* ]]; then")
+	fmt.Fprintln(w, "                (( i++ ))")
+	fmt.Fprintln(w, "            fi")
+	fmt.Fprintln(w, "        else")
+	fmt.Fprintln(w, "            positionals+=( \"${word}\" )")
+	fmt.Fprintln(w, "        fi")
+	fmt.Fprintln(w, "    done")
+	fmt.Fprintln(w, "    if (( ${#positionals[@]} < ${#args[@]} )) && [[ -n \"${args[${#positionals[@]}]}\" ]]; then")
+	fmt.Fprintln(w, "        _microcks_cli_values \"${cur}\" \"${args[${#positionals[@]}]}\"")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "    return 0")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o default -F _microcks_cli microcks-cli")
+}
+
+func writeFishCompletion(w io.Writer, specs []commandCompletion) {
+	fmt.Fprintln(w, "# fish completion for microcks-cli")
+	fmt.Fprintln(w, "complete -c microcks-cli -f")
+	for _, spec := range specs {
+		fmt.Fprintf(w, "complete -c microcks-cli -n '__fish_use_subcommand' -a %s -d %s\n", spec.name, fishQuote(spec.short))
+	}
+	for _, spec := range specs {
+		condition := "__fish_seen_subcommand_from " + spec.name
+		for _, name := range spec.flags {
+			line := fmt.Sprintf("complete -c microcks-cli -n '%s' -l %s", condition, name)
+			if values, ok := spec.flagValues[name]; ok {
+				line += " -x -a " + fishValues(spec.name, values)
+			} else if completionFileFlags[name] {
+				line += " -r -F"
+			} else if contains(spec.valueFlags, name) {
+				line += " -x"
+			}
+			fmt.Fprintln(w, line)
+		}
+		for _, values := range spec.args {
+			if len(values) > 0 {
+				fmt.Fprintf(w, "complete -c microcks-cli -n '%s' -a %s\n", condition, fishValues(spec.name, values))
+			}
+		}
+	}
+}
+
+func writePowerShellCompletion(w io.Writer, specs []commandCompletion) {
+	fmt.Fprintln(w, "# powershell completion for microcks-cli")
+	fmt.Fprintln(w, "$microcksCliCommands = @{")
+	for _, spec := range specs {
+		fmt.Fprintf(w, "    '%s' = @{\n", spec.name)
+		fmt.Fprintf(w, "        Flags = @(%s)\n", psList(prefixed("--", spec.flags)))
+		fmt.Fprintln(w, "        Values = @{")
+		for _, name := range sortedKeys(spec.flagValues) {
+			fmt.Fprintf(w, "            '--%s' = @(%s)\n", name, psList(spec.flagValues[name]))
+		}
+		fmt.Fprintln(w, "        }")
+		var args []string
+		for _, values := range spec.args {
+			args = append(args, "@("+psList(values)+")")
+		}
+		fmt.Fprintf(w, "        Args = @(%s)\n", strings.Join(args, ", "))
+		fmt.Fprintln(w, "    }")
+	}
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, `Register-ArgumentCompleter -Native -CommandName 'microcks-cli' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -ne '') { $words = $words[0..($words.Count - 2)] }
+    if ($words.Count -le 1) {
+        $candidates = @($microcksCliCommands.Keys) + @('--help', '--version')
+    } else {
+        $spec = $microcksCliCommands[$words[1]]
+        if ($null -eq $spec) { return }
+        $previous = $words[-1]
+        if ($spec.Values.ContainsKey($previous)) {
+            $candidates = $spec.Values[$previous]
+        } elseif ($wordToComplete.StartsWith('-')) {
+            $candidates = $spec.Flags
+        } else {
+            $position = @($words[2..($words.Count)] | Where-Object { $_ -and -not $_.StartsWith('-') }).Count
+            if ($position -lt $spec.Args.Count) { $candidates = $spec.Args[$position] } else { return }
+        }
+    }
+    if ($candidates.Count -eq 1 -and "$candidates".StartsWith('@')) {
+        $candidates = @(& $words[0] __complete "$candidates".Substring(1) $words[1] @($words | Select-Object -Skip 2) 2>$null)
+    }
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | Sort-Object | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}`)
+}
+
+// fishValues returns the fish arguments for values, calling the hidden __complete command for values
+// retrieved from Microcks.
+func fishValues(command string, values []string) string {
+	if kind, ok := dynamicKind(values); ok {
+		return fishQuote(fmt.Sprintf("(microcks-cli %s %s %s (commandline -opc)[3..-1] 2>/dev/null)", completeCommandName, kind, command))
+	}
+	return fishQuote(strings.Join(values, " "))
+}
+
+// dynamicKind tells if values are retrieved from Microcks, returning their kind.
+func dynamicKind(values []string) (string, bool) {
+	if len(values) == 1 && strings.HasPrefix(values[0], "@") {
+		return values[0][1:], true
+	}
+	return "", false
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "\\'", -1) + "'"
+}
+
+func psList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func TestCompletionScriptSyntax(t *testing.T) {
+	tests := []struct {
+		shell string
+		// checker is the shell parsing the script without executing it.
+		checker string
+	}{
+		{"bash", "bash"},
+		// Zsh script relies on bashcompinit, it must be valid for both.
+		{"zsh", "bash"},
+		{"zsh", "zsh"},
+	}
+	for _, test := range tests {
+		t.Run(test.shell+" with "+test.checker, func(t *testing.T) {
+			path, err := exec.LookPath(test.checker)
+			if err != nil {
+				t.Skipf("%s is not installed", test.checker)
+			}
+			var script bytes.Buffer
+			if err := NewCompletionCommand().Execute(context.Background(), []string{test.shell}, &script, &script); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			checker := exec.Command(path, "-n")
+			checker.Stdin = &script
+			if out, err := checker.CombinedOutput(); err != nil {
+				t.Errorf("%s -n rejected %s script: %v\n%s", test.checker, test.shell, err, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+var configUsage = usage{
+	name:        "config",
+	synopsis:    "config view|set <key> <value>|validate [flags]",
+	description: "Manage microcks-cli configuration.",
+	args: [][2]string{
+		{"view", "Print the effective configuration merged from flags, environment and configuration file (secrets masked)"},
+		{"set <key> <value>", "Set a setting of the active context (or of top-level settings if none) in configuration file"},
+		{"validate", "Check the configuration file and the settings of each of its contexts"},
+	},
+	examples: []string{
+		"microcks-cli config view",
+		"microcks-cli config view --config=./ci/microcks.yaml",
+		"microcks-cli config set tls.insecure true",
+		"microcks-cli config set keycloak.clientId microcks-serviceaccount --context=staging",
+		"microcks-cli config validate --online",
+	},
+}
+
+type configCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	online bool
+}
+
+// NewConfigCommand build a new ConfigCommand implementation
+func NewConfigCommand() Command {
+	c := new(configCommand)
+	c.fs = newFlagSet(configUsage)
+	c.cf.register(c.fs)
+	c.fs.BoolVar(&c.online, "online", false, "With validate, also check that Microcks URL and authentication of each context work")
+	return c
+}
+
+func (c *configCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of configCommand structure
+func (c *configCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	allowMissingConfig = len(args) > 0 && args[0] == "set"
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return usageErrorf("config command require an action (one of: view, set, validate). Check Usage.")
+	}
+	c.cf.setup(stdout, stderr)
+
+	switch args[0] {
+	case "view", "validate":
+		if len(args) > 1 {
+			return usageErrorf("config %s got unexpected arg '%s'. Check Usage.", args[0], args[1])
+		}
+		if args[0] == "view" {
+			return c.view(stdout)
+		}
+		return c.validate(ctx)
+	case "set":
+		if len(args) != 3 {
+			return usageErrorf("config set require <key> and <value> args. Check Usage.")
+		}
+		return c.set(stdout, args[1], args[2])
+	default:
+		return usageErrorf("config command does not support '%s' action. Check Usage.", args[0])
+	}
+}
+
+// view prints the effective merged configuration with secrets masked.
+func (c *configCommand) view(w io.Writer) error {
+	values := map[string]string{}
+	c.fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = displayValue(f)
+	})
+
+	effective := config.File{Settings: config.SettingsFromFlagValues(values)}
+	if configFile != nil {
+		effective.Defaults = configFile.Defaults
+	}
+
+	if c.cf.output.IsStructured() {
+		return c.cf.render(effective)
+	}
+	if len(configFilePath) > 0 {
+		fmt.Fprintf(w, "# Configuration file: %s\n", configFilePath)
+	} else {
+		fmt.Fprintln(w, "# No configuration file found")
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(effective); err != nil {
+		return fmt.Errorf("Cannot render configuration: %w", err)
+	}
+	return nil
+}
+
+// set writes the value of a dotted key into the active context of configuration file.
+func (c *configCommand) set(w io.Writer, key string, value string) error {
+	name, ok := config.SettingFlag(key)
+	if !ok {
+		return usageErrorf("Unknown configuration key '%s', valid ones are: %s.", key, strings.Join(config.SettingKeys(), ", "))
+	}
+	// Check value the same way the corresponding flag does.
+	if len(value) > 0 {
+		if err := newValidationFlagSet(new(clientFlags)).Set(name, value); err != nil {
+			return usageErrorf("Invalid value for %s: %s", key, err)
+		}
+	}
+
+	if configFile == nil {
+		configFile = &config.File{}
+	}
+	settings := &configFile.Settings
+	target := "top-level settings"
+	contextName := c.cf.contextName
+	if len(contextName) == 0 {
+		contextName = os.Getenv(envName("context"))
+	}
+	if len(contextName) == 0 {
+		contextName = configFile.CurrentContext
+	}
+	if len(contextName) > 0 {
+		context := configFile.Context(contextName)
+		if context == nil {
+			return fmt.Errorf("Context '%s' is not defined. Use 'context set %s' to create it.", contextName, contextName)
+		}
+		settings = &context.Settings
+		target = "context '" + contextName + "'"
+	}
+
+	values := settings.FlagValues()
+	if len(value) > 0 {
+		values[name] = value
+	} else {
+		delete(values, name)
+	}
+	*settings = config.SettingsFromFlagValues(values)
+
+	path := configFilePath
+	if len(path) == 0 {
+		path = config.UserFilePath()
+	}
+	if err := config.SaveFile(path, configFile); err != nil {
+		return fmt.Errorf("Cannot save configuration file %s: %w", path, err)
+	}
+	fmt.Fprintf(w, "Set %s of %s in %s\n", key, target, path)
+	return nil
+}
+
+// validate checks configuration file and the settings of each context, connecting to Microcks if online.
+func (c *configCommand) validate(ctx context.Context) error {
+	if len(configFilePath) == 0 {
+		return fmt.Errorf("No configuration file found")
+	}
+	// File has already been loaded successfully, reload it to get warnings.
+	_, warnings, err := config.LoadFile(configFilePath)
+	if err != nil {
+		return fmt.Errorf("Cannot load configuration file: %w", err)
+	}
+	result := &configValidation{File: configFilePath, Valid: true, Warnings: warnings}
+
+	if len(configFile.CurrentContext) > 0 && configFile.Context(configFile.CurrentContext) == nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("current context '%s' is not defined", configFile.CurrentContext))
+		result.Valid = false
+	}
+	names := []string{""}
+	if len(configFile.Contexts) > 0 {
+		names = names[:0]
+		for _, context := range configFile.Contexts {
+			names = append(names, context.Name)
+		}
+	}
+	if c.online {
+		c.cf.apply()
+		var cancel context.CancelFunc
+		ctx, cancel = c.cf.withTimeout(ctx)
+		defer cancel()
+	}
+	for _, name := range names {
+		validation := c.validateContext(ctx, name)
+		if len(validation.Problems) > 0 {
+			result.Valid = false
+		}
+		result.Contexts = append(result.Contexts, validation)
+	}
+
+	if err := c.cf.render(result); err != nil {
+		return err
+	}
+	if !result.Valid {
+		return &ReportedError{Err: fmt.Errorf("Configuration file %s is invalid", configFilePath)}
+	}
+	return nil
+}
+
+// validateContext checks the settings of a context, merged with top-level ones. Empty name stands for
+// top-level settings of a file without contexts.
+func (c *configCommand) validateContext(ctx context.Context, name string) contextValidation {
+	validation := contextValidation{Name: name}
+	if len(name) == 0 {
+		validation.Name = "(top-level)"
+	}
+	values, err := configFile.FlagValues(name)
+	if err != nil {
+		validation.Problems = append(validation.Problems, err.Error())
+		return validation
+	}
+	validation.MicrocksURL = values["microcksURL"]
+
+	var cf clientFlags
+	fs := newValidationFlagSet(&cf)
+	for _, key := range config.SettingKeys() {
+		flagName, _ := config.SettingFlag(key)
+		value, ok := values[flagName]
+		if !ok {
+			continue
+		}
+		if err := fs.Set(flagName, value); err != nil {
+			validation.Problems = append(validation.Problems, fmt.Sprintf("invalid %s: %s", key, err))
+		}
+	}
+	for _, setting := range mandatorySettings {
+		if len(values[setting[0]]) == 0 {
+			key, _ := config.SettingKey(setting[0])
+			validation.Problems = append(validation.Problems, "missing "+key)
+		}
+	}
+	if len(cf.caCertPaths) > 0 {
+		for _, path := range strings.Split(cf.caCertPaths, ",") {
+			if _, err := os.Stat(strings.TrimSpace(path)); err != nil {
+				validation.Problems = append(validation.Problems, fmt.Sprintf("cannot read tls.caCerts file: %s", err))
+			}
+		}
+	}
+
+	if c.online && len(validation.Problems) == 0 {
+		if _, err := cf.connect(ctx); err != nil {
+			validation.Problems = append(validation.Problems, err.Error())
+		} else {
+			validation.Online = true
+		}
+	}
+	return validation
+}
+
+// newValidationFlagSet returns a silent FlagSet with client flags cf, used to check setting values.
+func newValidationFlagSet(cf *clientFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cf.register(fs)
+	return fs
+}
+
+func (c *configCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
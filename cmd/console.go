@@ -0,0 +1,179 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// consoleLogger writes the CLI messages that are not part of the command result through a leveled
+// slog.Logger: info for user-facing progress messages, debug for requests summaries and decisions,
+// trace for full HTTP dumps. In text log format, progress messages go to stdout in text output mode
+// and to stderr in structured output modes; errors go to stderr in quiet mode. JSON logs always go
+// to stderr so that stdout remains machine-parseable. Connectors logs, with API requests summaries
+// and dumps, always go to stderr.
+type consoleLogger struct {
+	logger     *slog.Logger
+	connectors *slog.Logger
+	progress   io.Writer
+	errors     io.Writer
+	json       bool
+}
+
+// console is the logger shared by all commands.
+var console = newConsoleLogger(os.Stdout, os.Stdout, os.Stderr, slog.LevelInfo, false)
+
+func newConsoleLogger(progress io.Writer, errors io.Writer, stderr io.Writer, level slog.Level, json bool) *consoleLogger {
+	l := &consoleLogger{progress: progress, errors: errors, json: json}
+	if json {
+		l.logger = slog.New(slog.NewJSONHandler(stderr, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.LevelKey {
+					a.Value = slog.StringValue(config.LevelName(a.Value.Any().(slog.Level)))
+				}
+				return a
+			},
+		}))
+		l.connectors = l.logger
+	} else {
+		mu := &sync.Mutex{}
+		l.logger = slog.New(&consoleHandler{level: level, progress: progress, errors: errors, mu: mu})
+		l.connectors = slog.New(&consoleHandler{level: level, progress: stderr, errors: stderr, mu: mu})
+	}
+	return l
+}
+
+// configureConsole adapts logger to the output streams, output format, quiet mode and log settings of command.
+func configureConsole(stdout, stderr io.Writer, structured bool, quiet bool, level slog.Level, jsonFormat bool) {
+	progress, errors := stdout, stdout
+	if structured {
+		progress, errors = stderr, stderr
+	}
+	if quiet {
+		errors = stderr
+		if level < slog.LevelError {
+			level = slog.LevelError
+		}
+	}
+	console = newConsoleLogger(progress, errors, stderr, level, jsonFormat)
+	config.Logger = console.logger
+}
+
+// Printf writes a non-essential progress message at info level.
+func (l *consoleLogger) Printf(format string, args ...interface{}) {
+	l.logger.Info(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// Println writes a non-essential progress message at info level.
+func (l *consoleLogger) Println(args ...interface{}) {
+	l.logger.Info(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// Debugf writes a message at debug level.
+func (l *consoleLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debug(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// Warnf writes a warning message.
+func (l *consoleLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Warn(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// Errorf writes an error message.
+func (l *consoleLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Error(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// Errorln writes an error message.
+func (l *consoleLogger) Errorln(args ...interface{}) {
+	l.logger.Error(strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+// Styles returns the palette to use for progress messages.
+func (l *consoleLogger) Styles() output.Palette {
+	if l.json {
+		return output.Palette{}
+	}
+	return output.Styles(l.progress)
+}
+
+// consoleHandler is a slog.Handler writing plain human readable messages: info messages as is,
+// warnings and errors styled, debug and trace messages prefixed by their level. Attributes are
+// appended as key=value pairs.
+type consoleHandler struct {
+	level    slog.Leveler
+	progress io.Writer
+	errors   io.Writer
+	attrs    []slog.Attr
+	mu       *sync.Mutex
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	var b strings.Builder
+	switch {
+	case record.Level < slog.LevelInfo:
+		b.WriteString("[" + config.LevelName(record.Level) + "] ")
+	}
+	b.WriteString(record.Message)
+	appendAttr := func(a slog.Attr) bool {
+		b.WriteString(" " + a.Key + "=" + a.Value.String())
+		return true
+	}
+	for _, a := range h.attrs {
+		appendAttr(a)
+	}
+	record.Attrs(appendAttr)
+
+	w := h.progress
+	line := b.String()
+	switch {
+	case record.Level >= slog.LevelError:
+		w = h.errors
+		line = output.Styles(w).Failure(line)
+	case record.Level >= slog.LevelWarn:
+		line = output.Styles(w).Warning(line)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	// Groups are not used by the CLI, keep flat attributes.
+	return h
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+)
+
+var contextUsage = usage{
+	name:        "context",
+	synopsis:    "context list|use <name>|set <name> [flags]",
+	description: "Manage named contexts bundling Microcks URL, authentication and TLS settings.",
+	args: [][2]string{
+		{"list", "List the contexts defined in configuration file, marking the current one"},
+		{"use <name>", "Make <name> the current context"},
+		{"set <name>", "Create or update context <name> from the connection flags given"},
+	},
+	examples: []string{
+		"microcks-cli context set dev --microcksURL=http://localhost:8080/api/ \\\n" +
+			"    --keycloakClientId=microcks-serviceaccount --keycloakClientSecret=<secret>",
+		"microcks-cli context use dev",
+		"microcks-cli test 'Beer Catalog API:0.9' http://localhost:9090/api/ POSTMAN --context=staging",
+	},
+}
+
+type contextCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+}
+
+// NewContextCommand build a new ContextCommand implementation
+func NewContextCommand() Command {
+	c := new(contextCommand)
+	c.fs = newFlagSet(contextUsage)
+	c.cf.register(c.fs)
+	return c
+}
+
+func (c *contextCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of contextCommand structure
+func (c *contextCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	allowMissingConfig = true
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return usageErrorf("context command require an action (one of: list, use, set). Check Usage.")
+	}
+	c.cf.setup(stdout, stderr)
+
+	switch args[0] {
+	case "list":
+		c.list(stdout)
+		return nil
+	case "use", "set":
+		if len(args) < 2 {
+			return usageErrorf("context %s require <name> arg. Check Usage.", args[0])
+		}
+		if args[0] == "use" {
+			return c.use(stdout, args[1])
+		}
+		return c.set(stdout, args[1])
+	default:
+		return usageErrorf("context command does not support '%s' action. Check Usage.", args[0])
+	}
+}
+
+func (c *contextCommand) list(w io.Writer) {
+	if configFile == nil || len(configFile.Contexts) == 0 {
+		fmt.Fprintln(w, "No context defined")
+		return
+	}
+	for _, context := range configFile.Contexts {
+		marker := " "
+		if context.Name == configFile.CurrentContext {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %-20s %s\n", marker, context.Name, context.MicrocksURL)
+	}
+}
+
+func (c *contextCommand) use(w io.Writer, name string) error {
+	if configFile == nil || configFile.Context(name) == nil {
+		return fmt.Errorf("Context '%s' is not defined. Use 'context set %s' to create it.", name, name)
+	}
+	configFile.CurrentContext = name
+	if err := c.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Switched to context '%s'\n", name)
+	return nil
+}
+
+func (c *contextCommand) set(w io.Writer, name string) error {
+	if configFile == nil {
+		configFile = &config.File{}
+	}
+
+	// Only consider flags explicitly passed, merging them into existing context.
+	context := config.Context{Name: name}
+	if existing := configFile.Context(name); existing != nil {
+		context = *existing
+	}
+	values := context.Settings.FlagValues()
+	c.fs.Visit(func(f *flag.Flag) {
+		if explicitFlags[f.Name] {
+			values[f.Name] = f.Value.String()
+		}
+	})
+	context.Settings = config.SettingsFromFlagValues(values)
+
+	configFile.SetContext(context)
+	if len(configFile.CurrentContext) == 0 {
+		configFile.CurrentContext = name
+	}
+	if err := c.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Context '%s' saved\n", name)
+	return nil
+}
+
+func (c *contextCommand) save() error {
+	path := configFilePath
+	if len(path) == 0 {
+		path = config.UserFilePath()
+	}
+	if err := config.SaveFile(path, configFile); err != nil {
+		return fmt.Errorf("Cannot save configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *contextCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// testCoverage tells how many of the operations defined by a service were exercised by a test.
+type testCoverage struct {
+	Defined   int     `json:"defined" yaml:"defined"`
+	Exercised int     `json:"exercised" yaml:"exercised"`
+	Rate      float64 `json:"rate" yaml:"rate"`
+	// Missing are the operations of the service the test has no result for.
+	Missing []string `json:"missing" yaml:"missing"`
+}
+
+// applyCoverage computes the rate of the operations of a completed test among the ones defined by its
+// service, making it fail if this rate is below minimum, 0 only reporting it. Tests whose operations are
+// unknown, or of services without operations, get no coverage.
+func (r *testResult) applyCoverage(operations []connectors.Operation, minimum float64) {
+	if r.InProgress || r.details == nil || len(operations) == 0 {
+		return
+	}
+	tested := map[string]bool{}
+	for _, testCase := range r.details.TestCaseResults {
+		tested[testCase.OperationName] = true
+	}
+	coverage := &testCoverage{Defined: len(operations), Missing: []string{}}
+	for _, operation := range operations {
+		if tested[operation.Name] {
+			coverage.Exercised++
+		} else {
+			coverage.Missing = append(coverage.Missing, operation.Name)
+		}
+	}
+	coverage.Rate = float64(coverage.Exercised) / float64(coverage.Defined)
+	r.Coverage, r.minCoverage = coverage, minimum
+	if coverage.Rate < minimum {
+		r.Success = false
+	}
+}
+
+// coverageFailed tells the test exercised less operations than required.
+func (r *testResult) coverageFailed() bool {
+	return r.Coverage != nil && r.Coverage.Rate < r.minCoverage
+}
+
+// renderCoverage writes the rate of the operations of the service exercised by the test, listing the
+// missing ones.
+func (r *testResult) renderCoverage(w io.Writer) {
+	if r.Coverage == nil {
+		return
+	}
+	line := fmt.Sprintf("%d of %d operations exercised, %.1f%% coverage", r.Coverage.Exercised, r.Coverage.Defined, r.Coverage.Rate*100)
+	if r.minCoverage > 0 {
+		line += fmt.Sprintf(" (%.1f%% required)", r.minCoverage*100)
+	}
+	styles := output.Styles(w)
+	if r.coverageFailed() {
+		line = styles.Verdict(false, line)
+	}
+	fmt.Fprintln(w, line)
+	for _, name := range r.Coverage.Missing {
+		fmt.Fprintf(w, "  %s %s\n", styles.Warning("MISS"), styles.Bold(name))
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/openapidiff"
+	"github.com/microcks/microcks-cli/pkg/output"
+	"gopkg.in/yaml.v3"
+)
+
+// artifactDiff is the comparison of a local artifact with the contract stored by Microcks for the
+// same service and version.
+type artifactDiff struct {
+	File    string `json:"file" yaml:"file"`
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+	// Note tells why the artifact was not compared, if so.
+	Note        string               `json:"note,omitempty" yaml:"note,omitempty"`
+	Breaking    []openapidiff.Change `json:"breaking,omitempty" yaml:"breaking,omitempty"`
+	NonBreaking []openapidiff.Change `json:"nonBreaking,omitempty" yaml:"nonBreaking,omitempty"`
+}
+
+// diffArtifact compares the OpenAPI artifact with the OpenAPI contract stored by Microcks for the
+// service it defines. Other artifacts, and services not imported yet, are reported with a note.
+func diffArtifact(ctx context.Context, mc connectors.MicrocksClient, artifact artifactFile) (artifactDiff, error) {
+	diff := artifactDiff{File: artifact.location()}
+	content, err := artifact.read()
+	if err != nil {
+		return diff, fmt.Errorf("Cannot read artifact %s: %w", artifact.path, err)
+	}
+	if !openapidiff.IsOpenAPI(content) {
+		diff.Note = "diff not supported for this artifact type"
+		return diff, nil
+	}
+	var doc struct {
+		Info struct {
+			Title   string `yaml:"title"`
+			Version string `yaml:"version"`
+		} `yaml:"info"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil || len(doc.Info.Title) == 0 || len(doc.Info.Version) == 0 {
+		diff.Note = "no info title and version telling the service"
+		return diff, nil
+	}
+	diff.Service = doc.Info.Title + ":" + doc.Info.Version
+
+	service, err := mc.GetServiceByRef(ctx, doc.Info.Title, doc.Info.Version)
+	if errors.Is(err, connectors.ErrNotFound) {
+		diff.Note = "service not imported yet, nothing to compare with"
+		return diff, nil
+	}
+	if err != nil {
+		return diff, serviceError("Got error when invoking Microcks client getting Service", diff.Service, err)
+	}
+	resources, err := mc.GetServiceResources(ctx, service.ID)
+	if err != nil {
+		return diff, serviceError("Got error when invoking Microcks client getting Service resources", diff.Service, err)
+	}
+	var stored *connectors.Resource
+	for i, resource := range resources {
+		if resource.Type == connectors.ResourceTypeOpenAPISpec {
+			stored = &resources[i]
+			break
+		}
+	}
+	if stored == nil {
+		diff.Note = "no OpenAPI contract stored by Microcks for this service"
+		return diff, nil
+	}
+
+	report, err := openapidiff.Compare([]byte(stored.Content), content)
+	if err != nil {
+		return diff, fmt.Errorf("Cannot compare artifact %s with contract of %s: %w", artifact.path, diff.Service, err)
+	}
+	diff.Breaking, diff.NonBreaking = report.Breaking(), report.NonBreaking()
+	return diff, nil
+}
+
+// renderDiffs writes the changes of diffs, by category.
+func renderDiffs(w io.Writer, diffs []artifactDiff) {
+	styles := output.Styles(w)
+	for _, diff := range diffs {
+		if len(diff.Note) > 0 {
+			fmt.Fprintf(w, "Diff of %s: %s\n", diff.File, diff.Note)
+			continue
+		}
+		if len(diff.Breaking) == 0 && len(diff.NonBreaking) == 0 {
+			fmt.Fprintf(w, "Diff of %s with '%s' on Microcks: %s\n", diff.File, styles.Bold(diff.Service), styles.Verdict(true, "no changes"))
+			continue
+		}
+		fmt.Fprintf(w, "Diff of %s with '%s' on Microcks:\n", diff.File, styles.Bold(diff.Service))
+		if len(diff.Breaking) > 0 {
+			fmt.Fprintln(w, styles.Verdict(false, fmt.Sprintf("  Breaking changes (%d):", len(diff.Breaking))))
+			for _, change := range diff.Breaking {
+				fmt.Fprintf(w, "    - %-22s %s: %s\n", change.Kind, change.Location, change.Message)
+			}
+		}
+		if len(diff.NonBreaking) > 0 {
+			fmt.Fprintf(w, "  Non-breaking changes (%d):\n", len(diff.NonBreaking))
+			for _, change := range diff.NonBreaking {
+				fmt.Fprintf(w, "    - %-22s %s: %s\n", change.Kind, change.Location, change.Message)
+			}
+		}
+	}
+}
+
+// breakingChanges returns the number of breaking changes of diffs.
+func breakingChanges(diffs []artifactDiff) int {
+	count := 0
+	for _, diff := range diffs {
+		count += len(diff.Breaking)
+	}
+	return count
+}
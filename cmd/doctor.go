@@ -0,0 +1,320 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/connectors/keycloak"
+	"github.com/microcks/microcks-cli/version"
+)
+
+var doctorUsage = usage{
+	name:        "doctor",
+	synopsis:    "doctor [flags]",
+	description: "Diagnose connectivity, TLS and authentication problems with the configured Microcks instance.",
+	examples: []string{
+		"microcks-cli doctor",
+		"microcks-cli doctor --context=staging --output=json > doctor.json",
+	},
+}
+
+// doctorDialTimeout bounds network checks of doctor command.
+const doctorDialTimeout = 5 * time.Second
+
+// Status of a doctor check.
+const (
+	checkPassed  = "pass"
+	checkFailed  = "fail"
+	checkSkipped = "skip"
+)
+
+type doctorCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+}
+
+// NewDoctorCommand build a new DoctorCommand implementation
+func NewDoctorCommand() Command {
+	c := new(doctorCommand)
+	c.fs = newFlagSet(doctorUsage)
+	c.cf.register(c.fs)
+	return c
+}
+
+func (c *doctorCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of doctorCommand structure
+func (c *doctorCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if err := doctorUsage.checkArgs(args); err != nil {
+		return err
+	}
+	cf := &c.cf
+	cf.setup(stdout, stderr)
+	// Credentials are not mandatory here: their absence is diagnosed by token check.
+	if len(cf.microcksURL) == 0 {
+		return usageErrorf("--microcksURL flag is mandatory. Check Usage.")
+	}
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	// Only diagnose the first instance of a failover list.
+	microcksURL := strings.TrimSpace(strings.Split(cf.microcksURL, ",")[0])
+	result := c.diagnose(ctx, microcksURL)
+	if err := cf.render(result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return &ReportedError{Err: fmt.Errorf("Diagnosis of %s found problems", microcksURL)}
+	}
+	return nil
+}
+
+// diagnose runs checks in sequence, skipping those depending on a failed one.
+func (c *doctorCommand) diagnose(ctx context.Context, microcksURL string) *doctorResult {
+	result := &doctorResult{MicrocksURL: microcksURL, Success: true, RequestID: config.RequestID}
+	failed := false
+	run := func(name string, check func() doctorCheck) {
+		if failed {
+			result.Checks = append(result.Checks, doctorCheck{Name: name, Status: checkSkipped, Detail: "a previous check failed"})
+			return
+		}
+		outcome := check()
+		outcome.Name = name
+		if outcome.Status == checkFailed {
+			failed = true
+			result.Success = false
+		}
+		result.Checks = append(result.Checks, outcome)
+	}
+
+	u, err := url.Parse(microcksURL)
+	if err != nil || len(u.Hostname()) == 0 {
+		result.Success = false
+		result.Checks = append(result.Checks, doctorCheck{Name: "url", Status: checkFailed, Detail: fmt.Sprintf("invalid URL '%s'", microcksURL),
+			Hint: "--microcksURL should look like https://microcks.example.com/api/"})
+		return result
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	address := net.JoinHostPort(u.Hostname(), port)
+
+	run("dns", func() doctorCheck { return checkDNS(ctx, u.Hostname()) })
+	run("tcp", func() doctorCheck { return checkTCP(ctx, address) })
+	tlsOptions := c.cf.tlsOptions()
+	run("tls", func() doctorCheck { return checkTLS(ctx, u, address, tlsOptions) })
+	run("api", func() doctorCheck { return checkAPI(ctx, u, tlsOptions) })
+
+	cfg := c.cf.connectorsConfig()
+	var kc *keycloak.Config
+	run("keycloak-config", func() doctorCheck {
+		kc, err = keycloak.DiscoverConfig(ctx, microcksURL, keycloak.WithClientConfig(cfg))
+		if err != nil {
+			return doctorCheck{Status: checkFailed, Detail: err.Error(),
+				Hint: "Microcks did not return its Keycloak configuration: check --microcksURL points to the API (usually ending with /api/)"}
+		}
+		if !kc.Enabled {
+			return doctorCheck{Status: checkPassed, Detail: "Keycloak is disabled, no authentication needed"}
+		}
+		return doctorCheck{Status: checkPassed, Detail: "Keycloak realm at " + kc.RealmURL()}
+	})
+	var provider connectors.AuthProvider = connectors.StaticToken("unauthentifed-token")
+	run("token", func() doctorCheck {
+		if !kc.Enabled {
+			return doctorCheck{Status: checkSkipped, Detail: "Keycloak is disabled"}
+		}
+		if len(c.cf.keycloakClientID) == 0 || (len(c.cf.keycloakClientSecret) == 0 && len(c.cf.clientAssertionKey) == 0) {
+			return doctorCheck{Status: checkFailed, Detail: "missing Keycloak credentials",
+				Hint: "set --keycloakClientId and --keycloakClientSecret (or --clientAssertionKey) with the service account of Microcks realm"}
+		}
+		opts, err := c.cf.keycloakOptions(cfg)
+		if err != nil {
+			return doctorCheck{Status: checkFailed, Detail: err.Error(),
+				Hint: "check --clientAssertionKey is a PEM encoded RSA or ECDSA private key"}
+		}
+		provider = keycloak.NewClientCredentials(kc.RealmURL(), c.cf.keycloakClientID, c.cf.keycloakClientSecret, opts...)
+		if _, err := provider.Token(ctx); err != nil {
+			hint := "check --keycloakClientId and --keycloakClientSecret match a service account of Microcks realm"
+			if connectors.IsConnectionError(err) {
+				hint = fmt.Sprintf("Keycloak URL advertised by Microcks (%s) is not reachable from here: check Microcks Keycloak configuration or your network", kc.RealmURL())
+			}
+			return doctorCheck{Status: checkFailed, Detail: err.Error(), Hint: hint}
+		}
+		return doctorCheck{Status: checkPassed, Detail: "token acquired for " + c.cf.keycloakClientID}
+	})
+	run("authenticated-call", func() doctorCheck {
+		mc := connectors.NewMicrocksClient(microcksURL, connectors.WithConfig(cfg), connectors.WithAuthProvider(provider))
+		services, err := mc.ListServices(ctx, connectors.ListOptions{})
+		if err != nil {
+			hint := ""
+			if errors.Is(err, connectors.ErrUnauthorized) || errors.Is(err, connectors.ErrForbidden) {
+				hint = "token was refused: check the service account has the roles required by Microcks (eg. manager)"
+			}
+			return doctorCheck{Status: checkFailed, Detail: err.Error(), Hint: hint}
+		}
+		count := services.Total
+		if count < 0 {
+			count = len(services.Items)
+		}
+		return doctorCheck{Status: checkPassed, Detail: fmt.Sprintf("%d service(s) visible", count)}
+	})
+	return result
+}
+
+func checkDNS(ctx context.Context, host string) doctorCheck {
+	if net.ParseIP(host) != nil {
+		return doctorCheck{Status: checkSkipped, Detail: host + " is an IP address"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, doctorDialTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return doctorCheck{Status: checkFailed, Detail: err.Error(),
+			Hint: fmt.Sprintf("'%s' cannot be resolved: check the host of --microcksURL and your DNS or VPN", host)}
+	}
+	return doctorCheck{Status: checkPassed, Detail: host + " resolves to " + strings.Join(addresses, ", ")}
+}
+
+func checkTCP(ctx context.Context, address string) doctorCheck {
+	dialer := net.Dialer{Timeout: doctorDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return doctorCheck{Status: checkFailed, Detail: err.Error(),
+			Hint: fmt.Sprintf("cannot connect to %s: check the port of --microcksURL, firewalls and proxies", address)}
+	}
+	conn.Close()
+	return doctorCheck{Status: checkPassed, Detail: "connected to " + address}
+}
+
+// checkTLS performs a handshake without verification to report the served chain, then verifies it
+// against the CA pool used by other commands.
+func checkTLS(ctx context.Context, u *url.URL, address string, tlsOptions config.TLSOptions) doctorCheck {
+	if u.Scheme != "https" {
+		return doctorCheck{Status: checkSkipped, Detail: "plain HTTP"}
+	}
+	tlsConfig := tlsOptions.TLSConfig()
+	probeConfig := tlsConfig.Clone()
+	probeConfig.InsecureSkipVerify = true
+	probeConfig.ServerName = u.Hostname()
+
+	dialer := tls.Dialer{NetDialer: &net.Dialer{Timeout: doctorDialTimeout}, Config: probeConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return doctorCheck{Status: checkFailed, Detail: err.Error(),
+			Hint: "TLS handshake failed: check --tls-min-version and --tls-ciphers match what the server supports"}
+	}
+	state := conn.(*tls.Conn).ConnectionState()
+	conn.Close()
+
+	check := doctorCheck{Status: checkPassed, Detail: "negotiated " + tls.VersionName(state.Version)}
+	for _, cert := range state.PeerCertificates {
+		check.Certificates = append(check.Certificates, doctorCertificate{
+			Subject: cert.Subject.String(), Issuer: cert.Issuer.String(), NotAfter: cert.NotAfter.Format(time.RFC3339)})
+	}
+	if len(state.PeerCertificates) == 0 {
+		return check
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       u.Hostname(),
+		Roots:         tlsConfig.RootCAs,
+		Intermediates: intermediates,
+	})
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case err == nil:
+		check.Detail += ", certificate trusted"
+	case tlsOptions.Insecure:
+		check.Detail += ", certificate not trusted but verification is disabled by --insecure"
+	case errors.As(err, &unknownAuthority):
+		check.Status, check.Detail = checkFailed, err.Error()
+		check.Hint = "server certificate signed by unknown authority: consider --caCerts with the CA certificate"
+	case errors.As(err, &hostnameErr):
+		check.Status, check.Detail = checkFailed, err.Error()
+		check.Hint = fmt.Sprintf("server certificate is not valid for '%s': check the host of --microcksURL", u.Hostname())
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		check.Status, check.Detail = checkFailed, err.Error()
+		check.Hint = "server certificate has expired or is not yet valid: renew it or check the clock of this machine"
+	default:
+		check.Status, check.Detail = checkFailed, err.Error()
+	}
+	return check
+}
+
+// checkAPI checks an HTTP answer is received from Microcks URL, whatever its status.
+func checkAPI(ctx context.Context, u *url.URL, tlsOptions config.TLSOptions) doctorCheck {
+	ctx, cancel := context.WithTimeout(ctx, doctorDialTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return doctorCheck{Status: checkFailed, Detail: err.Error()}
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	config.SetRequestIDHeader(req)
+
+	client := http.DefaultClient
+	if tlsOptions.IsCustom() {
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: tlsOptions.TLSConfig()}}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return doctorCheck{Status: checkFailed, Detail: err.Error(),
+			Hint: "no HTTP answer: check proxies (HTTPS_PROXY, NO_PROXY) between this machine and Microcks"}
+	}
+	resp.Body.Close()
+	return doctorCheck{Status: checkPassed, Detail: "HTTP " + resp.Status}
+}
+
+func (c *doctorCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
@@ -0,0 +1,263 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// Codes of machine-readable errors. They are part of the CLI contract: never change or remove one.
+const (
+	errorCodeUsage            = "usage"
+	errorCodeAuthFailed       = "auth_failed"
+	errorCodeConnectionFailed = "connection_failed"
+	errorCodeNotFound         = "not_found"
+	errorCodeConflict         = "conflict"
+	errorCodeServerError      = "server_error"
+	errorCodeTestFailed       = "test_failed"
+	errorCodeTestTimeout      = "test_timeout"
+	errorCodeTimeout          = "timeout"
+	errorCodeRunFailed        = "run_failed"
+	errorCodePluginFailed     = "plugin_failed"
+	errorCodeUnsupported      = "unsupported_server"
+	errorCodeBreakingChanges  = "breaking_changes"
+	errorCodeInvalidArtifacts = "invalid_artifacts"
+	errorCodeError            = "error"
+)
+
+var (
+	// jsonErrors tells if a machine-readable error object must be written on failure (set by --errors or --output).
+	jsonErrors = false
+	// errorsOutput is where machine-readable error objects are written.
+	errorsOutput io.Writer = os.Stderr
+)
+
+// errorObject is the machine-readable description of a command failure.
+type errorObject struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Status    int    `json:"status,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// UsageError reports a command invoked with missing, unexpected or invalid arguments or flags.
+type UsageError struct {
+	msg string
+}
+
+func (e *UsageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &UsageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// TestFailedError reports a test that completed without success. Its result has already been rendered.
+type TestFailedError struct {
+	TestResultID string
+}
+
+func (e *TestFailedError) Error() string {
+	return fmt.Sprintf("test %s did not succeed", e.TestResultID)
+}
+
+// TestTimeoutError reports a test still in progress once its --waitFor elapsed. Its result has already
+// been rendered.
+type TestTimeoutError struct {
+	TestResultID string
+}
+
+func (e *TestTimeoutError) Error() string {
+	return fmt.Sprintf("test %s is still in progress", e.TestResultID)
+}
+
+// RunFailedError reports a run having failed steps. Its summary has already been rendered.
+type RunFailedError struct{}
+
+func (e *RunFailedError) Error() string {
+	return "run has failed steps"
+}
+
+// BreakingChangesError reports artifacts not imported because they break the contracts stored by
+// Microcks. Their changes have already been rendered.
+type BreakingChangesError struct {
+	Count int
+}
+
+func (e *BreakingChangesError) Error() string {
+	return fmt.Sprintf("%d breaking changes found, nothing imported", e.Count)
+}
+
+// InvalidArtifactsError reports artifacts that import --dry-run found Microcks would reject. They have
+// already been rendered.
+type InvalidArtifactsError struct {
+	Count int
+}
+
+func (e *InvalidArtifactsError) Error() string {
+	return fmt.Sprintf("%d invalid artifact(s) found", e.Count)
+}
+
+// UnsupportedServerError reports a command needing a capability the Microcks server does not provide.
+type UnsupportedServerError struct {
+	msg string
+}
+
+func (e *UnsupportedServerError) Error() string {
+	return e.msg
+}
+
+// ReportedError wraps an error that has already been reported to the user, such as flag parsing
+// errors printed along with command usage.
+type ReportedError struct {
+	Err error
+}
+
+func (e *ReportedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReportedError) Unwrap() error {
+	return e.Err
+}
+
+// ReportError writes the message of an error returned by a command, unless it has already been reported.
+// In JSON errors mode, a final error object is also written on stderr.
+func ReportError(err error) {
+	var reported *ReportedError
+	var failed *TestFailedError
+	var timedOut *TestTimeoutError
+	var runFailed *RunFailedError
+	if !errors.As(err, &reported) && !errors.As(err, &failed) && !errors.As(err, &timedOut) && !errors.As(err, &runFailed) {
+		console.Errorln(err.Error())
+	}
+	if jsonErrors {
+		json.NewEncoder(errorsOutput).Encode(struct {
+			Error errorObject `json:"error"`
+		}{newErrorObject(err)})
+	}
+}
+
+// newErrorObject categorizes err using the types of errors returned by commands and connectors.
+func newErrorObject(err error) errorObject {
+	obj := errorObject{Code: errorCodeError, Message: err.Error(), RequestID: config.RequestID}
+
+	var usageErr *UsageError
+	var testErr *TestFailedError
+	var timeoutErr *TestTimeoutError
+	var runErr *RunFailedError
+	var authErr *connectors.AuthError
+	var apiErr *connectors.APIError
+	var pluginErr *output.PluginError
+	var unsupportedErr *UnsupportedServerError
+	var breakingErr *BreakingChangesError
+	var invalidErr *InvalidArtifactsError
+	if errors.As(err, &apiErr) {
+		obj.Status = apiErr.StatusCode
+	}
+	switch {
+	case errors.As(err, &usageErr):
+		obj.Code = errorCodeUsage
+	case errors.As(err, &testErr):
+		obj.Code = errorCodeTestFailed
+	case errors.As(err, &timeoutErr):
+		obj.Code = errorCodeTestTimeout
+	case errors.As(err, &runErr):
+		obj.Code = errorCodeRunFailed
+	case errors.As(err, &pluginErr):
+		obj.Code = errorCodePluginFailed
+	case errors.As(err, &unsupportedErr):
+		obj.Code = errorCodeUnsupported
+	case errors.As(err, &breakingErr):
+		obj.Code = errorCodeBreakingChanges
+	case errors.As(err, &invalidErr):
+		obj.Code = errorCodeInvalidArtifacts
+	case errors.As(err, &authErr):
+		obj.Code = errorCodeAuthFailed
+	case errors.Is(err, connectors.ErrUnauthorized) || errors.Is(err, connectors.ErrForbidden):
+		obj.Code = errorCodeAuthFailed
+	case errors.Is(err, connectors.ErrNotFound):
+		obj.Code = errorCodeNotFound
+	case errors.Is(err, connectors.ErrConflict):
+		obj.Code = errorCodeConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		obj.Code = errorCodeTimeout
+	case apiErr != nil:
+		obj.Code = errorCodeServerError
+	case connectors.IsConnectionError(err):
+		obj.Code = errorCodeConnectionFailed
+	}
+	return obj
+}
+
+// requestError wraps an error returned by Microcks or Keycloak clients, decorating it with the current run ID.
+// Refused credentials are described with a hint instead of the raw response.
+func requestError(msg string, err error) error {
+	return fmt.Errorf("%s: %w [%s: %s]", msg, describeAPIError(err), config.RequestIDHeader, config.RequestID)
+}
+
+// serviceError wraps an error returned by a client request about service serviceRef, describing
+// not found responses as an unknown service.
+func serviceError(msg string, serviceRef string, err error) error {
+	if errors.Is(err, connectors.ErrNotFound) {
+		err = &describedError{msg: fmt.Sprintf("service '%s' not found", serviceRef), err: err}
+	}
+	return requestError(msg, err)
+}
+
+// describedError replaces the message of an error, keeping it available to errors.Is and errors.As.
+type describedError struct {
+	msg string
+	err error
+}
+
+func (e *describedError) Error() string {
+	return e.msg
+}
+
+func (e *describedError) Unwrap() error {
+	return e.err
+}
+
+// describeAPIError returns err with a friendlier message if it reports refused credentials or access.
+func describeAPIError(err error) error {
+	var described *describedError
+	var apiErr *connectors.APIError
+	if errors.As(err, &described) || !errors.As(err, &apiErr) {
+		return err
+	}
+	reason := ""
+	if len(apiErr.Message) > 0 {
+		reason = " (" + apiErr.Message + ")"
+	}
+	switch {
+	case errors.Is(err, connectors.ErrUnauthorized):
+		return &describedError{msg: fmt.Sprintf("credentials were refused by %s%s: check --keycloakClientId and --keycloakClientSecret", apiErr.Name, reason), err: err}
+	case errors.Is(err, connectors.ErrForbidden):
+		return &describedError{msg: fmt.Sprintf("access was denied by %s%s: check the roles of the Keycloak service account", apiErr.Name, reason), err: err}
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+func TestRequestErrorFormatting(t *testing.T) {
+	requestID, requestIDHeader := config.RequestID, config.RequestIDHeader
+	defer func() { config.RequestID, config.RequestIDHeader = requestID, requestIDHeader }()
+	config.RequestID, config.RequestIDHeader = "run-1", "X-Request-Id"
+	apiError := func(status int, body string, message string) *connectors.APIError {
+		return &connectors.APIError{Name: "Microcks for getting service", Method: "GET", Path: "/api/services/Beer:0.9",
+			StatusCode: status, Body: body, Message: message}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+		// wantCode and wantStatus are the ones of the JSON error object, whose message is want.
+		wantCode   string
+		wantStatus int
+	}{
+		{
+			name:       "unauthorized",
+			err:        requestError("Got error", apiError(401, `{"error": "invalid_client"}`, "invalid_client")),
+			want:       "Got error: credentials were refused by Microcks for getting service (invalid_client): check --keycloakClientId and --keycloakClientSecret [X-Request-Id: run-1]",
+			wantCode:   errorCodeAuthFailed,
+			wantStatus: 401,
+		},
+		{
+			name:       "forbidden",
+			err:        requestError("Got error", apiError(403, "", "")),
+			want:       "Got error: access was denied by Microcks for getting service: check the roles of the Keycloak service account [X-Request-Id: run-1]",
+			wantCode:   errorCodeAuthFailed,
+			wantStatus: 403,
+		},
+		{
+			name:       "not found service",
+			err:        serviceError("Got error", "Beer:0.9", apiError(404, "", "")),
+			want:       "Got error: service 'Beer:0.9' not found [X-Request-Id: run-1]",
+			wantCode:   errorCodeNotFound,
+			wantStatus: 404,
+		},
+		{
+			name:       "not found",
+			err:        requestError("Got error", apiError(404, "", "")),
+			want:       "Got error: unexpected status 404 from Microcks for getting service (GET /api/services/Beer:0.9) [X-Request-Id: run-1]",
+			wantCode:   errorCodeNotFound,
+			wantStatus: 404,
+		},
+		{
+			name:       "conflict",
+			err:        requestError("Got error", apiError(409, `{"message": "Service already exists"}`, "Service already exists")),
+			want:       "Got error: unexpected status 409 from Microcks for getting service (GET /api/services/Beer:0.9): Service already exists [X-Request-Id: run-1]",
+			wantCode:   errorCodeConflict,
+			wantStatus: 409,
+		},
+		{
+			name:       "server error with JSON message",
+			err:        requestError("Got error", apiError(500, `{"status": 500, "message": "NullPointerException"}`, "NullPointerException")),
+			want:       "Got error: unexpected status 500 from Microcks for getting service (GET /api/services/Beer:0.9): NullPointerException [X-Request-Id: run-1]",
+			wantCode:   errorCodeServerError,
+			wantStatus: 500,
+		},
+		{
+			name:       "server error with plain text",
+			err:        requestError("Got error", apiError(503, "Service Unavailable", "")),
+			want:       "Got error: unexpected status 503 from Microcks for getting service (GET /api/services/Beer:0.9): Service Unavailable [X-Request-Id: run-1]",
+			wantCode:   errorCodeServerError,
+			wantStatus: 503,
+		},
+		{
+			name:       "wrapped",
+			err:        fmt.Errorf("Cannot import beer.yaml: %w", requestError("Got error", fmt.Errorf("upload: %w", apiError(401, "", "")))),
+			want:       "Cannot import beer.yaml: Got error: credentials were refused by Microcks for getting service: check --keycloakClientId and --keycloakClientSecret [X-Request-Id: run-1]",
+			wantCode:   errorCodeAuthFailed,
+			wantStatus: 401,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.err.Error() != test.want {
+				t.Errorf("message = %s, want %s", test.err.Error(), test.want)
+			}
+			want := errorObject{Code: test.wantCode, Message: test.want, Status: test.wantStatus, RequestID: "run-1"}
+			if got := newErrorObject(test.err); got != want {
+				t.Errorf("error object = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
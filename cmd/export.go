@@ -0,0 +1,250 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// defaultSnapshotFile is the file snapshots are written to by default, named like the ones Microcks
+// exports from its UI.
+const defaultSnapshotFile = "microcks-repository.json"
+
+var exportUsage = usage{
+	name:        "export",
+	synopsis:    "export <apiName:apiVersion1,apiName:apiVersion2> [flags]",
+	description: "Export services of Microcks server as a repository snapshot, to back them up or import them in another instance.",
+	args: [][2]string{
+		{"<apiName:apiVersion1,apiName:apiVersion2>", "Comma separated services to export. Exemple: 'Beer Catalog API:0.9,Pastry API:2.0'"},
+	},
+	examples: []string{
+		"microcks-cli export 'Beer Catalog API:0.9,API Pastry:2.0' --file=snapshot.json \\\n" +
+			"    --microcksURL=http://localhost:8080/api/ \\\n" +
+			"    --keycloakClientId=microcks-serviceaccount --keycloakClientSecret=<secret>",
+		"microcks-cli export 'API Pastry:2.0' -f - --microcksURL=http://localhost:8080/api/ | gzip > pastry.json.gz",
+		"microcks-cli export 'Beer Catalog API:0.9,API Pastry:2.0' --split --output-dir=./backup \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+	},
+}
+
+type exportCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	file      string
+	split     bool
+	outputDir string
+}
+
+// NewExportCommand build a new ExportCommand implementation
+func NewExportCommand() Command {
+	c := new(exportCommand)
+	c.fs = newFlagSet(exportUsage)
+	c.cf.register(c.fs)
+	// --output already chooses the format of results, the snapshot file has its own flag.
+	c.fs.StringVar(&c.file, "f", defaultSnapshotFile, "Path of the snapshot file to write, '-' writing it on stdout instead of the result (--output keeps choosing the result format)")
+	c.fs.StringVar(&c.file, "file", defaultSnapshotFile, "Path of the snapshot file to write (alias of -f)")
+	c.fs.BoolVar(&c.split, "split", false, "Write the snapshot of each service in its own <name>-<version>.json file of --output-dir, with an index.json listing them with their checksums")
+	c.fs.StringVar(&c.outputDir, "output-dir", "", "Directory where to write the files of --split (default to current directory)")
+	return c
+}
+
+func (c *exportCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of exportCommand structure
+func (c *exportCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if err := exportUsage.checkArgs(args); err != nil {
+		return err
+	}
+	var serviceRefs []string
+	seen := map[string]bool{}
+	for _, serviceRef := range splitList(args[0]) {
+		if !strings.Contains(serviceRef, ":") {
+			return usageErrorf("Service reference '%s' should be <apiName:apiVersion>", serviceRef)
+		}
+		if !seen[serviceRef] {
+			seen[serviceRef] = true
+			serviceRefs = append(serviceRefs, serviceRef)
+		}
+	}
+	if len(serviceRefs) == 0 {
+		return usageErrorf("export command require at least one <apiName:apiVersion> service. Check Usage.")
+	}
+	if len(c.file) == 0 {
+		return usageErrorf("--file flag cannot be empty, use '-' to write the snapshot on stdout")
+	}
+	// The default value may also be given explicitly, only flags of the command line are checked.
+	fileSet := false
+	c.fs.Visit(func(f *flag.Flag) {
+		if (f.Name == "f" || f.Name == "file") && settingSources[f.Name] == "flag" {
+			fileSet = true
+		}
+	})
+	if c.split && fileSet {
+		return usageErrorf("--file flag cannot be used with --split, that writes files in --output-dir")
+	}
+	if !c.split && len(c.outputDir) > 0 {
+		return usageErrorf("--output-dir flag is only for --split")
+	}
+
+	cf := &c.cf
+	// A snapshot written on stdout must not be mixed with progress messages, they go to stderr.
+	if c.file == "-" {
+		cf.setup(stderr, stderr)
+	} else {
+		cf.setup(stdout, stderr)
+	}
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.split {
+		dir := c.outputDir
+		if len(dir) == 0 {
+			dir = "."
+		}
+		size, err := splitSnapshot(ctx, mc, serviceRefs, dir)
+		if err != nil {
+			return err
+		}
+		return cf.render(&exportResult{File: filepath.Join(dir, snapshotIndexFile), Services: serviceRefs, Size: size, RequestID: config.RequestID})
+	}
+	size, err := exportServices(ctx, mc, serviceRefs, c.file, stdout)
+	if err != nil || c.file == "-" {
+		return err
+	}
+	return cf.render(&exportResult{File: c.file, Services: serviceRefs, Size: size, RequestID: config.RequestID})
+}
+
+// exportServices writes the snapshot of services at file, or on stdout for '-', and returns its size. A
+// failure while streaming the snapshot is returned even if part of it has already been written.
+func exportServices(ctx context.Context, mc connectors.MicrocksClient, serviceRefs []string, file string, stdout io.Writer) (int64, error) {
+	serviceIDs, err := resolveServiceIDs(ctx, mc, serviceRefs)
+	if err != nil {
+		return 0, err
+	}
+
+	export := func(w io.Writer) (int64, error) {
+		size, err := mc.ExportServices(ctx, serviceIDs, w)
+		if err != nil {
+			return size, requestError("Got error when invoking Microcks client exporting services", err)
+		}
+		return size, nil
+	}
+	if file == "-" {
+		return export(stdout)
+	}
+	return writeSnapshot(file, export)
+}
+
+// resolveServiceIDs returns the identifiers of the services of serviceRefs, in the same order.
+func resolveServiceIDs(ctx context.Context, mc connectors.MicrocksClient, serviceRefs []string) ([]string, error) {
+	serviceIDs := make([]string, len(serviceRefs))
+	for i, serviceRef := range serviceRefs {
+		sep := strings.LastIndex(serviceRef, ":")
+		service, err := mc.GetServiceByRef(ctx, serviceRef[:sep], serviceRef[sep+1:])
+		if err != nil {
+			return nil, serviceError("Got error when invoking Microcks client getting Service", serviceRef, err)
+		}
+		serviceIDs[i] = service.ID
+	}
+	return serviceIDs, nil
+}
+
+// writeSnapshot writes the snapshot copied by export at path, replacing it only once complete, and returns
+// its size. Errors of export are returned as is.
+func writeSnapshot(path string, export func(w io.Writer) (int64, error)) (int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("Cannot write snapshot %s: %w", path, err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size, err := export(file)
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("Cannot write snapshot %s: %w", path, err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return 0, fmt.Errorf("Cannot write snapshot %s: %w", path, err)
+	}
+	return size, nil
+}
+
+// exportResult is the outcome of export command.
+type exportResult struct {
+	File      string   `json:"file" yaml:"file"`
+	Services  []string `json:"services" yaml:"services"`
+	Size      int64    `json:"size" yaml:"size"`
+	RequestID string   `json:"requestId" yaml:"requestId"`
+}
+
+// RenderText implements output.TextRenderer for exportResult.
+func (r *exportResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	services := make([]string, len(r.Services))
+	for i, service := range r.Services {
+		services[i] = "'" + styles.Bold(service) + "'"
+	}
+	fmt.Fprintln(w, styles.Verdict(true, fmt.Sprintf("Exported %d service(s) in %s (%d bytes): %s", len(r.Services), r.File, r.Size, strings.Join(services, ", "))))
+}
+
+// EnvVars implements output.EnvRenderer for exportResult.
+func (r *exportResult) EnvVars() [][2]string {
+	return [][2]string{
+		{"MICROCKS_EXPORT_FILE", r.File},
+		{"MICROCKS_EXPORT_COUNT", strconv.Itoa(len(r.Services))},
+		{"MICROCKS_REQUEST_ID", r.RequestID},
+	}
+}
+
+func (c *exportCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/connectors/testutil"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestExportServicesStreamFailure(t *testing.T) {
+	errStream := errors.New("connection reset by peer")
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"stdout", "-"},
+		{"file", "snapshot.json"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mc := &testutil.MockMicrocksClient{
+				ExportServicesFunc: func(ctx context.Context, serviceIDs []string, w io.Writer) (int64, error) {
+					n, _ := io.WriteString(w, `{"services":[`)
+					return int64(n), errStream
+				},
+			}
+			file := test.file
+			if file != "-" {
+				file = filepath.Join(t.TempDir(), file)
+			}
+
+			var stdout bytes.Buffer
+			_, err := exportServices(context.Background(), mc, []string{"Beer Catalog API:0.9"}, file, &stdout)
+			if !errors.Is(err, errStream) {
+				t.Fatalf("exportServices() error = %v, want %v", err, errStream)
+			}
+			if file != "-" {
+				if _, err := os.Stat(file); !os.IsNotExist(err) {
+					t.Errorf("partial snapshot %s was written", file)
+				}
+				entries, _ := os.ReadDir(filepath.Dir(file))
+				if len(entries) > 0 {
+					t.Errorf("temporary snapshot %s was left", entries[0].Name())
+				}
+			}
+		})
+	}
+}
+
+func TestExportSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		services string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "one service", services: "Beer Catalog API:0.9", want: []string{"Beer Catalog API"}},
+		{name: "several services", services: "Beer Catalog API:0.9,API Pastry:2.0", want: []string{"Beer Catalog API", "API Pastry"}},
+		{name: "unknown service", services: "Pet Store:1.0", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+			srv.AddService(connectors.Service{Name: "API Pastry", Version: "2.0", Type: connectors.ServiceTypeREST})
+
+			file := filepath.Join(t.TempDir(), "snapshot.json")
+			args := []string{test.services, "--file=" + file,
+				"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}
+			var stdout, stderr bytes.Buffer
+			err := NewExportCommand().Execute(context.Background(), args, &stdout, &stderr)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v, stderr: %s", err, test.wantErr, stderr.String())
+			}
+			if test.wantErr {
+				if _, err := os.Stat(file); !os.IsNotExist(err) {
+					t.Errorf("snapshot %s was written", file)
+				}
+				return
+			}
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("snapshot was not written: %v", err)
+			}
+			var snapshot struct {
+				Services []connectors.Service `json:"services"`
+			}
+			if err := json.Unmarshal(content, &snapshot); err != nil {
+				t.Fatalf("invalid snapshot %s: %v", content, err)
+			}
+			var names []string
+			for _, service := range snapshot.Services {
+				names = append(names, service.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("exported services %q, want %q", names, test.want)
+			}
+		})
+	}
+}
+
+func TestExportFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		// split exports services with --split in a temporary --output-dir.
+		split   bool
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "split", split: true},
+		{name: "split with file", split: true, args: []string{"--file=snapshot.json"}, wantErr: "--file flag cannot be used with --split, that writes files in --output-dir"},
+		{name: "split with default file", split: true, args: []string{"--file=" + defaultSnapshotFile}, wantErr: "--file flag cannot be used with --split, that writes files in --output-dir"},
+		{name: "split with short file flag", split: true, args: []string{"-f", defaultSnapshotFile}, wantErr: "--file flag cannot be used with --split, that writes files in --output-dir"},
+		{name: "split with file of environment", split: true, env: map[string]string{"MICROCKS_FILE": "tests.yaml"}},
+		{name: "output dir without split", args: []string{"--output-dir=snapshots"}, wantErr: "--output-dir flag is only for --split"},
+		{name: "empty file", args: []string{"--file="}, wantErr: "--file flag cannot be empty, use '-' to write the snapshot on stdout"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			t.Setenv("MICROCKS_FILE", "")
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+
+			args := []string{"Beer Catalog API:0.9", "--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}
+			if test.split {
+				args = append(args, "--split", "--output-dir="+t.TempDir())
+			}
+			args = append(args, test.args...)
+			var stdout, stderr bytes.Buffer
+			err := NewExportCommand().Execute(context.Background(), args, &stdout, &stderr)
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Execute() error = %v", err)
+				}
+				return
+			}
+			var usageErr *UsageError
+			if !errors.As(err, &usageErr) || err.Error() != test.wantErr {
+				t.Errorf("Execute() error = %v, want usage error %s", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"gopkg.in/yaml.v3"
+)
+
+// skippedArtifact is an artifact not imported with --if-newer, as Microcks already has it.
+type skippedArtifact struct {
+	File    string `json:"file" yaml:"file"`
+	Service string `json:"service" yaml:"service"`
+	Reason  string `json:"reason" yaml:"reason"`
+}
+
+// artifactService returns the service an OpenAPI or AsyncAPI artifact defines, from its info title
+// and version, and the type of the contract Microcks stores for it. ok is false for other artifacts.
+func artifactService(content []byte) (name string, version string, resourceType connectors.ResourceType, ok bool) {
+	var doc struct {
+		OpenAPI  string `yaml:"openapi"`
+		Swagger  string `yaml:"swagger"`
+		AsyncAPI string `yaml:"asyncapi"`
+		Info     struct {
+			Title   string `yaml:"title"`
+			Version string `yaml:"version"`
+		} `yaml:"info"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil || len(doc.Info.Title) == 0 || len(doc.Info.Version) == 0 {
+		return "", "", "", false
+	}
+	switch {
+	case len(doc.OpenAPI) > 0 || len(doc.Swagger) > 0:
+		resourceType = connectors.ResourceTypeOpenAPISpec
+	case len(doc.AsyncAPI) > 0:
+		resourceType = connectors.ResourceTypeAsyncAPISpec
+	default:
+		return "", "", "", false
+	}
+	return doc.Info.Title, doc.Info.Version, resourceType, true
+}
+
+// upToDateArtifact tells if Microcks already has the main artifact: the contract it stores for the
+// service is identical, or the service was updated after the file was modified, artifacts read from
+// stdin having no modification time. It returns the skipped artifact with the reason, nil if the artifact
+// must be imported, including when its service can't be determined or does not exist yet.
+func upToDateArtifact(ctx context.Context, mc connectors.MicrocksClient, artifact artifactFile) (*skippedArtifact, error) {
+	path := artifact.path
+	content, err := artifact.read()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read artifact %s: %w", path, err)
+	}
+	var info os.FileInfo
+	if artifact.content == nil {
+		if info, err = os.Stat(path); err != nil {
+			return nil, fmt.Errorf("Cannot read artifact %s: %w", path, err)
+		}
+	}
+	name, version, resourceType, ok := artifactService(content)
+	if !ok {
+		console.Debugf("Cannot tell the service of artifact %s, importing it", path)
+		return nil, nil
+	}
+	serviceRef := name + ":" + version
+
+	service, err := mc.GetServiceByRef(ctx, name, version)
+	if errors.Is(err, connectors.ErrNotFound) {
+		console.Debugf("Service '%s' of artifact %s not imported yet, importing it", serviceRef, path)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, serviceError("Got error when invoking Microcks client getting Service", serviceRef, err)
+	}
+	resources, err := mc.GetServiceResources(ctx, service.ID)
+	if err != nil {
+		return nil, serviceError("Got error when invoking Microcks client getting Service resources", serviceRef, err)
+	}
+
+	skipped := &skippedArtifact{File: artifact.location(), Service: serviceRef}
+	for _, resource := range resources {
+		if resource.Type == resourceType && resource.Content == string(content) {
+			skipped.Reason = "identical to the contract stored by Microcks"
+			return skipped, nil
+		}
+	}
+	if info != nil && service.Metadata != nil && service.Metadata.LastUpdate > 0 {
+		lastUpdate := time.UnixMilli(service.Metadata.LastUpdate)
+		if !lastUpdate.Before(info.ModTime()) {
+			skipped.Reason = fmt.Sprintf("service updated by Microcks on %s, after the file was modified on %s",
+				lastUpdate.UTC().Format(time.RFC3339), info.ModTime().UTC().Format(time.RFC3339))
+			return skipped, nil
+		}
+	}
+	return nil, nil
+}
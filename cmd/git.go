@@ -0,0 +1,176 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitArtifactPrefix starts the artifacts fetched from Git repositories, like
+// git+https://github.com/org/repo//specs/api.yaml@v1.2.0.
+const gitArtifactPrefix = "git+"
+
+// gitSchemes are the URL schemes of the repositories artifacts can be fetched from.
+var gitSchemes = []string{"https", "http", "ssh", "file"}
+
+// gitCheckout is a repository fetched at a reference, a branch, a tag or a commit, in dir. An empty ref
+// is the default branch.
+type gitCheckout struct {
+	repository string
+	ref        string
+	dir        string
+}
+
+// isGitArtifact tells if path references artifacts of a Git repository.
+func isGitArtifact(path string) bool {
+	return strings.HasPrefix(path, gitArtifactPrefix)
+}
+
+// parseGitArtifact splits the git+<url>[//<path>][@<ref>] reference of artifacts into the URL of the
+// repository, the path of the artifacts in this repository, that may be a directory or a glob pattern,
+// and the reference to fetch. The reference follows the last @ of the path, so a path holding an @ ends
+// with @<ref> or with a bare @ for the default branch.
+func parseGitArtifact(artifact string) (repository string, path string, ref string, err error) {
+	location := strings.TrimPrefix(artifact, gitArtifactPrefix)
+	schemeEnd := strings.Index(location, "://")
+	if schemeEnd < 0 || !contains(gitSchemes, location[:schemeEnd]) {
+		return "", "", "", usageErrorf("Invalid Git artifact '%s', it should be git+<%s>://<repository>[//<path>][@<ref>]", artifact, strings.Join(gitSchemes, "|"))
+	}
+	repository = location
+	rest := location[schemeEnd+3:]
+	if i := strings.Index(rest, "//"); i >= 0 {
+		repository, path = location[:schemeEnd+3+i], rest[i+2:]
+		if i := strings.LastIndex(path, "@"); i >= 0 {
+			path, ref = path[:i], path[i+1:]
+		}
+	} else if i := strings.LastIndex(rest, "@"); strings.Contains(rest, "/") && i > strings.LastIndex(rest, "/") {
+		// Without path, only an @ after the last slash of the URL path is a reference, others introduce users.
+		repository, ref = location[:schemeEnd+3+i], rest[i+1:]
+	}
+	if len(repository) == schemeEnd+3 {
+		return "", "", "", usageErrorf("Invalid Git artifact '%s', it has no repository", artifact)
+	}
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t") {
+		return "", "", "", usageErrorf("Invalid Git artifact '%s', '%s' is not a valid reference", artifact, ref)
+	}
+	path = filepath.Clean(filepath.FromSlash("/" + path))[1:]
+	return repository, path, ref, nil
+}
+
+// fetchGitArtifacts fetches the repositories of Git artifacts, once per repository and reference, and
+// replaces these artifacts with their paths in the fetched working trees. The returned cleanup removes
+// these working trees.
+func fetchGitArtifacts(ctx context.Context, artifacts []artifactFile) ([]artifactFile, func(), error) {
+	checkouts := map[string]*gitCheckout{}
+	cleanup := func() {
+		for _, checkout := range checkouts {
+			os.RemoveAll(checkout.dir)
+		}
+	}
+	fetched := make([]artifactFile, len(artifacts))
+	for i, artifact := range artifacts {
+		fetched[i] = artifact
+		if !isGitArtifact(artifact.path) {
+			continue
+		}
+		repository, path, ref, err := parseGitArtifact(artifact.path)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		checkout, ok := checkouts[repository+"@"+ref]
+		if !ok {
+			checkout = &gitCheckout{repository: repository, ref: ref}
+			if err := checkout.fetch(ctx); err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			checkouts[repository+"@"+ref] = checkout
+		}
+		fetched[i].path = filepath.Join(checkout.dir, path)
+		fetched[i].checkout = checkout
+		if _, err := os.Stat(fetched[i].path); err != nil && !hasGlobMeta(path) {
+			cleanup()
+			return nil, nil, usageErrorf("Cannot import %s: no %s in repository", artifact.path, filepath.ToSlash(path))
+		}
+	}
+	return fetched, cleanup, nil
+}
+
+// fetch makes a shallow clone of the repository at the reference in a temporary directory.
+func (c *gitCheckout) fetch(ctx context.Context) error {
+	ref := c.ref
+	if len(ref) == 0 {
+		ref = "HEAD"
+	}
+	console.Debugf("Fetching %s at %s", c.repository, ref)
+	dir, err := os.MkdirTemp("", "microcks-cli-git-")
+	if err != nil {
+		return err
+	}
+	c.dir = dir
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth=1", "--", c.repository, ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if err := runGit(ctx, dir, args...); err != nil {
+			os.RemoveAll(dir)
+			return fmt.Errorf("Cannot fetch %s at %s: %w", c.repository, ref, err)
+		}
+	}
+	return nil
+}
+
+// location returns the git+<url>//<path>@<ref> reference of the file at path in the working tree.
+func (c *gitCheckout) location(path string) string {
+	rel, err := filepath.Rel(c.dir, path)
+	if err != nil {
+		rel = path
+	}
+	location := gitArtifactPrefix + c.repository + "//" + filepath.ToSlash(rel)
+	if len(c.ref) > 0 {
+		location += "@" + c.ref
+	}
+	return location
+}
+
+// runGit runs git with args in dir, never prompting for credentials, that must come from the SSH agent or
+// the credential helpers configured for git.
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return errors.New("git command is required to import artifacts from Git repositories")
+		}
+		if message := strings.TrimSpace(stderr.String()); len(message) > 0 {
+			return fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGitArtifact(t *testing.T) {
+	tests := []struct {
+		artifact   string
+		repository string
+		path       string
+		ref        string
+	}{
+		{"git+https://github.com/org/repo.git", "https://github.com/org/repo.git", "", ""},
+		{"git+https://github.com/org/repo.git@v1.2.0", "https://github.com/org/repo.git", "", "v1.2.0"},
+		{"git+https://github.com/org/repo.git//specs/api.yaml@main", "https://github.com/org/repo.git", "specs/api.yaml", "main"},
+		{"git+https://github.com/org/repo.git//specs/*.yaml", "https://github.com/org/repo.git", "specs/*.yaml", ""},
+		{"git+https://github.com/org/repo.git//specs/api.yaml@feature/new-api", "https://github.com/org/repo.git", "specs/api.yaml", "feature/new-api"},
+		// Users of URLs are not references.
+		{"git+ssh://git@github.com/org/repo.git", "ssh://git@github.com/org/repo.git", "", ""},
+		{"git+ssh://git@github.com/org/repo.git@v1", "ssh://git@github.com/org/repo.git", "", "v1"},
+		{"git+https://token@example.com", "https://token@example.com", "", ""},
+		{"git+ssh://git@github.com/org/repo.git//api.yaml@v1", "ssh://git@github.com/org/repo.git", "api.yaml", "v1"},
+		// Missing paths are the root of repository.
+		{"git+https://github.com/org/repo.git//", "https://github.com/org/repo.git", "", ""},
+		{"git+https://github.com/org/repo.git//@v1", "https://github.com/org/repo.git", "", "v1"},
+		// The last @ of paths starts the reference, a bare one keeps the default branch.
+		{"git+https://github.com/org/repo.git//specs/@scope/api.yaml@v2", "https://github.com/org/repo.git", "specs/@scope/api.yaml", "v2"},
+		{"git+https://github.com/org/repo.git//specs/@scope/api.yaml@", "https://github.com/org/repo.git", "specs/@scope/api.yaml", ""},
+		// Paths cannot escape the working tree.
+		{"git+file:///srv/repo//../../etc/passwd", "file:///srv/repo", filepath.FromSlash("etc/passwd"), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.artifact, func(t *testing.T) {
+			repository, path, ref, err := parseGitArtifact(test.artifact)
+			if err != nil {
+				t.Fatalf("parseGitArtifact() error = %v", err)
+			}
+			if repository != test.repository || path != filepath.FromSlash(test.path) || ref != test.ref {
+				t.Errorf("parseGitArtifact() = (%q, %q, %q), want (%q, %q, %q)", repository, path, ref, test.repository, test.path, test.ref)
+			}
+		})
+	}
+}
+
+func TestParseGitArtifactErrors(t *testing.T) {
+	tests := []string{
+		// References starting with - would be read as options by git.
+		"git+https://github.com/org/repo.git@--upload-pack=touch",
+		"git+https://github.com/org/repo.git//api.yaml@-b",
+		"git+https://github.com/org/repo.git//api.yaml@main branch",
+		// scp-like and unsupported schemes.
+		"git+git@github.com:org/repo.git",
+		"git+ftp://example.com/repo.git",
+		"git+https://",
+		"git+https:////api.yaml",
+	}
+	for _, artifact := range tests {
+		t.Run(artifact, func(t *testing.T) {
+			_, _, _, err := parseGitArtifact(artifact)
+			var usageErr *UsageError
+			if !errors.As(err, &usageErr) {
+				t.Errorf("parseGitArtifact() error = %v, want a usage error", err)
+			}
+		})
+	}
+}
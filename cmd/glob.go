@@ -0,0 +1,243 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// artifactExtensions are the extensions of the files imported when walking a directory.
+var artifactExtensions = map[string]bool{
+	".yaml": true, ".yml": true, ".json": true, ".xml": true, ".proto": true, ".graphql": true, ".graphqls": true, ".har": true,
+}
+
+// secondaryArtifactPatterns are the names of the files found by walking directories or expanding globs that
+// are secondary artifacts: metadata, examples, Postman collections and HAR recordings. Others are main ones.
+var secondaryArtifactPatterns = []string{
+	"*-metadata.yml", "*-metadata.yaml", "*-examples.yml", "*-examples.yaml",
+	"*.postman_collection.json", "*-postman.json", "*.har",
+}
+
+// patternList is a flag.Value accumulating glob patterns given by repeated or comma separated flags.
+type patternList []string
+
+func (l *patternList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *patternList) Set(value string) error {
+	for _, pattern := range splitList(value) {
+		if _, err := globPattern(pattern); err != nil {
+			return err
+		}
+		*l = append(*l, pattern)
+	}
+	return nil
+}
+
+// matches tells if one of the patterns matches file, a path relative to the walked directory, patterns
+// without slash being matched against its name only.
+func (l patternList) matches(file string) bool {
+	file = filepath.ToSlash(file)
+	for _, pattern := range l {
+		target := file
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(file)
+		}
+		if re, err := globPattern(pattern); err == nil && re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGlobMeta tells if path holds glob special characters.
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// globPattern compiles a glob pattern of slash separated paths: * matches any characters but /, ** any
+// number of directories, ? any character but / and [...] a class of characters, negated by a leading !.
+func globPattern(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("pattern '%s' has an unterminated character class", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+	}
+	return re, nil
+}
+
+// expandArtifactFiles replaces the directories and glob patterns of artifacts with the files they hold, kept
+// if they match one of include patterns, when any, and none of exclude ones. Unless given with the pattern,
+// found files are main artifacts but the ones of secondaryArtifactPatterns, and come first so that their
+// services exist when importing secondary ones. Other artifacts are kept as is.
+func expandArtifactFiles(artifacts []artifactFile, include patternList, exclude patternList) ([]artifactFile, error) {
+	var expanded []artifactFile
+	seen := map[string]bool{}
+	for _, artifact := range artifacts {
+		if isRemoteArtifact(artifact.path) || artifact.snapshot != nil {
+			expanded = append(expanded, artifact)
+			continue
+		}
+		root, files, err := findArtifactFiles(artifact.path)
+		if err != nil {
+			return nil, err
+		}
+		if files == nil {
+			expanded = append(expanded, artifact)
+			continue
+		}
+
+		var found []artifactFile
+		for _, file := range files {
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				rel = file
+			}
+			if seen[file] || (len(include) > 0 && !include.matches(rel)) || exclude.matches(rel) {
+				continue
+			}
+			seen[file] = true
+			main := artifact.main
+			if !artifact.explicitMain {
+				main = !isSecondaryArtifact(file)
+			}
+			found = append(found, artifactFile{path: file, main: main, explicitMain: true, checkout: artifact.checkout})
+		}
+		if len(found) == 0 {
+			return nil, usageErrorf("No artifact to import found in %s", artifact.path)
+		}
+		sort.SliceStable(found, func(i, j int) bool { return found[i].main && !found[j].main })
+		expanded = append(expanded, found...)
+	}
+	return expanded, nil
+}
+
+// findArtifactFiles returns the files matching the glob pattern at path, or the artifact files of the
+// directory at path, in lexical order, with the directory they were found in. It returns nil files if path
+// is neither a pattern nor a directory.
+func findArtifactFiles(pathOrPattern string) (string, []string, error) {
+	if !hasGlobMeta(pathOrPattern) {
+		info, err := os.Stat(pathOrPattern)
+		if err != nil || !info.IsDir() {
+			return "", nil, nil
+		}
+		files, err := walkFiles(pathOrPattern, func(file string) bool {
+			return artifactExtensions[strings.ToLower(filepath.Ext(file))]
+		})
+		return pathOrPattern, files, err
+	}
+
+	pattern := path.Clean(filepath.ToSlash(pathOrPattern))
+	re, err := globPattern(pattern)
+	if err != nil {
+		return "", nil, usageErrorf("Cannot import %s: %s", pathOrPattern, err)
+	}
+	// Only the directory before the first segment holding special characters has to be walked.
+	segments := strings.Split(pattern, "/")
+	base := 0
+	for base < len(segments) && !hasGlobMeta(segments[base]) {
+		base++
+	}
+	root := strings.Join(segments[:base], "/")
+	if len(root) == 0 {
+		root = "."
+		if strings.HasPrefix(pattern, "/") {
+			root = "/"
+		}
+	}
+	files, err := walkFiles(filepath.FromSlash(root), func(file string) bool {
+		return re.MatchString(filepath.ToSlash(file))
+	})
+	return filepath.FromSlash(root), files, err
+}
+
+// walkFiles returns the regular files under root kept by keep, skipping hidden directories like .git.
+func walkFiles(root string, keep func(file string) bool) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if file != root && strings.HasPrefix(entry.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && keep(file) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list artifacts of %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// isSecondaryArtifact tells if the name of file follows the conventions of secondary artifacts.
+func isSecondaryArtifact(file string) bool {
+	name := strings.ToLower(filepath.Base(file))
+	for _, pattern := range secondaryArtifactPatterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
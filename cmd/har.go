@@ -0,0 +1,244 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// harDefaultMaxBodySize is the default size over which response bodies of HAR entries are dropped.
+const harDefaultMaxBodySize = 1 << 20
+
+// harSecretHeaders are the headers stripped from HAR entries, lower cased.
+var harSecretHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+}
+
+// harServicePattern finds the service a HAR file provides examples for in the comment of its log.
+var harServicePattern = regexp.MustCompile(`microcksId:\s*([^\n]+)`)
+
+// harOptions tells how a HAR file is prepared before being imported.
+type harOptions struct {
+	// filter is a pattern of the URLs of entries to keep, where * matches any characters.
+	filter string
+	// keepSecrets keeps cookies and authentication headers.
+	keepSecrets bool
+	// maxBodySize is the size over which response bodies are dropped, 0 meaning unbounded.
+	maxBodySize int64
+	// service is the name:version of the service the examples are for.
+	service string
+}
+
+// harReport tells what has been changed in a HAR file to import it.
+type harReport struct {
+	entries  int
+	filtered int
+	stripped int
+	dropped  []string
+	encoded  []string
+}
+
+// isHAR tells if an artifact is a HAR recording, from its extension or a JSON content having log
+// entries.
+func isHAR(filename string, content []byte) bool {
+	if strings.EqualFold(filepath.Ext(filename), ".har") {
+		return true
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return false
+	}
+	var har struct {
+		Log *struct {
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"log"`
+	}
+	return json.Unmarshal(content, &har) == nil && har.Log != nil && har.Log.Entries != nil
+}
+
+// prepareHAR filters the entries of a HAR file, strips their secrets, drops oversized response bodies
+// and encodes binary ones, then sets the microcksId comment Microcks uses to find the service the
+// examples are for. Fields it does not know are kept as is.
+func prepareHAR(content []byte, opts harOptions) ([]byte, harReport, error) {
+	var report harReport
+	var har map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&har); err != nil {
+		return nil, report, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	log, ok := har["log"].(map[string]interface{})
+	if !ok {
+		return nil, report, fmt.Errorf("invalid HAR file: no log object")
+	}
+	entries, _ := log["entries"].([]interface{})
+	report.entries = len(entries)
+
+	var filter *regexp.Regexp
+	if len(opts.filter) > 0 {
+		filter = regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(opts.filter), `\*`, ".*") + "$")
+	}
+	kept := []interface{}{}
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		request, _ := entry["request"].(map[string]interface{})
+		response, _ := entry["response"].(map[string]interface{})
+		method, _ := request["method"].(string)
+		url, _ := request["url"].(string)
+		if filter != nil && !filter.MatchString(url) {
+			report.filtered++
+			continue
+		}
+		if !opts.keepSecrets {
+			report.stripped += stripHARSecrets(request) + stripHARSecrets(response)
+		}
+		if content, ok := response["content"].(map[string]interface{}); ok {
+			switch prepareHARBody(content, opts.maxBodySize) {
+			case harBodyDropped:
+				report.dropped = append(report.dropped, method+" "+url)
+			case harBodyEncoded:
+				report.encoded = append(report.encoded, method+" "+url)
+			}
+		}
+		kept = append(kept, entry)
+	}
+	log["entries"] = kept
+
+	comment, _ := log["comment"].(string)
+	switch {
+	case len(opts.service) > 0:
+		comment = strings.TrimSpace(harServicePattern.ReplaceAllString(comment, "") + "\nmicrocksId: " + opts.service)
+	case !harServicePattern.MatchString(comment):
+		return nil, report, fmt.Errorf("HAR file does not tell the service its examples are for, import it after the main artifact of this service or set --har-service flag")
+	}
+	log["comment"] = comment
+
+	prepared, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return nil, report, err
+	}
+	return prepared, report, nil
+}
+
+// stripHARSecrets removes the cookies and the authentication headers of a HAR request or response,
+// returning how many were removed.
+func stripHARSecrets(message map[string]interface{}) int {
+	stripped := 0
+	if cookies, ok := message["cookies"].([]interface{}); ok && len(cookies) > 0 {
+		stripped += len(cookies)
+		message["cookies"] = []interface{}{}
+	}
+	headers, ok := message["headers"].([]interface{})
+	if !ok {
+		return stripped
+	}
+	kept := []interface{}{}
+	for _, h := range headers {
+		if header, ok := h.(map[string]interface{}); ok {
+			if name, _ := header["name"].(string); harSecretHeaders[strings.ToLower(name)] {
+				stripped++
+				continue
+			}
+		}
+		kept = append(kept, h)
+	}
+	message["headers"] = kept
+	return stripped
+}
+
+// Outcomes of prepareHARBody.
+const (
+	harBodyKept = iota
+	harBodyDropped
+	harBodyEncoded
+)
+
+// prepareHARBody drops a response body bigger than maxBodySize and base64 encodes a binary body
+// exported as text. Textual bodies exported base64 encoded are decoded so that they can be used as
+// examples.
+func prepareHARBody(content map[string]interface{}, maxBodySize int64) int {
+	text, _ := content["text"].(string)
+	encoding, _ := content["encoding"].(string)
+	if maxBodySize > 0 && int64(len(text)) > maxBodySize {
+		delete(content, "text")
+		delete(content, "encoding")
+		content["comment"] = fmt.Sprintf("Body of %d bytes dropped by microcks-cli", len(text))
+		return harBodyDropped
+	}
+	if encoding == "base64" {
+		if decoded, err := base64.StdEncoding.DecodeString(text); err == nil && utf8.Valid(decoded) && !looksBinary(string(decoded)) {
+			content["text"] = string(decoded)
+			delete(content, "encoding")
+		}
+		return harBodyKept
+	}
+	if looksBinary(text) {
+		content["text"] = base64.StdEncoding.EncodeToString([]byte(text))
+		content["encoding"] = "base64"
+		return harBodyEncoded
+	}
+	return harBodyKept
+}
+
+// looksBinary tells if text holds binary data: control characters other than whitespace, or
+// replacement characters of bytes that were not valid UTF-8.
+func looksBinary(text string) bool {
+	for _, r := range text {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
+
+// readHAR returns the content of an artifact if it's a HAR recording, nil otherwise or if it cannot be
+// read, leaving the error to the upload. Only .har and .json files are read.
+func readHAR(path string) []byte {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".har" && ext != ".json" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !isHAR(path, content) {
+		return nil
+	}
+	return content
+}
+
+// printHARReport tells what has been changed in HAR file path to import it.
+func printHARReport(path string, report harReport, maxBodySize int64) {
+	console.Printf("HAR file %s: importing %d of %d entries, %d cookies and authentication headers stripped\n", path, report.entries-report.filtered, report.entries, report.stripped)
+	if len(report.dropped) > 0 {
+		console.Warnf("HAR file %s: response bodies over %d bytes dropped for %s", path, maxBodySize, strings.Join(report.dropped, ", "))
+	}
+	if len(report.encoded) > 0 {
+		console.Printf("HAR file %s: binary response bodies base64 encoded for %s\n", path, strings.Join(report.encoded, ", "))
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsHAR(t *testing.T) {
+	tests := []struct {
+		filename string
+		content  string
+		want     bool
+	}{
+		{filename: "session.har", content: "", want: true},
+		{filename: "session.HAR", content: "{}", want: true},
+		{filename: "session.json", content: `{"log": {"version": "1.2", "entries": []}}`, want: true},
+		{filename: "session.json", content: `{"log": {"version": "1.2"}}`},
+		{filename: "beer-catalog-api-postman.json", content: `{"info": {"name": "Beer Catalog API"}, "item": []}`},
+		{filename: "beer-catalog-api-openapi.yaml", content: "openapi: 3.0.2"},
+	}
+	for _, test := range tests {
+		if got := isHAR(test.filename, []byte(test.content)); got != test.want {
+			t.Errorf("isHAR(%s, %s) = %v, want %v", test.filename, test.content, got, test.want)
+		}
+	}
+	// Exports are recognized from their content too.
+	for _, fixture := range []string{"chrome.har", "mitmproxy.har"} {
+		content, err := os.ReadFile(filepath.Join("testdata", "har", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isHAR("export.json", content) {
+			t.Errorf("isHAR() of %s content = false", fixture)
+		}
+	}
+}
+
+func TestPrepareHAR(t *testing.T) {
+	// body is the content of a response as prepared.
+	type body struct {
+		text     string
+		encoding string
+	}
+	const service = "Beer Catalog API:0.9"
+	tests := []struct {
+		name    string
+		fixture string
+		opts    harOptions
+		// wantURLs are the URLs of the entries kept, in order.
+		wantURLs     []string
+		wantFiltered int
+		wantStripped int
+		wantDropped  []string
+		wantEncoded  []string
+		// wantBodies are the response bodies of some of the entries kept, by URL.
+		wantBodies map[string]body
+		wantErr    string
+	}{
+		{
+			name:    "chrome",
+			fixture: "chrome.har",
+			opts:    harOptions{maxBodySize: harDefaultMaxBodySize, service: service},
+			wantURLs: []string{
+				"https://beers.example.com/api/beer?page=0",
+				"https://beers.example.com/api/beer/Rodenbach",
+				"https://beers.example.com/static/logo.png",
+				"https://beers.example.com/app.js",
+			},
+			wantStripped: 7,
+			wantBodies: map[string]body{
+				"https://beers.example.com/api/beer/Rodenbach": {text: `{"name":"Rodenbach","country":"Belgium","type":"Fruit","rating":4.4,"status":"available"}`},
+				"https://beers.example.com/static/logo.png":    {text: "iVBORw0KGgoAAAANSUhEUg==", encoding: "base64"},
+			},
+		},
+		{
+			name:         "chrome filtered",
+			fixture:      "chrome.har",
+			opts:         harOptions{filter: "https://beers.example.com/api/*", service: service},
+			wantURLs:     []string{"https://beers.example.com/api/beer?page=0", "https://beers.example.com/api/beer/Rodenbach"},
+			wantFiltered: 2,
+			wantStripped: 7,
+		},
+		{
+			name:         "chrome filter matching whole URLs",
+			fixture:      "chrome.har",
+			opts:         harOptions{filter: "*/api/beer", service: service},
+			wantURLs:     []string{},
+			wantFiltered: 4,
+		},
+		{
+			name:    "chrome keeping secrets",
+			fixture: "chrome.har",
+			opts:    harOptions{filter: "*/api/*", keepSecrets: true, service: service},
+			wantURLs: []string{
+				"https://beers.example.com/api/beer?page=0",
+				"https://beers.example.com/api/beer/Rodenbach",
+			},
+			wantFiltered: 2,
+		},
+		{
+			name:    "chrome without service",
+			fixture: "chrome.har",
+			wantErr: "HAR file does not tell the service its examples are for, import it after the main artifact of this service or set --har-service flag",
+		},
+		{
+			name:    "mitmproxy",
+			fixture: "mitmproxy.har",
+			opts:    harOptions{maxBodySize: harDefaultMaxBodySize, service: service},
+			wantURLs: []string{
+				"http://localhost:8080/api/beer/Westmalle%20Triple",
+				"http://localhost:8080/api/beer",
+				"http://localhost:8080/api/beer/Orval/label",
+			},
+			wantStripped: 2,
+			wantEncoded:  []string{"GET http://localhost:8080/api/beer/Orval/label"},
+			wantBodies: map[string]body{
+				"http://localhost:8080/api/beer/Westmalle%20Triple": {text: `{"name":"Westmalle Triple","country":"Belgium","type":"Trappist","rating":3.8,"status":"available"}`},
+				"http://localhost:8080/api/beer/Orval/label":        {text: "wolQTkcNChoKAAAADUlIRFI=", encoding: "base64"},
+			},
+		},
+		{
+			name:    "mitmproxy oversized bodies",
+			fixture: "mitmproxy.har",
+			opts:    harOptions{maxBodySize: 140, service: service},
+			wantURLs: []string{
+				"http://localhost:8080/api/beer/Westmalle%20Triple",
+				"http://localhost:8080/api/beer",
+				"http://localhost:8080/api/beer/Orval/label",
+			},
+			wantStripped: 2,
+			wantDropped:  []string{"POST http://localhost:8080/api/beer"},
+			wantEncoded:  []string{"GET http://localhost:8080/api/beer/Orval/label"},
+			wantBodies: map[string]body{
+				"http://localhost:8080/api/beer": {},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", "har", test.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			prepared, report, err := prepareHAR(content, test.opts)
+			if len(test.wantErr) > 0 {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("prepareHAR() error = %v, want %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("prepareHAR() error = %v", err)
+			}
+			var originalHAR map[string]interface{}
+			json.Unmarshal(content, &originalHAR)
+			if report.entries != len(originalHAR["log"].(map[string]interface{})["entries"].([]interface{})) || report.filtered != test.wantFiltered ||
+				report.stripped != test.wantStripped || !reflect.DeepEqual(report.dropped, test.wantDropped) || !reflect.DeepEqual(report.encoded, test.wantEncoded) {
+				t.Errorf("prepareHAR() report = %+v, want %d filtered, %d stripped, dropped %v and encoded %v",
+					report, test.wantFiltered, test.wantStripped, test.wantDropped, test.wantEncoded)
+			}
+
+			var har struct {
+				Log struct {
+					Creator map[string]interface{}   `json:"creator"`
+					Comment string                   `json:"comment"`
+					Entries []map[string]interface{} `json:"entries"`
+				} `json:"log"`
+			}
+			if err := json.Unmarshal(prepared, &har); err != nil {
+				t.Fatalf("prepared HAR is invalid: %v", err)
+			}
+			if har.Log.Comment != "microcksId: "+service {
+				t.Errorf("comment = %q, want the microcksId of the service", har.Log.Comment)
+			}
+			if har.Log.Creator["name"] == nil {
+				t.Errorf("creator = %v, want the one of the export", har.Log.Creator)
+			}
+			urls := []string{}
+			for _, entry := range har.Log.Entries {
+				request := entry["request"].(map[string]interface{})
+				response := entry["response"].(map[string]interface{})
+				url := request["url"].(string)
+				urls = append(urls, url)
+
+				if test.fixture == "chrome.har" && entry["_resourceType"] == nil {
+					t.Errorf("entry %s lost fields specific to Chrome", url)
+				}
+				secrets := 0
+				for _, message := range []map[string]interface{}{request, response} {
+					secrets += len(message["cookies"].([]interface{}))
+					for _, h := range message["headers"].([]interface{}) {
+						if harSecretHeaders[strings.ToLower(h.(map[string]interface{})["name"].(string))] {
+							secrets++
+						}
+					}
+				}
+				if !test.opts.keepSecrets && secrets > 0 {
+					t.Errorf("entry %s still has %d cookies or authentication headers", url, secrets)
+				}
+				if want, ok := test.wantBodies[url]; ok {
+					content := response["content"].(map[string]interface{})
+					got := body{}
+					got.text, _ = content["text"].(string)
+					got.encoding, _ = content["encoding"].(string)
+					if got != want {
+						t.Errorf("body of %s = %+v, want %+v", url, got, want)
+					}
+				}
+			}
+			if !reflect.DeepEqual(urls, test.wantURLs) {
+				t.Errorf("entries kept = %v, want %v", urls, test.wantURLs)
+			}
+			if test.opts.keepSecrets && !strings.Contains(string(prepared), "Bearer eyJhbGciOiJSUzI1NiJ9.e30.c2ln") {
+				t.Error("authentication headers were stripped")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// globalsOperation is the key of operationsHeaders holding the headers sent for every operation.
+const globalsOperation = "globals"
+
+// headerNamePattern matches the valid HTTP header names (RFC 7230 tokens).
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// headerList is a repeatable flag of "Name: value" headers, whose values may hold ${NAME} references
+// to environment variables.
+type headerList []connectors.HeaderDTO
+
+func (l *headerList) String() string {
+	if l == nil {
+		return ""
+	}
+	headers := make([]string, len(*l))
+	for i, header := range *l {
+		headers[i] = header.Name + ": " + header.Values
+	}
+	return strings.Join(headers, ", ")
+}
+
+func (l *headerList) Set(value string) error {
+	name, headerValue, found := strings.Cut(value, ":")
+	name, headerValue = strings.TrimSpace(name), strings.TrimSpace(headerValue)
+	if !found {
+		return fmt.Errorf("'%s' is not a 'Name: value' header", value)
+	}
+	if !headerNamePattern.MatchString(name) {
+		return fmt.Errorf("'%s' is not a valid header name", name)
+	}
+	headerValue, missing := config.ExpandEnvReferences(headerValue)
+	if len(missing) > 0 {
+		return fmt.Errorf("header '%s' references undefined environment variables: %v", name, missing)
+	}
+	*l = append(*l, connectors.HeaderDTO{Name: name, Values: headerValue})
+	return nil
+}
+
+// mergeGlobalHeaders adds globals to the globals section of the operationsHeaders JSON, returning the
+// resulting JSON. The headers of operations are kept as is, Microcks applying them over globals. A header
+// defined twice in globals, whatever the case of its name, is an error.
+func mergeGlobalHeaders(operationsHeaders string, globals []connectors.HeaderDTO) (string, error) {
+	if len(globals) == 0 {
+		return operationsHeaders, nil
+	}
+	headers := map[string]json.RawMessage{}
+	if len(strings.TrimSpace(operationsHeaders)) > 0 {
+		if err := json.Unmarshal([]byte(operationsHeaders), &headers); err != nil {
+			return "", fmt.Errorf("operationsHeaders is not a JSON object: %w", err)
+		}
+	}
+	merged := []connectors.HeaderDTO{}
+	if raw, ok := headers[globalsOperation]; ok {
+		if err := json.Unmarshal(raw, &merged); err != nil {
+			return "", fmt.Errorf("globals of operationsHeaders are not an array of headers: %w", err)
+		}
+	}
+
+	merged = append(merged, globals...)
+	names := map[string]bool{}
+	for _, header := range merged {
+		name := strings.ToLower(header.Name)
+		if names[name] {
+			return "", fmt.Errorf("header '%s' is defined several times in globals", header.Name)
+		}
+		names[name] = true
+	}
+
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	headers[globalsOperation] = raw
+	result, err := json.Marshal(headers)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
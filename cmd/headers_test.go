@@ -0,0 +1,112 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+func TestMergeGlobalHeaders(t *testing.T) {
+	tests := []struct {
+		name              string
+		operationsHeaders string
+		globals           []connectors.HeaderDTO
+		want              string
+		// wantErr is the start of the error, the end of JSON errors depending on Go versions.
+		wantErr string
+	}{
+		{
+			name:              "empty globals",
+			operationsHeaders: `{"GET /beer": [{"name": "Accept", "values": "application/json"}]}`,
+			want:              `{"GET /beer": [{"name": "Accept", "values": "application/json"}]}`,
+		},
+		{
+			name:    "empty globals and operations headers",
+			globals: []connectors.HeaderDTO{},
+			want:    "",
+		},
+		{
+			name:    "globals only",
+			globals: []connectors.HeaderDTO{{Name: "X-Tenant", Values: "acme"}, {Name: "Authorization", Values: "Bearer t0k3n"}},
+			want:    `{"globals":[{"name":"X-Tenant","values":"acme"},{"name":"Authorization","values":"Bearer t0k3n"}]}`,
+		},
+		{
+			name:              "globals added to the ones of operations headers",
+			operationsHeaders: `{"globals": [{"name": "X-Tenant", "values": "acme"}]}`,
+			globals:           []connectors.HeaderDTO{{Name: "X-Trace", Values: "on"}},
+			want:              `{"globals":[{"name":"X-Tenant","values":"acme"},{"name":"X-Trace","values":"on"}]}`,
+		},
+		{
+			name:              "operation overriding a global",
+			operationsHeaders: `{"GET /beer/{name}": [{"name": "x-tenant", "values": "other"}]}`,
+			globals:           []connectors.HeaderDTO{{Name: "X-Tenant", Values: "acme"}},
+			want:              `{"GET /beer/{name}":[{"name":"x-tenant","values":"other"}],"globals":[{"name":"X-Tenant","values":"acme"}]}`,
+		},
+		{
+			name:    "several values",
+			globals: []connectors.HeaderDTO{{Name: "Accept", Values: "application/json,application/xml"}},
+			want:    `{"globals":[{"name":"Accept","values":"application/json,application/xml"}]}`,
+		},
+		{
+			name:    "duplicate header",
+			globals: []connectors.HeaderDTO{{Name: "X-Tenant", Values: "acme"}, {Name: "X-Tenant", Values: "acme"}},
+			wantErr: "header 'X-Tenant' is defined several times in globals",
+		},
+		{
+			name:    "duplicate header of another case",
+			globals: []connectors.HeaderDTO{{Name: "X-Tenant", Values: "acme"}, {Name: "x-tenant", Values: "other"}},
+			wantErr: "header 'x-tenant' is defined several times in globals",
+		},
+		{
+			name:              "duplicate of a global of operations headers",
+			operationsHeaders: `{"globals": [{"name": "x-tenant", "values": "acme"}]}`,
+			globals:           []connectors.HeaderDTO{{Name: "X-TENANT", Values: "other"}},
+			wantErr:           "header 'X-TENANT' is defined several times in globals",
+		},
+		{
+			name:              "operations headers not an object",
+			operationsHeaders: `[{"name": "X-Tenant", "values": "acme"}]`,
+			globals:           []connectors.HeaderDTO{{Name: "X-Trace", Values: "on"}},
+			wantErr:           "operationsHeaders is not a JSON object: json: cannot unmarshal array",
+		},
+		{
+			name:              "globals not an array",
+			operationsHeaders: `{"globals": {"X-Tenant": "acme"}}`,
+			globals:           []connectors.HeaderDTO{{Name: "X-Trace", Values: "on"}},
+			wantErr:           "globals of operationsHeaders are not an array of headers: json: cannot unmarshal object",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mergeGlobalHeaders(test.operationsHeaders, test.globals)
+			if len(test.wantErr) > 0 {
+				if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+					t.Fatalf("mergeGlobalHeaders() error = %v, want %s...", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeGlobalHeaders() error = %v", err)
+			}
+			if got != test.want {
+				t.Errorf("mergeGlobalHeaders() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -15,7 +15,20 @@
  */
 package cmd
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+var helpUsage = usage{
+	name:        "help",
+	synopsis:    "help [command]",
+	description: "Display help about microcks-cli or one of its commands.",
+	examples: []string{
+		"microcks-cli help test",
+	},
+}
 
 type helpCommand struct {
 }
@@ -26,47 +39,41 @@ func NewHelpCommand() Command {
 }
 
 // Execute implementation on helpCommand structure
-func (c *helpCommand) Execute() {
-	fmt.Println("")
-	fmt.Println("microcks-cli is a CLI for interacting with Microcks server APIs.")
-	fmt.Println("It allows to launch tests or import API artifacts with minimal dependencies")
-	fmt.Println("")
-	fmt.Println("")
-	fmt.Println("Usage:")
-	fmt.Println("  microcks-cli [command]")
-	fmt.Println("")
-	fmt.Println("Available Commands:")
-	fmt.Println("  version     check this CLI version")
-	fmt.Println("  help        display this help message")
-	fmt.Println("  test        launch new test on Microcks server")
-	fmt.Println("  import      import API artifacts on Microcks server")
-	fmt.Println("")
-	fmt.Println("Use: microcks-cli test <apiName:apiVersion> <testEndpoint> <runner> \\")
-	fmt.Println("   --microcksURL=<> --waitFor=5sec \\")
-	fmt.Println("   --keycloakClientId=<> --keycloakClientSecret=<>")
-	fmt.Println("")
-	fmt.Println("Args: ")
-	fmt.Println("  <apiName:apiVersion>   Exemple: 'Beer Catalog API:0.9'")
-	fmt.Println("  <testEndpoint>         URL where is deployed implementation to test")
-	fmt.Println("  <runner>               Test strategy (one of: HTTP, SOAP, SOAP_UI, POSTMAN, OPEN_API_SCHEMA, ASYNC_API_SCHEMA, GRPC_PROTOBUF, GRAPHQL_SCHEMA)")
-	fmt.Println("")
-	fmt.Println("Flags: ")
-	fmt.Println("  --microcksURL          Microcks API endpoint")
-	fmt.Println("  --waitFor        	  Time to wait for test to finish (int + one of: milli, sec, min)")
-	fmt.Println("  --keycloakClientId     Keycloak Realm Service Account ClientId")
-	fmt.Println("  --keycloakClientSecret Keycloak Realm Service Account ClientSecret")
-	fmt.Println("")
-	fmt.Println("")
-	fmt.Println("Use: microcks-cli import <specificationFile1[:primary],specificationFile2[:primary]> \\")
-	fmt.Println("   --microcksURL=<> \\")
-	fmt.Println("   --keycloakClientId=<> --keycloakClientSecret=<>")
-	fmt.Println("")
-	fmt.Println("Args: ")
-	fmt.Println("  <specificationFile1[:primary],specificationFile2[:primary]>   Exemple: 'specs/my-openapi.yaml:true,specs/my-postmancollection.json:false'")
-	fmt.Println("")
-	fmt.Println("Flags: ")
-	fmt.Println("  --microcksURL          Microcks API endpoint")
-	fmt.Println("  --keycloakClientId     Keycloak Realm Service Account ClientId")
-	fmt.Println("  --keycloakClientSecret Keycloak Realm Service Account ClientSecret")
-	fmt.Println("")
+func (c *helpCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+	// Display help of a specific command if asked.
+	if len(args) > 0 {
+		if command, found := LookupCommand(args[0]); found {
+			if printer, ok := command.(usagePrinter); ok {
+				printer.printUsage(stdout)
+				return nil
+			}
+		}
+	}
+	c.printRootUsage(stdout)
+	return nil
+}
+
+func (c *helpCommand) printUsage(w io.Writer) {
+	helpUsage.print(w, nil)
+}
+
+func (c *helpCommand) printRootUsage(w io.Writer) {
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "microcks-cli is a CLI for interacting with Microcks server APIs.")
+	fmt.Fprintln(w, "It allows to launch tests or import API artifacts with minimal dependencies")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "  microcks-cli [command]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Available Commands:")
+	for _, spec := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", spec.name, spec.short)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Use \"microcks-cli [command] --help\" for more information about a command.")
+	fmt.Fprintln(w, "")
 }
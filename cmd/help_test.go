@@ -0,0 +1,78 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files of testdata")
+
+func TestHelpGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"root", nil},
+	}
+	for _, spec := range commands {
+		tests = append(tests, struct {
+			name string
+			args []string
+		}{spec.name, []string{spec.name}})
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := NewHelpCommand().Execute(context.Background(), test.args, &stdout, &stderr); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			// --help of commands must print the same usage as help command.
+			if len(test.args) > 0 {
+				command, _ := LookupCommand(test.args[0])
+				var helpOut bytes.Buffer
+				if err := command.Execute(context.Background(), []string{"--help"}, &helpOut, &stderr); err != nil {
+					t.Fatalf("Execute(--help) error = %v", err)
+				}
+				if helpOut.String() != stdout.String() {
+					t.Errorf("%s --help differs from help %s:\n%s", test.args[0], test.args[0], helpOut.String())
+				}
+			}
+
+			golden := filepath.Join("testdata", "help", test.name+".golden")
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, stdout.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("cannot read golden file, run 'go test ./cmd -run TestHelpGolden -update': %v", err)
+			}
+			if !bytes.Equal(stdout.Bytes(), want) {
+				t.Errorf("help output differs from %s, run 'go test ./cmd -run TestHelpGolden -update' if intended:\n%s", golden, stdout.String())
+			}
+		})
+	}
+}
@@ -16,102 +16,469 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/microcks/microcks-cli/pkg/config"
 	"github.com/microcks/microcks-cli/pkg/connectors"
+	"golang.org/x/term"
 )
 
+var importUsage = usage{
+	name:        "import",
+	synopsis:    "import <specificationFile1[:primary],specificationFile2[:primary]>|-f <file> [flags]",
+	description: "Import API artifacts on Microcks server, given as arg or listed in a YAML file.",
+	args: [][2]string{
+		{"<specificationFile1[:primary],specificationFile2[:primary]>", "Exemple: 'specs/my-openapi.yaml:true,specs/my-postmancollection.json:false'. http and https URLs are downloaded by Microcks, git+<url>//<path>@<ref> are fetched from Git repositories, directories and glob patterns like 'specs/**/*.yaml' are replaced by the artifacts they hold, directories written by export --split are imported as a snapshot, and '-' is read from stdin"},
+	},
+	examples: []string{
+		"microcks-cli import 'samples/weather-forecast-openapi.yml:true,samples/weather-forecast-postman.json:false' \\\n" +
+			"    --microcksURL=http://localhost:8080/api/ \\\n" +
+			"    --keycloakClientId=microcks-serviceaccount --keycloakClientSecret=<secret>",
+		"microcks-cli import 'specs/pastry-openapi.yaml,recordings/session.har' \\\n" +
+			"    --har-filter='https://api.example.com/pastries*' --microcksURL=http://localhost:8080/api/",
+		"microcks-cli import './specs/**/*.yaml' --exclude='*-draft.yaml' \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+		"microcks-cli import -f artifacts.yaml --microcksURL=http://localhost:8080/api/",
+		"microcks-cli import './specs' --dry-run",
+		"microcks-cli import ./backup --services='Beer Catalog API:0.9' --microcksURL=http://localhost:8080/api/",
+		"microcks-cli import 'git+https://github.com/microcks/microcks//samples/APIPastry-openapi.yaml@1.9.0' \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+		"./generate-openapi.sh | microcks-cli import - --artifact-name=openapi.yaml \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+		"microcks-cli import 'https://raw.githubusercontent.com/microcks/microcks/master/samples/APIPastry-openapi.yaml' \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+	},
+}
+
 type importComamnd struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	har            harOptions
+	diff           bool
+	failOnBreaking bool
+	ifNewer        bool
+	force          bool
+	secretName     string
+	include        patternList
+	exclude        patternList
+	file           string
+	dryRun         bool
+	artifactName   string
+	services       string
 }
 
 // NewImportCommand build a new ImportCommand implementation
 func NewImportCommand() Command {
-	return new(importComamnd)
+	c := new(importComamnd)
+	c.fs = newFlagSet(importUsage)
+	c.cf.register(c.fs)
+	c.fs.StringVar(&c.har.filter, "har-filter", "", "Pattern of the URLs of HAR entries to import, * matching any characters")
+	c.fs.StringVar(&c.har.service, "har-service", "", "Service <name:version> HAR examples are for (default to the service of previous main artifact)")
+	c.fs.BoolVar(&c.har.keepSecrets, "har-keep-secrets", false, "Keep cookies and authentication headers of HAR entries")
+	c.fs.Int64Var(&c.har.maxBodySize, "har-max-body-size", harDefaultMaxBodySize, "Size in bytes over which response bodies of HAR entries are dropped (0 means unbounded)")
+	c.fs.BoolVar(&c.diff, "diff", false, "Compare OpenAPI artifacts with the contracts stored by Microcks for the same service and version before importing")
+	c.fs.BoolVar(&c.failOnBreaking, "fail-on-breaking", false, "Import nothing and fail if OpenAPI artifacts have breaking changes (implies --diff)")
+	c.fs.BoolVar(&c.ifNewer, "if-newer", false, "Skip OpenAPI and AsyncAPI main artifacts whose contract on Microcks is identical, or whose service was updated after the file")
+	c.fs.BoolVar(&c.force, "force", false, "Import all artifacts, overriding --if-newer")
+	c.fs.Var(&c.include, "include", "Glob pattern of the files to import from directories and patterns, matched against their name or their path if it holds a / (repeatable or comma separated)")
+	c.fs.Var(&c.exclude, "exclude", "Glob pattern of the files not to import from directories and patterns, matched like --include (repeatable or comma separated)")
+	c.fs.StringVar(&c.secretName, "secretName", "", "Secret used by Microcks to download the artifacts given as URLs")
+	c.fs.StringVar(&c.file, "f", "", "Path of a YAML file listing the artifacts to import, replacing arg")
+	c.fs.StringVar(&c.file, "file", "", "Path of a YAML file listing the artifacts to import (alias of -f)")
+	c.fs.StringVar(&c.artifactName, "artifact-name", "", "File name of the artifact read from stdin with '-', its extension telling Microcks its type (default to stdin.json, stdin.xml or stdin.yaml after its content)")
+	c.fs.StringVar(&c.services, "services", "", "Comma separated <name:version> services to import from split snapshot directories (default to all)")
+	c.fs.BoolVar(&c.dryRun, "dry-run", false, "Parse artifacts locally and print the services Microcks would discover, without connecting to Microcks nor importing anything")
+	return c
 }
 
-// Execute implementation of importComamnd structure
-func (c *importComamnd) Execute() {
-	var err error
+func (c *importComamnd) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
 
-	// Parse subcommand args first.
-	if len(os.Args) < 3 {
-		fmt.Println("import command require <specificationFile1[:primary],specificationFile2[:primary]> args")
-		os.Exit(1)
+// Execute implementation of importComamnd structure
+func (c *importComamnd) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
 	}
 
-	specificationFiles := os.Args[2]
+	// Parse flags and positional args in any order.
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	var artifacts []artifactFile
+	if len(c.file) > 0 {
+		if len(args) > 0 {
+			return usageErrorf("-f flag replaces <specificationFile1[:primary],specificationFile2[:primary]> arg. Check Usage.")
+		}
+		if artifacts, err = readArtifactsFile(c.file); err != nil {
+			return err
+		}
+	} else {
+		if err := importUsage.checkArgs(args); err != nil {
+			return err
+		}
+		artifacts = parseArtifactFiles(args[0])
+	}
 
-	// Then parse flags.
-	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	cf := &c.cf
+	cf.setup(stdout, stderr)
+	// --timeout also bounds the fetch of Git repositories.
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
 
-	var microcksURL string
-	var keycloakClientID string
-	var keycloakClientSecret string
-	var insecureTLS bool
-	var caCertPaths string
-	var verbose bool
+	// Git repositories are fetched and directories and glob patterns are expanded before connecting, to
+	// fail early when nothing matches.
+	artifacts, cleanup, err := fetchGitArtifacts(ctx, artifacts)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	// Directories written by export --split hold a snapshot to reassemble rather than artifacts.
+	artifacts, err = loadSnapshotDirs(artifacts, splitList(c.services))
+	if err != nil {
+		return err
+	}
+	artifacts, err = expandArtifactFiles(artifacts, c.include, c.exclude)
+	if err != nil {
+		return err
+	}
+	if err := c.readStdinArtifact(artifacts); err != nil {
+		return err
+	}
 
-	importCmd.StringVar(&microcksURL, "microcksURL", "", "Microcks API URL")
-	importCmd.StringVar(&keycloakClientID, "keycloakClientId", "", "Keycloak Realm Service Account ClientId")
-	importCmd.StringVar(&keycloakClientSecret, "keycloakClientSecret", "", "Keycloak Realm Service Account ClientSecret")
-	importCmd.BoolVar(&insecureTLS, "insecure", false, "Whether to accept insecure HTTPS connection")
-	importCmd.StringVar(&caCertPaths, "caCerts", "", "Comma separated paths of CRT files to add to Root CAs")
-	importCmd.BoolVar(&verbose, "verbose", false, "Produce dumps of HTTP exchanges")
-	importCmd.Parse(os.Args[3:])
+	if c.dryRun {
+		if c.diff || c.failOnBreaking || c.ifNewer {
+			return usageErrorf("--dry-run flag cannot be used with --diff, --fail-on-breaking or --if-newer, that compare artifacts with Microcks")
+		}
+		for _, artifact := range artifacts {
+			if artifact.snapshot != nil {
+				return usageErrorf("--dry-run flag cannot inspect split snapshot %s", artifact.location())
+			}
+		}
+		result := inspectArtifacts(artifacts, c.har)
+		result.RequestID = config.RequestID
+		if err := cf.render(result); err != nil {
+			return err
+		}
+		if result.Invalid > 0 {
+			return &InvalidArtifactsError{Count: result.Invalid}
+		}
+		return nil
+	}
 
 	// Validate presence and values of flags.
-	if len(microcksURL) == 0 {
-		fmt.Println("--microcksURL flag is mandatory. Check Usage.")
-		os.Exit(1)
+	if err := cf.validate(); err != nil {
+		return err
 	}
-	if len(keycloakClientID) == 0 {
-		fmt.Println("--keycloakClientId flag is mandatory. Check Usage.")
-		os.Exit(1)
+	cf.apply()
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
 	}
-	if len(keycloakClientSecret) == 0 {
-		fmt.Println("--keycloakClientSecret flag is mandatory. Check Usage.")
-		os.Exit(1)
+
+	result := &importResult{Artifacts: []importedArtifact{}, RequestID: config.RequestID, summarize: cf.quiet}
+	// Main artifacts are compared before importing any, so that nothing is imported on breaking changes.
+	if c.diff || c.failOnBreaking {
+		for _, artifact := range artifacts {
+			if !artifact.main || artifact.snapshot != nil {
+				continue
+			}
+			if isRemoteArtifact(artifact.path) {
+				console.Warnf("Cannot compare remote artifact %s, it is imported without comparison", artifact.path)
+				continue
+			}
+			diff, err := diffArtifact(ctx, mc, artifact)
+			if err != nil {
+				return err
+			}
+			result.Diffs = append(result.Diffs, diff)
+		}
+		if count := breakingChanges(result.Diffs); count > 0 && c.failOnBreaking {
+			if err := cf.render(result); err != nil {
+				return err
+			}
+			return &BreakingChangesError{Count: count}
+		}
 	}
 
-	// Collect optional HTTPS transport flags.
-	if insecureTLS {
-		config.InsecureTLS = true
+	ifNewer := c.ifNewer && !c.force
+	if ifNewer {
+		result.Summary = &importSummary{}
 	}
-	if len(caCertPaths) > 0 {
-		config.CaCertPaths = caCertPaths
+	// Service of the last main artifact, that HAR files provide examples for.
+	var mainService string
+	for _, artifact := range artifacts {
+		f, mainArtifact, explicitMain := artifact.location(), artifact.main, artifact.explicitMain
+		if ifNewer && mainArtifact && !isRemoteArtifact(f) && artifact.snapshot == nil {
+			skipped, err := upToDateArtifact(ctx, mc, artifact)
+			if err != nil {
+				if renderErr := cf.render(result); renderErr != nil {
+					return renderErr
+				}
+				return err
+			}
+			if skipped != nil {
+				console.Debugf("Skipping artifact %s: %s", f, skipped.Reason)
+				result.Skipped = append(result.Skipped, *skipped)
+				result.Summary.Skipped++
+				mainService = skipped.Service
+				continue
+			}
+		}
+
+		if artifact.snapshot != nil {
+			console.Debugf("Importing split snapshot %s", f)
+			if err := mc.ImportSnapshot(ctx, bytes.NewReader(artifact.content), artifact.name); err != nil {
+				if renderErr := cf.render(result); renderErr != nil {
+					return renderErr
+				}
+				return requestError("Got error when invoking Microcks client importing snapshot", err)
+			}
+			for _, entry := range artifact.snapshot {
+				result.Artifacts = append(result.Artifacts, importedArtifact{File: f, MainArtifact: true, Service: entry.Service})
+				if result.Summary != nil {
+					result.Summary.Uploaded++
+				}
+			}
+			continue
+		}
+
+		// HAR recordings are secondary artifacts, prepared before being uploaded.
+		var msg string
+		if content := artifact.readHAR(); content != nil {
+			if !explicitMain {
+				mainArtifact = false
+			}
+			opts := c.har
+			if len(opts.service) == 0 {
+				opts.service = mainService
+			}
+			prepared, report, harErr := prepareHAR(content, opts)
+			if harErr != nil {
+				if renderErr := cf.render(result); renderErr != nil {
+					return renderErr
+				}
+				return fmt.Errorf("Cannot import HAR file %s: %w", f, harErr)
+			}
+			printHARReport(f, report, opts.maxBodySize)
+			console.Debugf("Importing HAR file %s as main artifact: %t", f, mainArtifact)
+			filename := strings.TrimSuffix(artifact.filename(), filepath.Ext(artifact.filename())) + ".har"
+			msg, err = mc.UploadArtifactContent(ctx, bytes.NewReader(prepared), filename, mainArtifact)
+		} else if artifact.content != nil {
+			console.Debugf("Importing artifact %s read from stdin as main artifact: %t", artifact.name, mainArtifact)
+			msg, err = mc.UploadArtifactContent(ctx, bytes.NewReader(artifact.content), artifact.name, mainArtifact)
+		} else {
+			console.Debugf("Importing artifact %s as main artifact: %t", f, mainArtifact)
+
+			// Try uploading this artifact.
+			secretName := c.secretName
+			if len(artifact.secretName) > 0 {
+				secretName = artifact.secretName
+			}
+			msg, err = importArtifact(ctx, mc, artifact.path, mainArtifact, secretName)
+		}
+		if err != nil {
+			// Render what has been imported so far before failing.
+			if renderErr := cf.render(result); renderErr != nil {
+				return renderErr
+			}
+			return requestError("Got error when invoking Microcks client importing Artifact", err)
+		}
+		result.Artifacts = append(result.Artifacts, importedArtifact{File: f, MainArtifact: mainArtifact, Service: msg})
+		if result.Summary != nil {
+			result.Summary.Uploaded++
+		}
+		if mainArtifact {
+			mainService = msg
+		}
+	}
+	return cf.render(result)
+}
+
+// artifactFile is an artifact to import.
+type artifactFile struct {
+	path string
+	main bool
+	// explicitMain tells if main was given with the path.
+	explicitMain bool
+	// secretName is the secret Microcks downloads URLs with, replacing --secretName.
+	secretName string
+	// content is the content of the artifact read from stdin, named name.
+	content []byte
+	name    string
+	// snapshot lists the services of the split snapshot directory reassembled in content, if the artifact
+	// is one.
+	snapshot []snapshotEntry
+	// checkout is the Git working tree the artifact was fetched in, if any.
+	checkout *gitCheckout
+}
+
+// location returns where the artifact comes from: its path, or its reference in a Git repository.
+func (a artifactFile) location() string {
+	if a.checkout != nil {
+		return a.checkout.location(a.path)
+	}
+	return a.path
+}
+
+// read returns the content of the artifact, read from stdin or from its file.
+func (a artifactFile) read() ([]byte, error) {
+	if a.content != nil {
+		return a.content, nil
+	}
+	return os.ReadFile(a.path)
+}
+
+// readHAR returns the content of the artifact if it is a HAR recording, nil otherwise.
+func (a artifactFile) readHAR() []byte {
+	if a.content == nil {
+		return readHAR(a.path)
+	}
+	if isHAR(a.name, a.content) {
+		return a.content
 	}
-	if verbose {
-		config.Verbose = true
+	return nil
+}
+
+// filename returns the name of the artifact file, telling Microcks its type.
+func (a artifactFile) filename() string {
+	if a.content != nil {
+		return a.name
 	}
+	return filepath.Base(a.path)
+}
+
+// stdinArtifact is the path of the artifact read from stdin.
+const stdinArtifact = "-"
 
-	mc := connectors.NewMicrocksClient(microcksURL)
-	mc.SetOAuthToken("unauthentifed-token")
+// stdin is where the artifact given as '-' is read from. It is a variable so that tests can pipe content.
+var stdin io.Reader = os.Stdin
 
-	sepSpecificationFiles := strings.Split(specificationFiles, ",")
-	for _, f := range sepSpecificationFiles {
-		mainArtifact := true
+// readStdinArtifact reads the content of the artifact given as '-' from stdin, naming it after
+// --artifact-name or, by default, after the format of its content.
+func (c *importComamnd) readStdinArtifact(artifacts []artifactFile) error {
+	index := -1
+	for i, artifact := range artifacts {
+		if artifact.path != stdinArtifact {
+			continue
+		}
+		if index >= 0 {
+			return usageErrorf("'-' can only be given once, stdin holding a single artifact")
+		}
+		index = i
+	}
+	if index < 0 {
+		if len(c.artifactName) > 0 {
+			return usageErrorf("--artifact-name flag is only for the artifact read from stdin with '-'")
+		}
+		return nil
+	}
+
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		return usageErrorf("Cannot read artifact '-' from stdin, it is a terminal: pipe the artifact content to the command")
+	}
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("Cannot read artifact from stdin: %w", err)
+	}
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 {
+		return usageErrorf("Cannot import artifact '-', nothing was read from stdin")
+	}
+	name := c.artifactName
+	if len(name) == 0 {
+		switch trimmed[0] {
+		case '{':
+			name = "stdin.json"
+		case '<':
+			name = "stdin.xml"
+		default:
+			name = "stdin.yaml"
+		}
+	}
+	artifacts[index].content, artifacts[index].name = content, name
+	return nil
+}
+
+// readArtifactsFile returns the artifacts listed in the artifacts file at path, in order.
+func readArtifactsFile(path string) ([]artifactFile, error) {
+	file, err := config.LoadArtifactsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	artifacts := make([]artifactFile, len(file.Artifacts))
+	for i, spec := range file.Artifacts {
+		artifacts[i] = artifactFile{path: spec.Path, main: true, explicitMain: spec.MainArtifact != nil, secretName: spec.SecretName}
+		if len(spec.URL) > 0 {
+			artifacts[i].path = spec.URL
+		}
+		if spec.MainArtifact != nil {
+			artifacts[i].main = *spec.MainArtifact
+		}
+	}
+	return artifacts, nil
+}
+
+// parseArtifactFiles parses the comma separated list of artifacts to import, each one being a path
+// possibly followed by :true or :false telling if it's a main artifact (default to true).
+func parseArtifactFiles(specificationFiles string) []artifactFile {
+	var artifacts []artifactFile
+	for _, f := range strings.Split(specificationFiles, ",") {
+		// URLs hold colons, only a trailing boolean tells if they are main artifacts.
+		if isRemoteArtifact(f) || isGitArtifact(f) {
+			artifact := artifactFile{path: f, main: true}
+			if i := strings.LastIndex(f, ":"); i >= 0 {
+				if main, err := strconv.ParseBool(f[i+1:]); err == nil {
+					artifact = artifactFile{path: f[:i], main: main, explicitMain: true}
+				}
+			}
+			artifacts = append(artifacts, artifact)
+			continue
+		}
+		artifact := artifactFile{path: f, main: true, explicitMain: strings.Contains(f, ":")}
 
 		// Check if mainArtifact flag is provided.
-		if strings.Contains(f, ":") {
+		if artifact.explicitMain {
 			pathAndMainArtifact := strings.Split(f, ":")
-			f = pathAndMainArtifact[0]
-			mainArtifact, err = strconv.ParseBool(pathAndMainArtifact[1])
+			artifact.path = pathAndMainArtifact[0]
+			var err error
+			artifact.main, err = strconv.ParseBool(pathAndMainArtifact[1])
 			if err != nil {
-				fmt.Printf("Cannot parse '%s' as Bool, default to true\n", pathAndMainArtifact[1])
+				console.Warnf("Cannot parse '%s' as Bool, default to true", pathAndMainArtifact[1])
 			}
 		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts
+}
 
-		// Try uploading this artifact.
-		msg, err := mc.UploadArtifact(f, mainArtifact)
-		if err != nil {
-			fmt.Printf("Got error when invoking Microcks client importing Artifact: %s", err)
-			os.Exit(1)
-		}
-		fmt.Printf("Microcks has discovered '%s'\n", msg)
+// isRemoteArtifact tells if the artifact at path is an http or https URL, downloaded by Microcks.
+func isRemoteArtifact(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// importArtifact uploads the artifact file at path to Microcks, or makes Microcks download it with the
+// secret named secretName if path is a URL, and returns the name and version of the service it defines.
+func importArtifact(ctx context.Context, mc connectors.MicrocksClient, path string, mainArtifact bool, secretName string) (string, error) {
+	if isRemoteArtifact(path) {
+		return mc.DownloadArtifact(ctx, path, mainArtifact, secretName)
 	}
+	return mc.UploadArtifact(ctx, path, mainArtifact)
+}
+
+func (c *importComamnd) flagSet() *flag.FlagSet {
+	return c.fs
 }
@@ -0,0 +1,127 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestImportArtifacts(t *testing.T) {
+	tests := []struct {
+		name      string
+		artifacts string
+		want      []microckstest.Upload
+		wantErr   bool
+	}{
+		{
+			name:      "main artifact",
+			artifacts: "beer-openapi.yaml",
+			want:      []microckstest.Upload{{Filename: "beer-openapi.yaml", MainArtifact: true, Content: []byte("openapi: 3.0.0")}},
+		},
+		{
+			name:      "main and secondary artifacts",
+			artifacts: "beer-openapi.yaml:true,beer-postman.json:false",
+			want: []microckstest.Upload{
+				{Filename: "beer-openapi.yaml", MainArtifact: true, Content: []byte("openapi: 3.0.0")},
+				{Filename: "beer-postman.json", MainArtifact: false, Content: []byte(`{"info": {}}`)},
+			},
+		},
+		{
+			name:      "unknown artifact",
+			artifacts: "pastry-openapi.yaml",
+			want:      []microckstest.Upload{{Filename: "pastry-openapi.yaml", MainArtifact: true, Content: []byte("openapi: 3.1.0")}},
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			beer := connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST}
+			srv.AddArtifact("beer-openapi.yaml", beer)
+			srv.AddArtifact("beer-postman.json", beer)
+
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "beer-openapi.yaml"), []byte("openapi: 3.0.0"), 0o644)
+			os.WriteFile(filepath.Join(dir, "beer-postman.json"), []byte(`{"info": {}}`), 0o644)
+			os.WriteFile(filepath.Join(dir, "pastry-openapi.yaml"), []byte("openapi: 3.1.0"), 0o644)
+
+			var artifacts []string
+			for _, artifact := range strings.Split(test.artifacts, ",") {
+				artifacts = append(artifacts, filepath.Join(dir, artifact))
+			}
+			args := []string{strings.Join(artifacts, ","), "--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}
+			var stdout, stderr bytes.Buffer
+			err := NewImportCommand().Execute(context.Background(), args, &stdout, &stderr)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v, stderr: %s", err, test.wantErr, stderr.String())
+			}
+			if got := srv.Uploads(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("uploads = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestImportFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientSecret string
+		failUpload   int
+		wantCode     string
+		wantStatus   int
+	}{
+		{name: "server error during upload", clientSecret: "s", failUpload: http.StatusServiceUnavailable, wantCode: errorCodeServerError, wantStatus: http.StatusServiceUnavailable},
+		{name: "refused credentials", clientSecret: "wrong", wantCode: errorCodeAuthFailed, wantStatus: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddArtifact("beer-openapi.yaml", connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+			if test.failUpload > 0 {
+				srv.FailRequests(http.MethodPost, "/api/artifact/upload", test.failUpload)
+			}
+			artifact := filepath.Join(t.TempDir(), "beer-openapi.yaml")
+			os.WriteFile(artifact, []byte("openapi: 3.0.0"), 0o644)
+
+			args := []string{artifact, "--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=" + test.clientSecret, "--no-input"}
+			var stdout, stderr bytes.Buffer
+			err := NewImportCommand().Execute(context.Background(), args, &stdout, &stderr)
+			if err == nil {
+				t.Fatal("Execute() succeeded")
+			}
+			if obj := newErrorObject(err); obj.Code != test.wantCode || obj.Status != test.wantStatus {
+				t.Errorf("error %v has code %s and status %d, want %s and %d", err, obj.Code, obj.Status, test.wantCode, test.wantStatus)
+			}
+			if uploads := srv.Uploads(); len(uploads) > 0 {
+				t.Errorf("got %d uploads, want none", len(uploads))
+			}
+		})
+	}
+}
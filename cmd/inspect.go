@@ -0,0 +1,327 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/output"
+	"gopkg.in/yaml.v3"
+)
+
+// Types of the artifacts recognized by import --dry-run.
+const (
+	artifactTypeOpenAPI     = "OpenAPI"
+	artifactTypeAsyncAPI    = "AsyncAPI"
+	artifactTypeGraphQL     = "GraphQL"
+	artifactTypeProtobuf    = "Protobuf"
+	artifactTypePostman     = "Postman"
+	artifactTypeSoapUI      = "SoapUI"
+	artifactTypeHAR         = "HAR"
+	artifactTypeAPIMetadata = "APIMetadata"
+	artifactTypeAPIExamples = "APIExamples"
+	artifactTypeRemote      = "remote"
+	artifactTypeUnknown     = "unknown"
+)
+
+var (
+	// descriptionVersionPattern finds the version=<version> that Postman collections and SoapUI projects
+	// declare in their description.
+	descriptionVersionPattern = regexp.MustCompile(`version=([^\s,;]+)`)
+	// graphQLIDPattern finds the # microcksId: <name>:<version> comment of GraphQL schemas.
+	graphQLIDPattern    = regexp.MustCompile(`(?m)^\s*#\s*microcksId:\s*(.+?)\s*:\s*([^\s:]+)\s*$`)
+	protoPackagePattern = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+	protoServicePattern = regexp.MustCompile(`(?m)^\s*service\s+(\w+)\s*\{`)
+)
+
+// inspectedArtifact is an artifact parsed locally by import --dry-run, with the services Microcks would
+// discover or complete from it, or the reason it would be rejected.
+type inspectedArtifact struct {
+	File         string   `json:"file" yaml:"file"`
+	MainArtifact bool     `json:"mainArtifact" yaml:"mainArtifact"`
+	Type         string   `json:"type" yaml:"type"`
+	Services     []string `json:"services,omitempty" yaml:"services,omitempty"`
+	Error        string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// importDryRunResult is the result of import command in dry-run mode.
+type importDryRunResult struct {
+	Artifacts []inspectedArtifact `json:"artifacts" yaml:"artifacts"`
+	Invalid   int                 `json:"invalid" yaml:"invalid"`
+	RequestID string              `json:"requestId" yaml:"requestId"`
+}
+
+// inspectArtifacts parses artifacts locally, in order, HAR recordings being prepared with har options
+// for the service of the previous main artifact when it has none.
+func inspectArtifacts(artifacts []artifactFile, har harOptions) *importDryRunResult {
+	result := &importDryRunResult{Artifacts: []inspectedArtifact{}}
+	var mainService string
+	for _, artifact := range artifacts {
+		inspected := inspectedArtifact{File: artifact.location(), MainArtifact: artifact.main}
+		if isRemoteArtifact(artifact.path) {
+			inspected.Type = artifactTypeRemote
+		} else if content := artifact.readHAR(); content != nil {
+			if !artifact.explicitMain {
+				inspected.MainArtifact = false
+			}
+			opts := har
+			if len(opts.service) == 0 {
+				opts.service = mainService
+			}
+			inspected.Type = artifactTypeHAR
+			if _, _, err := prepareHAR(content, opts); err != nil {
+				inspected.Error = err.Error()
+			} else if len(opts.service) > 0 {
+				inspected.Services = []string{opts.service}
+			}
+		} else if content, err := artifact.read(); err != nil {
+			inspected.Type, inspected.Error = artifactTypeUnknown, fmt.Sprintf("cannot read file: %s", err)
+		} else {
+			inspected.Type, inspected.Services, inspected.Error = inspectArtifact(artifact.filename(), content, artifact.main)
+		}
+		if len(inspected.Error) > 0 {
+			result.Invalid++
+		}
+		if inspected.MainArtifact && len(inspected.Services) > 0 {
+			mainService = inspected.Services[len(inspected.Services)-1]
+		}
+		result.Artifacts = append(result.Artifacts, inspected)
+	}
+	return result
+}
+
+// inspectArtifact detects the type of the artifact content of file filename and returns the name:version
+// of the services it defines, or the reason Microcks would reject it. Secondary artifacts may omit them.
+func inspectArtifact(filename string, content []byte, main bool) (string, []string, string) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".graphql", ".graphqls":
+		match := graphQLIDPattern.FindSubmatch(content)
+		if match == nil {
+			if main {
+				return artifactTypeGraphQL, nil, "missing '# microcksId: <name>:<version>' comment"
+			}
+			return artifactTypeGraphQL, nil, ""
+		}
+		return artifactTypeGraphQL, []string{string(match[1]) + ":" + string(match[2])}, ""
+	case ".proto":
+		pkg := protoPackagePattern.FindSubmatch(content)
+		if pkg == nil {
+			return artifactTypeProtobuf, nil, "missing package declaration"
+		}
+		// Microcks names gRPC services after their package, whose last part is the version.
+		parts := strings.Split(string(pkg[1]), ".")
+		var services []string
+		for _, match := range protoServicePattern.FindAllSubmatch(content, -1) {
+			services = append(services, string(pkg[1])+"."+string(match[1])+":"+parts[len(parts)-1])
+		}
+		if len(services) == 0 && main {
+			return artifactTypeProtobuf, nil, "no service declared"
+		}
+		return artifactTypeProtobuf, services, ""
+	case ".xml":
+		return inspectXMLArtifact(content, main)
+	default:
+		return inspectDocumentArtifact(content, main)
+	}
+}
+
+// inspectDocumentArtifact recognizes YAML and JSON artifacts: OpenAPI, AsyncAPI, Postman collections,
+// and metadata or examples of APIs.
+func inspectDocumentArtifact(content []byte, main bool) (string, []string, string) {
+	var doc struct {
+		OpenAPI  string `yaml:"openapi"`
+		Swagger  string `yaml:"swagger"`
+		AsyncAPI string `yaml:"asyncapi"`
+		Kind     string `yaml:"kind"`
+		Info     struct {
+			Title       string      `yaml:"title"`
+			Version     string      `yaml:"version"`
+			Name        string      `yaml:"name"`
+			Description interface{} `yaml:"description"`
+			PostmanID   string      `yaml:"_postman_id"`
+			Schema      string      `yaml:"schema"`
+		} `yaml:"info"`
+		Metadata struct {
+			Name    string `yaml:"name"`
+			Version string `yaml:"version"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return artifactTypeUnknown, nil, fmt.Sprintf("malformed YAML or JSON: %s", err)
+	}
+
+	switch {
+	case len(doc.OpenAPI) > 0 || len(doc.Swagger) > 0 || len(doc.AsyncAPI) > 0:
+		artifactType := artifactTypeOpenAPI
+		if len(doc.AsyncAPI) > 0 {
+			artifactType = artifactTypeAsyncAPI
+		}
+		if len(doc.Info.Title) == 0 || len(doc.Info.Version) == 0 {
+			return artifactType, nil, "missing info.title or info.version"
+		}
+		return artifactType, []string{doc.Info.Title + ":" + doc.Info.Version}, ""
+	case len(doc.Info.PostmanID) > 0 || strings.Contains(doc.Info.Schema, "postman"):
+		// Postman v2.1 descriptions may be objects holding the text as content.
+		description := doc.Info.Description
+		if object, ok := description.(map[string]interface{}); ok {
+			description = object["content"]
+		}
+		text, _ := description.(string)
+		match := descriptionVersionPattern.FindStringSubmatch(text)
+		if len(doc.Info.Name) == 0 || match == nil {
+			if main {
+				return artifactTypePostman, nil, "missing info.name or 'version=<version>' in info.description"
+			}
+			return artifactTypePostman, nil, ""
+		}
+		return artifactTypePostman, []string{doc.Info.Name + ":" + match[1]}, ""
+	case doc.Kind == artifactTypeAPIMetadata || doc.Kind == artifactTypeAPIExamples:
+		if len(doc.Metadata.Name) == 0 || len(doc.Metadata.Version) == 0 {
+			return doc.Kind, nil, "missing metadata.name or metadata.version"
+		}
+		return doc.Kind, []string{doc.Metadata.Name + ":" + doc.Metadata.Version}, ""
+	}
+	return artifactTypeUnknown, nil, "not an OpenAPI, AsyncAPI, Postman collection, APIMetadata or APIExamples document"
+}
+
+// inspectXMLArtifact recognizes SoapUI projects, whose interfaces are the services, versioned by the
+// version=<version> of their description or of the project one.
+func inspectXMLArtifact(content []byte, main bool) (string, []string, string) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var project bool
+	var interfaces, versions []string
+	var projectVersion string
+	// parent is the child of the project being decoded.
+	var parent string
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return artifactTypeUnknown, nil, fmt.Sprintf("malformed XML: %s", err)
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1:
+				if element.Name.Local != "soapui-project" {
+					return artifactTypeUnknown, nil, fmt.Sprintf("not a SoapUI project, root element is '%s'", element.Name.Local)
+				}
+				project = true
+			case depth == 2:
+				parent = element.Name.Local
+				if parent == "interface" {
+					interfaces = append(interfaces, xmlAttr(element, "name"))
+					versions = append(versions, "")
+				}
+			}
+			if element.Name.Local == "description" && (depth == 2 || (depth == 3 && parent == "interface")) {
+				var description string
+				if err := decoder.DecodeElement(&description, &element); err != nil {
+					return artifactTypeUnknown, nil, fmt.Sprintf("malformed XML: %s", err)
+				}
+				if match := descriptionVersionPattern.FindStringSubmatch(description); match != nil {
+					if depth == 2 {
+						projectVersion = match[1]
+					} else {
+						versions[len(versions)-1] = match[1]
+					}
+				}
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if !project {
+		return artifactTypeUnknown, nil, "not a SoapUI project"
+	}
+
+	var services []string
+	for i, name := range interfaces {
+		version := versions[i]
+		if len(version) == 0 {
+			version = projectVersion
+		}
+		if len(version) == 0 {
+			if main {
+				return artifactTypeSoapUI, nil, fmt.Sprintf("missing 'version=<version>' in the description of interface '%s' or of the project", name)
+			}
+			continue
+		}
+		services = append(services, name+":"+version)
+	}
+	if len(interfaces) == 0 && main {
+		return artifactTypeSoapUI, nil, "no interface defined"
+	}
+	return artifactTypeSoapUI, services, ""
+}
+
+// xmlAttr returns the value of the attribute of element having local name, empty if it has none.
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// RenderText implements output.TextRenderer for importDryRunResult.
+func (r *importDryRunResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	for _, artifact := range r.Artifacts {
+		switch {
+		case len(artifact.Error) > 0:
+			fmt.Fprintf(w, "%s %s (%s): %s\n", styles.Verdict(false, "FAIL"), artifact.File, artifact.Type, artifact.Error)
+		case artifact.Type == artifactTypeRemote:
+			fmt.Fprintf(w, "%s %s: downloaded by Microcks, not validated locally\n", styles.Warning("SKIP"), artifact.File)
+		case len(artifact.Services) == 0:
+			fmt.Fprintf(w, "%s %s (%s)\n", styles.Verdict(true, "OK"), artifact.File, artifact.Type)
+		default:
+			services := make([]string, len(artifact.Services))
+			for i, service := range artifact.Services {
+				services[i] = "'" + styles.Bold(service) + "'"
+			}
+			verb := "discover"
+			if !artifact.MainArtifact {
+				verb = "complete"
+			}
+			fmt.Fprintf(w, "%s %s (%s): Microcks would %s %s\n", styles.Verdict(true, "OK"), artifact.File, artifact.Type, verb, strings.Join(services, ", "))
+		}
+	}
+	line := fmt.Sprintf("Dry run: %d artifact(s) checked, %d invalid, nothing imported", len(r.Artifacts), r.Invalid)
+	fmt.Fprintln(w, styles.Verdict(r.Invalid == 0, line))
+}
+
+// EnvVars implements output.EnvRenderer for importDryRunResult.
+func (r *importDryRunResult) EnvVars() [][2]string {
+	return [][2]string{
+		{"MICROCKS_IMPORT_COUNT", strconv.Itoa(len(r.Artifacts))},
+		{"MICROCKS_IMPORT_INVALID", strconv.Itoa(r.Invalid)},
+		{"MICROCKS_REQUEST_ID", r.RequestID},
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reportJUnit is the only format of --reportFormat for now.
+const reportJUnit = "junit"
+
+// junitTestSuites is the root element of a JUnit XML report, holding a suite per test.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite is the test of a service on an endpoint.
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase is the test of an operation.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes a JUnit XML report of tests at path: a test suite per test holding a test case
+// per operation, failed ones carrying the messages of their failed steps. A failed test without any failed
+// operation, like a test still in progress or that could not run, is reported as an error test case named
+// after the service.
+func writeJUnitReport(path string, tests []*testResult) error {
+	report := junitTestSuites{Name: "microcks"}
+	var total int64
+	for _, test := range tests {
+		suite := newJUnitTestSuite(test)
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		if test.details != nil {
+			total += test.details.ElapsedTime
+		}
+	}
+	report.Time = junitSeconds(total)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Cannot write JUnit report: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Cannot write JUnit report: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil {
+		return fmt.Errorf("Cannot write JUnit report: %w", err)
+	}
+	return nil
+}
+
+func newJUnitTestSuite(test *testResult) junitTestSuite {
+	suite := junitTestSuite{
+		Name: test.ServiceRef + " on " + test.TestEndpoint,
+		Properties: []junitProperty{
+			{"service", test.ServiceRef},
+			{"endpoint", test.TestEndpoint},
+			{"runner", test.RunnerType},
+		},
+	}
+	if len(test.Environment) > 0 {
+		suite.Name += " (" + test.Environment + ")"
+		suite.Properties = append(suite.Properties, junitProperty{"environment", test.Environment})
+	}
+	if len(test.TestResultID) > 0 {
+		suite.Properties = append(suite.Properties, junitProperty{"testResultId", test.TestResultID})
+	}
+	if len(test.URL) > 0 {
+		suite.Properties = append(suite.Properties, junitProperty{"url", test.URL})
+	}
+
+	var elapsed int64
+	failed := false
+	if test.details != nil {
+		elapsed = test.details.ElapsedTime
+		if test.details.TestDate > 0 {
+			suite.Timestamp = time.UnixMilli(test.details.TestDate).UTC().Format("2006-01-02T15:04:05")
+		}
+		for _, testCase := range test.details.TestCaseResults {
+			junitCase := junitTestCase{Name: testCase.OperationName, ClassName: test.ServiceRef, Time: junitSeconds(testCase.ElapsedTime)}
+			if !testCase.Success {
+				failed = true
+				messages := failedSteps(testCase)
+				failure := &junitFailure{Message: "Operation failed", Type: "ContractViolation", Text: strings.Join(messages, "\n")}
+				if len(messages) > 0 {
+					failure.Message = messages[0]
+				}
+				junitCase.Failure = failure
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, junitCase)
+		}
+	}
+	if !test.Success && !failed {
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      test.ServiceRef,
+			ClassName: test.ServiceRef,
+			Time:      junitSeconds(0),
+			Error:     &junitFailure{Message: test.failure()},
+		})
+		suite.Errors++
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = junitSeconds(elapsed)
+	return suite
+}
+
+// junitSeconds formats a duration in milliseconds as JUnit seconds.
+func junitSeconds(millis int64) string {
+	return fmt.Sprintf("%.3f", float64(millis)/1000)
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// executeManifest launches the tests listed in the file given by -f, at most --concurrency at a time,
+// and renders their aggregated result. Options missing from a test default to the ones given by flags.
+func (c *testCommand) executeManifest(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) > 0 {
+		return usageErrorf("-f flag replaces <apiName:apiVersion> <testEndpoint> <runner> args. Check Usage.")
+	}
+	if len(c.endpoints) > 0 || len(c.broker) > 0 || len(c.topic) > 0 || len(c.binding) > 0 {
+		return usageErrorf("--endpoint, --broker, --topic and --binding flags cannot be used with -f, tests file giving the endpoints")
+	}
+	if c.requireAllPass || c.requireAnyPass {
+		return usageErrorf("--require-all-pass and --require-any-pass flags cannot be used with -f, all tests of the file are required to pass")
+	}
+	if len(c.compareWith) > 0 {
+		return usageErrorf("--compare-with flag cannot be used with -f")
+	}
+	if err := c.checkFlags(); err != nil {
+		return err
+	}
+	file, err := config.LoadTestsFile(c.file)
+	if err != nil {
+		return err
+	}
+
+	cf := &c.cf
+	cf.silent = c.teamCity && !c.dryRun
+	cf.skipAuth = c.dryRun && !c.verify
+	cf.setup(stdout, stderr)
+	if err := cf.validate(); err != nil {
+		return err
+	}
+
+	specs := make([]testSpec, len(file.Tests))
+	runners := []string{}
+	for i, test := range file.Tests {
+		if !runnerChoices[test.Runner] {
+			return usageErrorf("tests[%d] of %s: runner should be one of: HTTP, SOAP, SOAP_UI, POSTMAN, OPEN_API_SCHEMA, ASYNC_API_SCHEMA, GRPC_PROTOBUF, GRAPHQL_SCHEMA", i, c.file)
+		}
+		if test.Runner == asyncRunner {
+			if err := validateAsyncEndpoint(test.Endpoint); err != nil {
+				return err
+			}
+		}
+		waitFor := test.WaitFor
+		if len(waitFor) == 0 {
+			waitFor = c.waitFor
+		}
+		waitForMilliseconds, err := parseWaitFor(waitFor)
+		if err != nil {
+			return usageErrorf("Invalid waitFor of tests[%d] in %s: %s", i, c.file, err)
+		}
+
+		spec := c.newSpec(test.ServiceRef, test.Endpoint, test.Runner, waitForMilliseconds)
+		spec.fetchFull = true
+		if len(test.SecretName) > 0 {
+			spec.secretName = test.SecretName
+		}
+		if len(test.FilteredOperations) > 0 {
+			spec.filteredOperations = test.FilteredOperations
+		}
+		if len(test.OperationsHeaders) > 0 {
+			spec.operationsHeaders = test.OperationsHeaders
+		}
+		if len(test.OAuth2Context) > 0 {
+			spec.oAuth2Context = test.OAuth2Context
+		}
+		if spec.operationsHeaders, err = mergeGlobalHeaders(spec.operationsHeaders, c.globalHeaders); err != nil {
+			return usageErrorf("Invalid --globalHeader flag for tests[%d]: %s", i, err)
+		}
+		if err := connectors.ValidateTestOptions(spec.filteredOperations, spec.operationsHeaders, spec.oAuth2Context); err != nil {
+			return usageErrorf("Invalid test options of tests[%d] in %s: %s", i, c.file, err)
+		}
+		specs[i] = spec
+		runners = appendMissing(runners, test.Runner)
+	}
+	c.warnShortTimeout(specs)
+
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+	if c.dryRun && !c.verify {
+		return c.executeDryRun(ctx, nil, specs)
+	}
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+	for _, runner := range runners {
+		if err := cf.requireRunner(ctx, mc, runner); err != nil {
+			return err
+		}
+	}
+	if c.dryRun {
+		return c.executeDryRun(ctx, mc, specs)
+	}
+	cleanup, err := c.applySecret(ctx, mc)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	result := newManifestResult(c.file, runTests(ctx, mc, cf.microcksURL, specs, c.concurrency))
+	if err := cf.render(result); err != nil {
+		return err
+	}
+	if err := c.reportTests(ctx, result.Tests, func(test *testResult) string { return test.ServiceRef + " on " + test.TestEndpoint }); err != nil {
+		return err
+	}
+	if !result.Success {
+		if c.azureDevOps {
+			writeAzureDevOpsFailure(cf.decorationsOutput(), fmt.Sprintf("%d of %d tests of %s did not pass", result.Failed, len(result.Tests), c.file))
+		}
+		return testsError(result.Tests)
+	}
+	return nil
+}
+
+// executeServices launches the tests of several services given as a comma separated <apiName:apiVersion>
+// arg, at most --concurrency at a time, and renders their aggregated result like the tests of a file.
+func (c *testCommand) executeServices(ctx context.Context, mc connectors.MicrocksClient, serviceRefs []string, specs []testSpec) error {
+	cf := &c.cf
+	for i := range specs {
+		specs[i].fetchFull = true
+	}
+	result := newManifestResult("", runTests(ctx, mc, cf.microcksURL, specs, c.concurrency))
+	result.Services = serviceRefs
+	c.nameEnvironments(result.Tests)
+	if err := cf.render(result); err != nil {
+		return err
+	}
+	if err := c.reportTests(ctx, result.Tests, func(test *testResult) string { return test.ServiceRef + " on " + test.TestEndpoint }); err != nil {
+		return err
+	}
+	if !result.Success {
+		if c.azureDevOps {
+			writeAzureDevOpsFailure(cf.decorationsOutput(), fmt.Sprintf("%d of %d tests of %s did not pass", result.Failed, len(result.Tests), strings.Join(serviceRefs, ", ")))
+		}
+		return testsError(result.Tests)
+	}
+	return nil
+}
+
+// appendMissing appends value to values unless it's already there.
+func appendMissing(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// manifestResult is the outcome of the tests of a tests file, or of several services, rendered using the
+// --output format.
+type manifestResult struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Services are the tested services, when given as arg instead of a file.
+	Services  []string      `json:"services,omitempty" yaml:"services,omitempty"`
+	Success   bool          `json:"success" yaml:"success"`
+	Passed    int           `json:"passed" yaml:"passed"`
+	Failed    int           `json:"failed" yaml:"failed"`
+	Tests     []*testResult `json:"tests" yaml:"tests"`
+	RequestID string        `json:"requestId" yaml:"requestId"`
+	Timings   *timingReport `json:"timings,omitempty" yaml:"timings,omitempty"`
+}
+
+// newManifestResult gathers the tests of file, succeeding only if all of them passed.
+func newManifestResult(file string, tests []*testResult) *manifestResult {
+	result := &manifestResult{File: file, Tests: tests, RequestID: config.RequestID}
+	for _, test := range tests {
+		if test.Success {
+			result.Passed++
+		} else {
+			result.Failed++
+		}
+	}
+	result.Success = result.Failed == 0
+	return result
+}
+
+// setTimings implements timedResult for manifestResult.
+func (r *manifestResult) setTimings(report *timingReport) {
+	r.Timings = report
+}
+
+// RenderText implements output.TextRenderer for manifestResult: the result of every test, with the
+// failed operations of unsuccessful ones.
+func (r *manifestResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	title := r.File
+	if len(r.Services) > 0 {
+		title = strings.Join(r.Services, ", ")
+	}
+	fmt.Fprintf(w, "Tests of %s:\n", styles.Bold(title))
+	for i, test := range r.Tests {
+		switch {
+		case len(test.Error) > 0:
+			fmt.Fprintf(w, "[%d] %s %s on %s: %s\n", i+1, styles.Verdict(false, "ERROR"), test.ServiceRef, test.TestEndpoint, test.Error)
+		case test.Success:
+			fmt.Fprintf(w, "[%d] %s %s on %s: %s\n", i+1, styles.Verdict(true, "PASS"), test.ServiceRef, test.TestEndpoint, test.URL)
+		default:
+			fmt.Fprintf(w, "[%d] %s %s on %s: %s\n", i+1, styles.Verdict(false, "FAIL"), test.ServiceRef, test.TestEndpoint, test.URL)
+			test.renderFailures(w)
+		}
+	}
+	fmt.Fprintln(w, styles.Verdict(r.Success, fmt.Sprintf("%d of %d tests passed", r.Passed, len(r.Tests))))
+}
+
+// EnvVars implements output.EnvRenderer for manifestResult. Tests are numbered from 1.
+func (r *manifestResult) EnvVars() [][2]string {
+	vars := [][2]string{
+		{"MICROCKS_TEST_SUCCESS", strconv.FormatBool(r.Success)},
+		{"MICROCKS_TEST_COUNT", strconv.Itoa(len(r.Tests))},
+		{"MICROCKS_TEST_FAILED", strconv.Itoa(r.Failed)},
+	}
+	for i, test := range r.Tests {
+		prefix := fmt.Sprintf("MICROCKS_TEST_%d_", i+1)
+		vars = append(vars,
+			[2]string{prefix + "ID", test.TestResultID},
+			[2]string{prefix + "URL", test.URL},
+			[2]string{prefix + "SERVICE", test.ServiceRef},
+			[2]string{prefix + "ENDPOINT", test.TestEndpoint},
+			[2]string{prefix + "RUNNER", test.RunnerType},
+			[2]string{prefix + "SUCCESS", strconv.FormatBool(test.Success)},
+		)
+	}
+	return append(vars, [2]string{"MICROCKS_REQUEST_ID", r.RequestID})
+}
+
+// TektonResults implements tektonResult for manifestResult, joining the values of every test with commas.
+func (r *manifestResult) TektonResults() [][2]string {
+	ids, urls, failed := joinTests(r.Tests)
+	return [][2]string{
+		{"test-id", ids},
+		{"test-success", strconv.FormatBool(r.Success)},
+		{"test-url", urls},
+		{"operations-failed", failed},
+	}
+}
+
+// Outputs implements outputsResult for manifestResult, joining the values of every test with commas.
+func (r *manifestResult) Outputs() [][2]string {
+	ids, urls, failed := joinTests(r.Tests)
+	return [][2]string{
+		{"test_id", ids},
+		{"test_url", urls},
+		{"success", strconv.FormatBool(r.Success)},
+		{"operations_failed", failed},
+	}
+}
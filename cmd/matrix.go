@@ -0,0 +1,362 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// Policies telling if a matrix test succeeds.
+const (
+	matrixRequireAll = "all"
+	matrixRequireAny = "any"
+)
+
+// environmentPattern matches the names of the environments endpoints may be prefixed with.
+var environmentPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// endpointList is a flag.Value accumulating endpoints given by repeated or comma separated flags.
+type endpointList []string
+
+func (l *endpointList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *endpointList) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// splitList splits a comma separated list of endpoints or service references, ignoring empty ones.
+func splitList(value string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(value, ",") {
+		if endpoint = strings.TrimSpace(endpoint); len(endpoint) > 0 {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// splitEnvironment splits an endpoint prefixed by the name of its environment, like staging=https://stg/api,
+// the name being empty for endpoints without one.
+func splitEnvironment(endpoint string) (string, string) {
+	name, testEndpoint, found := strings.Cut(endpoint, "=")
+	if !found || !environmentPattern.MatchString(name) {
+		return "", endpoint
+	}
+	return name, testEndpoint
+}
+
+// nameEnvironments removes the names of environments from the endpoints of every service, returning the
+// name of each named endpoint. Names must be distinct among the endpoints of a service.
+func nameEnvironments(endpoints [][]string) (map[string]string, error) {
+	environments := map[string]string{}
+	for _, serviceEndpoints := range endpoints {
+		names := map[string]bool{}
+		for i, endpoint := range serviceEndpoints {
+			name, testEndpoint := splitEnvironment(endpoint)
+			if len(name) == 0 {
+				continue
+			}
+			if names[name] {
+				return nil, usageErrorf("Environment '%s' is given several times", name)
+			}
+			if other, ok := environments[testEndpoint]; ok && other != name {
+				return nil, usageErrorf("Endpoint %s is named both '%s' and '%s'", testEndpoint, other, name)
+			}
+			names[name] = true
+			environments[testEndpoint] = name
+			serviceEndpoints[i] = testEndpoint
+		}
+	}
+	return environments, nil
+}
+
+// runTestMatrix launches a test of the same service on every endpoint, running at most concurrency
+// tests at a time. Tests that cannot run are reported with their Error, without stopping others.
+func runTestMatrix(ctx context.Context, mc connectors.MicrocksClient, microcksURL string, spec testSpec, endpoints []string, concurrency int) []*testResult {
+	specs := make([]testSpec, len(endpoints))
+	for i, endpoint := range endpoints {
+		specs[i] = spec
+		specs[i].testEndpoint = endpoint
+	}
+	return runTests(ctx, mc, microcksURL, specs, concurrency)
+}
+
+// runTests launches the tests of specs, running at most concurrency tests at a time. Tests that cannot
+// run are reported with their Error, without stopping others.
+func runTests(ctx context.Context, mc connectors.MicrocksClient, microcksURL string, specs []testSpec, concurrency int) []*testResult {
+	results := make([]*testResult, len(specs))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func(i int, spec testSpec) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			result, err := runTest(ctx, mc, microcksURL, spec)
+			if err != nil {
+				result = &testResult{ServiceRef: spec.serviceRef, TestEndpoint: spec.testEndpoint, RunnerType: spec.runnerType, RequestID: config.RequestID, Error: err.Error()}
+			}
+			results[i] = result
+		}(i, spec)
+	}
+	wg.Wait()
+	return results
+}
+
+// matrixResult is the outcome of test command on several endpoints, rendered using the --output format.
+type matrixResult struct {
+	ServiceRef string        `json:"serviceRef" yaml:"serviceRef"`
+	RunnerType string        `json:"runnerType" yaml:"runnerType"`
+	Policy     string        `json:"policy" yaml:"policy"`
+	Success    bool          `json:"success" yaml:"success"`
+	Tests      []*testResult `json:"tests" yaml:"tests"`
+	RequestID  string        `json:"requestId" yaml:"requestId"`
+	Timings    *timingReport `json:"timings,omitempty" yaml:"timings,omitempty"`
+}
+
+// newMatrixResult gathers the tests of a matrix and computes its success following policy.
+func newMatrixResult(spec testSpec, policy string, tests []*testResult) *matrixResult {
+	passed := 0
+	for _, test := range tests {
+		if test.Success {
+			passed++
+		}
+	}
+	success := passed == len(tests)
+	if policy == matrixRequireAny {
+		success = passed > 0
+	}
+	return &matrixResult{ServiceRef: spec.serviceRef, RunnerType: spec.runnerType, Policy: policy, Success: success, Tests: tests, RequestID: config.RequestID}
+}
+
+// setTimings implements timedResult for matrixResult.
+func (r *matrixResult) setTimings(report *timingReport) {
+	r.Timings = report
+}
+
+// requirement describes the policy of the matrix.
+func (r *matrixResult) requirement() string {
+	if r.Policy == matrixRequireAny {
+		return "any endpoint"
+	}
+	return "all endpoints"
+}
+
+// label returns the name of the environment of the test, or its number in the matrix.
+func (r *matrixResult) label(i int) string {
+	if len(r.Tests[i].Environment) > 0 {
+		return r.Tests[i].Environment
+	}
+	return fmt.Sprintf("[%d]", i+1)
+}
+
+// failedTests returns the identifiers of failed tests, or their endpoint if they could not run.
+func failedTests(tests []*testResult) []string {
+	var failed []string
+	for _, test := range tests {
+		switch {
+		case test.Success:
+		case len(test.TestResultID) > 0:
+			failed = append(failed, test.TestResultID)
+		default:
+			failed = append(failed, test.TestEndpoint)
+		}
+	}
+	return failed
+}
+
+// matrixVerdicts returns the operations tested on any endpoint, in order of appearance, with their
+// verdict on each endpoint: PASS, FAIL or - if the operation has not been tested there.
+func (r *matrixResult) matrixVerdicts() ([]string, map[string][]string) {
+	var operations []string
+	verdicts := map[string][]string{}
+	for i, test := range r.Tests {
+		if test.details == nil {
+			continue
+		}
+		for _, testCase := range test.details.TestCaseResults {
+			if _, ok := verdicts[testCase.OperationName]; !ok {
+				operations = append(operations, testCase.OperationName)
+				verdicts[testCase.OperationName] = make([]string, len(r.Tests))
+				for j := range r.Tests {
+					verdicts[testCase.OperationName][j] = "-"
+				}
+			}
+			verdicts[testCase.OperationName][i] = "FAIL"
+			if testCase.Success {
+				verdicts[testCase.OperationName][i] = "PASS"
+			}
+		}
+	}
+	return operations, verdicts
+}
+
+// RenderText implements output.TextRenderer for matrixResult: operations verdicts side by side on
+// every endpoint, flagging divergences, then the result of the test on each endpoint.
+func (r *matrixResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	fmt.Fprintf(w, "Tests of '%s' on %d endpoints:\n", styles.Bold(r.ServiceRef), len(r.Tests))
+
+	operations, verdicts := r.matrixVerdicts()
+	if len(operations) > 0 {
+		width := len("OPERATION")
+		for _, operation := range operations {
+			if len(operation) > width {
+				width = len(operation)
+			}
+		}
+		header := fmt.Sprintf("  %-*s", width, "OPERATION")
+		columns := make([]int, len(r.Tests))
+		for i := range r.Tests {
+			columns[i] = max(len("PASS"), len(r.label(i)))
+			header += fmt.Sprintf("  %-*s", columns[i], r.label(i))
+		}
+		fmt.Fprintln(w, strings.TrimRight(header, " "))
+		for _, operation := range operations {
+			line := fmt.Sprintf("  %-*s", width, operation)
+			diverges := false
+			for i, verdict := range verdicts[operation] {
+				cell := fmt.Sprintf("%-*s", columns[i], verdict)
+				if verdict != "-" {
+					cell = styles.Verdict(verdict == "PASS", cell)
+				}
+				line += "  " + cell
+				diverges = diverges || verdict != verdicts[operation][0]
+			}
+			if diverges {
+				line += "  " + styles.Warning("<- diverges")
+			}
+			fmt.Fprintln(w, strings.TrimRight(line, " "))
+		}
+	}
+
+	labelWidth := 0
+	for i := range r.Tests {
+		labelWidth = max(labelWidth, len(r.label(i)))
+	}
+	for i, test := range r.Tests {
+		label := fmt.Sprintf("%-*s", labelWidth, r.label(i))
+		switch {
+		case len(test.Error) > 0:
+			fmt.Fprintf(w, "%s %s %s: %s\n", label, styles.Verdict(false, "ERROR"), test.TestEndpoint, test.Error)
+		case test.Success:
+			fmt.Fprintf(w, "%s %s %s: %s\n", label, styles.Verdict(true, "PASS"), test.TestEndpoint, test.URL)
+		default:
+			fmt.Fprintf(w, "%s %s %s: %s\n", label, styles.Verdict(false, "FAIL"), test.TestEndpoint, test.URL)
+		}
+	}
+	outcome := "failed"
+	if r.Success {
+		outcome = "succeeded"
+	}
+	fmt.Fprintln(w, styles.Verdict(r.Success, fmt.Sprintf("Required to pass on %s: %s", r.requirement(), outcome)))
+}
+
+// EnvVars implements output.EnvRenderer for matrixResult. Tests are numbered from 1.
+func (r *matrixResult) EnvVars() [][2]string {
+	vars := [][2]string{
+		{"MICROCKS_TEST_SUCCESS", strconv.FormatBool(r.Success)},
+		{"MICROCKS_TEST_COUNT", strconv.Itoa(len(r.Tests))},
+		{"MICROCKS_TEST_SERVICE", r.ServiceRef},
+		{"MICROCKS_TEST_RUNNER", r.RunnerType},
+	}
+	for i, test := range r.Tests {
+		prefix := fmt.Sprintf("MICROCKS_TEST_%d_", i+1)
+		vars = append(vars,
+			[2]string{prefix + "ID", test.TestResultID},
+			[2]string{prefix + "URL", test.URL},
+			[2]string{prefix + "ENDPOINT", test.TestEndpoint},
+			[2]string{prefix + "SUCCESS", strconv.FormatBool(test.Success)},
+		)
+		if len(test.Environment) > 0 {
+			vars = append(vars, [2]string{prefix + "ENVIRONMENT", test.Environment})
+		}
+	}
+	return append(vars, [2]string{"MICROCKS_REQUEST_ID", r.RequestID})
+}
+
+// TektonResults implements tektonResult for matrixResult, joining the values of every test with commas.
+func (r *matrixResult) TektonResults() [][2]string {
+	ids, urls, failed := joinTests(r.Tests)
+	return [][2]string{
+		{"test-id", ids},
+		{"test-success", strconv.FormatBool(r.Success)},
+		{"test-url", urls},
+		{"operations-failed", failed},
+	}
+}
+
+// Outputs implements outputsResult for matrixResult, joining the values of every test with commas.
+func (r *matrixResult) Outputs() [][2]string {
+	ids, urls, failed := joinTests(r.Tests)
+	return [][2]string{
+		{"test_id", ids},
+		{"test_url", urls},
+		{"success", strconv.FormatBool(r.Success)},
+		{"operations_failed", failed},
+	}
+}
+
+// testsError returns the error reporting the unsuccessful ones of tests: a TestTimeoutError if they are
+// all still in progress, a TestFailedError otherwise.
+func testsError(tests []*testResult) error {
+	inProgress := true
+	for _, test := range tests {
+		if !test.Success && (!test.InProgress || test.FailedFast) {
+			inProgress = false
+		}
+	}
+	failed := strings.Join(failedTests(tests), ", ")
+	if inProgress {
+		return &TestTimeoutError{TestResultID: failed}
+	}
+	return &TestFailedError{TestResultID: failed}
+}
+
+// joinTests returns the comma separated ids and urls of tests, and the distinct operations failed by them.
+func joinTests(tests []*testResult) (ids string, urls string, failed string) {
+	var idList, urlList, failedList []string
+	seen := map[string]bool{}
+	for _, test := range tests {
+		idList = append(idList, test.TestResultID)
+		urlList = append(urlList, test.URL)
+		for _, operation := range test.failedOperations() {
+			if !seen[operation] {
+				seen[operation] = true
+				failedList = append(failedList, operation)
+			}
+		}
+	}
+	return strings.Join(idList, ","), strings.Join(urlList, ","), strings.Join(failedList, ",")
+}
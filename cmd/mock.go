@@ -0,0 +1,329 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+var mockUsage = usage{
+	name:        "mock",
+	synopsis:    "mock url <apiName:apiVersion1,apiName:apiVersion2> [flags]",
+	description: "Print the URLs of the mocks Microcks serves for services, or write them as a dotenv or Postman environment file.",
+	args: [][2]string{
+		{"url", "Print the mock URLs of the operations of services"},
+		{"<apiName:apiVersion1,apiName:apiVersion2>", "Comma separated services. Exemple: 'Beer Catalog API:0.9,Pastry API:2.0'"},
+	},
+	examples: []string{
+		"microcks-cli mock url 'Beer Catalog API:0.9' --microcksURL=http://localhost:8080/api/",
+		"microcks-cli mock url 'Beer Catalog API:0.9,API Pastry:2.0' --format=dotenv --prefix=VITE \\\n" +
+			"    --microcksURL=http://localhost:8080/api/ > .env.local",
+		"microcks-cli mock url 'API Pastry:2.0' --format=postman-env --microcksURL=http://localhost:8080/api/ > pastry.postman_environment.json",
+	},
+}
+
+// Environment file formats of mock url.
+const (
+	mockFormatDotenv     = "dotenv"
+	mockFormatPostmanEnv = "postman-env"
+)
+
+type mockCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	format string
+	prefix string
+}
+
+// NewMockCommand build a new MockCommand implementation
+func NewMockCommand() Command {
+	c := new(mockCommand)
+	c.fs = newFlagSet(mockUsage)
+	c.cf.register(c.fs)
+	c.fs.StringVar(&c.format, "format", "", "Environment file written on stdout instead of the result (one of: dotenv, postman-env)")
+	c.fs.StringVar(&c.prefix, "prefix", "", "Prefix of the variable names of --format and --output=env, like VITE or REACT_APP")
+	return c
+}
+
+func (c *mockCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of mockCommand structure
+func (c *mockCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return usageErrorf("mock command require an action (one of: url). Check Usage.")
+	}
+	if args[0] != "url" {
+		return usageErrorf("mock command does not support '%s' action. Check Usage.", args[0])
+	}
+	if err := mockUsage.checkArgs(args); err != nil {
+		return err
+	}
+	var serviceRefs []string
+	for _, serviceRef := range splitList(args[1]) {
+		if !strings.Contains(serviceRef, ":") {
+			return usageErrorf("Service reference '%s' should be <apiName:apiVersion>", serviceRef)
+		}
+		serviceRefs = append(serviceRefs, serviceRef)
+	}
+	if len(serviceRefs) == 0 {
+		return usageErrorf("mock url command require at least one <apiName:apiVersion> service. Check Usage.")
+	}
+	if c.format != "" && c.format != mockFormatDotenv && c.format != mockFormatPostmanEnv {
+		return usageErrorf("Invalid value for --format flag: '%s' is not one of %s, %s", c.format, mockFormatDotenv, mockFormatPostmanEnv)
+	}
+	if c.format != "" && c.cf.output != output.Text {
+		return usageErrorf("--format flag writes an environment file instead of the result, it cannot be used with --output")
+	}
+
+	cf := &c.cf
+	// An environment file written on stdout must not be mixed with progress messages, they go to stderr.
+	if c.format != "" {
+		cf.setup(stderr, stderr)
+	} else {
+		cf.setup(stdout, stderr)
+	}
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := &mockURLResult{Services: []mockService{}, RequestID: config.RequestID, prefix: c.prefix}
+	for _, serviceRef := range serviceRefs {
+		sep := strings.LastIndex(serviceRef, ":")
+		service, err := mc.GetServiceByRef(ctx, serviceRef[:sep], serviceRef[sep+1:])
+		if err != nil {
+			return serviceError("Got error when invoking Microcks client getting Service", serviceRef, err)
+		}
+		mock, ok := mockURLs(cf.microcksURL, *service)
+		if !ok {
+			console.Warnf("Service '%s' of type %s has no HTTP mock, it is skipped", serviceRef, service.Type)
+			continue
+		}
+		result.Services = append(result.Services, mock)
+	}
+
+	switch c.format {
+	case mockFormatDotenv:
+		return writeDotenv(stdout, mockVariables(result.Services, c.prefix))
+	case mockFormatPostmanEnv:
+		return writePostmanEnv(stdout, "Microcks mocks of "+strings.Join(serviceRefs, ", "), mockVariables(result.Services, c.prefix))
+	}
+	return cf.render(result)
+}
+
+// mockService holds the mock URLs of a service.
+type mockService struct {
+	Service    string          `json:"service" yaml:"service"`
+	BaseURL    string          `json:"baseUrl" yaml:"baseUrl"`
+	Operations []mockOperation `json:"operations" yaml:"operations"`
+
+	name string
+}
+
+// mockOperation is the mock URL of an operation, a template holding its path parameters like {id}.
+type mockOperation struct {
+	Operation string `json:"operation" yaml:"operation"`
+	URL       string `json:"url" yaml:"url"`
+}
+
+// mockURLs returns the URLs of the mocks Microcks serves for service, next to the API at microcksURL. It
+// returns false for services mocked on other protocols than HTTP, like gRPC and event based ones.
+func mockURLs(microcksURL string, service connectors.Service) (mockService, bool) {
+	var kind string
+	switch service.Type {
+	case connectors.ServiceTypeREST:
+		kind = "rest"
+	case connectors.ServiceTypeGenericREST:
+		kind = "dynarest"
+	case connectors.ServiceTypeSOAP:
+		kind = "soap"
+	case connectors.ServiceTypeGraphQL:
+		kind = "graphql"
+	default:
+		return mockService{}, false
+	}
+	// Microcks encodes spaces of names as '+' in its mock URLs.
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.PathEscape(s), "%20", "+")
+	}
+	root := strings.TrimSuffix(strings.Split(microcksURL, "/api")[0], "/")
+	mock := mockService{
+		Service:    service.Name + ":" + service.Version,
+		BaseURL:    root + "/" + kind + "/" + escape(service.Name) + "/" + escape(service.Version),
+		Operations: []mockOperation{},
+		name:       service.Name,
+	}
+	for _, operation := range service.Operations {
+		u := mock.BaseURL
+		switch service.Type {
+		case connectors.ServiceTypeREST:
+			// REST operations are named after their method and path template, like GET /pets/{id}.
+			if _, path, found := strings.Cut(operation.Name, " "); found {
+				u += path
+			}
+		case connectors.ServiceTypeGenericREST:
+			u += "/" + strings.TrimPrefix(operation.Name, "/")
+		}
+		mock.Operations = append(mock.Operations, mockOperation{Operation: operation.Name, URL: u})
+	}
+	return mock, true
+}
+
+// mockURLResult is the outcome of mock url command.
+type mockURLResult struct {
+	Services  []mockService `json:"services" yaml:"services"`
+	RequestID string        `json:"requestId" yaml:"requestId"`
+
+	prefix string
+}
+
+// RenderText implements output.TextRenderer for mockURLResult.
+func (r *mockURLResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	for _, service := range r.Services {
+		fmt.Fprintf(w, "%s %s\n", styles.Bold(service.Service), service.BaseURL)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, operation := range service.Operations {
+			fmt.Fprintf(tw, "  %s\t%s\n", operation.Operation, operation.URL)
+		}
+		tw.Flush()
+	}
+}
+
+// EnvVars implements output.EnvRenderer for mockURLResult, with the variables of --format.
+func (r *mockURLResult) EnvVars() [][2]string {
+	return mockVariables(r.Services, r.prefix)
+}
+
+// mockVariables returns the variables holding the base URL and the operation URLs of services, named after
+// prefix, the service and the operation in upper snake case. Names that would collide get a numbered
+// suffix, in order.
+func mockVariables(services []mockService, prefix string) [][2]string {
+	var variables [][2]string
+	taken := map[string]bool{}
+	add := func(value string, parts ...string) {
+		name := envVariableName(append([]string{prefix}, parts...)...)
+		unique := name
+		for n := 2; taken[unique]; n++ {
+			unique = name + "_" + strconv.Itoa(n)
+		}
+		if unique != name {
+			console.Warnf("Variable %s is already defined, %s is used for %s", name, unique, value)
+		}
+		taken[unique] = true
+		variables = append(variables, [2]string{unique, value})
+	}
+	for _, service := range services {
+		add(service.BaseURL, service.name, "BASE_URL")
+		for _, operation := range service.Operations {
+			add(operation.URL, service.name, operation.Operation)
+		}
+	}
+	return variables
+}
+
+// envVariableName joins parts in an upper snake case variable name, their runs of characters other than
+// ASCII letters and digits becoming a single '_'. Names starting with a digit are prefixed by '_'.
+func envVariableName(parts ...string) string {
+	var words []string
+	for _, part := range parts {
+		words = append(words, strings.FieldsFunc(strings.ToUpper(part), func(r rune) bool {
+			return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		})...)
+	}
+	name := strings.Join(words, "_")
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// writeDotenv writes variables as KEY=value lines, values being double quoted when they hold characters
+// that dotenv parsers would interpret.
+func writeDotenv(w io.Writer, variables [][2]string) error {
+	for _, variable := range variables {
+		value := variable[1]
+		if strings.ContainsAny(value, " \t#\"'\\$`") {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`).Replace(value) + `"`
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", variable[0], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postmanEnv is a Postman environment, as imported by Postman.
+type postmanEnv struct {
+	Name   string            `json:"name"`
+	Values []postmanVariable `json:"values"`
+	Scope  string            `json:"_postman_variable_scope"`
+}
+
+type postmanVariable struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+// writePostmanEnv writes variables as a Postman environment named name.
+func writePostmanEnv(w io.Writer, name string, variables [][2]string) error {
+	env := postmanEnv{Name: name, Values: []postmanVariable{}, Scope: "environment"}
+	for _, variable := range variables {
+		env.Values = append(env.Values, postmanVariable{Key: variable[0], Value: variable[1], Type: "default", Enabled: true})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(env)
+}
+
+func (c *mockCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
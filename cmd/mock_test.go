@@ -0,0 +1,237 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestMockVariables(t *testing.T) {
+	tests := []struct {
+		name       string
+		prefix     string
+		operations []string
+		want       [][2]string
+	}{
+		{
+			name:       "path parameters",
+			operations: []string{"GET /pets/{id}", "POST /pets"},
+			want: [][2]string{
+				{"PET_STORE_BASE_URL", "base"},
+				{"PET_STORE_GET_PETS_ID", "GET /pets/{id}"},
+				{"PET_STORE_POST_PETS", "POST /pets"},
+			},
+		},
+		{
+			name:       "prefix",
+			prefix:     "vite_",
+			operations: []string{"GET /pets/{id}"},
+			want: [][2]string{
+				{"VITE_PET_STORE_BASE_URL", "base"},
+				{"VITE_PET_STORE_GET_PETS_ID", "GET /pets/{id}"},
+			},
+		},
+		{
+			name:       "collisions",
+			operations: []string{"GET /pets/{id}", "GET /pets/:id", "GET /pets/id", "BASE URL"},
+			want: [][2]string{
+				{"PET_STORE_BASE_URL", "base"},
+				{"PET_STORE_GET_PETS_ID", "GET /pets/{id}"},
+				{"PET_STORE_GET_PETS_ID_2", "GET /pets/:id"},
+				{"PET_STORE_GET_PETS_ID_3", "GET /pets/id"},
+				{"PET_STORE_BASE_URL_2", "BASE URL"},
+			},
+		},
+		{
+			name:       "awkward characters",
+			operations: []string{"get /pâtés/{id}/owners", "GET /-/_/--"},
+			want: [][2]string{
+				{"PET_STORE_BASE_URL", "base"},
+				{"PET_STORE_GET_P_T_S_ID_OWNERS", "get /pâtés/{id}/owners"},
+				{"PET_STORE_GET", "GET /-/_/--"},
+			},
+		},
+		{
+			name:       "leading digit",
+			prefix:     "2024",
+			operations: []string{},
+			want: [][2]string{
+				{"_2024_PET_STORE_BASE_URL", "base"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			configureConsole(&out, &out, false, false, slog.LevelInfo, false)
+			service := mockService{BaseURL: "base", name: "Pet-Store"}
+			for _, operation := range test.operations {
+				service.Operations = append(service.Operations, mockOperation{Operation: operation, URL: operation})
+			}
+			if got := mockVariables([]mockService{service}, test.prefix); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("mockVariables() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteDotenv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"http://mocks/rest/Pet+Store/1.0/pets/{id}", "URL=http://mocks/rest/Pet+Store/1.0/pets/{id}\n"},
+		{"http://mocks/rest/a#b", "URL=\"http://mocks/rest/a#b\"\n"},
+		{`http://mocks/rest/"$HOME"\x`, `URL="http://mocks/rest/\"\$HOME\"\\x"` + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeDotenv(&out, [][2]string{{"URL", test.value}}); err != nil {
+				t.Fatalf("writeDotenv() error = %v", err)
+			}
+			if out.String() != test.want {
+				t.Errorf("writeDotenv() = %s, want %s", out.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestMockURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		service connectors.Service
+		want    []string
+		wantOK  bool
+	}{
+		{
+			name:    "REST",
+			service: connectors.Service{Name: "Pet Store", Version: "1.0", Type: connectors.ServiceTypeREST, Operations: []connectors.Operation{{Name: "GET /pets/{id}"}}},
+			want:    []string{"http://mocks:8080/rest/Pet+Store/1.0", "http://mocks:8080/rest/Pet+Store/1.0/pets/{id}"},
+			wantOK:  true,
+		},
+		{
+			name:    "generic REST",
+			service: connectors.Service{Name: "Pets", Version: "1.0", Type: connectors.ServiceTypeGenericREST, Operations: []connectors.Operation{{Name: "pet"}}},
+			want:    []string{"http://mocks:8080/dynarest/Pets/1.0", "http://mocks:8080/dynarest/Pets/1.0/pet"},
+			wantOK:  true,
+		},
+		{
+			name:    "SOAP",
+			service: connectors.Service{Name: "Hello/Service", Version: "0.9", Type: connectors.ServiceTypeSOAP, Operations: []connectors.Operation{{Name: "sayHello"}}},
+			want:    []string{"http://mocks:8080/soap/Hello%2FService/0.9", "http://mocks:8080/soap/Hello%2FService/0.9"},
+			wantOK:  true,
+		},
+		{
+			name:    "GraphQL",
+			service: connectors.Service{Name: "Movies", Version: "1.0", Type: connectors.ServiceTypeGraphQL, Operations: []connectors.Operation{{Name: "allFilms"}}},
+			want:    []string{"http://mocks:8080/graphql/Movies/1.0", "http://mocks:8080/graphql/Movies/1.0"},
+			wantOK:  true,
+		},
+		{
+			name:    "gRPC",
+			service: connectors.Service{Name: "Greeter", Version: "1", Type: connectors.ServiceTypeGRPC},
+		},
+		{
+			name:    "event",
+			service: connectors.Service{Name: "User signed up", Version: "1", Type: connectors.ServiceTypeEvent},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock, ok := mockURLs("http://mocks:8080/api/", test.service)
+			if ok != test.wantOK {
+				t.Fatalf("mockURLs() ok = %v, want %v", ok, test.wantOK)
+			}
+			if !ok {
+				return
+			}
+			got := []string{mock.BaseURL}
+			for _, operation := range mock.Operations {
+				got = append(got, operation.URL)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("mockURLs() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMockURLFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		want  string
+	}{
+		{
+			name:  "dotenv",
+			flags: []string{"--format=dotenv", "--prefix=VITE"},
+			want: "VITE_BEER_CATALOG_API_BASE_URL=http://HOST/rest/Beer+Catalog+API/0.9\n" +
+				"VITE_BEER_CATALOG_API_GET_BEER_NAME=http://HOST/rest/Beer+Catalog+API/0.9/beer/{name}\n",
+		},
+		{
+			name:  "postman environment",
+			flags: []string{"--format=postman-env"},
+			want: `{
+  "name": "Microcks mocks of Beer Catalog API:0.9",
+  "values": [
+    {
+      "key": "BEER_CATALOG_API_BASE_URL",
+      "value": "http://HOST/rest/Beer+Catalog+API/0.9",
+      "type": "default",
+      "enabled": true
+    },
+    {
+      "key": "BEER_CATALOG_API_GET_BEER_NAME",
+      "value": "http://HOST/rest/Beer+Catalog+API/0.9/beer/{name}",
+      "type": "default",
+      "enabled": true
+    }
+  ],
+  "_postman_variable_scope": "environment"
+}
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST,
+				Operations: []connectors.Operation{{Name: "GET /beer/{name}", Method: "GET"}}})
+
+			args := append([]string{"url", "Beer Catalog API:0.9",
+				"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}, test.flags...)
+			var stdout, stderr bytes.Buffer
+			if err := NewMockCommand().Execute(context.Background(), args, &stdout, &stderr); err != nil {
+				t.Fatalf("Execute() error = %v, stderr: %s", err, stderr.String())
+			}
+			want := strings.ReplaceAll(test.want, "http://HOST", srv.URL)
+			if stdout.String() != want {
+				t.Errorf("output =\n%s\nwant\n%s", stdout.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// githubOutputEnv is set by GitHub Actions to the file collecting the outputs of the running step.
+const githubOutputEnv = "GITHUB_OUTPUT"
+
+// outputsResult is implemented by results exposing values as step outputs, by output name.
+type outputsResult interface {
+	Outputs() [][2]string
+}
+
+// writeOutputs appends every value of result to the outputs file at path, using the format of GitHub
+// Actions: name=value lines, or a heredoc for values spanning several lines.
+func writeOutputs(path string, result outputsResult) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("Cannot write outputs: %w", err)
+	}
+	defer file.Close()
+	for _, output := range result.Outputs() {
+		if err := writeOutput(file, output[0], output[1]); err != nil {
+			return fmt.Errorf("Cannot write outputs: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeResultFile writes result at path as printed by --output json, replacing the file only once
+// complete so that readers never get a partial result.
+func writeResultFile(path string, result interface{}) error {
+	var content bytes.Buffer
+	if err := output.Render(&content, output.JSON, result); err != nil {
+		return fmt.Errorf("Cannot write result file: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("Cannot write result file: %w", err)
+		}
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("Cannot write result file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("Cannot write result file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Cannot write result file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("Cannot write result file: %w", err)
+	}
+	return nil
+}
+
+// writeOutput writes a single output. Values having line breaks are written as a heredoc whose
+// delimiter is random, so that it cannot appear in the value.
+func writeOutput(w io.Writer, name string, value string) error {
+	if !strings.ContainsAny(value, "\r\n") {
+		_, err := fmt.Fprintf(w, "%s=%s\n", name, value)
+		return err
+	}
+	delimiter := "ghadelimiter_" + config.NewUUID()
+	for strings.Contains(value, delimiter) {
+		delimiter = "ghadelimiter_" + config.NewUUID()
+	}
+	_, err := fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+	return err
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteOutput(t *testing.T) {
+	heredoc := regexp.MustCompile(`^(\w+)<<(ghadelimiter_[\w-]+)\n((?s).*)\n(ghadelimiter_[\w-]+)\n$`)
+	tests := []struct {
+		name        string
+		value       string
+		wantHeredoc bool
+	}{
+		{name: "single line", value: "65f1d2c3e4b5a6978890abcd"},
+		{name: "empty", value: ""},
+		{name: "equal signs and spaces", value: "url=http://mocks/#/tests?a=b c"},
+		{name: "several lines", value: "GET /beer\nGET /beer/{name}", wantHeredoc: true},
+		{name: "carriage return", value: "GET /beer\r\nGET /beer/{name}", wantHeredoc: true},
+		{name: "trailing line break", value: "GET /beer\n", wantHeredoc: true},
+		{name: "delimiter like content", value: "a\nghadelimiter_x\nEOF\n<<b", wantHeredoc: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeOutput(&out, "operations_failed", test.value); err != nil {
+				t.Fatalf("writeOutput() error = %v", err)
+			}
+
+			// Read the output back like the runner does.
+			var name, value string
+			if match := heredoc.FindStringSubmatch(out.String()); match != nil {
+				if !test.wantHeredoc {
+					t.Errorf("writeOutput() used a heredoc for %q", test.value)
+				}
+				if match[2] != match[4] || strings.Contains(test.value, match[2]) {
+					t.Fatalf("heredoc delimiter %s is not unique in:\n%s", match[2], out.String())
+				}
+				name, value = match[1], match[3]
+			} else {
+				if test.wantHeredoc {
+					t.Fatalf("writeOutput() = %q, want a heredoc", out.String())
+				}
+				line := strings.TrimSuffix(out.String(), "\n")
+				if strings.Contains(line, "\n") {
+					t.Fatalf("writeOutput() = %q, want a single line", out.String())
+				}
+				name, value, _ = strings.Cut(line, "=")
+			}
+			if name != "operations_failed" || value != test.value {
+				t.Errorf("output read back as %s = %q, want %q", name, value, test.value)
+			}
+		})
+	}
+}
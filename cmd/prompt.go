@@ -0,0 +1,94 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// prompter asks the user for values missing from command line, environment and configuration file.
+type prompter interface {
+	// Prompt asks for the value of label, returning def when answer is empty. Secret answers are not echoed.
+	Prompt(label string, def string, secret bool) (string, error)
+}
+
+// newPrompter returns the prompter to use, or nil when the CLI must stay non-interactive because
+// --no-input is set or stdin is not a terminal. It is a variable so that tests can script answers.
+var newPrompter = func(stderr io.Writer, noInput bool) prompter {
+	return newTerminalPrompter(os.Stdin, stderr, noInput)
+}
+
+// newTerminalPrompter returns a prompter reading answers from in and prompting on out, or nil when
+// --no-input is set or in is not a terminal.
+func newTerminalPrompter(in *os.File, out io.Writer, noInput bool) prompter {
+	if noInput || !term.IsTerminal(int(in.Fd())) {
+		return nil
+	}
+	return &terminalPrompter{
+		reader: bufio.NewReader(in),
+		out:    out,
+		readPassword: func() ([]byte, error) {
+			return term.ReadPassword(int(in.Fd()))
+		},
+	}
+}
+
+// terminalPrompter prompts on out and reads answers from a terminal.
+type terminalPrompter struct {
+	reader *bufio.Reader
+	out    io.Writer
+	// readPassword reads a secret answer without echoing it.
+	readPassword func() ([]byte, error)
+}
+
+func (p *terminalPrompter) Prompt(label string, def string, secret bool) (string, error) {
+	switch {
+	case len(def) > 0 && secret:
+		fmt.Fprintf(p.out, "%s [********]: ", label)
+	case len(def) > 0:
+		fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+	default:
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+
+	var answer string
+	if secret {
+		b, err := p.readPassword()
+		fmt.Fprintln(p.out)
+		if err != nil {
+			return "", err
+		}
+		answer = string(b)
+	} else {
+		line, err := p.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return "", err
+		}
+		answer = line
+	}
+
+	answer = strings.TrimSpace(answer)
+	if len(answer) == 0 {
+		return def, nil
+	}
+	return answer, nil
+}
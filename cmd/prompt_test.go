@@ -0,0 +1,237 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+)
+
+// scriptedPrompter returns a terminal prompter reading answers, secret ones included, from the lines of script.
+func scriptedPrompter(script string, out io.Writer) *terminalPrompter {
+	reader := bufio.NewReader(strings.NewReader(script))
+	return &terminalPrompter{
+		reader: reader,
+		out:    out,
+		readPassword: func() ([]byte, error) {
+			line, err := reader.ReadString('\n')
+			if err != nil && len(line) == 0 {
+				return nil, err
+			}
+			return []byte(strings.TrimSuffix(line, "\n")), nil
+		},
+	}
+}
+
+func TestTerminalPrompter(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		def        string
+		secret     bool
+		want       string
+		wantPrompt string
+		wantErr    error
+	}{
+		{name: "answer", script: "http://mocks/api/\n", want: "http://mocks/api/", wantPrompt: "Microcks API URL: "},
+		{name: "answer over default", script: " http://mocks/api/ \n", def: "http://file/api/", want: "http://mocks/api/", wantPrompt: "Microcks API URL [http://file/api/]: "},
+		{name: "default", script: "\n", def: "http://file/api/", want: "http://file/api/", wantPrompt: "Microcks API URL [http://file/api/]: "},
+		{name: "last line", script: "http://mocks/api/", want: "http://mocks/api/", wantPrompt: "Microcks API URL: "},
+		{name: "secret", script: "s3cr3t\n", def: "file-secret", secret: true, want: "s3cr3t", wantPrompt: "Microcks API URL [********]: \n"},
+		{name: "end of input", script: "", wantPrompt: "Microcks API URL: ", wantErr: io.EOF},
+		{name: "end of secret input", script: "", secret: true, wantPrompt: "Microcks API URL: \n", wantErr: io.EOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := scriptedPrompter(test.script, &out).Prompt("Microcks API URL", test.def, test.secret)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Prompt() error = %v, want %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("Prompt() = %q, want %q", got, test.want)
+			}
+			if out.String() != test.wantPrompt {
+				t.Errorf("Prompt() wrote %q, want %q", out.String(), test.wantPrompt)
+			}
+		})
+	}
+}
+
+func TestNewTerminalPrompter(t *testing.T) {
+	// Pipes stand for stdin of CI jobs and scripts, which must never be prompted.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if p := newTerminalPrompter(r, io.Discard, false); p != nil {
+		t.Errorf("newTerminalPrompter() of a pipe = %v, want nil", p)
+	}
+	if p := newTerminalPrompter(r, io.Discard, true); p != nil {
+		t.Errorf("newTerminalPrompter() with --no-input = %v, want nil", p)
+	}
+}
+
+func TestValidatePrompts(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		file   string
+		script string
+		// interactive is false when stdin is not a terminal.
+		interactive bool
+		want        map[string]string
+		// prompted are the flags whose value must come from answers.
+		prompted   []string
+		wantErr    string
+		wantStderr string
+	}{
+		{
+			name:        "accept answers",
+			script:      "http://mocks/api/\nmicrocks-serviceaccount\ns3cr3t\n",
+			interactive: true,
+			want:        map[string]string{"microcksURL": "http://mocks/api/", "keycloakClientId": "microcks-serviceaccount", "keycloakClientSecret": "s3cr3t"},
+			prompted:    []string{"microcksURL", "keycloakClientId", "keycloakClientSecret"},
+		},
+		{
+			name:        "accept defaults of configuration file",
+			args:        []string{"--microcksURL=", "--keycloakClientSecret="},
+			file:        "microcksURL: http://file/api/\nkeycloak:\n  clientId: file-client\n  clientSecret: file-secret\n",
+			script:      "\n\n",
+			interactive: true,
+			want:        map[string]string{"microcksURL": "http://file/api/", "keycloakClientId": "file-client", "keycloakClientSecret": "file-secret"},
+			prompted:    []string{"microcksURL", "keycloakClientSecret"},
+		},
+		{
+			name:        "only missing values",
+			args:        []string{"--microcksURL=http://flag/api/"},
+			script:      "microcks-serviceaccount\ns3cr3t\n",
+			interactive: true,
+			want:        map[string]string{"microcksURL": "http://flag/api/", "keycloakClientId": "microcks-serviceaccount"},
+			prompted:    []string{"keycloakClientId", "keycloakClientSecret"},
+		},
+		{
+			name:        "decline",
+			script:      "\n",
+			interactive: true,
+			wantErr:     "--microcksURL flag is mandatory. Check Usage.",
+		},
+		{
+			name:        "invalid answer asked again",
+			script:      "mocks\nftp://mocks/\nhttp://mocks/api/\nmicrocks-serviceaccount\ns3cr3t\n",
+			interactive: true,
+			want:        map[string]string{"microcksURL": "http://mocks/api/"},
+			prompted:    []string{"microcksURL"},
+			wantStderr:  "Microcks API URL: Invalid Microcks API URL: 'mocks' is not an absolute http or https URL\nMicrocks API URL: Invalid Microcks API URL: 'ftp://mocks/' is not an absolute http or https URL\nMicrocks API URL: ",
+		},
+		{
+			name:        "invalid answers",
+			script:      "mocks\nmocks\nhttp://mocks/api/,mocks\nhttp://mocks/api/\n",
+			interactive: true,
+			wantErr:     "Invalid value for --microcksURL flag: 'mocks' is not an absolute http or https URL",
+		},
+		{
+			name:        "end of input",
+			script:      "http://mocks/api/\n",
+			interactive: true,
+			wantErr:     "Cannot read Keycloak Service Account ClientId: EOF",
+		},
+		{
+			name:    "not a terminal",
+			script:  "http://mocks/api/\nmicrocks-serviceaccount\ns3cr3t\n",
+			wantErr: "--microcksURL flag is mandatory. Check Usage.",
+		},
+		{
+			name:        "no input",
+			args:        []string{"--no-input"},
+			script:      "http://mocks/api/\nmicrocks-serviceaccount\ns3cr3t\n",
+			interactive: true,
+			wantErr:     "--microcksURL flag is mandatory. Check Usage.",
+		},
+	}
+	defer func(previous func(io.Writer, bool) prompter) { newPrompter = previous }(newPrompter)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Neither the environment nor the user configuration file of the machine must be used.
+			t.Setenv("HOME", t.TempDir())
+			for _, name := range []string{"MICROCKS_URL", "MICROCKS_KEYCLOAK_CLIENT_ID", "MICROCKS_KEYCLOAK_CLIENT_SECRET", config.ConfigEnvVar} {
+				t.Setenv(name, "")
+			}
+			if len(test.file) > 0 {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(test.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv(config.ConfigEnvVar, path)
+			}
+			var stderr bytes.Buffer
+			prompted := false
+			newPrompter = func(out io.Writer, noInput bool) prompter {
+				prompted = true
+				if noInput || !test.interactive {
+					return nil
+				}
+				return scriptedPrompter(test.script, out)
+			}
+
+			f := &clientFlags{}
+			fs := newFlagSet(usage{name: "test"})
+			f.register(fs)
+			if _, err := parseArgs(fs, test.args, io.Discard); err != nil {
+				t.Fatalf("parseArgs() error = %v", err)
+			}
+			f.stderr = &stderr
+			err := f.validate()
+			if len(test.wantErr) > 0 {
+				var usageErr *UsageError
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("validate() error = %v, want %s", err, test.wantErr)
+				}
+				if !strings.HasPrefix(test.wantErr, "Cannot read") && !errors.As(err, &usageErr) {
+					t.Errorf("validate() error = %T, want a usage error", err)
+				}
+			} else if err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			if !prompted {
+				t.Error("validate() did not ask for a prompter")
+			}
+			for name, want := range test.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			for _, name := range test.prompted {
+				if settingSources[name] != "prompt" {
+					t.Errorf("source of %s = %q, want prompt", name, settingSources[name])
+				}
+			}
+			if len(test.wantStderr) > 0 && !strings.HasPrefix(stderr.String(), test.wantStderr) {
+				t.Errorf("validate() wrote:\n%s\nwant it to start with:\n%s", stderr.String(), test.wantStderr)
+			}
+		})
+	}
+}
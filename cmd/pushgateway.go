@@ -0,0 +1,189 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/transport"
+)
+
+const (
+	// pushgatewayJob is the job grouping pushed metrics, unless overridden by a job label.
+	pushgatewayJob = "microcks-cli"
+	// Environment variables holding the credentials of the Pushgateway: basic auth or bearer token.
+	pushgatewayUsernameEnv = "MICROCKS_PUSHGATEWAY_USERNAME"
+	pushgatewayPasswordEnv = "MICROCKS_PUSHGATEWAY_PASSWORD"
+	pushgatewayTokenEnv    = "MICROCKS_PUSHGATEWAY_TOKEN"
+	// pushgatewayErrorPreview is the number of bytes of error responses reported.
+	pushgatewayErrorPreview = 512
+)
+
+// labelNamePattern matches valid Prometheus label names.
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// metricsLabels holds the labels added to pushed metrics. It is a flag.Value accepting comma separated
+// key=value pairs, possibly repeated.
+type metricsLabels map[string]string
+
+func (l metricsLabels) String() string {
+	pairs := make([]string, 0, len(l))
+	for _, name := range l.names() {
+		pairs = append(pairs, name+"="+l[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l metricsLabels) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		name, labelValue, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			return fmt.Errorf("'%s' is not a key=value pair", pair)
+		}
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("'%s' is not a valid Prometheus label name", name)
+		}
+		if name == "service" || name == "version" || name == "runner" {
+			return fmt.Errorf("label '%s' is set from the test", name)
+		}
+		l[name] = labelValue
+	}
+	return nil
+}
+
+func (l metricsLabels) names() []string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// groupingKey returns the labels identifying the metrics of result in the Pushgateway, job first.
+func groupingKey(result *testResult, extra metricsLabels) [][2]string {
+	job := pushgatewayJob
+	if value, ok := extra["job"]; ok {
+		job = value
+	}
+	service, version := result.ServiceRef, ""
+	if i := strings.LastIndex(service, ":"); i >= 0 {
+		service, version = service[:i], service[i+1:]
+	}
+	key := [][2]string{{"job", job}, {"service", service}, {"version", version}, {"runner", result.RunnerType}}
+	for _, name := range extra.names() {
+		if name != "job" {
+			key = append(key, [2]string{name, extra[name]})
+		}
+	}
+	return key
+}
+
+// groupingPath returns the URL path of a grouping key. Values are base64 encoded when they cannot
+// be used as path segments, as defined by the Pushgateway protocol.
+func groupingPath(key [][2]string) string {
+	var b strings.Builder
+	b.WriteString("metrics")
+	for _, label := range key {
+		name, value := label[0], label[1]
+		if len(value) == 0 || strings.Contains(value, "/") {
+			b.WriteString("/" + name + "@base64/" + base64.URLEncoding.EncodeToString([]byte(value)))
+			if len(value) == 0 {
+				// Empty values must be given as a single padding character.
+				b.WriteString("=")
+			}
+			continue
+		}
+		b.WriteString("/" + name + "/" + url.PathEscape(value))
+	}
+	return b.String()
+}
+
+// writeTestMetrics writes the metrics of result using the Prometheus text exposition format. Labels
+// are given by the grouping key.
+func writeTestMetrics(w io.Writer, result *testResult) {
+	success := 0
+	if result.Success {
+		success = 1
+	}
+	var duration float64
+	operations, failed := 0, len(result.failedOperations())
+	if result.details != nil {
+		duration = float64(result.details.ElapsedTime) / 1000
+		operations = len(result.details.TestCaseResults)
+	}
+	metrics := []struct {
+		name, help string
+		value      interface{}
+	}{
+		{"microcks_test_success", "Whether the test succeeded (1) or not (0).", success},
+		{"microcks_test_duration_seconds", "Duration of the test as measured by Microcks.", duration},
+		{"microcks_test_operations_total", "Number of operations tested.", operations},
+		{"microcks_test_operations_failed", "Number of operations whose test failed.", failed},
+	}
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", metric.name, metric.help, metric.name, metric.name, metric.value)
+	}
+}
+
+// pushTestMetrics replaces the metrics of the test group in the Pushgateway at gatewayURL with the ones of result.
+func pushTestMetrics(ctx context.Context, httpClient *http.Client, gatewayURL string, result *testResult, extra metricsLabels) error {
+	var body bytes.Buffer
+	writeTestMetrics(&body, result)
+
+	u := strings.TrimSuffix(gatewayURL, "/") + "/" + groupingPath(groupingKey(result, extra))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if token := os.Getenv(pushgatewayTokenEnv); len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if username := os.Getenv(pushgatewayUsernameEnv); len(username) > 0 {
+		req.SetBasicAuth(username, os.Getenv(pushgatewayPasswordEnv))
+	}
+
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Pushgateway for pushing metrics", true)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, pushgatewayErrorPreview))
+		return fmt.Errorf("unexpected status %d from Pushgateway: %s", resp.StatusCode, strings.TrimSpace(string(preview)))
+	}
+	return nil
+}
+
+// newPushgatewayClient builds the HTTP client of the Pushgateway, sharing the TLS, logging and retry
+// settings of Microcks clients.
+func newPushgatewayClient(cfg connectors.Config) *http.Client {
+	cfg.Cache = nil
+	return connectors.NewHTTPClient(cfg, nil)
+}
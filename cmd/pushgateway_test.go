@@ -0,0 +1,156 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+func TestPushTestMetrics(t *testing.T) {
+	details := &connectors.TestResult{
+		ElapsedTime: 1534,
+		TestCaseResults: []connectors.TestCaseResult{
+			{OperationName: "GET /beer", Success: true},
+			{OperationName: "GET /beer/{name}"},
+		},
+	}
+	const failedMetrics = `# HELP microcks_test_success Whether the test succeeded (1) or not (0).
+# TYPE microcks_test_success gauge
+microcks_test_success 0
+# HELP microcks_test_duration_seconds Duration of the test as measured by Microcks.
+# TYPE microcks_test_duration_seconds gauge
+microcks_test_duration_seconds 1.534
+# HELP microcks_test_operations_total Number of operations tested.
+# TYPE microcks_test_operations_total gauge
+microcks_test_operations_total 2
+# HELP microcks_test_operations_failed Number of operations whose test failed.
+# TYPE microcks_test_operations_failed gauge
+microcks_test_operations_failed 1
+`
+	tests := []struct {
+		name     string
+		result   *testResult
+		labels   string
+		env      map[string]string
+		status   int
+		wantPath string
+		wantBody string
+		wantAuth string
+		wantErr  string
+	}{
+		{
+			name:     "failed test",
+			result:   &testResult{ServiceRef: "Beer Catalog API:0.9", RunnerType: "OPEN_API_SCHEMA", details: details},
+			wantPath: "/metrics/job/microcks-cli/service/Beer%20Catalog%20API/version/0.9/runner/OPEN_API_SCHEMA",
+			wantBody: failedMetrics,
+		},
+		{
+			name:     "successful test without details",
+			result:   &testResult{ServiceRef: "Beer Catalog API:0.9", RunnerType: "HTTP", Success: true},
+			wantPath: "/metrics/job/microcks-cli/service/Beer%20Catalog%20API/version/0.9/runner/HTTP",
+			wantBody: strings.NewReplacer("microcks_test_success 0", "microcks_test_success 1", "seconds 1.534", "seconds 0",
+				"total 2", "total 0", "failed 1", "failed 0").Replace(failedMetrics),
+		},
+		{
+			name:     "job and extra labels",
+			result:   &testResult{ServiceRef: "Beer Catalog API:0.9", RunnerType: "HTTP", details: details},
+			labels:   "job=nightly,env=staging,branch=feature/beers",
+			wantPath: "/metrics/job/nightly/service/Beer%20Catalog%20API/version/0.9/runner/HTTP/branch@base64/ZmVhdHVyZS9iZWVycw==/env/staging",
+			wantBody: failedMetrics,
+		},
+		{
+			name:     "empty version",
+			result:   &testResult{ServiceRef: "Beer Catalog API", RunnerType: "HTTP", details: details},
+			wantPath: "/metrics/job/microcks-cli/service/Beer%20Catalog%20API/version@base64/=/runner/HTTP",
+			wantBody: failedMetrics,
+		},
+		{
+			name:     "bearer token",
+			result:   &testResult{ServiceRef: "Beer Catalog API:0.9", RunnerType: "HTTP", details: details},
+			env:      map[string]string{pushgatewayTokenEnv: "t0k3n", pushgatewayUsernameEnv: "ci"},
+			wantPath: "/metrics/job/microcks-cli/service/Beer%20Catalog%20API/version/0.9/runner/HTTP",
+			wantBody: failedMetrics,
+			wantAuth: "Bearer t0k3n",
+		},
+		{
+			name:     "basic auth",
+			result:   &testResult{ServiceRef: "Beer Catalog API:0.9", RunnerType: "HTTP", details: details},
+			env:      map[string]string{pushgatewayUsernameEnv: "ci", pushgatewayPasswordEnv: "s3cr3t"},
+			wantPath: "/metrics/job/microcks-cli/service/Beer%20Catalog%20API/version/0.9/runner/HTTP",
+			wantBody: failedMetrics,
+			wantAuth: "Basic Y2k6czNjcjN0",
+		},
+		{
+			name:     "refused push",
+			result:   &testResult{ServiceRef: "Beer Catalog API:0.9", RunnerType: "HTTP", details: details},
+			status:   http.StatusBadRequest,
+			wantPath: "/metrics/job/microcks-cli/service/Beer%20Catalog%20API/version/0.9/runner/HTTP",
+			wantBody: failedMetrics,
+			wantErr:  "unexpected status 400 from Pushgateway: pushed metrics are invalid",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{pushgatewayTokenEnv, pushgatewayUsernameEnv, pushgatewayPasswordEnv} {
+				t.Setenv(name, test.env[name])
+			}
+			var method, path, contentType, auth, body string
+			gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				method, path, body = r.Method, r.URL.EscapedPath(), string(b)
+				contentType, auth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+				if test.status != 0 {
+					http.Error(w, "pushed metrics are invalid", test.status)
+				}
+			}))
+			defer gateway.Close()
+
+			labels := metricsLabels{}
+			if len(test.labels) > 0 {
+				if err := labels.Set(test.labels); err != nil {
+					t.Fatalf("Set(%s) error = %v", test.labels, err)
+				}
+			}
+			err := pushTestMetrics(context.Background(), newPushgatewayClient(connectors.Config{}), gateway.URL+"/", test.result, labels)
+			if len(test.wantErr) > 0 {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("pushTestMetrics() error = %v, want %s", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("pushTestMetrics() error = %v", err)
+			}
+			if method != http.MethodPut || path != test.wantPath {
+				t.Errorf("pushed with %s %s, want PUT %s", method, path, test.wantPath)
+			}
+			if contentType != "text/plain; version=0.0.4; charset=utf-8" {
+				t.Errorf("Content-Type = %s, want text exposition format", contentType)
+			}
+			if auth != test.wantAuth {
+				t.Errorf("Authorization = %q, want %q", auth, test.wantAuth)
+			}
+			if body != test.wantBody {
+				t.Errorf("pushed metrics:\n%s\nwant:\n%s", body, test.wantBody)
+			}
+		})
+	}
+}
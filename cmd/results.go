@@ -0,0 +1,709 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+// testResult is the outcome of test command, rendered using the --output format.
+type testResult struct {
+	TestResultID string `json:"testResultId" yaml:"testResultId"`
+	ServiceRef   string `json:"serviceRef" yaml:"serviceRef"`
+	TestEndpoint string `json:"testEndpoint" yaml:"testEndpoint"`
+	RunnerType   string `json:"runnerType" yaml:"runnerType"`
+	Success      bool   `json:"success" yaml:"success"`
+	InProgress   bool   `json:"inProgress" yaml:"inProgress"`
+	// FailedFast tells the CLI stopped waiting for the test in progress as one of its operations failed.
+	FailedFast bool   `json:"failedFast,omitempty" yaml:"failedFast,omitempty"`
+	URL        string `json:"url" yaml:"url"`
+	// Environment is the name the endpoint was given, like staging in staging=https://stg/api.
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
+	// TestDate is the time the test started and ElapsedTime its duration in milliseconds, as reported by Microcks.
+	TestDate    string `json:"testDate,omitempty" yaml:"testDate,omitempty"`
+	ElapsedTime int64  `json:"elapsedTime,omitempty" yaml:"elapsedTime,omitempty"`
+	// Operations are the results of tested operations, only known when the full result was fetched.
+	Operations []testedOperation `json:"operations,omitempty" yaml:"operations,omitempty"`
+	// SuccessRate is the rate of passed operations, only set with a minimum success rate.
+	SuccessRate *float64 `json:"successRate,omitempty" yaml:"successRate,omitempty"`
+	// Comparison tells the regressions of the test, only set when compared with a previous test.
+	Comparison *testComparison `json:"comparison,omitempty" yaml:"comparison,omitempty"`
+	// Coverage tells the operations of the service exercised by the test, only set with --coverage.
+	Coverage *testCoverage `json:"coverage,omitempty" yaml:"coverage,omitempty"`
+	// Retry tells the previous test whose failed operations were launched again, only set with --retry-failed.
+	Retry *testRetry `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Attempts is the number of tests launched, only set when retries are allowed.
+	Attempts  int           `json:"attempts,omitempty" yaml:"attempts,omitempty"`
+	RequestID string        `json:"requestId" yaml:"requestId"`
+	Timings   *timingReport `json:"timings,omitempty" yaml:"timings,omitempty"`
+	// Error tells why the test could not run, in matrix tests whose other tests are still reported.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	// details is the result polled from Microcks, with test cases when the full result was fetched.
+	details *connectors.TestResult
+	// minSuccessRate is the rate SuccessRate is compared to.
+	minSuccessRate float64
+	// minCoverage is the rate of Coverage the test must reach, 0 if not gated.
+	minCoverage float64
+}
+
+// testedOperation is the result of testing an operation, as reported in structured output.
+type testedOperation struct {
+	Name        string `json:"name" yaml:"name"`
+	Success     bool   `json:"success" yaml:"success"`
+	ElapsedTime int64  `json:"elapsedTime" yaml:"elapsedTime"`
+	// Failures are the validation errors of the failed requests or messages of the operation.
+	Failures []string `json:"failures,omitempty" yaml:"failures,omitempty"`
+}
+
+// testedOperations returns the results of the operations of details, nil if it holds no test cases.
+func testedOperations(details *connectors.TestResult) []testedOperation {
+	var operations []testedOperation
+	for _, testCase := range details.TestCaseResults {
+		operations = append(operations, testedOperation{
+			Name:        testCase.OperationName,
+			Success:     testCase.Success,
+			ElapsedTime: testCase.ElapsedTime,
+			Failures:    failedSteps(testCase),
+		})
+	}
+	return operations
+}
+
+// failedSteps returns the validation errors of the failed steps of testCase, prefixed by the name of
+// their request or event message.
+func failedSteps(testCase connectors.TestCaseResult) []string {
+	var failures []string
+	for _, step := range testCase.TestStepResults {
+		if step.Success {
+			continue
+		}
+		name := step.RequestName
+		if len(name) == 0 {
+			name = step.EventMessageName
+		}
+		message := step.Message
+		if len(message) == 0 {
+			message = "no validation error reported"
+		}
+		failures = append(failures, name+": "+message)
+	}
+	return failures
+}
+
+// setTimings implements timedResult for testResult.
+func (r *testResult) setTimings(report *timingReport) {
+	r.Timings = report
+}
+
+// RenderText implements output.TextRenderer for testResult.
+func (r *testResult) RenderText(w io.Writer) {
+	r.renderFailures(w)
+	if r.SuccessRate != nil {
+		fmt.Fprintf(w, "%.1f%% of operations passed (%.1f%% required)\n", *r.SuccessRate*100, r.minSuccessRate*100)
+	}
+	r.renderComparison(w)
+	r.renderCoverage(w)
+	r.renderRetry(w)
+	fmt.Fprintln(w, output.Styles(w).Verdict(r.Success, fmt.Sprintf("Full TestResult details are available here: %s ", r.URL)))
+}
+
+// renderFailures writes the failed operations of a test, each with the validation errors of its failed
+// requests or messages.
+func (r *testResult) renderFailures(w io.Writer) {
+	if r.details == nil {
+		return
+	}
+	styles := output.Styles(w)
+	var failed []connectors.TestCaseResult
+	for _, testCase := range r.details.TestCaseResults {
+		if !testCase.Success {
+			failed = append(failed, testCase)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%d of %d operations failed:\n", len(failed), len(r.details.TestCaseResults))
+	for _, testCase := range failed {
+		fmt.Fprintf(w, "  %s %s\n", styles.Verdict(false, "FAIL"), styles.Bold(testCase.OperationName))
+		for _, failure := range failedSteps(testCase) {
+			fmt.Fprintf(w, "      - %s\n", failure)
+		}
+	}
+}
+
+// EnvVars implements output.EnvRenderer for testResult.
+func (r *testResult) EnvVars() [][2]string {
+	return [][2]string{
+		{"MICROCKS_TEST_ID", r.TestResultID},
+		{"MICROCKS_TEST_URL", r.URL},
+		{"MICROCKS_TEST_SUCCESS", strconv.FormatBool(r.Success)},
+		{"MICROCKS_TEST_IN_PROGRESS", strconv.FormatBool(r.InProgress)},
+		{"MICROCKS_TEST_SERVICE", r.ServiceRef},
+		{"MICROCKS_TEST_ENDPOINT", r.TestEndpoint},
+		{"MICROCKS_TEST_RUNNER", r.RunnerType},
+		{"MICROCKS_REQUEST_ID", r.RequestID},
+	}
+}
+
+// TektonResults implements tektonResult for testResult.
+func (r *testResult) TektonResults() [][2]string {
+	return [][2]string{
+		{"test-id", r.TestResultID},
+		{"test-success", strconv.FormatBool(r.Success)},
+		{"test-url", r.URL},
+		{"operations-failed", strings.Join(r.failedOperations(), ",")},
+	}
+}
+
+// Outputs implements outputsResult for testResult.
+func (r *testResult) Outputs() [][2]string {
+	return [][2]string{
+		{"test_id", r.TestResultID},
+		{"test_url", r.URL},
+		{"success", strconv.FormatBool(r.Success)},
+		{"operations_failed", strings.Join(r.failedOperations(), ",")},
+	}
+}
+
+// applySuccessRate computes the rate of passed operations of a completed test, making it succeed if
+// this rate reaches minimum. Tests whose operations are unknown keep the verdict of Microcks.
+func (r *testResult) applySuccessRate(minimum float64) {
+	if r.InProgress || r.details == nil || len(r.details.TestCaseResults) == 0 {
+		return
+	}
+	passed := 0
+	for _, testCase := range r.details.TestCaseResults {
+		if testCase.Success {
+			passed++
+		}
+	}
+	rate := float64(passed) / float64(len(r.details.TestCaseResults))
+	r.SuccessRate, r.minSuccessRate = &rate, minimum
+	r.Success = rate >= minimum
+}
+
+// failure describes why the test did not succeed, empty if it did.
+func (r *testResult) failure() string {
+	switch {
+	case r.Success:
+		return ""
+	case len(r.Error) > 0:
+		return fmt.Sprintf("Test of %s on %s could not run: %s", r.ServiceRef, r.TestEndpoint, r.Error)
+	case r.FailedFast:
+		return fmt.Sprintf("Test %s of %s has failed operations, see %s", r.TestResultID, r.ServiceRef, r.URL)
+	case r.InProgress:
+		return fmt.Sprintf("Test %s of %s is still in progress, see %s", r.TestResultID, r.ServiceRef, r.URL)
+	case r.coverageFailed():
+		return fmt.Sprintf("Test %s of %s exercised %.1f%% of operations, %.1f%% required, see %s", r.TestResultID, r.ServiceRef, r.Coverage.Rate*100, r.minCoverage*100, r.URL)
+	default:
+		return fmt.Sprintf("Test %s of %s did not succeed, see %s", r.TestResultID, r.ServiceRef, r.URL)
+	}
+}
+
+// failedOperations returns the names of the operations whose test failed.
+func (r *testResult) failedOperations() []string {
+	var names []string
+	if r.details != nil {
+		for _, testCase := range r.details.TestCaseResults {
+			if !testCase.Success {
+				names = append(names, testCase.OperationName)
+			}
+		}
+	}
+	return names
+}
+
+// importedArtifact is the outcome of a single artifact import.
+type importedArtifact struct {
+	File         string `json:"file" yaml:"file"`
+	MainArtifact bool   `json:"mainArtifact" yaml:"mainArtifact"`
+	Service      string `json:"service" yaml:"service"`
+}
+
+// importSummary counts the artifacts uploaded and skipped by import command with --if-newer.
+type importSummary struct {
+	Uploaded int `json:"uploaded" yaml:"uploaded"`
+	Skipped  int `json:"skipped" yaml:"skipped"`
+}
+
+// importResult is the outcome of import command, rendered using the --output format.
+type importResult struct {
+	Artifacts []importedArtifact `json:"artifacts" yaml:"artifacts"`
+	Skipped   []skippedArtifact  `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	Summary   *importSummary     `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Diffs     []artifactDiff     `json:"diffs,omitempty" yaml:"diffs,omitempty"`
+	RequestID string             `json:"requestId" yaml:"requestId"`
+	Timings   *timingReport      `json:"timings,omitempty" yaml:"timings,omitempty"`
+
+	// summarize asks for a one-line text summary instead of one line per artifact.
+	summarize bool
+}
+
+// setTimings implements timedResult for importResult.
+func (r *importResult) setTimings(report *timingReport) {
+	r.Timings = report
+}
+
+// RenderText implements output.TextRenderer for importResult.
+func (r *importResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	renderDiffs(w, r.Diffs)
+	for _, skipped := range r.Skipped {
+		fmt.Fprintf(w, "Skipped %s for '%s': %s\n", skipped.File, styles.Bold(skipped.Service), skipped.Reason)
+	}
+	if r.summarize {
+		services := make([]string, len(r.Artifacts))
+		for i, artifact := range r.Artifacts {
+			services[i] = "'" + styles.Bold(artifact.Service) + "'"
+		}
+		fmt.Fprintf(w, "Microcks has discovered %d artifact(s): %s\n", len(r.Artifacts), strings.Join(services, ", "))
+	} else {
+		for _, artifact := range r.Artifacts {
+			fmt.Fprintf(w, "Microcks has discovered '%s'\n", styles.Bold(artifact.Service))
+		}
+	}
+	if r.Summary != nil {
+		fmt.Fprintf(w, "Uploaded %d artifact(s), skipped %d\n", r.Summary.Uploaded, r.Summary.Skipped)
+	}
+}
+
+// EnvVars implements output.EnvRenderer for importResult. Services are numbered from 1.
+func (r *importResult) EnvVars() [][2]string {
+	vars := [][2]string{{"MICROCKS_IMPORT_COUNT", strconv.Itoa(len(r.Artifacts))}}
+	for i, artifact := range r.Artifacts {
+		vars = append(vars, [2]string{fmt.Sprintf("MICROCKS_IMPORT_SERVICE_%d", i+1), artifact.Service})
+	}
+	if len(r.Diffs) > 0 {
+		vars = append(vars, [2]string{"MICROCKS_IMPORT_BREAKING_CHANGES", strconv.Itoa(breakingChanges(r.Diffs))})
+	}
+	if r.Summary != nil {
+		vars = append(vars, [2]string{"MICROCKS_IMPORT_SKIPPED", strconv.Itoa(r.Summary.Skipped)})
+	}
+	return append(vars, [2]string{"MICROCKS_REQUEST_ID", r.RequestID})
+}
+
+// TektonResults implements tektonResult for importResult.
+func (r *importResult) TektonResults() [][2]string {
+	services := make([]string, len(r.Artifacts))
+	for i, artifact := range r.Artifacts {
+		services[i] = artifact.Service
+	}
+	return [][2]string{{"discovered-services", strings.Join(services, ",")}}
+}
+
+// Outputs implements outputsResult for importResult.
+func (r *importResult) Outputs() [][2]string {
+	services := make([]string, len(r.Artifacts))
+	for i, artifact := range r.Artifacts {
+		services[i] = artifact.Service
+	}
+	return [][2]string{{"discovered_services", strings.Join(services, ",")}}
+}
+
+// Status of a run step.
+const (
+	stepSucceeded = "succeeded"
+	stepFailed    = "failed"
+	stepSkipped   = "skipped"
+)
+
+// runStepResult is the outcome of a step of run command.
+type runStepResult struct {
+	Name   string          `json:"name" yaml:"name"`
+	Kind   string          `json:"kind" yaml:"kind"`
+	Status string          `json:"status" yaml:"status"`
+	Error  string          `json:"error,omitempty" yaml:"error,omitempty"`
+	Test   *testResult     `json:"test,omitempty" yaml:"test,omitempty"`
+	Import *importResult   `json:"import,omitempty" yaml:"import,omitempty"`
+	Steps  []runStepResult `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+// runResult is the outcome of run command, rendered using the --output format.
+type runResult struct {
+	Steps     []runStepResult `json:"steps" yaml:"steps"`
+	Success   bool            `json:"success" yaml:"success"`
+	RequestID string          `json:"requestId" yaml:"requestId"`
+	Timings   *timingReport   `json:"timings,omitempty" yaml:"timings,omitempty"`
+}
+
+// setTimings implements timedResult for runResult.
+func (r *runResult) setTimings(report *timingReport) {
+	r.Timings = report
+}
+
+// RenderText implements output.TextRenderer for runResult.
+func (r *runResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	fmt.Fprintln(w, styles.Bold("Run summary:"))
+	var renderSteps func(steps []runStepResult, indent string)
+	renderSteps = func(steps []runStepResult, indent string) {
+		for _, step := range steps {
+			status := fmt.Sprintf("%-9s", step.Status)
+			if step.Status != stepSkipped {
+				status = styles.Verdict(step.Status == stepSucceeded, status)
+			}
+			line := fmt.Sprintf("%s%s %s (%s)", indent, status, step.Name, step.Kind)
+			switch {
+			case len(step.Error) > 0:
+				line += ": " + step.Error
+			case step.Test != nil:
+				line += ": " + step.Test.URL
+			case step.Import != nil:
+				services := make([]string, len(step.Import.Artifacts))
+				for i, artifact := range step.Import.Artifacts {
+					services[i] = "'" + artifact.Service + "'"
+				}
+				line += ": " + strings.Join(services, ", ")
+			}
+			fmt.Fprintln(w, line)
+			renderSteps(step.Steps, indent+"  ")
+		}
+	}
+	renderSteps(r.Steps, "  ")
+}
+
+// EnvVars implements output.EnvRenderer for runResult.
+func (r *runResult) EnvVars() [][2]string {
+	failed := 0
+	for _, step := range r.Steps {
+		if step.Status == stepFailed {
+			failed++
+		}
+	}
+	return [][2]string{
+		{"MICROCKS_RUN_SUCCESS", strconv.FormatBool(r.Success)},
+		{"MICROCKS_RUN_STEPS", strconv.Itoa(len(r.Steps))},
+		{"MICROCKS_RUN_FAILED_STEPS", strconv.Itoa(failed)},
+		{"MICROCKS_REQUEST_ID", r.RequestID},
+	}
+}
+
+// testRequest is a request that would launch a test.
+type testRequest struct {
+	Method  string                 `json:"method" yaml:"method"`
+	URL     string                 `json:"url" yaml:"url"`
+	Payload map[string]interface{} `json:"payload" yaml:"payload"`
+	// body is the payload as sent.
+	body string
+}
+
+// testDryRunResult is the result of test command in dry-run mode.
+type testDryRunResult struct {
+	Requests  []testRequest `json:"requests" yaml:"requests"`
+	RequestID string        `json:"requestId" yaml:"requestId"`
+}
+
+func (r *testDryRunResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	for _, request := range r.Requests {
+		fmt.Fprintf(w, "%s %s\n", styles.Bold(request.Method), request.URL)
+		fmt.Fprintln(w, request.body)
+	}
+	fmt.Fprintln(w, styles.Verdict(true, "Dry run: test options are valid, no test launched"))
+}
+
+// testArchiveResult is the result of test archive command.
+type testArchiveResult struct {
+	File         string `json:"file" yaml:"file"`
+	TestResultID string `json:"testResultId" yaml:"testResultId"`
+	Files        int    `json:"files" yaml:"files"`
+	Size         int64  `json:"size" yaml:"size"`
+	RequestID    string `json:"requestId" yaml:"requestId"`
+}
+
+func (r *testArchiveResult) RenderText(w io.Writer) {
+	fmt.Fprintln(w, output.Styles(w).Verdict(true, fmt.Sprintf("Test %s archived in %s (%d files, %d bytes)", r.TestResultID, r.File, r.Files, r.Size)))
+}
+
+// EnvVars implements output.EnvRenderer for testArchiveResult.
+func (r *testArchiveResult) EnvVars() [][2]string {
+	return [][2]string{
+		{"MICROCKS_TEST_ARCHIVE", r.File},
+		{"MICROCKS_TEST_ID", r.TestResultID},
+	}
+}
+
+// runPlanStep is a step of run command plan.
+type runPlanStep struct {
+	Name        string        `json:"name" yaml:"name"`
+	Kind        string        `json:"kind" yaml:"kind"`
+	MicrocksURL string        `json:"microcksURL" yaml:"microcksURL"`
+	Description string        `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       []runPlanStep `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+// runPlan is the plan printed by run command in dry-run mode.
+type runPlan struct {
+	Steps []runPlanStep `json:"steps" yaml:"steps"`
+}
+
+func newRunPlan(file *config.RunFile, microcksURL string) *runPlan {
+	var planSteps func(steps []config.RunStep, prefix string, microcksURL string) []runPlanStep
+	planSteps = func(steps []config.RunStep, prefix string, microcksURL string) []runPlanStep {
+		plan := make([]runPlanStep, 0, len(steps))
+		for i, step := range steps {
+			stepURL := microcksURL
+			if len(step.MicrocksURL) > 0 {
+				stepURL = step.MicrocksURL
+			}
+			index := prefix + fmt.Sprint(i+1)
+			planStep := runPlanStep{Name: stepName(step, index), Kind: step.Kind(), MicrocksURL: stepURL}
+			switch {
+			case step.Import != nil:
+				planStep.Description = "import " + strings.Join(step.Import.Files, ", ")
+				if step.Import.MainArtifact != nil && !*step.Import.MainArtifact {
+					planStep.Description += " as secondary artifacts"
+				}
+				if len(step.Import.Labels) > 0 {
+					planStep.Description += fmt.Sprintf(" with labels %v", step.Import.Labels)
+				}
+			case step.Test != nil:
+				planStep.Description = fmt.Sprintf("test '%s' on %s with %s runner", step.Test.ServiceRef, step.Test.Endpoint, step.Test.Runner)
+			default:
+				planStep.Steps = planSteps(step.Parallel, index+".", stepURL)
+			}
+			if step.ContinueOnError {
+				planStep.Description += " (continue on error)"
+			}
+			plan = append(plan, planStep)
+		}
+		return plan
+	}
+	return &runPlan{Steps: planSteps(file.Steps, "", microcksURL)}
+}
+
+// RenderText implements output.TextRenderer for runPlan.
+func (p *runPlan) RenderText(w io.Writer) {
+	fmt.Fprintln(w, output.Styles(w).Bold("Run plan:"))
+	var renderSteps func(steps []runPlanStep, indent string)
+	renderSteps = func(steps []runPlanStep, indent string) {
+		for i, step := range steps {
+			if step.Kind == "parallel" {
+				fmt.Fprintf(w, "%s%d. %s (in parallel)%s\n", indent, i+1, step.Name, step.Description)
+				renderSteps(step.Steps, indent+"   ")
+				continue
+			}
+			fmt.Fprintf(w, "%s%d. %s: %s on %s\n", indent, i+1, step.Name, step.Description, step.MicrocksURL)
+		}
+	}
+	renderSteps(p.Steps, "  ")
+}
+
+// contextValidation is the outcome of the validation of a configuration file context.
+type contextValidation struct {
+	Name        string   `json:"name" yaml:"name"`
+	MicrocksURL string   `json:"microcksURL" yaml:"microcksURL"`
+	Problems    []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+	// Online tells if connection to Microcks has been checked successfully.
+	Online bool `json:"online,omitempty" yaml:"online,omitempty"`
+}
+
+// configValidation is the outcome of config validate command, rendered using the --output format.
+type configValidation struct {
+	File     string              `json:"file" yaml:"file"`
+	Valid    bool                `json:"valid" yaml:"valid"`
+	Warnings []string            `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+	Contexts []contextValidation `json:"contexts" yaml:"contexts"`
+}
+
+// RenderText implements output.TextRenderer for configValidation.
+func (r *configValidation) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	fmt.Fprintf(w, "Configuration file %s\n", r.File)
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "  %s\n", styles.Warning(warning))
+	}
+	for _, context := range r.Contexts {
+		status := "valid"
+		switch {
+		case len(context.Problems) > 0:
+			status = "invalid"
+		case context.Online:
+			status = "valid, connected"
+		}
+		fmt.Fprintf(w, "  %s %s (%s)\n", styles.Verdict(len(context.Problems) == 0, fmt.Sprintf("%-16s", status)), context.Name, context.MicrocksURL)
+		for _, problem := range context.Problems {
+			fmt.Fprintf(w, "      %s\n", problem)
+		}
+	}
+	if r.Valid {
+		fmt.Fprintln(w, styles.Verdict(true, "Configuration is valid"))
+	} else {
+		fmt.Fprintln(w, styles.Verdict(false, "Configuration is invalid"))
+	}
+}
+
+// EnvVars implements output.EnvRenderer for configValidation.
+func (r *configValidation) EnvVars() [][2]string {
+	return [][2]string{
+		{"MICROCKS_CONFIG_FILE", r.File},
+		{"MICROCKS_CONFIG_VALID", strconv.FormatBool(r.Valid)},
+	}
+}
+
+// doctorCertificate describes a certificate served by Microcks.
+type doctorCertificate struct {
+	Subject  string `json:"subject" yaml:"subject"`
+	Issuer   string `json:"issuer" yaml:"issuer"`
+	NotAfter string `json:"notAfter" yaml:"notAfter"`
+}
+
+// doctorCheck is the outcome of a check of doctor command.
+type doctorCheck struct {
+	Name         string              `json:"name" yaml:"name"`
+	Status       string              `json:"status" yaml:"status"`
+	Detail       string              `json:"detail,omitempty" yaml:"detail,omitempty"`
+	Hint         string              `json:"hint,omitempty" yaml:"hint,omitempty"`
+	Certificates []doctorCertificate `json:"certificates,omitempty" yaml:"certificates,omitempty"`
+}
+
+// doctorResult is the outcome of doctor command, rendered using the --output format.
+type doctorResult struct {
+	MicrocksURL string        `json:"microcksURL" yaml:"microcksURL"`
+	Checks      []doctorCheck `json:"checks" yaml:"checks"`
+	Success     bool          `json:"success" yaml:"success"`
+	RequestID   string        `json:"requestId" yaml:"requestId"`
+	Timings     *timingReport `json:"timings,omitempty" yaml:"timings,omitempty"`
+}
+
+// setTimings implements timedResult for doctorResult.
+func (r *doctorResult) setTimings(report *timingReport) {
+	r.Timings = report
+}
+
+// RenderText implements output.TextRenderer for doctorResult.
+func (r *doctorResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	fmt.Fprintf(w, "Diagnosis of %s\n", styles.Bold(r.MicrocksURL))
+	for _, check := range r.Checks {
+		status := strings.ToUpper(check.Status)
+		if check.Status != checkSkipped {
+			status = styles.Verdict(check.Status == checkPassed, status)
+		}
+		fmt.Fprintf(w, "  %s %-18s %s\n", status, check.Name, check.Detail)
+		for _, cert := range check.Certificates {
+			fmt.Fprintf(w, "       - %s (issued by %s, expires %s)\n", cert.Subject, cert.Issuer, cert.NotAfter)
+		}
+		if len(check.Hint) > 0 {
+			fmt.Fprintf(w, "       %s\n", styles.Warning("Hint: "+check.Hint))
+		}
+	}
+}
+
+// EnvVars implements output.EnvRenderer for doctorResult. MICROCKS_DOCTOR_FAILED_CHECK is the name
+// of the failed check, empty on success.
+func (r *doctorResult) EnvVars() [][2]string {
+	var failed string
+	for _, check := range r.Checks {
+		if check.Status == checkFailed {
+			failed = check.Name
+			break
+		}
+	}
+	return [][2]string{
+		{"MICROCKS_DOCTOR_SUCCESS", strconv.FormatBool(r.Success)},
+		{"MICROCKS_DOCTOR_FAILED_CHECK", failed},
+		{"MICROCKS_REQUEST_ID", r.RequestID},
+	}
+}
+
+// serviceList is the outcome of services list command, rendered using the --output format.
+type serviceList struct {
+	Services  []connectors.Service `json:"services" yaml:"services"`
+	RequestID string               `json:"requestId" yaml:"requestId"`
+
+	// columns are the columns of text output chosen with --columns, defaults are used if empty.
+	columns []string
+}
+
+// RenderText implements output.TextRenderer for serviceList.
+func (r *serviceList) RenderText(w io.Writer) {
+	r.renderTable(w, defaultServiceColumns)
+}
+
+// RenderWide implements output.WideRenderer for serviceList, adding labels and source artifact.
+func (r *serviceList) RenderWide(w io.Writer) {
+	r.renderTable(w, wideServiceColumns)
+}
+
+func (r *serviceList) renderTable(w io.Writer, defaults []string) {
+	if len(r.Services) == 0 {
+		fmt.Fprintln(w, "No service found")
+		return
+	}
+	names := r.columns
+	if len(names) == 0 {
+		names = defaults
+	}
+	columns := make([]serviceColumn, len(names))
+	headers := make([]string, len(names))
+	for i, name := range names {
+		columns[i], _ = findServiceColumn(name)
+		headers[i] = columns[i].header
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, service := range r.Services {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = column.value(service)
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	tw.Flush()
+}
+
+// EnvVars implements output.EnvRenderer for serviceList. Services are numbered from 1.
+func (r *serviceList) EnvVars() [][2]string {
+	vars := [][2]string{{"MICROCKS_SERVICES_COUNT", strconv.Itoa(len(r.Services))}}
+	for i, service := range r.Services {
+		vars = append(vars, [2]string{fmt.Sprintf("MICROCKS_SERVICE_%d", i+1), service.Name + ":" + service.Version})
+	}
+	return append(vars, [2]string{"MICROCKS_REQUEST_ID", r.RequestID})
+}
+
+// formatLabels writes labels as sorted key=value pairs, or <none>.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "<none>"
+	}
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// formatMillis writes a Microcks timestamp in milliseconds as an UTC RFC 3339 date, empty if unset.
+func formatMillis(millis int64) string {
+	if millis == 0 {
+		return ""
+	}
+	return time.UnixMilli(millis).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+func TestResultGolden(t *testing.T) {
+	details := &connectors.TestResult{
+		TestCaseResults: []connectors.TestCaseResult{
+			{OperationName: "GET /beer", Success: true, ElapsedTime: 12},
+			{OperationName: "GET /beer/{name}", ElapsedTime: 31, TestStepResults: []connectors.TestStepResult{
+				{RequestName: "Rodenbach", Message: "Response code 404 is not expected"},
+			}},
+		},
+	}
+	imported := &importResult{
+		Artifacts: []importedArtifact{
+			{File: "beer-catalog-api-openapi.yaml", MainArtifact: true, Service: "Beer Catalog API:0.9"},
+			{File: "beer-catalog-api-postman.json", Service: "Beer Catalog API:0.9"},
+		},
+		RequestID: "run-1",
+	}
+	tested := &testResult{
+		TestResultID: "65f1d2c3e4b5a6978890abcd",
+		ServiceRef:   "Beer Catalog API:0.9",
+		TestEndpoint: "http://beers:8080/api",
+		RunnerType:   "OPEN_API_SCHEMA",
+		URL:          "http://microcks/#/tests/65f1d2c3e4b5a6978890abcd",
+		TestDate:     "2024-03-13T09:27:31Z",
+		ElapsedTime:  43,
+		Operations:   testedOperations(details),
+		RequestID:    "run-1",
+		details:      details,
+	}
+	results := []struct {
+		name   string
+		result interface{}
+	}{
+		{"import", imported},
+		{"test", tested},
+		{"export", &exportResult{
+			File:      "snapshot.json",
+			Services:  []string{"Beer Catalog API:0.9", "Pastry API:2.0"},
+			Size:      2048,
+			RequestID: "run-1",
+		}},
+		{"run", &runResult{
+			Steps: []runStepResult{
+				{Name: "import-beers", Kind: "import", Status: stepSucceeded, Import: imported},
+				{Name: "test-beers", Kind: "test", Status: stepFailed, Test: tested},
+				{Name: "smoke", Kind: "group", Status: stepSkipped, Steps: []runStepResult{
+					{Name: "test-pastries", Kind: "test", Status: stepSkipped},
+				}},
+			},
+			RequestID: "run-1",
+		}},
+		{"list", &serviceList{
+			Services: []connectors.Service{
+				{ID: "1", Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST, SourceArtifact: "beer-catalog-api-openapi.yaml",
+					Metadata: &connectors.ServiceMetadata{Labels: map[string]string{"domain": "beers", "status": "stable"}}},
+				{ID: "2", Name: "User signed-up API", Version: "0.1.1", Type: connectors.ServiceTypeEvent},
+			},
+			RequestID: "run-1",
+		}},
+	}
+	formats := []output.Format{output.Text, output.JSON, output.YAML, output.Env}
+	for _, result := range results {
+		for _, format := range formats {
+			name := result.name + "." + string(format)
+			t.Run(name, func(t *testing.T) {
+				var out bytes.Buffer
+				if err := output.Render(&out, format, result.result); err != nil {
+					t.Fatalf("Render() error = %v", err)
+				}
+
+				golden := filepath.Join("testdata", "results", name+".golden")
+				if *updateGolden {
+					if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("cannot read golden file, run 'go test ./cmd -run TestResultGolden -update': %v", err)
+				}
+				if !bytes.Equal(out.Bytes(), want) {
+					t.Errorf("%s output differs from %s, run 'go test ./cmd -run TestResultGolden -update' if intended:\n%s", format, golden, out.String())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// testRetry tells which operations of a previous test were launched again by --retry-failed.
+type testRetry struct {
+	PreviousID string   `json:"previousTestResultId" yaml:"previousTestResultId"`
+	Operations []string `json:"operations" yaml:"operations"`
+}
+
+// executeRetryFailed launches again the operations that failed in the test having identifier --retry-failed,
+// on the same endpoint with the same runner, and reports all the operations of this test, the ones launched
+// again replacing the failed ones.
+func (c *testCommand) executeRetryFailed(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) > 0 || len(c.endpoints) > 0 || len(c.broker) > 0 || len(c.topic) > 0 || len(c.binding) > 0 {
+		return usageErrorf("--retry-failed flag replaces args, testing the service on the endpoint of the previous test. Check Usage.")
+	}
+	if len(c.file) > 0 {
+		return usageErrorf("--retry-failed and --file flags are mutually exclusive")
+	}
+	if len(c.filteredOperations) > 0 {
+		return usageErrorf("--retry-failed and --filteredOperations flags are mutually exclusive, the failed operations being the filtered ones")
+	}
+	if len(c.compareWith) > 0 {
+		return usageErrorf("--retry-failed and --compare-with flags are mutually exclusive")
+	}
+	if c.dryRun {
+		return usageErrorf("--retry-failed flag cannot be used with --dry-run")
+	}
+	if err := c.checkFlags(); err != nil {
+		return err
+	}
+
+	cf := &c.cf
+	cf.silent = c.teamCity
+	cf.setup(stdout, stderr)
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	waitForMilliseconds, err := parseWaitFor(c.waitFor)
+	if err != nil {
+		return usageErrorf("Invalid value for --waitFor flag: %s", err)
+	}
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous, err := mc.GetFullTestResult(ctx, c.retryFailed)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving test to retry", err)
+	}
+	if previous.InProgress {
+		return usageErrorf("Test %s is still in progress, wait for it with 'microcks-cli test wait %s'", previous.ID, previous.ID)
+	}
+	var failed []string
+	for _, testCase := range previous.TestCaseResults {
+		if !testCase.Success {
+			failed = append(failed, testCase.OperationName)
+		}
+	}
+	if len(failed) == 0 {
+		return usageErrorf("Test %s has no failed operations to retry", previous.ID)
+	}
+	service, err := mc.GetService(ctx, previous.ServiceID)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving service of test", err)
+	}
+	serviceRef := service.Name + ":" + service.Version
+	if err := cf.requireRunner(ctx, mc, previous.RunnerType); err != nil {
+		return err
+	}
+
+	// Operations are launched again the way they were, unless flags tell otherwise.
+	if err := c.reuseTestOptions(previous); err != nil {
+		return err
+	}
+	filteredOperations, err := json.Marshal(failed)
+	if err != nil {
+		return err
+	}
+	c.filteredOperations = string(filteredOperations)
+
+	if c.teamCity {
+		writeTeamCityMessage(cf.decorationsOutput(), "testSuiteStarted", [][2]string{{"name", serviceRef}})
+		defer writeTeamCityMessage(cf.decorationsOutput(), "testSuiteFinished", [][2]string{{"name", serviceRef}})
+	}
+	spec := c.newSpec(serviceRef, previous.TestedEndpoint, previous.RunnerType, waitForMilliseconds)
+	spec.fetchFull = true
+	// The coverage of the retried operations alone is meaningless, it is computed once merged.
+	coverage := spec.coverage
+	spec.coverage = false
+	c.warnShortTimeout([]testSpec{spec})
+	cleanup, err := c.applySecret(ctx, mc)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	console.Printf("Retrying %d failed operations of test %s: %s\n", len(failed), previous.ID, strings.Join(failed, ", "))
+	result, err := runTest(ctx, mc, cf.microcksURL, spec)
+	if err != nil {
+		return err
+	}
+	result.applyRetry(previous, failed)
+	if spec.minSuccessRate > 0 {
+		result.applySuccessRate(spec.minSuccessRate)
+	}
+	if coverage {
+		result.applyCoverage(service.Operations, spec.minCoverage)
+	}
+	return c.reportTest(ctx, result)
+}
+
+// reuseTestOptions sets the secret, operations headers and OAuth2 context of previous as options of the
+// test, for the ones not given by flags, and merges --globalHeader flags into operations headers.
+func (c *testCommand) reuseTestOptions(previous *connectors.TestResult) error {
+	if len(c.secretName) == 0 && previous.SecretRef != nil {
+		c.secretName = previous.SecretRef.Name
+	}
+	if len(c.operationsHeaders) == 0 && len(previous.OperationsHeaders) > 0 {
+		headers := map[string][]connectors.HeaderDTO{}
+		for operation, testHeaders := range previous.OperationsHeaders {
+			for _, header := range testHeaders {
+				headers[operation] = append(headers[operation], connectors.HeaderDTO{Name: header.Name, Values: strings.Join(header.Values, ",")})
+			}
+		}
+		data, err := json.Marshal(headers)
+		if err != nil {
+			return err
+		}
+		c.operationsHeaders = string(data)
+	}
+	if len(c.oAuth2Context) == 0 && previous.OAuth2Context != nil {
+		data, err := json.Marshal(previous.OAuth2Context)
+		if err != nil {
+			return err
+		}
+		c.oAuth2Context = string(data)
+	}
+
+	var err error
+	if c.operationsHeaders, err = mergeGlobalHeaders(c.operationsHeaders, c.globalHeaders); err != nil {
+		return usageErrorf("Invalid --globalHeader flag: %s", err)
+	}
+	if err := connectors.ValidateTestOptions(c.filteredOperations, c.operationsHeaders, c.oAuth2Context); err != nil {
+		return usageErrorf("Invalid test options: %s", err)
+	}
+	return nil
+}
+
+// applyRetry merges the operations of previous into a completed test launching again its failed ones, the
+// outcome of each retried operation replacing the previous one. The test succeeds if all the operations of
+// previous now pass. Tests whose operations are unknown keep the verdict of Microcks.
+func (r *testResult) applyRetry(previous *connectors.TestResult, retried []string) {
+	r.Retry = &testRetry{PreviousID: previous.ID, Operations: retried}
+	if r.InProgress || r.details == nil || len(r.details.TestCaseResults) == 0 {
+		return
+	}
+	retriedCases := map[string]connectors.TestCaseResult{}
+	for _, testCase := range r.details.TestCaseResults {
+		retriedCases[testCase.OperationName] = testCase
+	}
+	merged := *r.details
+	merged.TestCaseResults = nil
+	merged.Success = true
+	for _, testCase := range previous.TestCaseResults {
+		if retriedCase, ok := retriedCases[testCase.OperationName]; ok {
+			testCase = retriedCase
+		}
+		merged.TestCaseResults = append(merged.TestCaseResults, testCase)
+		merged.Success = merged.Success && testCase.Success
+	}
+	r.details = &merged
+	r.Operations = testedOperations(&merged)
+	r.Success = merged.Success
+}
+
+// renderRetry writes the test whose failed operations were launched again.
+func (r *testResult) renderRetry(w io.Writer) {
+	if r.Retry == nil {
+		return
+	}
+	fmt.Fprintf(w, "Retried %d failed operations of test %s\n", len(r.Retry.Operations), r.Retry.PreviousID)
+}
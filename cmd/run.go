@@ -0,0 +1,308 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+var runUsage = usage{
+	name:        "run",
+	synopsis:    "run -f <file> [flags]",
+	description: "Run the import and test steps described in a YAML file.",
+	examples: []string{
+		"microcks-cli run -f microcks.yaml",
+		"microcks-cli run -f microcks.yaml --dry-run",
+	},
+}
+
+type runCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	file   string
+	dryRun bool
+
+	clients map[config.Settings]stepClient
+}
+
+// stepClient is a client connected with the settings of a step.
+type stepClient struct {
+	mc          connectors.MicrocksClient
+	microcksURL string
+}
+
+// NewRunCommand build a new RunCommand implementation
+func NewRunCommand() Command {
+	c := new(runCommand)
+	c.fs = newFlagSet(runUsage)
+	c.cf.register(c.fs)
+	c.fs.StringVar(&c.file, "f", "", "Path of the YAML file describing steps to run")
+	c.fs.StringVar(&c.file, "file", "", "Path of the YAML file describing steps to run (alias of -f)")
+	c.fs.BoolVar(&c.dryRun, "dry-run", false, "Print the plan of steps without running them")
+	c.clients = map[config.Settings]stepClient{}
+	return c
+}
+
+func (c *runCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of runCommand structure
+func (c *runCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if err := runUsage.checkArgs(args); err != nil {
+		return err
+	}
+	if len(c.file) == 0 {
+		return usageErrorf("-f flag is mandatory. Check Usage.")
+	}
+
+	runFile, err := config.LoadRunFile(c.file)
+	if err != nil {
+		return err
+	}
+
+	cf := &c.cf
+	cf.setup(stdout, stderr)
+
+	// Shared settings of run file apply to flags not given on command line or environment.
+	if err := c.overrideSettings(runFile.Settings.FlagValues(), "run file "+c.file); err != nil {
+		return err
+	}
+	if c.dryRun {
+		return cf.render(newRunPlan(runFile, cf.microcksURL))
+	}
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	result := &runResult{Success: true, RequestID: config.RequestID}
+	stopped := false
+	for i, step := range runFile.Steps {
+		index := fmt.Sprint(i + 1)
+		name := stepName(step, index)
+		if stopped {
+			result.Steps = append(result.Steps, runStepResult{Name: name, Kind: step.Kind(), Status: stepSkipped})
+			continue
+		}
+		stepResult := c.runStep(ctx, step, name, index)
+		if stepResult.Status == stepFailed {
+			result.Success = false
+			stopped = !step.ContinueOnError
+		}
+		result.Steps = append(result.Steps, stepResult)
+	}
+
+	if err := cf.render(result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return &RunFailedError{}
+	}
+	return nil
+}
+
+// runStep executes a step, never failing but reporting errors in step result.
+func (c *runCommand) runStep(ctx context.Context, step config.RunStep, name string, index string) runStepResult {
+	if len(step.Parallel) > 0 {
+		return c.runParallel(ctx, step, name, index)
+	}
+	client, err := c.connect(ctx, step.Settings)
+	if err != nil {
+		return failedStep(name, step.Kind(), err)
+	}
+	return c.runSingleStep(ctx, step, name, client)
+}
+
+// runParallel executes the steps of a parallel group concurrently. Clients are connected sequentially
+// beforehand as connection settings are shared state.
+func (c *runCommand) runParallel(ctx context.Context, group config.RunStep, name string, index string) runStepResult {
+	result := runStepResult{Name: name, Kind: group.Kind(), Status: stepSucceeded, Steps: make([]runStepResult, len(group.Parallel))}
+
+	var wg sync.WaitGroup
+	for i, step := range group.Parallel {
+		childName := stepName(step, fmt.Sprintf("%s.%d", index, i+1))
+		client, err := c.connect(ctx, mergeSettings(group.Settings, step.Settings))
+		if err != nil {
+			result.Steps[i] = failedStep(childName, step.Kind(), err)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, step config.RunStep) {
+			defer wg.Done()
+			result.Steps[i] = c.runSingleStep(ctx, step, childName, client)
+		}(i, step)
+	}
+	wg.Wait()
+
+	for i, step := range group.Parallel {
+		if result.Steps[i].Status == stepFailed && !step.ContinueOnError {
+			result.Status = stepFailed
+		}
+	}
+	return result
+}
+
+// runSingleStep executes an import or test step with client.
+func (c *runCommand) runSingleStep(ctx context.Context, step config.RunStep, name string, client stepClient) runStepResult {
+	console.Printf("Running step '%s'", name)
+	switch {
+	case step.Import != nil:
+		result := &importResult{RequestID: config.RequestID, summarize: true}
+		mainArtifact := step.Import.MainArtifact == nil || *step.Import.MainArtifact
+		for _, f := range step.Import.Files {
+			msg, err := importArtifact(ctx, client.mc, f, mainArtifact, "")
+			if err != nil {
+				stepResult := failedStep(name, step.Kind(), requestError("Got error when invoking Microcks client importing Artifact", err))
+				stepResult.Import = result
+				return stepResult
+			}
+			if len(step.Import.Labels) > 0 {
+				if err := client.mc.UpdateServiceLabels(ctx, msg, step.Import.Labels); err != nil {
+					return failedStep(name, step.Kind(), serviceError("Got error when invoking Microcks client updating labels", msg, err))
+				}
+			}
+			result.Artifacts = append(result.Artifacts, importedArtifact{File: f, MainArtifact: mainArtifact, Service: msg})
+		}
+		return runStepResult{Name: name, Kind: step.Kind(), Status: stepSucceeded, Import: result}
+	default:
+		if !runnerChoices[step.Test.Runner] {
+			return failedStep(name, step.Kind(), usageErrorf("runner should be one of: HTTP, SOAP, SOAP_UI, POSTMAN, OPEN_API_SCHEMA, ASYNC_API_SCHEMA, GRPC_PROTOBUF, GRAPHQL_SCHEMA"))
+		}
+		if step.Test.Runner == asyncRunner {
+			if err := validateAsyncEndpoint(step.Test.Endpoint); err != nil {
+				return failedStep(name, step.Kind(), err)
+			}
+		}
+		waitFor := step.Test.WaitFor
+		if len(waitFor) == 0 {
+			waitFor = "5sec"
+		}
+		waitForMilliseconds, err := parseWaitFor(waitFor)
+		if err != nil {
+			return failedStep(name, step.Kind(), usageErrorf("Invalid waitFor: %s", err))
+		}
+		if err := c.cf.requireRunner(ctx, client.mc, step.Test.Runner); err != nil {
+			return failedStep(name, step.Kind(), err)
+		}
+		result, err := runTest(ctx, client.mc, client.microcksURL, testSpec{
+			serviceRef:         step.Test.ServiceRef,
+			testEndpoint:       step.Test.Endpoint,
+			runnerType:         step.Test.Runner,
+			secretName:         step.Test.SecretName,
+			waitFor:            waitForMilliseconds,
+			filteredOperations: step.Test.FilteredOperations,
+			operationsHeaders:  step.Test.OperationsHeaders,
+			oAuth2Context:      step.Test.OAuth2Context,
+			fetchFull:          c.cf.output.IsStructured(),
+		})
+		if err != nil {
+			return failedStep(name, step.Kind(), err)
+		}
+		stepResult := runStepResult{Name: name, Kind: step.Kind(), Status: stepSucceeded, Test: result}
+		if !result.Success {
+			stepResult.Status = stepFailed
+			stepResult.Error = fmt.Sprintf("test %s did not succeed", result.TestResultID)
+		}
+		return stepResult
+	}
+}
+
+// connect returns a client connected with the step settings overriding the shared ones.
+// Clients are reused between steps having the same settings.
+func (c *runCommand) connect(ctx context.Context, settings config.Settings) (stepClient, error) {
+	if client, ok := c.clients[settings]; ok {
+		return client, nil
+	}
+
+	// Step settings are applied on a copy of shared flags.
+	saved := c.cf
+	defer func() {
+		c.cf = saved
+	}()
+
+	if err := c.overrideSettings(settings.FlagValues(), "run file step"); err != nil {
+		return stepClient{}, err
+	}
+
+	mc, err := c.cf.connect(ctx)
+	if err != nil {
+		return stepClient{}, err
+	}
+	client := stepClient{mc: mc, microcksURL: c.cf.microcksURL}
+	c.clients[settings] = client
+	return client, nil
+}
+
+// overrideSettings sets flags from values, unless they were given on command line or in environment.
+func (c *runCommand) overrideSettings(values map[string]string, source string) error {
+	for name, value := range values {
+		if src := settingSources[name]; src == "flag" || strings.HasPrefix(src, "env ") {
+			continue
+		}
+		if err := c.fs.Set(name, value); err != nil {
+			return fmt.Errorf("Invalid value for %s in %s: %s", name, source, err)
+		}
+		settingSources[name] = source
+	}
+	return nil
+}
+
+// mergeSettings returns base settings overridden by the non-empty override ones.
+func mergeSettings(base, override config.Settings) config.Settings {
+	values := base.FlagValues()
+	for name, value := range override.FlagValues() {
+		values[name] = value
+	}
+	return config.SettingsFromFlagValues(values)
+}
+
+func stepName(step config.RunStep, index string) string {
+	if len(step.Name) > 0 {
+		return step.Name
+	}
+	return step.Kind() + " #" + index
+}
+
+func failedStep(name string, kind string, err error) runStepResult {
+	console.Errorln(fmt.Sprintf("Step '%s' failed: %s", name, err))
+	return runStepResult{Name: name, Kind: kind, Status: stepFailed, Error: err.Error()}
+}
+
+func (c *runCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
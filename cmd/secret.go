@@ -0,0 +1,307 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
+)
+
+var secretUsage = usage{
+	name:        "secret",
+	synopsis:    "secret apply -f <file> [flags]",
+	description: "Manage the secrets used by Microcks tests. Secret values are never printed.",
+	args: [][2]string{
+		{"apply", "Create and update the secrets declared in a YAML file, deleting the other ones with --prune"},
+	},
+	examples: []string{
+		"microcks-cli secret apply -f secrets.yaml --dry-run",
+		"microcks-cli secret apply -f secrets.yaml --prune",
+	},
+}
+
+// Actions applied on secrets.
+const (
+	secretCreate    = "create"
+	secretUpdate    = "update"
+	secretDelete    = "delete"
+	secretUnchanged = "unchanged"
+)
+
+type secretCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	file   string
+	prune  bool
+	dryRun bool
+}
+
+// NewSecretCommand build a new SecretCommand implementation
+func NewSecretCommand() Command {
+	c := new(secretCommand)
+	c.fs = newFlagSet(secretUsage)
+	c.cf.register(c.fs)
+	c.fs.StringVar(&c.file, "f", "", "Path of the YAML file declaring secrets")
+	c.fs.StringVar(&c.file, "file", "", "Path of the YAML file declaring secrets (alias of -f)")
+	c.fs.BoolVar(&c.prune, "prune", false, "Delete the secrets of Microcks not declared in the file")
+	c.fs.BoolVar(&c.dryRun, "dry-run", false, "Print the changes without applying them")
+	return c
+}
+
+func (c *secretCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of secretCommand structure
+func (c *secretCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return usageErrorf("secret command require an action (one of: apply). Check Usage.")
+	}
+	if args[0] != "apply" {
+		return usageErrorf("secret command does not support '%s' action. Check Usage.", args[0])
+	}
+	if len(args) > 1 {
+		return usageErrorf("secret apply got unexpected arg '%s'. Check Usage.", args[1])
+	}
+	if len(c.file) == 0 {
+		return usageErrorf("-f flag is mandatory. Check Usage.")
+	}
+
+	file, err := config.LoadSecretsFile(c.file)
+	if err != nil {
+		return err
+	}
+
+	cf := &c.cf
+	cf.setup(stdout, stderr)
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := connectors.ListAll(ctx, mc.ListSecrets, 0)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client listing Secrets", err)
+	}
+	result := planSecrets(file.Secrets, existing, c.prune)
+	result.File, result.DryRun, result.RequestID = c.file, c.dryRun, config.RequestID
+	if !c.dryRun {
+		for _, change := range result.Changes {
+			if err := applySecret(ctx, mc, change); err != nil {
+				return err
+			}
+		}
+	}
+	return cf.render(result)
+}
+
+// planSecrets compares declared secrets with the existing ones by name, returning the changes
+// needed to reconcile them. Existing secrets not declared are deleted if prune is set.
+func planSecrets(declared []config.SecretSpec, existing []connectors.Secret, prune bool) *secretApplyResult {
+	result := &secretApplyResult{Changes: []secretChange{}}
+	byName := map[string]connectors.Secret{}
+	for _, secret := range existing {
+		byName[secret.Name] = secret
+	}
+	for _, spec := range declared {
+		wanted := connectors.Secret{Name: spec.Name, Description: spec.Description, Username: spec.Username, Password: spec.Password,
+			Token: spec.Token, TokenHeader: spec.TokenHeader, CaCertPem: spec.CaCertPem}
+		current, found := byName[spec.Name]
+		delete(byName, spec.Name)
+		if !found {
+			result.Changes = append(result.Changes, secretChange{Name: spec.Name, Action: secretCreate, secret: wanted})
+			continue
+		}
+		wanted.ID = current.ID
+		change := secretChange{Name: spec.Name, Action: secretUnchanged, Changed: secretDiff(current, wanted), secret: wanted}
+		if len(change.Changed) > 0 {
+			change.Action = secretUpdate
+		}
+		result.Changes = append(result.Changes, change)
+	}
+
+	var unmanaged []connectors.Secret
+	for _, secret := range byName {
+		unmanaged = append(unmanaged, secret)
+	}
+	sort.Slice(unmanaged, func(i, j int) bool { return unmanaged[i].Name < unmanaged[j].Name })
+	for _, secret := range unmanaged {
+		if prune {
+			result.Changes = append(result.Changes, secretChange{Name: secret.Name, Action: secretDelete, secret: secret})
+		} else {
+			result.Unmanaged = append(result.Unmanaged, secret.Name)
+		}
+	}
+	return result
+}
+
+// secretDiff returns the names of the fields of current to change to get wanted. Values are compared
+// through their hash and reported as a whole, so that no change tells anything about them.
+func secretDiff(current connectors.Secret, wanted connectors.Secret) []string {
+	var changed []string
+	if current.Description != wanted.Description {
+		changed = append(changed, "description")
+	}
+	if current.TokenHeader != wanted.TokenHeader {
+		changed = append(changed, "tokenHeader")
+	}
+	if secretValuesHash(current) != secretValuesHash(wanted) {
+		changed = append(changed, "values")
+	}
+	return changed
+}
+
+// secretValuesHash returns a hash of the credentials of secret.
+func secretValuesHash(secret connectors.Secret) [sha256.Size]byte {
+	hash := sha256.New()
+	for _, value := range []string{secret.Username, secret.Password, secret.Token, secret.CaCertPem} {
+		// Length prefix keeps values from being shifted from one field to another.
+		fmt.Fprintf(hash, "%d:%s", len(value), value)
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// applySecret sends the request implementing change to Microcks.
+func applySecret(ctx context.Context, mc connectors.MicrocksClient, change secretChange) error {
+	var err error
+	switch change.Action {
+	case secretCreate:
+		_, err = mc.CreateSecret(ctx, change.secret)
+	case secretUpdate:
+		err = mc.UpdateSecret(ctx, change.secret)
+	case secretDelete:
+		err = mc.DeleteSecret(ctx, change.secret.ID)
+	}
+	if err != nil {
+		return requestError(fmt.Sprintf("Got error when invoking Microcks client to %s Secret %s", change.Action, change.Name), err)
+	}
+	console.Debugf("Secret %s: %s", change.Name, change.Action)
+	return nil
+}
+
+// upsertSecret creates or updates on Microcks the secret declared by spec, returning its identifier.
+func upsertSecret(ctx context.Context, mc connectors.MicrocksClient, spec config.SecretSpec) (string, error) {
+	existing, err := connectors.ListAll(ctx, mc.ListSecrets, 0)
+	if err != nil {
+		return "", requestError("Got error when invoking Microcks client listing Secrets", err)
+	}
+	change := planSecrets([]config.SecretSpec{spec}, existing, false).Changes[0]
+	if change.Action != secretCreate {
+		return change.secret.ID, applySecret(ctx, mc, change)
+	}
+	id, err := mc.CreateSecret(ctx, change.secret)
+	if err != nil {
+		return "", requestError(fmt.Sprintf("Got error when invoking Microcks client to %s Secret %s", change.Action, change.Name), err)
+	}
+	console.Debugf("Secret %s: %s", change.Name, change.Action)
+	return id, nil
+}
+
+func (c *secretCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
+
+// secretChange is the action applied on a secret by secret apply.
+type secretChange struct {
+	Name   string `json:"name" yaml:"name"`
+	Action string `json:"action" yaml:"action"`
+	// Changed lists the changed fields of updated secrets, values being reported as a whole.
+	Changed []string `json:"changed,omitempty" yaml:"changed,omitempty"`
+	// secret holds the values to send, it is never rendered.
+	secret connectors.Secret
+}
+
+// secretApplyResult is the outcome of secret apply command, rendered using the --output format.
+type secretApplyResult struct {
+	File      string         `json:"file" yaml:"file"`
+	DryRun    bool           `json:"dryRun" yaml:"dryRun"`
+	Changes   []secretChange `json:"changes" yaml:"changes"`
+	Unmanaged []string       `json:"unmanaged,omitempty" yaml:"unmanaged,omitempty"`
+	RequestID string         `json:"requestId" yaml:"requestId"`
+}
+
+// count returns the number of changes with action.
+func (r *secretApplyResult) count(action string) int {
+	count := 0
+	for _, change := range r.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+// RenderText implements output.TextRenderer for secretApplyResult.
+func (r *secretApplyResult) RenderText(w io.Writer) {
+	styles := output.Styles(w)
+	title := "Secrets applied from " + r.File
+	if r.DryRun {
+		title = "Secrets to apply from " + r.File + " (dry run)"
+	}
+	fmt.Fprintln(w, styles.Bold(title+":"))
+	for _, change := range r.Changes {
+		line := fmt.Sprintf("  %-9s %s", change.Action, change.Name)
+		if len(change.Changed) > 0 {
+			line += " (" + strings.Join(change.Changed, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	if len(r.Unmanaged) > 0 {
+		fmt.Fprintln(w, styles.Warning("  kept, not declared in file (use --prune to delete them): "+strings.Join(r.Unmanaged, ", ")))
+	}
+	fmt.Fprintf(w, "%d created, %d updated, %d deleted, %d unchanged\n", r.count(secretCreate), r.count(secretUpdate), r.count(secretDelete), r.count(secretUnchanged))
+}
+
+// EnvVars implements output.EnvRenderer for secretApplyResult.
+func (r *secretApplyResult) EnvVars() [][2]string {
+	return [][2]string{
+		{"MICROCKS_SECRETS_CREATED", strconv.Itoa(r.count(secretCreate))},
+		{"MICROCKS_SECRETS_UPDATED", strconv.Itoa(r.count(secretUpdate))},
+		{"MICROCKS_SECRETS_DELETED", strconv.Itoa(r.count(secretDelete))},
+		{"MICROCKS_SECRETS_UNCHANGED", strconv.Itoa(r.count(secretUnchanged))},
+		{"MICROCKS_REQUEST_ID", r.RequestID},
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operators of label requirements.
+const (
+	selectorEquals    = "="
+	selectorNotEquals = "!="
+	selectorIn        = "in"
+	selectorNotIn     = "notin"
+	selectorExists    = "exists"
+	selectorNotExists = "!"
+)
+
+// labelRequirement is a condition on a label of a labelSelector.
+type labelRequirement struct {
+	key      string
+	operator string
+	values   []string
+}
+
+// labelSelector selects resources by their labels, using Kubernetes-style expressions: requirements
+// separated by commas must all be satisfied. It's shared by the commands selecting services by labels.
+type labelSelector []labelRequirement
+
+// parseLabelSelector parses an expression made of comma separated requirements, each being one of:
+// key=value, key==value, key!=value, key in (v1,v2), key notin (v1,v2), key (label exists) or !key
+// (label does not exist). An empty expression selects everything.
+func parseLabelSelector(expression string) (labelSelector, error) {
+	if len(strings.TrimSpace(expression)) == 0 {
+		return nil, nil
+	}
+	var requirements []string
+	depth, start := 0, 0
+	for i, r := range expression {
+		switch r {
+		case '(':
+			if depth > 0 {
+				return nil, fmt.Errorf("nested '(' at position %d", i+1)
+			}
+			depth++
+		case ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("unexpected ')' at position %d", i+1)
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				requirements = append(requirements, expression[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("missing ')' at end of '%s'", strings.TrimSpace(expression[start:]))
+	}
+	requirements = append(requirements, expression[start:])
+
+	selector := make(labelSelector, 0, len(requirements))
+	for _, requirement := range requirements {
+		parsed, err := parseLabelRequirement(strings.TrimSpace(requirement))
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, parsed)
+	}
+	return selector, nil
+}
+
+func parseLabelRequirement(requirement string) (labelRequirement, error) {
+	if len(requirement) == 0 {
+		return labelRequirement{}, fmt.Errorf("empty requirement")
+	}
+	if key, ok := strings.CutPrefix(requirement, "!"); ok && !strings.Contains(key, "=") {
+		return newLabelRequirement(strings.TrimSpace(key), selectorNotExists, nil)
+	}
+	if key, value, ok := strings.Cut(requirement, "!="); ok {
+		return newLabelRequirement(strings.TrimSpace(key), selectorNotEquals, []string{value})
+	}
+	if key, value, ok := strings.Cut(requirement, "=="); ok {
+		return newLabelRequirement(strings.TrimSpace(key), selectorEquals, []string{value})
+	}
+	if key, value, ok := strings.Cut(requirement, "="); ok {
+		return newLabelRequirement(strings.TrimSpace(key), selectorEquals, []string{value})
+	}
+	if open := strings.Index(requirement, "("); open >= 0 {
+		fields := strings.Fields(requirement[:open])
+		if len(fields) != 2 || (fields[1] != selectorIn && fields[1] != selectorNotIn) {
+			return labelRequirement{}, fmt.Errorf("'%s' is not of the form <key> in (<values>) or <key> notin (<values>)", requirement)
+		}
+		if !strings.HasSuffix(requirement, ")") {
+			return labelRequirement{}, fmt.Errorf("unexpected '%s' after ')' in '%s'", strings.TrimSpace(requirement[strings.Index(requirement, ")")+1:]), requirement)
+		}
+		values := strings.Split(requirement[open+1:len(requirement)-1], ",")
+		if len(values) == 1 && len(strings.TrimSpace(values[0])) == 0 {
+			return labelRequirement{}, fmt.Errorf("empty set of values in '%s'", requirement)
+		}
+		return newLabelRequirement(fields[0], fields[1], values)
+	}
+	if fields := strings.Fields(requirement); len(fields) > 1 {
+		if fields[1] == selectorIn || fields[1] == selectorNotIn {
+			return labelRequirement{}, fmt.Errorf("missing '(' after '%s %s'", fields[0], fields[1])
+		}
+		return labelRequirement{}, fmt.Errorf("'%s' is not a requirement, missing operator between '%s' and '%s'", requirement, fields[0], fields[1])
+	}
+	return newLabelRequirement(requirement, selectorExists, nil)
+}
+
+// newLabelRequirement checks key and values, trimming values, before building a requirement.
+func newLabelRequirement(key string, operator string, values []string) (labelRequirement, error) {
+	if len(key) == 0 {
+		return labelRequirement{}, fmt.Errorf("missing label key before '%s'", operator)
+	}
+	if strings.ContainsAny(key, " \t=!(),") {
+		return labelRequirement{}, fmt.Errorf("invalid label key '%s'", key)
+	}
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+		if strings.ContainsAny(values[i], "=!()") {
+			return labelRequirement{}, fmt.Errorf("invalid value '%s' for label '%s'", values[i], key)
+		}
+	}
+	return labelRequirement{key: key, operator: operator, values: values}, nil
+}
+
+// matches tells if labels satisfy every requirement of selector. Like Kubernetes, != and notin
+// requirements are satisfied by resources not having the label.
+func (s labelSelector) matches(labels map[string]string) bool {
+	for _, requirement := range s {
+		value, found := labels[requirement.key]
+		var ok bool
+		switch requirement.operator {
+		case selectorExists:
+			ok = found
+		case selectorNotExists:
+			ok = !found
+		case selectorEquals, selectorIn:
+			ok = found && contains(requirement.values, value)
+		case selectorNotEquals, selectorNotIn:
+			ok = !found || !contains(requirement.values, value)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
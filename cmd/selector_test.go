@@ -0,0 +1,124 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       labelSelector
+		wantErr    string
+	}{
+		{expression: "", want: nil},
+		{expression: "  ", want: nil},
+		{expression: "env=prod", want: labelSelector{{"env", selectorEquals, []string{"prod"}}}},
+		{expression: "env==prod", want: labelSelector{{"env", selectorEquals, []string{"prod"}}}},
+		{expression: " env = prod ", want: labelSelector{{"env", selectorEquals, []string{"prod"}}}},
+		{expression: "env=", want: labelSelector{{"env", selectorEquals, []string{""}}}},
+		{expression: "env!=prod", want: labelSelector{{"env", selectorNotEquals, []string{"prod"}}}},
+		{expression: "env in (prod, staging)", want: labelSelector{{"env", selectorIn, []string{"prod", "staging"}}}},
+		{expression: "env in(prod)", want: labelSelector{{"env", selectorIn, []string{"prod"}}}},
+		{expression: "env notin (dev,test)", want: labelSelector{{"env", selectorNotIn, []string{"dev", "test"}}}},
+		{expression: "env", want: labelSelector{{"env", selectorExists, nil}}},
+		{expression: "!env", want: labelSelector{{"env", selectorNotExists, nil}}},
+		{expression: "! env", want: labelSelector{{"env", selectorNotExists, nil}}},
+		{
+			expression: "domain=beers,env in (prod,staging),!deprecated,team",
+			want: labelSelector{
+				{"domain", selectorEquals, []string{"beers"}},
+				{"env", selectorIn, []string{"prod", "staging"}},
+				{"deprecated", selectorNotExists, nil},
+				{"team", selectorExists, nil},
+			},
+		},
+		{expression: "env=prod,", wantErr: "empty requirement"},
+		{expression: ",env=prod", wantErr: "empty requirement"},
+		{expression: "=prod", wantErr: "missing label key before '='"},
+		{expression: "!=prod", wantErr: "missing label key before '!='"},
+		{expression: "!", wantErr: "missing label key before '!'"},
+		{expression: "env=pr=od", wantErr: "invalid value 'pr=od' for label 'env'"},
+		{expression: "my env=prod", wantErr: "invalid label key 'my env'"},
+		{expression: "env in (prod,staging", wantErr: "missing ')' at end of 'env in (prod,staging'"},
+		{expression: "env in prod)", wantErr: "unexpected ')' at position 12"},
+		{expression: "env in ((prod))", wantErr: "nested '(' at position 9"},
+		{expression: "env in ()", wantErr: "empty set of values in 'env in ()'"},
+		{expression: "env in (prod) x", wantErr: "unexpected 'x' after ')' in 'env in (prod) x'"},
+		{expression: "env within (prod)", wantErr: "'env within (prod)' is not of the form <key> in (<values>) or <key> notin (<values>)"},
+		{expression: "env in prod", wantErr: "missing '(' after 'env in'"},
+		{expression: "env prod", wantErr: "'env prod' is not a requirement, missing operator between 'env' and 'prod'"},
+	}
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			got, err := parseLabelSelector(test.expression)
+			if len(test.wantErr) > 0 {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("parseLabelSelector() error = %v, want %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLabelSelector() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseLabelSelector() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"domain": "beers", "env": "prod", "team": ""}
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=staging", false},
+		{"team=", true},
+		{"owner=", false},
+		{"env!=staging", true},
+		{"env!=prod", false},
+		{"owner!=alice", true},
+		{"env in (staging,prod)", true},
+		{"env in (staging,dev)", false},
+		{"owner in (alice)", false},
+		{"env notin (staging,dev)", true},
+		{"env notin (prod)", false},
+		{"owner notin (alice)", true},
+		{"team", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!team", false},
+		{"domain=beers,env in (prod),!owner", true},
+		{"domain=beers,env=staging", false},
+	}
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			selector, err := parseLabelSelector(test.expression)
+			if err != nil {
+				t.Fatalf("parseLabelSelector() error = %v", err)
+			}
+			if got := selector.matches(labels); got != test.want {
+				t.Errorf("matches(%v) = %v, want %v", labels, got, test.want)
+			}
+		})
+	}
+}
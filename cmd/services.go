@@ -0,0 +1,214 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+var servicesUsage = usage{
+	name:        "services",
+	synopsis:    "services list [flags]",
+	description: "Explore the services known by Microcks.",
+	args: [][2]string{
+		{"list", "List the services, possibly selected by labels"},
+	},
+	examples: []string{
+		"microcks-cli services list --selector='domain=finance,status in (beta,GA)' --sort-by=lastUpdate",
+		"microcks-cli services list --columns=name,version,labels --output=wide",
+	},
+}
+
+// serviceColumn is a column of services list.
+type serviceColumn struct {
+	name   string
+	header string
+	value  func(service connectors.Service) string
+}
+
+// serviceColumns lists the columns services list can render, in their default order.
+var serviceColumns = []serviceColumn{
+	{"id", "ID", func(s connectors.Service) string { return s.ID }},
+	{"name", "NAME", func(s connectors.Service) string { return s.Name }},
+	{"version", "VERSION", func(s connectors.Service) string { return s.Version }},
+	{"type", "TYPE", func(s connectors.Service) string { return string(s.Type) }},
+	{"operations", "OPERATIONS", func(s connectors.Service) string { return fmt.Sprint(len(s.Operations)) }},
+	{"labels", "LABELS", func(s connectors.Service) string { return formatLabels(serviceLabels(s)) }},
+	{"sourceArtifact", "SOURCE ARTIFACT", func(s connectors.Service) string { return s.SourceArtifact }},
+	{"createdOn", "CREATED ON", func(s connectors.Service) string { return formatMillis(serviceMetadata(s).CreatedOn) }},
+	{"lastUpdate", "LAST UPDATE", func(s connectors.Service) string { return formatMillis(serviceMetadata(s).LastUpdate) }},
+}
+
+var (
+	defaultServiceColumns = []string{"name", "version", "type", "operations"}
+	wideServiceColumns    = []string{"name", "version", "type", "operations", "labels", "sourceArtifact"}
+)
+
+// serviceSorts gives the less function of each --sort-by key. Ties are broken by name and version.
+var serviceSorts = map[string]func(a, b connectors.Service) bool{
+	"name": serviceNameLess,
+	"version": func(a, b connectors.Service) bool {
+		return a.Version < b.Version || a.Version == b.Version && a.Name < b.Name
+	},
+	"lastUpdate": func(a, b connectors.Service) bool {
+		aUpdate, bUpdate := serviceMetadata(a).LastUpdate, serviceMetadata(b).LastUpdate
+		return aUpdate < bUpdate || aUpdate == bUpdate && serviceNameLess(a, b)
+	},
+	"operations": func(a, b connectors.Service) bool {
+		return len(a.Operations) < len(b.Operations) || len(a.Operations) == len(b.Operations) && serviceNameLess(a, b)
+	},
+}
+
+func serviceNameLess(a, b connectors.Service) bool {
+	return a.Name < b.Name || a.Name == b.Name && a.Version < b.Version
+}
+
+type servicesCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	selector string
+	sortBy   string
+	columns  string
+}
+
+// NewServicesCommand build a new ServicesCommand implementation
+func NewServicesCommand() Command {
+	c := new(servicesCommand)
+	c.fs = newFlagSet(servicesUsage)
+	c.cf.register(c.fs)
+	c.fs.StringVar(&c.selector, "selector", "", "Label selector like 'env=prod,tier in (front,back),!deprecated' (operators: =, ==, !=, in, notin, exists and !)")
+	c.fs.StringVar(&c.sortBy, "sort-by", "name", "Sort key of listed services (one of: name, version, lastUpdate, operations)")
+	c.fs.StringVar(&c.columns, "columns", "", "Comma separated columns of text output (among: id, name, version, type, operations, labels, sourceArtifact, createdOn, lastUpdate)")
+	return c
+}
+
+func (c *servicesCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// Execute implementation of servicesCommand structure
+func (c *servicesCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return usageErrorf("services command require an action (one of: list). Check Usage.")
+	}
+	if args[0] != "list" {
+		return usageErrorf("services command does not support '%s' action. Check Usage.", args[0])
+	}
+	if len(args) > 1 {
+		return usageErrorf("services list got unexpected arg '%s'. Check Usage.", args[1])
+	}
+
+	cf := &c.cf
+	cf.setup(stdout, stderr)
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	selector, err := parseLabelSelector(c.selector)
+	if err != nil {
+		return usageErrorf("Invalid value for --selector flag: %s", err)
+	}
+	less, ok := serviceSorts[c.sortBy]
+	if !ok {
+		return usageErrorf("Invalid value for --sort-by flag: '%s' is not one of name, version, lastUpdate, operations", c.sortBy)
+	}
+	columns, err := parseServiceColumns(c.columns)
+	if err != nil {
+		return usageErrorf("Invalid value for --columns flag: %s", err)
+	}
+
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Walk every page before selecting so that the list is never silently partial.
+	services, err := connectors.ListAll(ctx, mc.ListServices, 0)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client listing Services", err)
+	}
+	selected := []connectors.Service{}
+	for _, service := range services {
+		if selector.matches(serviceLabels(service)) {
+			selected = append(selected, service)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return less(selected[i], selected[j]) })
+
+	return cf.render(&serviceList{Services: selected, RequestID: config.RequestID, columns: columns})
+}
+
+// parseServiceColumns checks the comma separated column names given, ignoring case.
+func parseServiceColumns(value string) ([]string, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return nil, nil
+	}
+	var columns []string
+	for _, name := range strings.Split(value, ",") {
+		column, ok := findServiceColumn(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown column '%s'", strings.TrimSpace(name))
+		}
+		columns = append(columns, column.name)
+	}
+	return columns, nil
+}
+
+func findServiceColumn(name string) (serviceColumn, bool) {
+	for _, column := range serviceColumns {
+		if strings.EqualFold(column.name, name) {
+			return column, true
+		}
+	}
+	return serviceColumn{}, false
+}
+
+func serviceMetadata(service connectors.Service) connectors.ServiceMetadata {
+	if service.Metadata == nil {
+		return connectors.ServiceMetadata{}
+	}
+	return *service.Metadata
+}
+
+func serviceLabels(service connectors.Service) map[string]string {
+	return serviceMetadata(service).Labels
+}
+
+func (c *servicesCommand) flagSet() *flag.FlagSet {
+	return c.fs
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+)
+
+const envPrefix = "MICROCKS_"
+
+// envNames holds the environment variables names that cannot be derived from flag names.
+var envNames = map[string]string{
+	"microcksURL":   "MICROCKS_URL",
+	"oAuth2Context": "MICROCKS_OAUTH2_CONTEXT",
+}
+
+// secretFlags holds the names of flags whose values must never be displayed.
+var secretFlags = map[string]bool{
+	"keycloakClientSecret": true,
+}
+
+var (
+	// settingSources records where the value of each flag comes from: "flag", "env <NAME>", "config <path>" or "default".
+	settingSources = map[string]string{}
+	// configFilePath is the path of the configuration file in use, if any.
+	configFilePath string
+	// configFile is the configuration file in use, if any.
+	configFile *config.File
+	// explicitFlags records the names of flags explicitly passed on command line.
+	explicitFlags = map[string]bool{}
+	// allowMissingConfig tells if an explicit configuration file may not exist yet (commands creating it).
+	allowMissingConfig = false
+)
+
+// envName computes the environment variable name for a flag: MICROCKS_ + upper snake case of flag name.
+func envName(flagName string) string {
+	if name, ok := envNames[flagName]; ok {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	runes := []rune(flagName)
+	for i, r := range runes {
+		switch {
+		case r == '-':
+			b.WriteRune('_')
+		case unicode.IsUpper(r):
+			// Start a new word unless previous was already upper case (eg. URL, ID).
+			if i > 0 && runes[i-1] != '-' && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// resolveSettings fills flags that were not explicitly passed with their environment variable value
+// or, if none, with the value from configuration file. Precedence is: flags, environment, configuration
+// file and finally flag defaults. An error is returned on the first invalid value. Settings of a
+// previous command are forgotten.
+func resolveSettings(fs *flag.FlagSet) error {
+	explicitFlags, settingSources, configFile = map[string]bool{}, map[string]string{}, nil
+	explicit := explicitFlags
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+		if alias, ok := deprecatedAlias(f); ok {
+			explicit[alias.replacement] = true
+		}
+		// Flags sharing the variable of an explicit one, like -f and --file, are explicit too.
+		fs.VisitAll(func(other *flag.Flag) {
+			if sameVariable(f.Value, other.Value) {
+				explicit[other.Name] = true
+			}
+		})
+	})
+
+	fileValues, err := loadConfigFile(fs, explicit)
+	if err != nil {
+		return err
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := deprecatedAlias(f); err != nil || ok {
+			// Deprecated names are resolved along with their replacement.
+			return
+		}
+		if explicit[f.Name] {
+			settingSources[f.Name] = "flag"
+			return
+		}
+		names := append([]string{f.Name}, deprecatedNames(fs, f.Name)...)
+		for i, flagName := range names {
+			name := envName(flagName)
+			if value, ok := os.LookupEnv(name); ok && len(value) > 0 {
+				if i > 0 {
+					warnDeprecated(flagAlias{flagName, f.Name}, name+" environment variable")
+				}
+				if setErr := fs.Set(f.Name, value); setErr != nil {
+					err = usageErrorf("Invalid value for %s environment variable: %s", name, setErr)
+					return
+				}
+				settingSources[f.Name] = "env " + name
+				return
+			}
+		}
+		for i, flagName := range names {
+			value, ok := fileValues[flagName]
+			if !ok {
+				continue
+			}
+			if i > 0 {
+				warnDeprecated(flagAlias{flagName, f.Name}, flagName+" key in configuration file")
+			}
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("Invalid value for %s in configuration file %s: %s", f.Name, configFilePath, setErr)
+				return
+			}
+			settingSources[f.Name] = "config " + configFilePath
+			return
+		}
+		settingSources[f.Name] = "default"
+	})
+	return err
+}
+
+// sameVariable tells if flag values a and b set the same variable.
+func sameVariable(a flag.Value, b flag.Value) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	return va.Kind() == reflect.Ptr && va.Type() == vb.Type() && va.Pointer() == vb.Pointer()
+}
+
+// loadConfigFile finds and loads the configuration file, returning the flag values it holds.
+func loadConfigFile(fs *flag.FlagSet, explicit map[string]bool) (map[string]string, error) {
+	var explicitPath string
+	if explicit["config"] {
+		explicitPath = fs.Lookup("config").Value.String()
+	}
+	configFilePath = config.FindFile(explicitPath)
+	if len(configFilePath) == 0 {
+		return nil, nil
+	}
+
+	file, warnings, err := config.LoadFile(configFilePath)
+	if err != nil && allowMissingConfig && os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot load configuration file: %w", err)
+	}
+	for _, warning := range warnings {
+		console.Warnf("Warning: %s", warning)
+	}
+	configFile = file
+
+	// Select context from flag or environment, defaulting to current context of file.
+	var contextName string
+	if explicit["context"] {
+		contextName = fs.Lookup("context").Value.String()
+	} else {
+		contextName = os.Getenv(envName("context"))
+	}
+	values, err := file.FlagValues(contextName)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot load configuration file %s: %w", configFilePath, err)
+	}
+	return values, nil
+}
+
+// displayValue returns the flag value to display, redacting secrets.
+func displayValue(f *flag.Flag) string {
+	value := f.Value.String()
+	if secretFlags[f.Name] && len(value) > 0 {
+		return "********"
+	}
+	return value
+}
+
+// configFileValue returns the value of a flag in the current context of configuration file, if any.
+// It is offered as default when prompting for a value missing from the selected context.
+func configFileValue(name string) string {
+	if configFile == nil {
+		return ""
+	}
+	values, err := configFile.FlagValues("")
+	if err != nil {
+		return ""
+	}
+	return values[name]
+}
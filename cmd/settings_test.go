@@ -0,0 +1,152 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/config"
+)
+
+func TestResolveSettings(t *testing.T) {
+	const file = `microcksURL: http://file/api/
+keycloak:
+  clientSecret: file-secret
+contexts:
+  - name: staging
+    microcksURL: http://staging/api/
+`
+	// setting is the value of a flag and its source, CONFIG standing for the configuration file.
+	type setting struct {
+		value  string
+		source string
+	}
+	tests := []struct {
+		name string
+		// previous are the args of a command resolved before, whose settings must not leak.
+		previous []string
+		args     []string
+		env      map[string]string
+		file     string
+		want     map[string]setting
+	}{
+		{
+			name: "defaults",
+			want: map[string]setting{"microcksURL": {"", "default"}, "keycloakClientSecret": {"", "default"}, "file": {"tests.yaml", "default"}},
+		},
+		{
+			name: "configuration file",
+			file: file,
+			want: map[string]setting{"microcksURL": {"http://file/api/", "config CONFIG"}, "keycloakClientSecret": {"file-secret", "config CONFIG"}},
+		},
+		{
+			name: "context of configuration file",
+			env:  map[string]string{"MICROCKS_CONTEXT": "staging"},
+			file: file,
+			want: map[string]setting{"microcksURL": {"http://staging/api/", "config CONFIG"}, "context": {"staging", "env MICROCKS_CONTEXT"}},
+		},
+		{
+			name: "environment over configuration file",
+			env:  map[string]string{"MICROCKS_URL": "http://env/api/", "MICROCKS_KEYCLOAK_CLIENT_SECRET": "env-secret"},
+			file: file,
+			want: map[string]setting{"microcksURL": {"http://env/api/", "env MICROCKS_URL"}, "keycloakClientSecret": {"env-secret", "env MICROCKS_KEYCLOAK_CLIENT_SECRET"}},
+		},
+		{
+			name: "flag over environment",
+			args: []string{"--microcksURL=http://flag/api/"},
+			env:  map[string]string{"MICROCKS_URL": "http://env/api/", "MICROCKS_KEYCLOAK_CLIENT_SECRET": "env-secret"},
+			file: file,
+			want: map[string]setting{"microcksURL": {"http://flag/api/", "flag"}, "keycloakClientSecret": {"env-secret", "env MICROCKS_KEYCLOAK_CLIENT_SECRET"}},
+		},
+		{
+			name: "empty environment variable",
+			env:  map[string]string{"MICROCKS_URL": ""},
+			file: file,
+			want: map[string]setting{"microcksURL": {"http://file/api/", "config CONFIG"}},
+		},
+		{
+			name: "short flag over environment of long one",
+			args: []string{"-f", "flag.yaml"},
+			env:  map[string]string{"MICROCKS_FILE": "env.yaml"},
+			want: map[string]setting{"file": {"flag.yaml", "flag"}, "f": {"flag.yaml", "flag"}},
+		},
+		{
+			name: "long flag over environment of short one",
+			args: []string{"--file=flag.yaml"},
+			env:  map[string]string{"MICROCKS_F": "env.yaml"},
+			want: map[string]setting{"file": {"flag.yaml", "flag"}, "f": {"flag.yaml", "flag"}},
+		},
+		{
+			name:     "previous command",
+			previous: []string{"--microcksURL=http://previous/api/", "-f", "previous.yaml"},
+			env:      map[string]string{"MICROCKS_URL": "http://env/api/"},
+			want:     map[string]setting{"microcksURL": {"http://env/api/", "env MICROCKS_URL"}, "file": {"tests.yaml", "default"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Neither the environment nor the user configuration file of the machine must be used.
+			t.Setenv("HOME", t.TempDir())
+			for _, name := range []string{"MICROCKS_URL", "MICROCKS_KEYCLOAK_CLIENT_SECRET", "MICROCKS_CONTEXT", "MICROCKS_FILE", "MICROCKS_F", config.ConfigEnvVar} {
+				t.Setenv(name, "")
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if len(test.file) > 0 {
+				if err := os.WriteFile(path, []byte(test.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv(config.ConfigEnvVar, path)
+			}
+
+			newFlags := func() *flag.FlagSet {
+				fs := flag.NewFlagSet("test", flag.ContinueOnError)
+				fs.String("microcksURL", "", "")
+				fs.String("keycloakClientSecret", "", "")
+				fs.String("context", "", "")
+				var file string
+				fs.StringVar(&file, "f", "tests.yaml", "")
+				fs.StringVar(&file, "file", "tests.yaml", "")
+				return fs
+			}
+			if test.previous != nil {
+				if _, err := parseArgs(newFlags(), test.previous, io.Discard); err != nil {
+					t.Fatalf("parseArgs() of previous command error = %v", err)
+				}
+			}
+			fs := newFlags()
+			if _, err := parseArgs(fs, test.args, io.Discard); err != nil {
+				t.Fatalf("parseArgs() error = %v", err)
+			}
+
+			for name, want := range test.want {
+				if want.source == "config CONFIG" {
+					want.source = "config " + path
+				}
+				got := setting{fs.Lookup(name).Value.String(), settingSources[name]}
+				if got != want {
+					t.Errorf("%s = %q from %q, want %q from %q", name, got.value, got.source, want.value, want.source)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// snapshotIndexFile is the manifest of a split snapshot directory, listing the file of each service.
+const snapshotIndexFile = "index.json"
+
+// snapshotIndex is the manifest of a snapshot split by export --split in one file per service.
+type snapshotIndex struct {
+	Services []snapshotEntry `json:"services"`
+}
+
+// snapshotEntry is the file of a split snapshot holding a service, with the SHA-256 checksum of its content.
+type snapshotEntry struct {
+	Service string `json:"service"`
+	File    string `json:"file"`
+	SHA256  string `json:"sha256"`
+}
+
+// splitSnapshot exports the snapshot of each service of serviceRefs in its own file of dir, named after
+// the service, then writes their index. Files are written as indented JSON with sorted keys and services
+// are sorted by reference, so that exports of unchanged services are byte-identical. It returns the
+// number of bytes written.
+func splitSnapshot(ctx context.Context, mc connectors.MicrocksClient, serviceRefs []string, dir string) (int64, error) {
+	serviceIDs, err := resolveServiceIDs(ctx, mc, serviceRefs)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("Cannot write snapshot in %s: %w", dir, err)
+	}
+
+	order := make([]int, len(serviceRefs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return serviceRefs[order[i]] < serviceRefs[order[j]] })
+
+	index := &snapshotIndex{Services: []snapshotEntry{}}
+	files := map[string]bool{snapshotIndexFile: true}
+	var size int64
+	for _, i := range order {
+		buffer := &bytes.Buffer{}
+		if _, err := mc.ExportServices(ctx, serviceIDs[i:i+1], buffer); err != nil {
+			return size, serviceError("Got error when invoking Microcks client exporting service", serviceRefs[i], err)
+		}
+		content, err := canonicalJSON(buffer.Bytes())
+		if err != nil {
+			return size, fmt.Errorf("Cannot read snapshot of service '%s': %w", serviceRefs[i], err)
+		}
+
+		file := snapshotFileName(serviceRefs[i], files)
+		if _, err := writeSnapshot(filepath.Join(dir, file), writeBytes(content)); err != nil {
+			return size, err
+		}
+		size += int64(len(content))
+		checksum := sha256.Sum256(content)
+		index.Services = append(index.Services, snapshotEntry{Service: serviceRefs[i], File: file, SHA256: hex.EncodeToString(checksum[:])})
+	}
+
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return size, err
+	}
+	content = append(content, '\n')
+	if _, err := writeSnapshot(filepath.Join(dir, snapshotIndexFile), writeBytes(content)); err != nil {
+		return size, err
+	}
+	return size + int64(len(content)), nil
+}
+
+// writeBytes returns a writeSnapshot export function writing content.
+func writeBytes(content []byte) func(w io.Writer) (int64, error) {
+	return func(w io.Writer) (int64, error) {
+		n, err := w.Write(content)
+		return int64(n), err
+	}
+}
+
+// canonicalJSON returns data indented with object keys sorted, numbers being kept as is.
+func canonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// snapshotFileName returns the file name of the snapshot of serviceRef, <name>-<version>.json with the
+// characters other than letters, digits, '.', '_' and '-' replaced by '_'. Names already taken, whatever
+// their case, get a numbered suffix. The returned name is added to taken.
+func snapshotFileName(serviceRef string, taken map[string]bool) string {
+	sep := strings.LastIndex(serviceRef, ":")
+	base := sanitizeFileName(serviceRef[:sep]) + "-" + sanitizeFileName(serviceRef[sep+1:])
+	name := base + ".json"
+	for n := 2; taken[strings.ToLower(name)]; n++ {
+		name = base + "-" + strconv.Itoa(n) + ".json"
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
+
+// sanitizeFileName replaces the characters of name that are not portable in file names by '_'.
+func sanitizeFileName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+	// Leading dots would hide files or make them refer to directories.
+	if strings.HasPrefix(sanitized, ".") {
+		sanitized = "_" + sanitized[1:]
+	}
+	return sanitized
+}
+
+// isSnapshotDir tells if path is a directory of split snapshot, holding an index.
+func isSnapshotDir(path string) bool {
+	info, err := os.Stat(filepath.Join(path, snapshotIndexFile))
+	return err == nil && !info.IsDir()
+}
+
+// loadSnapshotDirs reassembles the split snapshot of each artifact that is a snapshot directory in a single
+// snapshot, its content. If services is not empty, only them are kept and the directories holding none of
+// them are dropped from the returned artifacts. Services not found in any directory are reported as a usage
+// error.
+func loadSnapshotDirs(artifacts []artifactFile, services []string) ([]artifactFile, error) {
+	var loaded []artifactFile
+	found := map[string]bool{}
+	snapshots := 0
+	for _, artifact := range artifacts {
+		if isRemoteArtifact(artifact.path) || !isSnapshotDir(artifact.path) {
+			loaded = append(loaded, artifact)
+			continue
+		}
+		snapshots++
+		content, entries, err := readSplitSnapshot(artifact.path, services)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			if len(services) == 0 {
+				return nil, usageErrorf("No service to import found in snapshot %s", artifact.location())
+			}
+			continue
+		}
+		for _, entry := range entries {
+			found[entry.Service] = true
+		}
+		artifact.content, artifact.name, artifact.snapshot = content, defaultSnapshotFile, entries
+		loaded = append(loaded, artifact)
+	}
+	if len(services) > 0 && snapshots == 0 {
+		return nil, usageErrorf("--services flag is only for split snapshot directories, holding an %s file", snapshotIndexFile)
+	}
+	for _, service := range services {
+		if !found[service] {
+			return nil, usageErrorf("Service '%s' is not in split snapshots", service)
+		}
+	}
+	return loaded, nil
+}
+
+// readSplitSnapshot reads the split snapshot of dir, checking the checksums of its files, and returns them
+// merged in a single snapshot with the entries of its services. Only services are kept if not empty, no
+// entry being returned if the snapshot holds none of them.
+func readSplitSnapshot(dir string, services []string) ([]byte, []snapshotEntry, error) {
+	content, err := os.ReadFile(filepath.Join(dir, snapshotIndexFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	var index snapshotIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, nil, fmt.Errorf("Cannot read snapshot index %s: %w", filepath.Join(dir, snapshotIndexFile), err)
+	}
+	wanted := map[string]bool{}
+	for _, service := range services {
+		wanted[service] = true
+	}
+
+	var entries []snapshotEntry
+	merged := map[string]interface{}{}
+	for _, entry := range index.Services {
+		if len(wanted) > 0 && !wanted[entry.Service] {
+			continue
+		}
+		// Index must not make the CLI read files out of the snapshot directory.
+		if entry.File != filepath.Base(entry.File) || entry.File == snapshotIndexFile || strings.HasPrefix(entry.File, ".") {
+			return nil, nil, fmt.Errorf("Cannot read snapshot of service '%s', file %q is not in %s", entry.Service, entry.File, dir)
+		}
+		path := filepath.Join(dir, entry.File)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		checksum := sha256.Sum256(content)
+		if hex.EncodeToString(checksum[:]) != strings.ToLower(entry.SHA256) {
+			return nil, nil, fmt.Errorf("Cannot import snapshot of service '%s', %s does not match its checksum in %s", entry.Service, path, snapshotIndexFile)
+		}
+		if err := mergeSnapshot(merged, content); err != nil {
+			return nil, nil, fmt.Errorf("Cannot read snapshot %s: %w", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, nil, nil
+	}
+	content, err = json.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, entries, nil
+}
+
+// mergeSnapshot appends the arrays of the snapshot content, services, resources and examples, to the ones
+// of merged.
+func mergeSnapshot(merged map[string]interface{}, content []byte) error {
+	var snapshot map[string]json.RawMessage
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return err
+	}
+	for key, value := range snapshot {
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err != nil {
+			// Values other than arrays are the same in the snapshot of every service.
+			if _, found := merged[key]; !found {
+				merged[key] = value
+			}
+			continue
+		}
+		existing, _ := merged[key].([]json.RawMessage)
+		merged[key] = append(existing, items...)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/connectors/testutil"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestSnapshotFileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceRefs []string
+		want        []string
+	}{
+		{"plain", []string{"Beer Catalog API:0.9"}, []string{"Beer_Catalog_API-0.9.json"}},
+		{"separators", []string{"org/pets:v1:2"}, []string{"org_pets_v1-2.json"}},
+		{"hidden", []string{"..:1.0"}, []string{"_.-1.0.json"}},
+		{"unicode", []string{"Pâtisserie API:2.0"}, []string{"P_tisserie_API-2.0.json"}},
+		{"collisions", []string{"a b:1", "a_b:1", "A?B:1"}, []string{"a_b-1.json", "a_b-1-2.json", "A_B-1-3.json"}},
+		{"index", []string{"index:json"}, []string{"index-json.json"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			taken := map[string]bool{snapshotIndexFile: true}
+			var got []string
+			for _, serviceRef := range test.serviceRefs {
+				got = append(got, snapshotFileName(serviceRef, taken))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("snapshotFileName() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExportSplit(t *testing.T) {
+	t.Setenv(githubOutputEnv, "")
+	srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+	defer srv.Close()
+	srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+	srv.AddService(connectors.Service{Name: "API Pastry", Version: "2.0", Type: connectors.ServiceTypeREST})
+	srv.AddResource("API Pastry:2.0", connectors.Resource{Name: "pastry.yaml", Type: "OPEN_API_SPEC", Content: "openapi: 3.0.0 # <&>"})
+
+	export := func(dir string, services string) {
+		t.Helper()
+		args := []string{services, "--split", "--output-dir=" + dir,
+			"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}
+		var stdout, stderr bytes.Buffer
+		if err := NewExportCommand().Execute(context.Background(), args, &stdout, &stderr); err != nil {
+			t.Fatalf("Execute() error = %v, stderr: %s", err, stderr.String())
+		}
+	}
+	first, second := t.TempDir(), t.TempDir()
+	export(first, "Beer Catalog API:0.9,API Pastry:2.0")
+	// Services are ordered by reference whatever the order they are given in.
+	export(second, "API Pastry:2.0,Beer Catalog API:0.9")
+
+	files := []string{"API_Pastry-2.0.json", "Beer_Catalog_API-0.9.json", snapshotIndexFile}
+	for _, dir := range []string{first, second} {
+		entries, _ := os.ReadDir(dir)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, files) {
+			t.Fatalf("export wrote %q, want %q", names, files)
+		}
+	}
+	for _, file := range files {
+		a, _ := os.ReadFile(filepath.Join(first, file))
+		b, _ := os.ReadFile(filepath.Join(second, file))
+		if !bytes.Equal(a, b) {
+			t.Errorf("consecutive exports of %s differ:\n%s\n%s", file, a, b)
+		}
+	}
+
+	var index snapshotIndex
+	content, _ := os.ReadFile(filepath.Join(first, snapshotIndexFile))
+	if err := json.Unmarshal(content, &index); err != nil {
+		t.Fatalf("invalid index %s: %v", content, err)
+	}
+	if len(index.Services) != 2 || index.Services[0].Service != "API Pastry:2.0" || index.Services[0].File != "API_Pastry-2.0.json" {
+		t.Errorf("index = %s", content)
+	}
+	pastry, _ := os.ReadFile(filepath.Join(first, "API_Pastry-2.0.json"))
+	if !bytes.Contains(pastry, []byte("openapi: 3.0.0 # <&>")) || !bytes.Contains(pastry, []byte("\n  \"resources\": [")) {
+		t.Errorf("snapshot is not indented JSON with unescaped content:\n%s", pastry)
+	}
+}
+
+func TestImportSplitSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    []string
+		corrupt  bool
+		want     []string
+		wantErr  bool
+		wantSent int
+	}{
+		{name: "all services", want: []string{"API Pastry", "Beer Catalog API"}, wantSent: 1},
+		{name: "subset", flags: []string{"--services=Beer Catalog API:0.9"}, want: []string{"Beer Catalog API"}, wantSent: 1},
+		{name: "unknown service", flags: []string{"--services=Pet Store:1.0"}, wantErr: true},
+		{name: "checksum mismatch", corrupt: true, wantErr: true},
+		{name: "dry run", flags: []string{"--dry-run"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			snapshots := map[string]string{
+				"Beer Catalog API:0.9": `{"services": [{"id": "s1", "name": "Beer Catalog API", "version": "0.9", "type": "REST"}], "resources": [{"id": "r1", "name": "beer.yaml", "serviceId": "s1"}]}`,
+				"API Pastry:2.0":       `{"services": [{"id": "s2", "name": "API Pastry", "version": "2.0", "type": "REST"}], "resources": []}`,
+			}
+			mc := &testutil.MockMicrocksClient{
+				GetServiceByRefFunc: func(ctx context.Context, name string, version string) (*connectors.Service, error) {
+					return &connectors.Service{ID: name + ":" + version, Name: name, Version: version}, nil
+				},
+				ExportServicesFunc: func(ctx context.Context, serviceIDs []string, w io.Writer) (int64, error) {
+					n, err := io.WriteString(w, snapshots[serviceIDs[0]])
+					return int64(n), err
+				},
+			}
+			dir := t.TempDir()
+			if _, err := splitSnapshot(context.Background(), mc, []string{"Beer Catalog API:0.9", "API Pastry:2.0"}, dir); err != nil {
+				t.Fatalf("splitSnapshot() error = %v", err)
+			}
+			if test.corrupt {
+				os.WriteFile(filepath.Join(dir, "API_Pastry-2.0.json"), []byte(`{"services": []}`), 0o644)
+			}
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+
+			args := append([]string{dir, "--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}, test.flags...)
+			var stdout, stderr bytes.Buffer
+			err := NewImportCommand().Execute(context.Background(), args, &stdout, &stderr)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, test.wantErr)
+			}
+			imports := srv.Imports()
+			if len(imports) != test.wantSent {
+				t.Fatalf("got %d imported snapshots, want %d", len(imports), test.wantSent)
+			}
+			if test.wantSent == 0 {
+				return
+			}
+			var snapshot struct {
+				Services  []connectors.Service  `json:"services"`
+				Resources []connectors.Resource `json:"resources"`
+			}
+			if err := json.Unmarshal(imports[0].Content, &snapshot); err != nil {
+				t.Fatalf("invalid snapshot %s: %v", imports[0].Content, err)
+			}
+			var names []string
+			for _, service := range snapshot.Services {
+				names = append(names, service.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("imported services %q, want %q", names, test.want)
+			}
+			if len(srv.Uploads()) > 0 {
+				t.Errorf("snapshot files were uploaded as artifacts")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSuggestionDistance is the maximum edit distance between an unknown and a registered command name
+// for the latter to be suggested.
+const maxSuggestionDistance = 2
+
+// UnknownCommandError builds the usage error returned when name is not a registered command.
+// It lists the available commands and suggests the closest ones.
+func UnknownCommandError(name string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Unknown command '%s' for microcks-cli.", name)
+	if suggestions := suggestCommands(name); len(suggestions) > 0 {
+		fmt.Fprintf(&b, " Did you mean '%s'?", strings.Join(suggestions, "' or '"))
+	}
+	names := make([]string, 0, len(commands))
+	for _, spec := range commands {
+		names = append(names, spec.name)
+	}
+	fmt.Fprintf(&b, "\nAvailable commands: %s. Run 'microcks-cli help' for usage.", strings.Join(names, ", "))
+	return usageErrorf("%s", b.String())
+}
+
+// suggestCommands returns the registered command names close to name: the ones name is a prefix of,
+// or that name starts with, come first, then the ones within maxSuggestionDistance edits, closest first.
+func suggestCommands(name string) []string {
+	type candidate struct {
+		name     string
+		prefix   bool
+		distance int
+	}
+	lower := strings.ToLower(name)
+	var candidates []candidate
+	for _, spec := range commands {
+		c := candidate{
+			name:     spec.name,
+			prefix:   len(lower) > 0 && (strings.HasPrefix(spec.name, lower) || strings.HasPrefix(lower, spec.name)),
+			distance: editDistance(lower, spec.name),
+		}
+		if c.prefix || c.distance <= maxSuggestionDistance {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].prefix != candidates[j].prefix {
+			return candidates[i].prefix
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	suggestions := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		suggestions = append(suggestions, c.name)
+	}
+	return suggestions
+}
+
+// editDistance computes the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
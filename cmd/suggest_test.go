@@ -0,0 +1,53 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"tset", []string{"test"}},
+		{"hlep", []string{"help"}},
+		{"imoprt", []string{"import"}},
+		{"exprot", []string{"export"}},
+		{"servics", []string{"services"}},
+		{"docter", []string{"doctor"}},
+		{"mok", []string{"mock"}},
+		{"secrets", []string{"secret"}},
+		{"TEST", []string{"test"}},
+		{"ver", []string{"version"}},
+		{"testing", []string{"test"}},
+		// Prefixes first, in registration order, then close names.
+		{"con", []string{"config", "context", "run"}},
+		// Closest first.
+		{"hest", []string{"test", "help"}},
+		{"xyz", []string{}},
+		{"", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := suggestCommands(test.name); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("suggestCommands(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
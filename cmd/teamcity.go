@@ -0,0 +1,118 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+// teamCityEnv is set by TeamCity agents to the version of the server.
+const teamCityEnv = "TEAMCITY_VERSION"
+
+// runningOnTeamCity tells if the CLI runs in a TeamCity build.
+func runningOnTeamCity() bool {
+	return len(os.Getenv(teamCityEnv)) > 0
+}
+
+// teamCityEscape escapes a value of TeamCity service messages: pipe is the escape character and
+// non ASCII characters are written as |0xNNNN UTF-16 code units.
+func teamCityEscape(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '|':
+			b.WriteString("||")
+		case '\'':
+			b.WriteString("|'")
+		case '\n':
+			b.WriteString("|n")
+		case '\r':
+			b.WriteString("|r")
+		case '[':
+			b.WriteString("|[")
+		case ']':
+			b.WriteString("|]")
+		case '\u0085':
+			b.WriteString("|x")
+		case '\u2028':
+			b.WriteString("|l")
+		case '\u2029':
+			b.WriteString("|p")
+		default:
+			if r < 0x80 {
+				b.WriteRune(r)
+				continue
+			}
+			for _, unit := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, "|0x%04x", unit)
+			}
+		}
+	}
+	return b.String()
+}
+
+// writeTeamCityMessage writes a TeamCity service message with attributes given as name and value pairs.
+func writeTeamCityMessage(w io.Writer, message string, attributes [][2]string) {
+	var b strings.Builder
+	b.WriteString("##teamcity[" + message)
+	for _, attribute := range attributes {
+		b.WriteString(" " + attribute[0] + "='" + teamCityEscape(attribute[1]) + "'")
+	}
+	b.WriteString("]")
+	fmt.Fprintln(w, b.String())
+}
+
+// writeTeamCityTests reports each operation of a test as a TeamCity test, with its duration and the
+// messages of failed steps. A failed test without any failed operation, like a test still in progress
+// or that could not run, is reported as a failed test named after the service.
+func writeTeamCityTests(w io.Writer, result *testResult) {
+	failed := false
+	if result.details != nil {
+		for _, testCase := range result.details.TestCaseResults {
+			name := [2]string{"name", testCase.OperationName}
+			writeTeamCityMessage(w, "testStarted", [][2]string{name})
+			if !testCase.Success {
+				failed = true
+				message, details := "Operation failed", []string{}
+				for _, step := range testCase.TestStepResults {
+					if step.Success || len(step.Message) == 0 {
+						continue
+					}
+					if len(details) == 0 {
+						message = step.Message
+					}
+					stepName := step.RequestName
+					if len(stepName) == 0 {
+						stepName = step.EventMessageName
+					}
+					details = append(details, stepName+": "+step.Message)
+				}
+				writeTeamCityMessage(w, "testFailed", [][2]string{name, {"message", message}, {"details", strings.Join(details, "\n")}})
+			}
+			writeTeamCityMessage(w, "testFinished", [][2]string{name, {"duration", fmt.Sprint(testCase.ElapsedTime)}})
+		}
+	}
+	if !result.Success && !failed {
+		name := [2]string{"name", result.ServiceRef}
+		writeTeamCityMessage(w, "testStarted", [][2]string{name})
+		writeTeamCityMessage(w, "testFailed", [][2]string{name, {"message", result.failure()}})
+		writeTeamCityMessage(w, "testFinished", [][2]string{name})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+func TestTeamCityEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"GET /beer/{name}", "GET /beer/{name}"},
+		{"a|b", "a||b"},
+		{"it's", "it|'s"},
+		{`"quoted"`, `"quoted"`},
+		{"[array]", "|[array|]"},
+		{"line 1\r\nline 2", "line 1|r|nline 2"},
+		{"next\u0085line", "next|xline"},
+		{"line\u2028separator", "line|lseparator"},
+		{"paragraph\u2029separator", "paragraph|pseparator"},
+		{"pâte", "p|0x00e2te"},
+		{"emoji 🍺", "emoji |0xd83c|0xdf7a"},
+		{"||'']", "|||||'|'|]"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			if got := teamCityEscape(test.value); got != test.want {
+				t.Errorf("teamCityEscape(%q) = %s, want %s", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestWriteTeamCityTests(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *testResult
+		want   string
+	}{
+		{
+			name: "operations",
+			result: &testResult{Success: false, details: &connectors.TestResult{TestCaseResults: []connectors.TestCaseResult{
+				{OperationName: "GET /beer", ElapsedTime: 12, Success: true},
+				{OperationName: "GET /beer/{name}", ElapsedTime: 30, Success: false, TestStepResults: []connectors.TestStepResult{
+					{RequestName: "karmeliet", Success: false, Message: "missing ['status']"},
+					{RequestName: "rochefort", Success: true},
+					{EventMessageName: "orval", Success: false, Message: "invalid"},
+				}},
+			}}},
+			want: "##teamcity[testStarted name='GET /beer']\n" +
+				"##teamcity[testFinished name='GET /beer' duration='12']\n" +
+				"##teamcity[testStarted name='GET /beer/{name}']\n" +
+				"##teamcity[testFailed name='GET /beer/{name}' message='missing |[|'status|'|]' details='karmeliet: missing |[|'status|'|]|norval: invalid']\n" +
+				"##teamcity[testFinished name='GET /beer/{name}' duration='30']\n",
+		},
+		{
+			name:   "failed without operations",
+			result: &testResult{TestResultID: "t1", ServiceRef: "Beer Catalog API:0.9", URL: "http://mocks/#/tests/t1", InProgress: true},
+			want: "##teamcity[testStarted name='Beer Catalog API:0.9']\n" +
+				"##teamcity[testFailed name='Beer Catalog API:0.9' message='Test t1 of Beer Catalog API:0.9 is still in progress, see http://mocks/#/tests/t1']\n" +
+				"##teamcity[testFinished name='Beer Catalog API:0.9']\n",
+		},
+		{
+			name:   "passed without operations",
+			result: &testResult{Success: true},
+			want:   "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeTeamCityTests(&out, test.result)
+			if out.String() != test.want {
+				t.Errorf("writeTeamCityTests() =\n%s\nwant\n%s", out.String(), test.want)
+			}
+		})
+	}
+}
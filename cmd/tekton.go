@@ -0,0 +1,83 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+const (
+	// tektonResultsDefaultDir is where Tekton collects the results written by the steps of a Task.
+	tektonResultsDefaultDir = "/tekton/results"
+	// tektonResultsMaxBytes is the size allowed by Tekton for all the results of a step, as they're
+	// passed through the termination message of its container.
+	tektonResultsMaxBytes = 4096
+	// tektonResultOverhead is the size taken in the termination message by a result besides its name and value.
+	tektonResultOverhead = 32
+)
+
+// tektonResult is implemented by results exposing values as Tekton results, by result name.
+type tektonResult interface {
+	TektonResults() [][2]string
+}
+
+// defaultTektonResultsDir returns the results directory of Tekton when running in a Task step (that
+// is, a Kubernetes pod having this directory), empty otherwise.
+func defaultTektonResultsDir() string {
+	if len(os.Getenv("KUBERNETES_SERVICE_HOST")) == 0 {
+		return ""
+	}
+	if info, err := os.Stat(tektonResultsDefaultDir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return tektonResultsDefaultDir
+}
+
+// writeTektonResults writes every value of result in a file of dir named after it. Values are
+// truncated, with a warning, so that all of them fit in the size allowed by Tekton.
+func writeTektonResults(dir string, result tektonResult) error {
+	values := result.TektonResults()
+	budget := tektonResultsMaxBytes
+	for _, value := range values {
+		budget -= len(value[0]) + tektonResultOverhead
+	}
+	for _, value := range values {
+		name, content := value[0], value[1]
+		if len(content) > budget {
+			content = truncateUTF8(content, max(budget, 0))
+			console.Warnf("Tekton result %s truncated to %d bytes to fit the %d bytes allowed by Tekton", name, len(content), tektonResultsMaxBytes)
+		}
+		budget -= len(content)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("Cannot write Tekton result: %w", err)
+		}
+	}
+	return nil
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// endpointData is the data of the templates of endpoints: the service to test, so that a gateway exposing
+// several services can be tested with https://gateway/{{.Name}}/{{.Version}} for example.
+type endpointData struct {
+	ServiceRef string
+	Name       string
+	Version    string
+}
+
+// newEndpointData returns the data of the templates of the endpoints of the service serviceRef.
+func newEndpointData(serviceRef string) endpointData {
+	data := endpointData{ServiceRef: serviceRef, Name: serviceRef}
+	if i := strings.LastIndex(serviceRef, ":"); i >= 0 {
+		data.Name, data.Version = serviceRef[:i], serviceRef[i+1:]
+	}
+	return data
+}
+
+// expandEndpoint executes the Go template placeholders of endpoint, like https://{{env "PREVIEW_HOST"}}/api.
+// The env function gives the value of an environment variable and the flag function the value of a flag
+// of fs, an undefined variable or unknown flag being an error, and data is the dot of the template.
+// Endpoints without placeholders are kept as is.
+func expandEndpoint(endpoint string, fs *flag.FlagSet, data endpointData) (string, error) {
+	if !strings.Contains(endpoint, "{{") {
+		return endpoint, nil
+	}
+	tmpl, err := template.New("testEndpoint").Option("missingkey=error").Funcs(template.FuncMap{
+		"env": func(name string) (string, error) {
+			value, found := os.LookupEnv(name)
+			if !found {
+				return "", fmt.Errorf("environment variable %s is not defined", name)
+			}
+			return value, nil
+		},
+		"flag": func(name string) (string, error) {
+			f := fs.Lookup(name)
+			if f == nil {
+				return "", fmt.Errorf("flag --%s is not defined", name)
+			}
+			return f.Value.String(), nil
+		},
+	}).Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	var expanded strings.Builder
+	if err := tmpl.Execute(&expanded, data); err != nil {
+		return "", err
+	}
+	return expanded.String(), nil
+}
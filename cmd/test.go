@@ -16,8 +16,14 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -27,6 +33,13 @@ import (
 	"github.com/microcks/microcks-cli/pkg/connectors"
 )
 
+// minPollInterval is the shortest --pollInterval, not to hammer the Microcks API.
+const minPollInterval = 100 * time.Millisecond
+
+// serverReportMargin is the time given to Microcks to report a test completed once its --waitFor elapsed.
+// It is a variable so that tests do not wait for it.
+var serverReportMargin = 10 * time.Second
+
 var runnerChoices = map[string]bool{
 	"HTTP":             true,
 	"SOAP_HTTP":        true,
@@ -38,159 +51,942 @@ var runnerChoices = map[string]bool{
 	"GRAPHQL_SCHEMA":   true,
 }
 
+var testUsage = usage{
+	name:     "test",
+	synopsis: "test <apiName:apiVersion> <testEndpoint> <runner>|-f <file>|--retry-failed <testResultId>|wait <testResultId>|archive <testResultId> [flags]",
+	description: "Launch new test on Microcks server and wait for its result, launch the tests listed in a YAML file, wait for the result of a test already launched, launch again the failed operations of a test, or archive the result of a past test with its messages. " +
+		"Interrupting the command stops waiting for tests without cancelling them, as Microcks has no API to cancel a test " +
+		"(see https://microcks.io/documentation/references/apis/open-api/): they run on Microcks until they complete or their --waitFor elapses.",
+	args: [][2]string{
+		{"<apiName:apiVersion>", "Service to test reference. Exemple: 'Beer Catalog API:0.9'. A comma separated list tests every service"},
+		{"<testEndpoint>", "URL where is deployed implementation to test, or broker endpoint for ASYNC_API_SCHEMA (can be built with --broker and --topic instead). A comma separated list tests every endpoint. May hold {{env \"NAME\"}}, {{flag \"name\"}}, {{.Name}} and {{.Version}} of the service template placeholders"},
+		{"<runner>", "Test strategy (one of: HTTP, SOAP, SOAP_UI, POSTMAN, OPEN_API_SCHEMA, ASYNC_API_SCHEMA, GRPC_PROTOBUF, GRAPHQL_SCHEMA)"},
+	},
+	examples: []string{
+		"microcks-cli test 'Beer Catalog API:0.9' http://localhost:9090/api/ POSTMAN \\\n" +
+			"    --microcksURL=http://localhost:8080/api/ --waitFor=3sec \\\n" +
+			"    --keycloakClientId=microcks-serviceaccount --keycloakClientSecret=<secret>",
+		"microcks-cli test 'User signed-up API:0.1.1' ASYNC_API_SCHEMA \\\n" +
+			"    --broker=kafka://my-cluster-kafka-bootstrap:9092 --topic=user-signedup \\\n" +
+			"    --microcksURL=http://localhost:8080/api/ --waitFor=5sec",
+		"microcks-cli test 'Beer Catalog API:0.9' OPEN_API_SCHEMA \\\n" +
+			"    --endpoint=http://beers-blue:9090/api/ --endpoint=http://beers-green:9090/api/ \\\n" +
+			"    --microcksURL=http://localhost:8080/api/ --waitFor=3sec",
+		"microcks-cli test 'orders:1.0,payments:2.1' 'https://gateway/{{.Name}}/v{{.Version}}' OPEN_API_SCHEMA \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+		"microcks-cli test -f tests.yaml --concurrency=2 \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+		"microcks-cli test --retry-failed 65f1d2c3e4b5a6978890abcd --waitFor=10sec \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+		"microcks-cli test wait 65f1d2c3e4b5a6978890abcd --waitFor=2min \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+		"microcks-cli test archive 65f1d2c3e4b5a6978890abcd --file=beer-test.tar.gz \\\n" +
+			"    --microcksURL=http://localhost:8080/api/",
+	},
+}
+
 type testCommand struct {
+	fs *flag.FlagSet
+	cf clientFlags
+
+	waitFor            string
+	secretName         string
+	filteredOperations string
+	operationsHeaders  string
+	globalHeaders      headerList
+	oAuth2Context      string
+	// operationsHeadersFile and oAuth2ContextFile are paths of files holding the JSON of the options above.
+	operationsHeadersFile string
+	oAuth2ContextFile     string
+	pushgateway           string
+	metricsLabels         metricsLabels
+	azureDevOps           bool
+	teamCity              bool
+	allureResults         string
+	reportFormat          string
+	reportFile            string
+	broker                string
+	topic                 string
+	binding               string
+	endpoints             endpointList
+	concurrency           int
+	requireAllPass        bool
+	requireAnyPass        bool
+	dryRun                bool
+	verify                bool
+	pollStrategy          string
+	pollInterval          time.Duration
+	follow                bool
+	retries               int
+	retryDelay            time.Duration
+	minSuccessRate        float64
+	failFast              bool
+	secretFile            string
+	deleteSecret          bool
+	secret                *config.SecretSpec
+	compareWith           string
+	retryFailed           string
+	coverage              bool
+	minCoverage           float64
+	file                  string
+	// environments are the names of the endpoints given as name=url.
+	environments map[string]string
 }
 
 // NewTestCommand build a new TestCommand implementation
 func NewTestCommand() Command {
-	return new(testCommand)
+	c := &testCommand{metricsLabels: metricsLabels{}}
+	c.fs = newFlagSet(testUsage)
+	c.cf.register(c.fs)
+	c.fs.StringVar(&c.waitFor, "waitFor", "5sec", "Time to wait for test to finish (int + one of: milli, sec, min, or a duration like 30s or 2m30s)")
+	c.fs.StringVar(&c.secretName, "secretName", "", "Secret to use for connecting test endpoint")
+	c.fs.StringVar(&c.secretFile, "secretFile", "", "Path of a YAML file declaring the secret to use for connecting test endpoint, created or updated on Microcks before launching tests")
+	c.fs.BoolVar(&c.deleteSecret, "deleteSecret", false, "Delete the secret of --secretFile from Microcks once tests are done")
+	c.fs.StringVar(&c.filteredOperations, "filteredOperations", "", "List of operations to launch a test for")
+	c.fs.StringVar(&c.operationsHeaders, "operationsHeaders", "", "Override of operations headers as JSON string")
+	c.fs.Var(&c.globalHeaders, "globalHeader", "Header sent when testing every operation as 'Name: value', merged into the globals of --operationsHeaders (repeatable, value may reference ${ENV} variables)")
+	c.fs.StringVar(&c.oAuth2Context, "oAuth2Context", "", "Spec of an OAuth2 client context as JSON string")
+	c.fs.StringVar(&c.operationsHeadersFile, "operationsHeadersFile", "", "Path of a JSON file holding the override of operations headers, replacing --operationsHeaders")
+	c.fs.StringVar(&c.oAuth2ContextFile, "oAuth2ContextFile", "", "Path of a JSON file holding the spec of an OAuth2 client context, replacing --oAuth2Context")
+	c.fs.StringVar(&c.pushgateway, "pushgateway", "", "URL of a Prometheus Pushgateway to push test metrics to once completed")
+	c.fs.Var(c.metricsLabels, "metrics-label", "Label added to pushed metrics as key=value (repeatable or comma separated)")
+	c.fs.BoolVar(&c.azureDevOps, "azure-devops", runningOnAzureDevOps(), "Report failed operations and test failure, and upload --reportFile and --output-file, with Azure Pipelines logging commands (default to true when TF_BUILD=True)")
+	c.fs.StringVar(&c.allureResults, "allure-results", "", "Directory where to write Allure 2 results of tested operations")
+	c.fs.StringVar(&c.reportFormat, "reportFormat", "", "Format of the report written to --reportFile (one of: junit)")
+	c.fs.StringVar(&c.reportFile, "reportFile", "", "Path of the report of tested operations, written in --reportFormat")
+	c.fs.BoolVar(&c.teamCity, "teamcity", runningOnTeamCity(), "Report operations as tests with TeamCity service messages, instead of normal output (default to true when TEAMCITY_VERSION is set)")
+	c.fs.StringVar(&c.broker, "broker", "", "Broker of ASYNC_API_SCHEMA test endpoint, like kafka://host:9092 (replaces <testEndpoint> with --topic)")
+	c.fs.StringVar(&c.topic, "topic", "", "Topic of ASYNC_API_SCHEMA test endpoint (replaces <testEndpoint> with --broker)")
+	c.fs.StringVar(&c.binding, "binding", "", "AsyncAPI binding giving the scheme of --broker without one (one of: KAFKA, MQTT, WS, AMQP, NATS, GOOGLEPUBSUB, SQS, SNS)")
+	c.fs.Var(&c.endpoints, "endpoint", "Endpoint to test, replacing <testEndpoint> arg (repeatable or comma separated, each one gets its own test). May be named after its environment as name=url")
+	c.fs.Var(&c.endpoints, "endpoints", "Endpoints to test as a comma separated list, like staging=https://stg/api,perf=https://perf/api (alias of --endpoint)")
+	c.fs.IntVar(&c.concurrency, "concurrency", 4, "Maximum number of tests running at a time when testing several endpoints")
+	c.fs.BoolVar(&c.requireAllPass, "require-all-pass", false, "Succeed only if tests pass on all endpoints (default policy)")
+	c.fs.BoolVar(&c.requireAnyPass, "require-any-pass", false, "Succeed if tests pass on at least one endpoint")
+	c.fs.BoolVar(&c.dryRun, "dry-run", false, "Print the requests that would launch tests after validating flags, without connecting to Microcks nor launching them")
+	c.fs.BoolVar(&c.verify, "verify", false, "With --dry-run, check on Microcks that services exist, runners can test them and secrets resolve")
+	c.fs.StringVar(&c.pollStrategy, "poll-strategy", connectors.PollAdaptive, "How to poll the result of tests in progress (one of: adaptive, fixed). adaptive waits 1s, 2s then 5s between polls, then spaces them out up to 30 seconds while the test status does not change, fixed polls every --pollInterval. backoff is an alias of adaptive")
+	c.fs.DurationVar(&c.pollInterval, "pollInterval", 0, "Delay between polls of tests in progress with the fixed poll strategy (default to 2s), or first delay with the adaptive one (eg. 500ms)")
+	c.fs.BoolVar(&c.follow, "follow", false, "Print the status and duration of every operation as soon as its test completes")
+	c.fs.IntVar(&c.retries, "retries", 0, "Number of times a test that did not succeed is launched again before declaring failure")
+	c.fs.DurationVar(&c.retryDelay, "retryDelay", 5*time.Second, "Time to wait before launching again a test that did not succeed (eg. 30s)")
+	c.fs.Float64Var(&c.minSuccessRate, "minSuccessRate", 0, "Minimum rate of passed operations, between 0 and 1, for a test to succeed (eg. 0.9, default to all of them as decided by Microcks)")
+	c.fs.BoolVar(&c.failFast, "fail-fast", false, "Stop waiting for a test as soon as one of its operations failed, instead of waiting for all of them")
+	c.fs.StringVar(&c.compareWith, "compare-with", "", "Identifier of a previous test, or latest for the last one on the same endpoint, to compare with: the test fails only if operations passing in it fail")
+	c.fs.BoolVar(&c.coverage, "coverage", false, "Print the rate of the operations of the service exercised by the test, with the missing ones")
+	c.fs.Float64Var(&c.minCoverage, "minCoverage", 0, "Minimum rate of the operations of the service exercised by the test, between 0 and 1, for the test to succeed (implies --coverage)")
+	c.fs.StringVar(&c.retryFailed, "retry-failed", "", "Identifier of a previous test whose failed operations are launched again, on the same endpoint with the same runner, replacing args. The outcome merges the ones of the other operations of the previous test")
+	c.fs.StringVar(&c.file, "f", "", "Path of a YAML file listing the tests to launch, replacing args")
+	c.fs.StringVar(&c.file, "file", "", "Path of a YAML file listing the tests to launch (alias of -f), or of the tarball written by test archive (default to <testResultId>.tar.gz)")
+	return c
 }
 
-// Execute implementation of testCommand structure
-func (c *testCommand) Execute() {
-	var err error
+func (c *testCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
 
-	// Parse subcommand args first.
-	if len(os.Args) < 5 {
-		fmt.Println("test command require <apiName:apiVersion> <testEndpoint> <runner> args")
-		os.Exit(1)
+// Execute implementation of testCommand structure
+func (c *testCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
 	}
 
-	serviceRef := os.Args[2]
-	testEndpoint := os.Args[3]
-	runnerType := os.Args[4]
+	// Parse flags and positional args in any order.
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 && args[0] == "archive" {
+		return c.executeArchive(ctx, args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "wait" {
+		return c.executeWait(ctx, args[1:], stdout, stderr)
+	}
+	if len(c.retryFailed) > 0 {
+		return c.executeRetryFailed(ctx, args, stdout, stderr)
+	}
+	if len(c.file) > 0 {
+		return c.executeManifest(ctx, args, stdout, stderr)
+	}
+	// The endpoint of async tests may be built from flags instead of given as arg.
+	if len(c.broker) > 0 || len(c.topic) > 0 || len(c.binding) > 0 {
+		if len(args) != 2 {
+			return usageErrorf("--broker, --topic and --binding flags replace <testEndpoint> arg, expecting <apiName:apiVersion> <runner> args. Check Usage.")
+		}
+		if args[1] != asyncRunner {
+			return usageErrorf("--broker, --topic and --binding flags are only for %s runner", asyncRunner)
+		}
+		testEndpoint, err := buildAsyncEndpoint(c.broker, c.topic, c.binding)
+		if err != nil {
+			return err
+		}
+		args = []string{args[0], testEndpoint, args[1]}
+	}
+	// Several endpoints may be given with flags instead of the arg.
+	if len(c.endpoints) > 0 {
+		if len(args) != 2 {
+			return usageErrorf("--endpoint flags replace <testEndpoint> arg, expecting <apiName:apiVersion> <runner> args. Check Usage.")
+		}
+		args = []string{args[0], c.endpoints.String(), args[1]}
+	}
+	if err := testUsage.checkArgs(args); err != nil {
+		return err
+	}
 
-	// Validate presence and values of args.
-	if &serviceRef == nil || strings.HasPrefix(serviceRef, "-") {
-		fmt.Println("test command require <apiName:apiVersion> <testEndpoint> <runner> args")
-		os.Exit(1)
+	serviceRefs := splitList(args[0])
+	if len(serviceRefs) == 0 {
+		return usageErrorf("<apiName:apiVersion> arg should not be empty. Check Usage.")
+	}
+	// The endpoints of every service, the arg being a template that may reference the service.
+	endpoints := make([][]string, len(serviceRefs))
+	for i, serviceRef := range serviceRefs {
+		testEndpoint, err := expandEndpoint(args[1], c.fs, newEndpointData(serviceRef))
+		if err != nil {
+			return usageErrorf("Invalid <testEndpoint> template: %s", err)
+		}
+		if endpoints[i] = splitList(testEndpoint); len(endpoints[i]) == 0 {
+			return usageErrorf("<testEndpoint> arg should not be empty. Check Usage.")
+		}
+	}
+	// Endpoints may be named after their environment, like staging=https://stg/api.
+	if c.environments, err = nameEnvironments(endpoints); err != nil {
+		return err
 	}
-	if &testEndpoint == nil || strings.HasPrefix(testEndpoint, "-") {
-		fmt.Println("test command require <apiName:apiVersion> <testEndpoint> <runner> args")
-		os.Exit(1)
+	serviceRef, testEndpoints := serviceRefs[0], endpoints[0]
+	runnerType := args[2]
+	if len(c.compareWith) > 0 && (len(serviceRefs) > 1 || len(testEndpoints) > 1) {
+		return usageErrorf("--compare-with flag cannot be used with several services or endpoints")
 	}
-	if &runnerType == nil || strings.HasPrefix(runnerType, "-") {
-		fmt.Println("test command require <apiName:apiVersion> <testEndpoint> <runner> args")
-		os.Exit(1)
+	if c.requireAllPass && c.requireAnyPass {
+		return usageErrorf("--require-all-pass and --require-any-pass flags are mutually exclusive")
 	}
+	if c.requireAnyPass && len(serviceRefs) > 1 {
+		return usageErrorf("--require-any-pass flag cannot be used with several services, all of them are required to pass")
+	}
+	if err := c.checkFlags(); err != nil {
+		return err
+	}
+
+	// Validate values of args.
 	if _, validChoice := runnerChoices[runnerType]; !validChoice {
-		fmt.Println("<runner> should be one of: HTTP, SOAP, SOAP_UI, POSTMAN, OPEN_API_SCHEMA, ASYNC_API_SCHEMA, GRPC_PROTOBUF, GRAPHQL_SCHEMA")
-		os.Exit(1)
-	}
-
-	// Then parse flags.
-	testCmd := flag.NewFlagSet("test", flag.ExitOnError)
-
-	var microcksURL string
-	var keycloakClientID string
-	var keycloakClientSecret string
-	var waitFor string
-	var secretName string
-	var filteredOperations string
-	var operationsHeaders string
-	var oAuth2Context string
-	var insecureTLS bool
-	var caCertPaths string
-	var verbose bool
-
-	testCmd.StringVar(&microcksURL, "microcksURL", "", "Microcks API URL")
-	testCmd.StringVar(&keycloakClientID, "keycloakClientId", "", "Keycloak Realm Service Account ClientId")
-	testCmd.StringVar(&keycloakClientSecret, "keycloakClientSecret", "", "Keycloak Realm Service Account ClientSecret")
-	testCmd.StringVar(&waitFor, "waitFor", "5sec", "Time to wait for test to finish")
-	testCmd.StringVar(&secretName, "secretName", "", "Secret to use for connecting test endpoint")
-	testCmd.StringVar(&filteredOperations, "filteredOperations", "", "List of operations to launch a test for")
-	testCmd.StringVar(&operationsHeaders, "operationsHeaders", "", "Override of operations headers as JSON string")
-	testCmd.StringVar(&oAuth2Context, "oAuth2Context", "", "Spec of an OAuth2 client context as JSON string")
-	testCmd.BoolVar(&insecureTLS, "insecure", false, "Whether to accept insecure HTTPS connection")
-	testCmd.StringVar(&caCertPaths, "caCerts", "", "Comma separated paths of CRT files to add to Root CAs")
-	testCmd.BoolVar(&verbose, "verbose", false, "Produce dumps of HTTP exchanges")
-	testCmd.Parse(os.Args[5:])
+		return usageErrorf("<runner> should be one of: HTTP, SOAP, SOAP_UI, POSTMAN, OPEN_API_SCHEMA, ASYNC_API_SCHEMA, GRPC_PROTOBUF, GRAPHQL_SCHEMA")
+	}
+	if runnerType == asyncRunner {
+		for _, serviceEndpoints := range endpoints {
+			for _, testEndpoint := range serviceEndpoints {
+				if err := validateAsyncEndpoint(testEndpoint); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cf := &c.cf
+	cf.silent = c.teamCity && !c.dryRun
+	// Offline dry runs only need the Microcks URL to print the target of requests.
+	cf.skipAuth = c.dryRun && !c.verify
 
 	// Validate presence and values of flags.
-	if len(microcksURL) == 0 {
-		fmt.Println("--microcksURL flag is mandatory. Check Usage.")
-		os.Exit(1)
+	cf.setup(stdout, stderr)
+	if err := cf.validate(); err != nil {
+		return err
 	}
-	if len(keycloakClientID) == 0 {
-		fmt.Println("--keycloakClientId flag is mandatory. Check Usage.")
-		os.Exit(1)
+	waitForMilliseconds, err := parseWaitFor(c.waitFor)
+	if err != nil {
+		return usageErrorf("Invalid value for --waitFor flag: %s", err)
 	}
-	if len(keycloakClientSecret) == 0 {
-		fmt.Println("--keycloakClientSecret flag is mandatory. Check Usage.")
-		os.Exit(1)
+	if c.operationsHeaders, err = mergeGlobalHeaders(c.operationsHeaders, c.globalHeaders); err != nil {
+		return usageErrorf("Invalid --globalHeader flag: %s", err)
 	}
-	if &waitFor == nil || (!strings.HasSuffix(waitFor, "milli") && !strings.HasSuffix(waitFor, "sec") && !strings.HasSuffix(waitFor, "min")) {
-		fmt.Println("--waitFor format is wrong. Applying default 5sec")
-		waitFor = "5sec"
+	if err := connectors.ValidateTestOptions(c.filteredOperations, c.operationsHeaders, c.oAuth2Context); err != nil {
+		return usageErrorf("Invalid test options: %s", err)
 	}
 
-	// Collect optional HTTPS transport flags.
-	if insecureTLS {
-		config.InsecureTLS = true
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
+
+	var specs []testSpec
+	for i, serviceRef := range serviceRefs {
+		for _, testEndpoint := range endpoints[i] {
+			specs = append(specs, c.newSpec(serviceRef, testEndpoint, runnerType, waitForMilliseconds))
+		}
 	}
-	if len(caCertPaths) > 0 {
-		config.CaCertPaths = caCertPaths
+	c.warnShortTimeout(specs)
+	if c.dryRun && !c.verify {
+		return c.executeDryRun(ctx, nil, specs)
 	}
-	if verbose {
-		config.Verbose = true
+
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
 	}
 
-	// Compute time to wait in milliseconds.
-	var waitForMilliseconds int64 = 5000
-	if strings.HasSuffix(waitFor, "milli") {
-		waitForMilliseconds, _ = strconv.ParseInt(waitFor[:len(waitFor)-5], 0, 64)
-	} else if strings.HasSuffix(waitFor, "sec") {
-		waitForMilliseconds, _ = strconv.ParseInt(waitFor[:len(waitFor)-3], 0, 64)
-		waitForMilliseconds = waitForMilliseconds * 1000
-	} else if strings.HasSuffix(waitFor, "min") {
-		waitForMilliseconds, _ = strconv.ParseInt(waitFor[:len(waitFor)-3], 0, 64)
-		waitForMilliseconds = waitForMilliseconds * 60 * 1000
+	if err := cf.requireRunner(ctx, mc, runnerType); err != nil {
+		return err
+	}
+	if c.dryRun {
+		return c.executeDryRun(ctx, mc, specs)
+	}
+	cleanup, err := c.applySecret(ctx, mc)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if len(serviceRefs) > 1 {
+		return c.executeServices(ctx, mc, serviceRefs, specs)
 	}
 
-	mc := connectors.NewMicrocksClient(microcksURL)
-	mc.SetOAuthToken("unauthentifed-token")
+	if c.teamCity {
+		writeTeamCityMessage(cf.decorationsOutput(), "testSuiteStarted", [][2]string{{"name", serviceRef}})
+		defer writeTeamCityMessage(cf.decorationsOutput(), "testSuiteFinished", [][2]string{{"name", serviceRef}})
+	}
+	spec := c.newSpec(serviceRef, testEndpoints[0], runnerType, waitForMilliseconds)
+	if len(testEndpoints) > 1 {
+		spec.fetchFull = true
+		return c.executeMatrix(ctx, mc, spec, testEndpoints)
+	}
+	if len(c.compareWith) > 0 {
+		if spec.baseline, err = c.resolveBaseline(ctx, mc, serviceRef, spec.testEndpoint, ""); err != nil {
+			return err
+		}
+	}
+	result, err := runTest(ctx, mc, cf.microcksURL, spec)
+	if err != nil {
+		return err
+	}
+	c.nameEnvironments([]*testResult{result})
+	return c.reportTest(ctx, result)
+}
+
+// nameEnvironments sets the environment of the tests whose endpoint was given as name=url.
+func (c *testCommand) nameEnvironments(tests []*testResult) {
+	for _, test := range tests {
+		test.Environment = c.environments[test.TestEndpoint]
+	}
+}
 
-	var testResultID string
-	testResultID, err = mc.CreateTestResult(serviceRef, testEndpoint, runnerType, secretName, waitForMilliseconds, filteredOperations, operationsHeaders, oAuth2Context)
+// reportTest renders the result of a single test, writes the reports asked by flags and returns the
+// error telling the test did not succeed.
+func (c *testCommand) reportTest(ctx context.Context, result *testResult) error {
+	cf := &c.cf
+	if err := cf.render(result); err != nil {
+		return err
+	}
+	if len(c.allureResults) > 0 {
+		if err := writeAllureResults(c.allureResults, result); err != nil {
+			return err
+		}
+	}
+	if len(c.reportFile) > 0 {
+		if err := writeJUnitReport(c.reportFile, []*testResult{result}); err != nil {
+			return err
+		}
+	}
+	if c.azureDevOps {
+		writeAzureDevOpsUploads(cf.decorationsOutput(), c.reportFile, cf.outputFile)
+		writeAzureDevOpsTest(cf.decorationsOutput(), result)
+	}
+	if c.teamCity {
+		writeTeamCityTests(cf.decorationsOutput(), result)
+	}
+	c.pushMetrics(ctx, result, c.metricsLabels)
+	if !result.Success {
+		return testsError([]*testResult{result})
+	}
+	return nil
+}
+
+// executeWait resumes polling the test having identifier args[0], launched by another client like the
+// Jenkins plugin or the Kubernetes operator, and reports it like the tests launched by the command.
+func (c *testCommand) executeWait(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		return usageErrorf("test wait require a <testResultId> arg. Check Usage.")
+	}
+	testResultID := args[0]
+	if len(c.secretFile) > 0 {
+		return usageErrorf("--secretFile flag cannot be used with test wait")
+	}
+	if err := c.checkFlags(); err != nil {
+		return err
+	}
+
+	cf := &c.cf
+	cf.silent = c.teamCity
+	cf.setup(stdout, stderr)
+	if err := cf.validate(); err != nil {
+		return err
+	}
+	waitForMilliseconds, err := parseWaitFor(c.waitFor)
 	if err != nil {
-		fmt.Printf("Got error when invoking Microcks client creating Test: %s", err)
-		os.Exit(1)
+		return usageErrorf("Invalid value for --waitFor flag: %s", err)
 	}
-	//fmt.Printf("Retrieve TestResult ID: %s", testResultID)
+	cf.apply()
+	ctx, cancel := cf.withTimeout(ctx)
+	defer cancel()
 
-	// Finally - wait before checking and loop for some time
-	time.Sleep(1 * time.Second)
+	mc, err := cf.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := mc.GetFullTestResult(ctx, testResultID)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving test result", err)
+	}
+	service, err := mc.GetService(ctx, current.ServiceID)
+	if err != nil {
+		return requestError("Got error when invoking Microcks client retrieving service of test", err)
+	}
+	serviceRef := service.Name + ":" + service.Version
 
-	// Add 10.000ms to wait time as it's now representing the server timeout.
-	now := nowInMilliseconds()
-	future := now + waitForMilliseconds + 10000
+	if c.teamCity {
+		writeTeamCityMessage(cf.decorationsOutput(), "testSuiteStarted", [][2]string{{"name", serviceRef}})
+		defer writeTeamCityMessage(cf.decorationsOutput(), "testSuiteFinished", [][2]string{{"name", serviceRef}})
+	}
+	spec := c.newSpec(serviceRef, current.TestedEndpoint, current.RunnerType, waitForMilliseconds)
+	c.warnShortTimeout([]testSpec{spec})
+	if len(c.compareWith) > 0 {
+		if spec.baseline, err = c.resolveBaseline(ctx, mc, serviceRef, spec.testEndpoint, testResultID); err != nil {
+			return err
+		}
+	}
+	result, err := waitTest(ctx, mc, cf.microcksURL, spec, testResultID)
+	if err != nil {
+		return err
+	}
+	return c.reportTest(ctx, result)
+}
 
-	var success = false
-	for nowInMilliseconds() < future {
-		testResultSummary, err := mc.GetTestResult(testResultID)
+// checkFlags validates the flags shared by tests launched from args or from a tests file.
+func (c *testCommand) checkFlags() error {
+	if c.concurrency < 1 {
+		return usageErrorf("Invalid value for --concurrency flag: should be at least 1")
+	}
+	if len(c.reportFormat) > 0 && c.reportFormat != reportJUnit {
+		return usageErrorf("--reportFormat should be one of: %s", reportJUnit)
+	}
+	if (len(c.reportFormat) > 0) != (len(c.reportFile) > 0) {
+		return usageErrorf("--reportFormat and --reportFile flags go together. Check Usage.")
+	}
+	if _, err := connectors.ParsePollStrategy(c.pollStrategy); err != nil {
+		return usageErrorf("Invalid value for --poll-strategy flag: %s", err)
+	}
+	if c.pollInterval != 0 && c.pollInterval < minPollInterval {
+		return usageErrorf("Invalid value for --pollInterval flag: should be at least %s", minPollInterval)
+	}
+	if c.retries < 0 {
+		return usageErrorf("Invalid value for --retries flag: should not be negative")
+	}
+	if c.retryDelay < 0 {
+		return usageErrorf("Invalid value for --retryDelay flag: should not be negative")
+	}
+	if c.minSuccessRate < 0 || c.minSuccessRate > 1 {
+		return usageErrorf("Invalid value for --minSuccessRate flag: should be between 0 and 1")
+	}
+	if c.minCoverage < 0 || c.minCoverage > 1 {
+		return usageErrorf("Invalid value for --minCoverage flag: should be between 0 and 1")
+	}
+	if c.failFast && c.minSuccessRate > 0 {
+		return usageErrorf("--fail-fast and --minSuccessRate flags are mutually exclusive")
+	}
+	if len(c.compareWith) > 0 && c.minSuccessRate > 0 {
+		return usageErrorf("--compare-with and --minSuccessRate flags are mutually exclusive")
+	}
+	if c.verify && !c.dryRun {
+		return usageErrorf("--verify flag requires --dry-run flag")
+	}
+	if err := c.readSecretFile(); err != nil {
+		return err
+	}
+	return c.readOptionFiles()
+}
+
+// readSecretFile loads the secret of --secretFile, used by tests in place of --secretName.
+func (c *testCommand) readSecretFile() error {
+	if len(c.secretFile) == 0 {
+		if c.deleteSecret {
+			return usageErrorf("--deleteSecret flag requires --secretFile flag")
+		}
+		return nil
+	}
+	if len(c.secretName) > 0 {
+		return usageErrorf("--secretName and --secretFile flags are mutually exclusive")
+	}
+	secret, err := config.LoadSecretFile(c.secretFile)
+	if err != nil {
+		return usageErrorf("Cannot read --secretFile: %s", err)
+	}
+	c.secret, c.secretName = secret, secret.Name
+	return nil
+}
+
+// applySecret creates or updates the secret of --secretFile on Microcks, if any, returning the function
+// to call once tests are done, deleting it with --deleteSecret.
+func (c *testCommand) applySecret(ctx context.Context, mc connectors.MicrocksClient) (func(), error) {
+	if c.secret == nil {
+		return func() {}, nil
+	}
+	secretID, err := upsertSecret(ctx, mc, *c.secret)
+	if err != nil {
+		return nil, err
+	}
+	if !c.deleteSecret {
+		return func() {}, nil
+	}
+	return func() {
+		// Tests may have been interrupted, the secret must be deleted anyway.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if err := mc.DeleteSecret(ctx, secretID); err != nil {
+			console.Warnf("Cannot delete Secret %s from Microcks: %s", c.secret.Name, err)
+			return
+		}
+		console.Debugf("Secret %s: %s", c.secret.Name, secretDelete)
+	}, nil
+}
+
+// readOptionFiles reads the JSON options of tests given as files, which cannot be used with the flag
+// giving the same option.
+func (c *testCommand) readOptionFiles() error {
+	for _, option := range []struct {
+		name  string
+		file  string
+		value *string
+	}{
+		{"operationsHeaders", c.operationsHeadersFile, &c.operationsHeaders},
+		{"oAuth2Context", c.oAuth2ContextFile, &c.oAuth2Context},
+	} {
+		if len(option.file) == 0 {
+			continue
+		}
+		if len(*option.value) > 0 {
+			return usageErrorf("--%s and --%sFile flags are mutually exclusive", option.name, option.name)
+		}
+		data, err := os.ReadFile(option.file)
+		if err != nil {
+			return usageErrorf("Cannot read --%sFile: %s", option.name, err)
+		}
+		*option.value = string(data)
+	}
+	return nil
+}
+
+// newSpec builds the spec of a test launched with the options given by flags.
+func (c *testCommand) newSpec(serviceRef string, testEndpoint string, runnerType string, waitFor int64) testSpec {
+	return testSpec{
+		serviceRef:         serviceRef,
+		testEndpoint:       testEndpoint,
+		runnerType:         runnerType,
+		secretName:         c.secretName,
+		waitFor:            waitFor,
+		filteredOperations: c.filteredOperations,
+		operationsHeaders:  c.operationsHeaders,
+		oAuth2Context:      c.oAuth2Context,
+		pollStrategy:       c.pollStrategy,
+		pollInterval:       c.pollInterval,
+		follow:             c.follow,
+		retries:            c.retries,
+		retryDelay:         c.retryDelay,
+		minSuccessRate:     c.minSuccessRate,
+		failFast:           c.failFast,
+		coverage:           c.coverage || c.minCoverage > 0,
+		minCoverage:        c.minCoverage,
+		fetchFull:          c.cf.output.IsStructured() || len(c.cf.tektonResultsDir) > 0 || len(c.cf.outputsFile) > 0 || len(c.pushgateway) > 0 || c.azureDevOps || c.teamCity || len(c.allureResults) > 0 || len(c.reportFile) > 0 || len(c.compareWith) > 0 || c.coverage || c.minCoverage > 0,
+	}
+}
+
+// executeDryRun renders the requests that would launch the tests. Their services, runners and secrets
+// are checked on Microcks first unless mc is nil.
+func (c *testCommand) executeDryRun(ctx context.Context, mc connectors.MicrocksClient, specs []testSpec) error {
+	target, err := testsURL(c.cf.microcksURL)
+	if err != nil {
+		return usageErrorf("Invalid value for --microcksURL flag: %s", err)
+	}
+
+	if mc != nil {
+		if err := c.checkSpecs(ctx, mc, specs); err != nil {
+			return err
+		}
+	}
+
+	result := &testDryRunResult{RequestID: config.RequestID}
+	for _, spec := range specs {
+		body, err := connectors.TestRequestBody(console.connectors, spec.serviceRef, spec.testEndpoint, spec.runnerType, spec.secretName, spec.waitFor, spec.filteredOperations, spec.operationsHeaders, spec.oAuth2Context)
+		if err != nil {
+			return fmt.Errorf("Cannot build request of test on %s: %w", spec.testEndpoint, err)
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fmt.Errorf("Cannot build request of test on %s: %w", spec.testEndpoint, err)
+		}
+		result.Requests = append(result.Requests, testRequest{Method: http.MethodPost, URL: target, Payload: payload, body: string(body)})
+	}
+	return c.cf.render(result)
+}
+
+// checkSpecs checks on Microcks that the services of specs exist and can be tested by their runner, and
+// that their secrets resolve.
+func (c *testCommand) checkSpecs(ctx context.Context, mc connectors.MicrocksClient, specs []testSpec) error {
+	var secrets map[string]bool
+	for _, spec := range specs {
+		if err := checkTestedService(ctx, mc, spec.serviceRef, spec.runnerType); err != nil {
+			return err
+		}
+		if len(spec.secretName) == 0 {
+			continue
+		}
+		if secrets == nil {
+			list, err := connectors.ListAll(ctx, mc.ListSecrets, 0)
+			if err != nil {
+				return requestError("Got error when invoking Microcks client listing Secrets", err)
+			}
+			secrets = map[string]bool{}
+			for _, secret := range list {
+				secrets[secret.Name] = true
+			}
+		}
+		// The secret of --secretFile is only created when launching tests.
+		if !secrets[spec.secretName] && (c.secret == nil || c.secret.Name != spec.secretName) {
+			return usageErrorf("Secret '%s' of test of %s not found on Microcks", spec.secretName, spec.serviceRef)
+		}
+	}
+	return nil
+}
+
+// runnerServiceTypes are the types of services each runner can test.
+var runnerServiceTypes = map[string][]connectors.ServiceType{
+	"HTTP":             {connectors.ServiceTypeREST, connectors.ServiceTypeGenericREST, connectors.ServiceTypeSOAP, connectors.ServiceTypeGraphQL},
+	"SOAP_HTTP":        {connectors.ServiceTypeSOAP},
+	"SOAP_UI":          {connectors.ServiceTypeSOAP, connectors.ServiceTypeREST},
+	"POSTMAN":          {connectors.ServiceTypeREST, connectors.ServiceTypeGenericREST, connectors.ServiceTypeSOAP, connectors.ServiceTypeGraphQL},
+	"OPEN_API_SCHEMA":  {connectors.ServiceTypeREST},
+	"ASYNC_API_SCHEMA": {connectors.ServiceTypeEvent, connectors.ServiceTypeGenericEvent},
+	"GRPC_PROTOBUF":    {connectors.ServiceTypeGRPC},
+	"GRAPHQL_SCHEMA":   {connectors.ServiceTypeGraphQL},
+}
+
+// checkTestedService checks that service serviceRef exists on Microcks and that runnerType can test it.
+// Services whose type is not reported are assumed to be compatible.
+func checkTestedService(ctx context.Context, mc connectors.MicrocksClient, serviceRef string, runnerType string) error {
+	i := strings.LastIndex(serviceRef, ":")
+	if i < 0 {
+		return usageErrorf("Service reference '%s' should be <apiName:apiVersion>", serviceRef)
+	}
+	service, err := mc.GetServiceByRef(ctx, serviceRef[:i], serviceRef[i+1:])
+	if err != nil {
+		return serviceError("Got error when invoking Microcks client getting Service", serviceRef, err)
+	}
+	if len(service.Type) == 0 {
+		return nil
+	}
+	for _, serviceType := range runnerServiceTypes[runnerType] {
+		if service.Type == serviceType {
+			return nil
+		}
+	}
+	return usageErrorf("%s runner cannot test %s service '%s'", runnerType, service.Type, serviceRef)
+}
+
+// testsURL returns the URL CreateTestResult posts tests to, using the first Microcks URL.
+func testsURL(microcksURL string) (string, error) {
+	apiURL := strings.TrimSpace(strings.Split(microcksURL, ",")[0])
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", err
+	}
+	return u.ResolveReference(&url.URL{Path: "api/tests"}).String(), nil
+}
+
+// executeMatrix tests the service on several endpoints and renders a comparative summary.
+func (c *testCommand) executeMatrix(ctx context.Context, mc connectors.MicrocksClient, spec testSpec, endpoints []string) error {
+	cf := &c.cf
+	policy := matrixRequireAll
+	if c.requireAnyPass {
+		policy = matrixRequireAny
+	}
+	result := newMatrixResult(spec, policy, runTestMatrix(ctx, mc, cf.microcksURL, spec, endpoints, c.concurrency))
+	c.nameEnvironments(result.Tests)
+
+	if err := cf.render(result); err != nil {
+		return err
+	}
+	if err := c.reportTests(ctx, result.Tests, func(test *testResult) string { return test.TestEndpoint }); err != nil {
+		return err
+	}
+	if !result.Success {
+		if c.azureDevOps {
+			writeAzureDevOpsFailure(cf.decorationsOutput(), fmt.Sprintf("Tests of %s did not pass on %s", spec.serviceRef, result.requirement()))
+		}
+		return testsError(result.Tests)
+	}
+	return nil
+}
+
+// reportTests writes the reports of tests asked by flags once they are rendered. Tests are named by
+// name in TeamCity suites and their metrics are pushed to their own group, labelled with their endpoint.
+func (c *testCommand) reportTests(ctx context.Context, tests []*testResult, name func(test *testResult) string) error {
+	cf := &c.cf
+	if len(c.allureResults) > 0 {
+		for _, test := range tests {
+			if err := writeAllureResults(c.allureResults, test); err != nil {
+				return err
+			}
+		}
+	}
+	if len(c.reportFile) > 0 {
+		if err := writeJUnitReport(c.reportFile, tests); err != nil {
+			return err
+		}
+	}
+	if c.azureDevOps {
+		writeAzureDevOpsUploads(cf.decorationsOutput(), c.reportFile, cf.outputFile)
+	}
+	for _, test := range tests {
+		if c.azureDevOps && !test.Success {
+			writeAzureDevOpsIssues(cf.decorationsOutput(), test)
+		}
+		if c.teamCity {
+			writeTeamCityMessage(cf.decorationsOutput(), "testSuiteStarted", [][2]string{{"name", name(test)}})
+			writeTeamCityTests(cf.decorationsOutput(), test)
+			writeTeamCityMessage(cf.decorationsOutput(), "testSuiteFinished", [][2]string{{"name", name(test)}})
+		}
+		if len(test.Error) == 0 {
+			labels := metricsLabels{}
+			for name, value := range c.metricsLabels {
+				labels[name] = value
+			}
+			labels["endpoint"] = test.TestEndpoint
+			if len(test.Environment) > 0 {
+				labels["environment"] = test.Environment
+			}
+			c.pushMetrics(ctx, test, labels)
+		}
+	}
+	return nil
+}
+
+// pushMetrics pushes the metrics of a test to the Pushgateway if one is set. Metrics are best effort,
+// they never change the outcome of the test.
+func (c *testCommand) pushMetrics(ctx context.Context, result *testResult, labels metricsLabels) {
+	if len(c.pushgateway) == 0 {
+		return
+	}
+	if err := pushTestMetrics(ctx, newPushgatewayClient(c.cf.connectorsConfig()), c.pushgateway, result, labels); err != nil {
+		console.Warnf("Cannot push test metrics to Pushgateway at %s: %s", c.pushgateway, err)
+	}
+}
+
+// testSpec holds the parameters of a test to launch on Microcks.
+type testSpec struct {
+	serviceRef         string
+	testEndpoint       string
+	runnerType         string
+	secretName         string
+	waitFor            int64
+	filteredOperations string
+	operationsHeaders  string
+	oAuth2Context      string
+	// pollStrategy is the name of the connectors.PollStrategy used to wait for the result.
+	pollStrategy string
+	// pollInterval replaces the delay between polls of the strategy, 0 keeping its default.
+	pollInterval time.Duration
+	// follow prints the operations as their test completes.
+	follow bool
+	// retries is the number of times a test that did not succeed is launched again, after retryDelay.
+	retries    int
+	retryDelay time.Duration
+	// minSuccessRate is the rate of passed operations making the test succeed, 0 keeping the verdict of Microcks.
+	minSuccessRate float64
+	// failFast stops waiting for the test as soon as an operation failed.
+	failFast bool
+	// baseline is the previous test whose failed operations are not regressions, nil to keep the verdict of Microcks.
+	baseline *connectors.TestResult
+	// coverage compares the tested operations with the ones of the service, minCoverage being the rate of
+	// them the test must exercise, 0 only reporting it.
+	coverage    bool
+	minCoverage float64
+	// fetchFull asks for the test cases of the result, to report operations.
+	fetchFull bool
+}
+
+// parseWaitFor computes the time to wait in milliseconds from an int followed by one of milli, sec, min,
+// or from a Go duration like 30s, 2m30s or 500ms.
+func parseWaitFor(waitFor string) (int64, error) {
+	for _, unit := range []struct {
+		suffix string
+		millis int64
+	}{{"milli", 1}, {"sec", 1000}, {"min", 60 * 1000}} {
+		if strings.HasSuffix(waitFor, unit.suffix) {
+			value, err := strconv.ParseInt(waitFor[:len(waitFor)-len(unit.suffix)], 0, 64)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("'%s' is not an int followed by %s", waitFor, unit.suffix)
+			}
+			return value * unit.millis, nil
+		}
+	}
+	duration, err := time.ParseDuration(waitFor)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("'%s' is neither an int followed by one of milli, sec, min nor a duration like 30s or 2m30s", waitFor)
+	}
+	return duration.Milliseconds(), nil
+}
+
+// runTest launches a test on Microcks and polls its result until it completes or times out. A test that
+// did not succeed is launched again up to spec.retries times, the result of the last one being returned.
+func runTest(ctx context.Context, mc connectors.MicrocksClient, microcksURL string, spec testSpec) (*testResult, error) {
+	for attempt := 1; ; attempt++ {
+		result, err := launchTest(ctx, mc, microcksURL, spec)
 		if err != nil {
-			fmt.Printf("Got error when invoking Microcks client check TestResult: %s", err)
-			os.Exit(1)
+			return nil, err
+		}
+		if spec.retries > 0 {
+			result.Attempts = attempt
+		}
+		// Launching the test again would not exercise more operations.
+		if result.Success || attempt > spec.retries || result.coverageFailed() {
+			return result, nil
 		}
-		success = testResultSummary.Success
-		inProgress := testResultSummary.InProgress
-		fmt.Printf("MicrocksClient got status for test \"%s\" - success: %s, inProgress: %s \n", testResultID, fmt.Sprint(success), fmt.Sprint(inProgress))
 
-		if !inProgress {
-			break
+		console.Warnf("Test %s of %s did not succeed, launching it again in %s (retry %d of %d)", result.TestResultID, spec.serviceRef, spec.retryDelay, attempt, spec.retries)
+		timer := time.NewTimer(spec.retryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("Stopped retrying test of %s: %w", spec.serviceRef, ctx.Err())
+		case <-timer.C:
 		}
+	}
+}
 
-		fmt.Println("MicrocksTester waiting for 2 seconds before checking again or exiting.")
-		time.Sleep(2 * time.Second)
+// launchTest launches a test on Microcks and polls its result until it completes or times out.
+func launchTest(ctx context.Context, mc connectors.MicrocksClient, microcksURL string, spec testSpec) (*testResult, error) {
+	testResultID, err := mc.CreateTestResult(ctx, spec.serviceRef, spec.testEndpoint, spec.runnerType, spec.secretName, spec.waitFor, spec.filteredOperations, spec.operationsHeaders, spec.oAuth2Context)
+	if err != nil {
+		return nil, serviceError("Got error when invoking Microcks client creating Test", spec.serviceRef, err)
+	}
+	return waitTest(ctx, mc, microcksURL, spec, testResultID)
+}
+
+// waitTest polls the result of the test having identifier testResultID until it completes or times out.
+func waitTest(ctx context.Context, mc connectors.MicrocksClient, microcksURL string, spec testSpec, testResultID string) (*testResult, error) {
+	strategy, err := connectors.ParsePollStrategy(spec.pollStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if adaptive, ok := strategy.(*connectors.AdaptivePoll); ok && spec.pollInterval > 0 {
+		adaptive.Steps = []time.Duration{spec.pollInterval}
+	}
+
+	// Finally - wait before checking and loop for some time. The wait time being the timeout of the
+	// test on Microcks, polling goes on for serverReportMargin after it.
+	var onTestCase func(testCase connectors.TestCaseResult)
+	if spec.follow {
+		onTestCase = func(testCase connectors.TestCaseResult) {
+			status := "PASS"
+			if !testCase.Success {
+				status = "FAIL"
+			}
+			console.Printf("  %s %s (%d ms)\n", console.Styles().Verdict(testCase.Success, status), testCase.OperationName, testCase.ElapsedTime)
+		}
+	}
+	result, err := mc.WaitForTestResult(ctx, testResultID, connectors.PollOptions{
+		Timeout:   time.Duration(spec.waitFor)*time.Millisecond + serverReportMargin,
+		Interval:  spec.pollInterval,
+		Strategy:  strategy,
+		FetchFull: spec.fetchFull,
+		OnPoll: func(summary *connectors.TestResultSummary, next time.Duration) {
+			console.Printf("MicrocksClient got status for test \"%s\" - success: %s, inProgress: %s \n", testResultID, console.Styles().Verdict(summary.Success || summary.InProgress, fmt.Sprint(summary.Success)), fmt.Sprint(summary.InProgress))
+			if next > 0 {
+				console.Printf("MicrocksTester waiting for %g seconds before checking again or exiting.\n", next.Seconds())
+			}
+		},
+		OnTestCase: onTestCase,
+		FailFast:   spec.failFast,
+	})
+	failedFast := false
+	switch {
+	case errors.Is(err, connectors.ErrWaitTimeout):
+		// Still in progress, reported as a failure by caller.
+	case errors.Is(err, connectors.ErrTestCaseFailed):
+		console.Warnf("An operation of test %s failed, stopped waiting for its other operations", testResultID)
+		failedFast = true
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		if cause := context.Cause(ctx); cause != nil {
+			err = cause
+		}
+		// Microcks has no API to cancel a test, it only ends once completed or timed out.
+		console.Warnf("Test %s keeps running on Microcks until it completes or its %s timeout elapses, resume waiting for it with 'microcks-cli test wait %s' or see %s",
+			testResultID, time.Duration(spec.waitFor)*time.Millisecond, testResultID, testURL(microcksURL, testResultID))
+		return nil, fmt.Errorf("Stopped waiting for result of test %s: %w", testResultID, err)
+	case err != nil:
+		return nil, requestError("Got error when invoking Microcks client check TestResult", err)
+	}
+	if !result.Success && !result.InProgress && len(result.TestCaseResults) == 0 {
+		// Fetch the test cases of failed tests to tell which operations failed and why.
+		if full, err := mc.GetFullTestResult(ctx, testResultID); err == nil {
+			result = full
+		} else {
+			console.Debugf("Cannot retrieve test cases of test %s: %v", testResultID, err)
+		}
 	}
 
-	fmt.Printf("Full TestResult details are available here: %s/#/tests/%s \n", strings.Split(microcksURL, "/api")[0], testResultID)
+	test := &testResult{
+		TestResultID: testResultID,
+		ServiceRef:   spec.serviceRef,
+		TestEndpoint: spec.testEndpoint,
+		RunnerType:   spec.runnerType,
+		Success:      result.Success,
+		InProgress:   result.InProgress,
+		URL:          testURL(microcksURL, testResultID),
+		Operations:   testedOperations(result),
+		FailedFast:   failedFast,
+		ElapsedTime:  result.ElapsedTime,
+		RequestID:    config.RequestID,
+		details:      result,
+	}
+	if result.TestDate > 0 {
+		test.TestDate = time.UnixMilli(result.TestDate).UTC().Format(time.RFC3339)
+	}
+	if spec.minSuccessRate > 0 {
+		test.applySuccessRate(spec.minSuccessRate)
+	}
+	if spec.baseline != nil {
+		test.applyComparison(spec.baseline)
+	}
+	if spec.coverage && !test.InProgress {
+		service, err := mc.GetService(ctx, result.ServiceID)
+		if err != nil {
+			return nil, serviceError("Got error when invoking Microcks client getting Service", spec.serviceRef, err)
+		}
+		test.applyCoverage(service.Operations, spec.minCoverage)
+	}
+	return test, nil
+}
 
-	if !success {
-		os.Exit(1)
+// warnShortTimeout warns when --timeout may interrupt tests before Microcks completes them.
+func (c *testCommand) warnShortTimeout(specs []testSpec) {
+	if c.cf.timeout <= 0 {
+		return
+	}
+	for _, spec := range specs {
+		if wait := time.Duration(spec.waitFor)*time.Millisecond + serverReportMargin; c.cf.timeout < wait {
+			console.Warnf("--timeout of %s may interrupt tests before Microcks completes them, their --waitFor letting them run up to %s", c.cf.timeout, wait)
+			return
+		}
 	}
 }
 
-func nowInMilliseconds() int64 {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+// testURL returns the URL of the page of the test having identifier testResultID in Microcks UI.
+func testURL(microcksURL string, testResultID string) string {
+	return fmt.Sprintf("%s/#/tests/%s", strings.Split(microcksURL, "/api")[0], testResultID)
+}
+
+func (c *testCommand) flagSet() *flag.FlagSet {
+	return c.fs
 }
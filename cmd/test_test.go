@@ -0,0 +1,293 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/connectors/testutil"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestTestDryRunPayload(t *testing.T) {
+	tests := []struct {
+		name         string
+		testEndpoint string
+		flags        []string
+		want         map[string]interface{}
+	}{
+		{
+			name:         "required fields",
+			testEndpoint: "http://beers:8080/api",
+			want:         map[string]interface{}{"serviceId": "Beer Catalog API:0.9", "testEndpoint": "http://beers:8080/api", "runnerType": "HTTP", "timeout": 5000.0},
+		},
+		{
+			name:         "quoted endpoint",
+			testEndpoint: `http://beers:8080/api?filter="stout"&x=\`,
+			flags:        []string{"--waitFor=2s"},
+			want:         map[string]interface{}{"serviceId": "Beer Catalog API:0.9", "testEndpoint": `http://beers:8080/api?filter="stout"&x=\`, "runnerType": "HTTP", "timeout": 2000.0},
+		},
+		{
+			name:         "filtered operations",
+			testEndpoint: "http://beers:8080/api",
+			flags:        []string{`--filteredOperations=["GET /beer"]`},
+			want:         map[string]interface{}{"serviceId": "Beer Catalog API:0.9", "testEndpoint": "http://beers:8080/api", "runnerType": "HTTP", "timeout": 5000.0, "filteredOperations": []interface{}{"GET /beer"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+
+			args := append([]string{"Beer Catalog API:0.9", test.testEndpoint, "HTTP", "--dry-run", "--output=json",
+				"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}, test.flags...)
+			var stdout, stderr bytes.Buffer
+			if err := NewTestCommand().Execute(context.Background(), args, &stdout, &stderr); err != nil {
+				t.Fatalf("Execute() error = %v, stderr: %s", err, stderr.String())
+			}
+			var result struct {
+				Requests []struct {
+					Method  string                 `json:"method"`
+					URL     string                 `json:"url"`
+					Payload map[string]interface{} `json:"payload"`
+				} `json:"requests"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+				t.Fatalf("invalid JSON result %q: %v", stdout.String(), err)
+			}
+			if len(result.Requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(result.Requests))
+			}
+			request := result.Requests[0]
+			if request.Method != "POST" || !strings.HasSuffix(request.URL, "/api/tests") {
+				t.Errorf("request = %s %s, want POST .../api/tests", request.Method, request.URL)
+			}
+			got, _ := json.Marshal(request.Payload)
+			want, _ := json.Marshal(test.want)
+			if !bytes.Equal(got, want) {
+				t.Errorf("payload = %s, want %s", got, want)
+			}
+			if launched := srv.Tests(); len(launched) > 0 {
+				t.Errorf("dry run launched %d tests", len(launched))
+			}
+		})
+	}
+}
+
+func TestTestDryRunTextBody(t *testing.T) {
+	t.Setenv(githubOutputEnv, "")
+	srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+	defer srv.Close()
+	srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+
+	args := []string{"Beer Catalog API:0.9", `http://beers/"quoted"`, "HTTP", "--dry-run", "--no-color",
+		"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}
+	var stdout, stderr bytes.Buffer
+	if err := NewTestCommand().Execute(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("Execute() error = %v, stderr: %s", err, stderr.String())
+	}
+	want := `{"serviceId":"Beer Catalog API:0.9","testEndpoint":"http://beers/\"quoted\"","runnerType":"HTTP","timeout":5000}`
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if strings.HasPrefix(line, "{") {
+			if line != want {
+				t.Errorf("body = %s, want %s", line, want)
+			}
+			return
+		}
+	}
+	t.Errorf("no request body in output %q", stdout.String())
+}
+
+func TestTestDryRunOffline(t *testing.T) {
+	tests := []struct {
+		name        string
+		flags       []string
+		withServer  bool
+		wantErr     bool
+		wantRequest bool
+	}{
+		{name: "no credentials nor server", flags: []string{"--dry-run"}, wantRequest: true},
+		{name: "unknown secret not checked", flags: []string{"--dry-run", "--secretName=unknown"}, wantRequest: true},
+		{name: "verify", flags: []string{"--dry-run", "--verify", "--keycloakClientId=c", "--keycloakClientSecret=s"}, withServer: true, wantRequest: true},
+		{name: "verify unknown secret", flags: []string{"--dry-run", "--verify", "--keycloakClientId=c", "--keycloakClientSecret=s", "--secretName=unknown"}, withServer: true, wantErr: true},
+		{name: "verify without credentials", flags: []string{"--dry-run", "--verify", "--no-input"}, wantErr: true},
+		{name: "verify without dry run", flags: []string{"--verify"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			// Nothing listens on this URL: offline dry runs must not connect to it.
+			microcksURL := "http://127.0.0.1:1/api/"
+			if test.withServer {
+				srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+				defer srv.Close()
+				srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+				microcksURL = srv.URL + "/"
+			}
+
+			args := append([]string{"Beer Catalog API:0.9", "http://beers:8080/api", "HTTP", "--output=json",
+				"--microcksURL=" + microcksURL}, test.flags...)
+			var stdout, stderr bytes.Buffer
+			err := NewTestCommand().Execute(context.Background(), args, &stdout, &stderr)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v, stderr: %s", err, test.wantErr, stderr.String())
+			}
+			if got := strings.Contains(stdout.String(), `/api/tests"`); got != test.wantRequest {
+				t.Errorf("request printed = %v, want %v, stdout: %s", got, test.wantRequest, stdout.String())
+			}
+		})
+	}
+}
+
+func TestWaitTestCancelled(t *testing.T) {
+	errTimeout := errors.New("--timeout of 1m0s elapsed")
+	tests := []struct {
+		name   string
+		cancel func(parent context.Context) (context.Context, func())
+		want   error
+	}{
+		{"interrupted", func(parent context.Context) (context.Context, func()) {
+			ctx, cancel := context.WithCancel(parent)
+			return ctx, cancel
+		}, context.Canceled},
+		{"timeout", func(parent context.Context) (context.Context, func()) {
+			ctx, cancel := context.WithCancelCause(parent)
+			return ctx, func() { cancel(errTimeout) }
+		}, errTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			configureConsole(&out, &out, false, false, slog.LevelInfo, false)
+			ctx, cancel := test.cancel(context.Background())
+			mc := &testutil.MockMicrocksClient{
+				WaitForTestResultFunc: func(ctx context.Context, testResultID string, opts connectors.PollOptions) (*connectors.TestResult, error) {
+					// Interrupted while polling the test still in progress.
+					cancel()
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}
+			spec := testSpec{serviceRef: "Beer Catalog API:0.9", testEndpoint: "http://beers", runnerType: "HTTP", waitFor: 60000}
+
+			_, err := waitTest(ctx, mc, "http://microcks/api/", spec, "test-1")
+			if !errors.Is(err, test.want) {
+				t.Errorf("waitTest() error = %v, want %v", err, test.want)
+			}
+			if !strings.Contains(err.Error(), "Stopped waiting for result of test test-1") {
+				t.Errorf("waitTest() error = %v", err)
+			}
+			// Tests cannot be cancelled on Microcks, the warning tells how to resume waiting.
+			for _, want := range []string{"keeps running on Microcks", time.Minute.String(), "microcks-cli test wait test-1"} {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("warning %q does not contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestTestOutcome(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      microckstest.TestScript
+		wantFailure bool
+	}{
+		{
+			name:   "success",
+			script: microckstest.TestScript{InProgressPolls: 1, Success: true, TestCaseResults: []connectors.TestCaseResult{{OperationName: "GET /beer", Success: true}}},
+		},
+		{
+			name:        "failure",
+			script:      microckstest.TestScript{TestCaseResults: []connectors.TestCaseResult{{OperationName: "GET /beer", Success: false}}},
+			wantFailure: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+			srv.ScriptTest("Beer Catalog API:0.9", test.script)
+
+			args := []string{"Beer Catalog API:0.9", "http://beers", "HTTP", "--poll-strategy=fixed", "--pollInterval=100ms",
+				"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=s"}
+			var stdout, stderr bytes.Buffer
+			err := NewTestCommand().Execute(context.Background(), args, &stdout, &stderr)
+			var failed *TestFailedError
+			if (err != nil) != test.wantFailure || err != nil && !errors.As(err, &failed) {
+				t.Fatalf("Execute() error = %v, wantFailure %v, stderr: %s", err, test.wantFailure, stderr.String())
+			}
+			launched := srv.Tests()
+			if len(launched) != 1 {
+				t.Fatalf("got %d launched tests, want 1", len(launched))
+			}
+			if launched[0].TestedEndpoint != "http://beers" || launched[0].RunnerType != "HTTP" {
+				t.Errorf("launched test = %+v", launched[0])
+			}
+		})
+	}
+}
+
+func TestTestFailures(t *testing.T) {
+	tests := []struct {
+		name         string
+		serviceRef   string
+		clientSecret string
+		script       microckstest.TestScript
+		wantCode     string
+		wantStatus   int
+	}{
+		{name: "unknown service", serviceRef: "Pastry API:2.0", clientSecret: "s", wantCode: errorCodeNotFound, wantStatus: http.StatusNotFound},
+		{name: "still in progress", serviceRef: "Beer Catalog API:0.9", clientSecret: "s", script: microckstest.TestScript{InProgressPolls: 1000}, wantCode: errorCodeTestTimeout},
+		{name: "refused credentials", serviceRef: "Beer Catalog API:0.9", clientSecret: "wrong", wantCode: errorCodeAuthFailed, wantStatus: http.StatusUnauthorized},
+	}
+	margin := serverReportMargin
+	defer func() { serverReportMargin = margin }()
+	serverReportMargin = 0
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(githubOutputEnv, "")
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+			srv.ScriptTest("Beer Catalog API:0.9", test.script)
+
+			args := []string{test.serviceRef, "http://beers", "HTTP", "--waitFor=1sec", "--poll-strategy=fixed", "--pollInterval=100ms",
+				"--microcksURL=" + srv.URL + "/", "--keycloakClientId=c", "--keycloakClientSecret=" + test.clientSecret, "--no-input"}
+			var stdout, stderr bytes.Buffer
+			err := NewTestCommand().Execute(context.Background(), args, &stdout, &stderr)
+			if err == nil {
+				t.Fatal("Execute() succeeded")
+			}
+			if obj := newErrorObject(err); obj.Code != test.wantCode || obj.Status != test.wantStatus {
+				t.Errorf("error %v has code %s and status %d, want %s and %d", err, obj.Code, obj.Status, test.wantCode, test.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// timingReport gathers the timings of API requests recorded with --timing. It is included in
+// structured results and summarized on stderr once command has completed.
+type timingReport struct {
+	Requests  []requestTiming  `json:"requests" yaml:"requests"`
+	Endpoints []endpointTiming `json:"endpoints" yaml:"endpoints"`
+}
+
+// requestTiming is the timing of an API request, durations being in milliseconds.
+type requestTiming struct {
+	Endpoint string  `json:"endpoint" yaml:"endpoint"`
+	Method   string  `json:"method" yaml:"method"`
+	Path     string  `json:"path" yaml:"path"`
+	Status   int     `json:"status" yaml:"status"`
+	DNS      float64 `json:"dnsMs" yaml:"dnsMs"`
+	Connect  float64 `json:"connectMs" yaml:"connectMs"`
+	TLS      float64 `json:"tlsMs" yaml:"tlsMs"`
+	TTFB     float64 `json:"ttfbMs" yaml:"ttfbMs"`
+	Total    float64 `json:"totalMs" yaml:"totalMs"`
+}
+
+// endpointTiming summarizes the total durations of the requests to an endpoint, in milliseconds.
+type endpointTiming struct {
+	Endpoint string  `json:"endpoint" yaml:"endpoint"`
+	Count    int     `json:"count" yaml:"count"`
+	P50      float64 `json:"p50Ms" yaml:"p50Ms"`
+	P95      float64 `json:"p95Ms" yaml:"p95Ms"`
+}
+
+var (
+	// timingEnabled tells if --timing was given, timings being recorded by recordTiming.
+	timingEnabled bool
+	timingsMutex  sync.Mutex
+	timings       []connectors.RequestTiming
+)
+
+// recordTiming stores the timing of a completed API request. It may be called by concurrent clients.
+func recordTiming(timing connectors.RequestTiming) {
+	timingsMutex.Lock()
+	defer timingsMutex.Unlock()
+	timings = append(timings, timing)
+}
+
+// recordedTimings returns the timings recorded so far, in completion order.
+func recordedTimings() []connectors.RequestTiming {
+	timingsMutex.Lock()
+	defer timingsMutex.Unlock()
+	return append([]connectors.RequestTiming(nil), timings...)
+}
+
+// timedResult is implemented by results able to carry the timings of API requests.
+type timedResult interface {
+	setTimings(report *timingReport)
+}
+
+// newTimingReport builds the report of timings recorded so far, nil if timing is disabled.
+func newTimingReport() *timingReport {
+	if !timingEnabled {
+		return nil
+	}
+	report := &timingReport{Requests: []requestTiming{}, Endpoints: []endpointTiming{}}
+	totals := map[string][]time.Duration{}
+	var endpoints []string
+	for _, t := range recordedTimings() {
+		report.Requests = append(report.Requests, requestTiming{
+			Endpoint: t.Name,
+			Method:   t.Method,
+			Path:     t.Path,
+			Status:   t.Status,
+			DNS:      millis(t.DNS),
+			Connect:  millis(t.Connect),
+			TLS:      millis(t.TLS),
+			TTFB:     millis(t.TTFB),
+			Total:    millis(t.Total),
+		})
+		if _, ok := totals[t.Name]; !ok {
+			endpoints = append(endpoints, t.Name)
+		}
+		totals[t.Name] = append(totals[t.Name], t.Total)
+	}
+	for _, endpoint := range endpoints {
+		durations := totals[endpoint]
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		report.Endpoints = append(report.Endpoints, endpointTiming{
+			Endpoint: endpoint,
+			Count:    len(durations),
+			P50:      millis(percentile(durations, 50)),
+			P95:      millis(percentile(durations, 95)),
+		})
+	}
+	return report
+}
+
+// RenderText writes the summary table of endpoint timings.
+func (r *timingReport) RenderText(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tCOUNT\tP50\tP95")
+	for _, e := range r.Endpoints {
+		fmt.Fprintf(tw, "%s\t%d\t%.1fms\t%.1fms\n", e.Endpoint, e.Count, e.P50, e.P95)
+	}
+	tw.Flush()
+}
+
+// ReportTimings writes the summary of API request timings on stderr when --timing is set.
+func ReportTimings() {
+	report := newTimingReport()
+	if report == nil {
+		return
+	}
+	fmt.Fprintf(errorsOutput, "Timing of %d API request(s):\n", len(report.Requests))
+	report.RenderText(errorsOutput)
+}
+
+// percentile returns the p-th percentile of sorted durations using the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -16,20 +16,186 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/microcks/microcks-cli/pkg/output"
 	"github.com/microcks/microcks-cli/version"
 )
 
+// latestReleaseURL is the GitHub API endpoint describing the latest microcks-cli release.
+const latestReleaseURL = "https://api.github.com/repos/microcks/microcks-cli/releases/latest"
+
+var versionUsage = usage{
+	name:        "version",
+	synopsis:    "version [flags]",
+	description: "Check this CLI version and build information.",
+	examples: []string{
+		"microcks-cli version --check",
+		"microcks-cli --version",
+	},
+}
+
 type versionCommand struct {
+	fs     *flag.FlagSet
+	output output.Format
+	check  bool
 }
 
 // NewVersionCommand build a new VersionCommand implementation
 func NewVersionCommand() Command {
-	return new(versionCommand)
+	c := new(versionCommand)
+	c.fs = newFlagSet(versionUsage)
+	c.output = output.Text
+	c.fs.Func("output", "Output format of command result (one of: text, wide, json, yaml, env, exec:<plugin>)", func(value string) (err error) {
+		c.output, err = output.ParseFormat(value)
+		return err
+	})
+	c.fs.BoolVar(&c.check, "check", false, "Check whether a newer release is available on GitHub")
+	return c
 }
 
 // Execute implementation on versionCommand structure
-func (c *versionCommand) Execute() {
-	fmt.Println(version.Version)
+func (c *versionCommand) Execute(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if wantsHelp(args) {
+		c.printUsage(stdout)
+		return nil
+	}
+	args, err := parseArgs(c.fs, args, stderr)
+	if err != nil {
+		return err
+	}
+	if err := versionUsage.checkArgs(args); err != nil {
+		return err
+	}
+
+	result := &versionResult{
+		Version:   version.Version,
+		Commit:    version.GitCommit(),
+		BuildDate: version.Date(),
+		GoVersion: version.GoVersion(),
+	}
+	if c.check {
+		// Never fail on check: the version itself is what matters.
+		latest, err := latestRelease(ctx)
+		if err != nil {
+			fmt.Fprintf(stderr, "Cannot check for a newer release: %s\n", err)
+		} else {
+			result.LatestVersion = latest
+			result.UpdateAvailable = newerVersion(latest, version.Version)
+		}
+	}
+	output.PluginEnv = pluginEnv(versionUsage.name)
+	if err := output.Render(stdout, c.output, result); err != nil {
+		return fmt.Errorf("Cannot render result: %w", err)
+	}
+	return nil
+}
+
+func (c *versionCommand) printUsage(w io.Writer) {
+	c.fs.SetOutput(w)
+	c.fs.Usage()
+}
+
+// versionResult is the outcome of version command, rendered using the --output format.
+type versionResult struct {
+	Version         string `json:"version" yaml:"version"`
+	Commit          string `json:"commit" yaml:"commit"`
+	BuildDate       string `json:"buildDate" yaml:"buildDate"`
+	GoVersion       string `json:"goVersion" yaml:"goVersion"`
+	LatestVersion   string `json:"latestVersion,omitempty" yaml:"latestVersion,omitempty"`
+	UpdateAvailable bool   `json:"updateAvailable,omitempty" yaml:"updateAvailable,omitempty"`
+}
+
+// RenderText implements output.TextRenderer for versionResult.
+func (r *versionResult) RenderText(w io.Writer) {
+	fmt.Fprintln(w, r.Version)
+	fmt.Fprintf(w, "  Git commit: %s\n", r.Commit)
+	fmt.Fprintf(w, "  Build date: %s\n", r.BuildDate)
+	fmt.Fprintf(w, "  Go version: %s\n", r.GoVersion)
+	switch {
+	case r.UpdateAvailable:
+		fmt.Fprintln(w, output.Styles(w).Warning(fmt.Sprintf("A newer release is available: %s (https://github.com/microcks/microcks-cli/releases)", r.LatestVersion)))
+	case len(r.LatestVersion) > 0:
+		fmt.Fprintln(w, "You are using the latest release.")
+	}
+}
+
+// EnvVars implements output.EnvRenderer for versionResult.
+func (r *versionResult) EnvVars() [][2]string {
+	return [][2]string{
+		{"MICROCKS_CLI_VERSION", r.Version},
+		{"MICROCKS_CLI_COMMIT", r.Commit},
+		{"MICROCKS_CLI_BUILD_DATE", r.BuildDate},
+		{"MICROCKS_CLI_LATEST_VERSION", r.LatestVersion},
+		{"MICROCKS_CLI_UPDATE_AVAILABLE", strconv.FormatBool(r.UpdateAvailable)},
+	}
+}
+
+// latestRelease queries GitHub releases API for the latest release version.
+func latestRelease(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", latestReleaseURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("unexpected status %d from GitHub", resp.StatusCode)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&release); err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(release.TagName, "v"), nil
+}
+
+// newerVersion tells if semantic version candidate is greater than current.
+func newerVersion(candidate, current string) bool {
+	parse := func(v string) []int {
+		v = strings.SplitN(strings.TrimPrefix(v, "v"), "-", 2)[0]
+		var parts []int
+		for _, part := range strings.Split(v, ".") {
+			n, _ := strconv.Atoi(part)
+			parts = append(parts, n)
+		}
+		return parts
+	}
+	c, v := parse(candidate), parse(current)
+	for i := 0; i < len(c) || i < len(v); i++ {
+		var a, b int
+		if i < len(c) {
+			a = c[i]
+		}
+		if i < len(v) {
+			b = v[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return false
+}
+
+func (c *versionCommand) flagSet() *flag.FlagSet {
+	return c.fs
 }
@@ -1,3 +1,11 @@
 module github.com/microcks/microcks-cli
 
-go 1.12
+go 1.21
+
+require (
+	golang.org/x/term v0.20.0
+	golang.org/x/time v0.8.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.20.0 // indirect
@@ -1,33 +1,104 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/microcks/microcks-cli/cmd"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/output"
 )
 
 func main() {
-	var c cmd.Command
+	// Interrupting the CLI cancels pending API requests and polling. A second interruption kills it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	if len(os.Args) == 1 {
-		cmd.NewHelpCommand().Execute()
-		os.Exit(1)
+	// Argument files are expanded before dispatch so that every command supports them.
+	args, err := cmd.ExpandArgFiles(os.Args[1:])
+	if err != nil {
+		os.Exit(exitCode(err))
 	}
 
-	switch os.Args[1] {
-	case "version":
-		c = cmd.NewVersionCommand()
-	case "help":
-		c = cmd.NewHelpCommand()
-	case "test":
-		c = cmd.NewTestCommand()
-	case "import":
-		c = cmd.NewImportCommand()
-	default:
-		cmd.NewHelpCommand().Execute()
-		os.Exit(1)
+	if len(args) == 0 {
+		cmd.NewHelpCommand().Execute(ctx, nil, os.Stdout, os.Stderr)
+		os.Exit(exitUsage)
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help":
+		cmd.NewHelpCommand().Execute(ctx, nil, os.Stdout, os.Stderr)
+		return
+	case "-version", "--version":
+		os.Exit(exitCode(cmd.NewVersionCommand().Execute(ctx, args[1:], os.Stdout, os.Stderr)))
 	}
 
-	c.Execute()
-	return
+	c, found := cmd.LookupCommand(args[0])
+	if !found {
+		os.Exit(exitCode(cmd.UnknownCommandError(args[0])))
+	}
+
+	// Exit only once command has returned so that its deferred cleanups have run.
+	err = c.Execute(ctx, args[1:], os.Stdout, os.Stderr)
+	cmd.ReportTimings()
+	code := exitCode(err)
+	os.Exit(code)
+}
+
+// Exit codes of microcks-cli.
+const (
+	exitOK = 0
+	// exitFailure is used for failed tests and errors not falling in another category.
+	exitFailure = 1
+	// exitUsage is used for invalid invocations.
+	exitUsage = 2
+	// exitAuth is used when Microcks or Keycloak refuse the credentials or deny access.
+	exitAuth = 3
+	// exitServer is used when Microcks or Keycloak are unreachable, answer with an unexpected status or
+	// when Microcks does not support the command.
+	exitServer = 4
+	// exitTimeout is used when tests are still in progress once --waitFor elapsed, or when --timeout
+	// interrupts the command.
+	exitTimeout = 5
+	// exitPlugin is used when the formatter plugin of --output=exec:<plugin> fails.
+	exitPlugin = 6
+)
+
+// exitCode reports the error returned by a command and maps it to the process exit code.
+func exitCode(err error) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	cmd.ReportError(err)
+
+	var usageErr *cmd.UsageError
+	var testErr *cmd.TestFailedError
+	var timeoutErr *cmd.TestTimeoutError
+	var pluginErr *output.PluginError
+	var authErr *connectors.AuthError
+	var apiErr *connectors.APIError
+	var unsupportedErr *cmd.UnsupportedServerError
+	switch {
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.As(err, &testErr):
+		return exitFailure
+	case errors.As(err, &timeoutErr) || errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.As(err, &pluginErr):
+		return exitPlugin
+	case errors.As(err, &authErr) || errors.Is(err, connectors.ErrUnauthorized) || errors.Is(err, connectors.ErrForbidden):
+		return exitAuth
+	case errors.As(err, &apiErr) || errors.As(err, &unsupportedErr) || connectors.IsConnectionError(err):
+		return exitServer
+	default:
+		return exitFailure
+	}
 }
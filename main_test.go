@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/microcks/microcks-cli/cmd"
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+func TestExitCode(t *testing.T) {
+	apiError := func(status int) *connectors.APIError {
+		return &connectors.APIError{Name: "Microcks for getting service", Method: "GET", Path: "/api/services/Beer:0.9", StatusCode: status}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, exitOK},
+		{"help", flag.ErrHelp, exitOK},
+		{"usage", cmd.UnknownCommandError("tset"), exitUsage},
+		{"test failed", &cmd.TestFailedError{TestResultID: "1"}, exitFailure},
+		{"test timeout", &cmd.TestTimeoutError{TestResultID: "1"}, exitTimeout},
+		{"command timeout", fmt.Errorf("--timeout of 1m0s elapsed: %w", context.DeadlineExceeded), exitTimeout},
+		{"unauthorized", apiError(401), exitAuth},
+		{"forbidden", apiError(403), exitAuth},
+		{"authentication", &connectors.AuthError{Err: errors.New("invalid_client")}, exitAuth},
+		{"not found", apiError(404), exitServer},
+		{"conflict", apiError(409), exitServer},
+		{"server error", apiError(500), exitServer},
+		{"unavailable", apiError(503), exitServer},
+		{"wrapped unauthorized", fmt.Errorf("Cannot import beer.yaml: %w", fmt.Errorf("upload: %w", apiError(401))), exitAuth},
+		{"wrapped server error", fmt.Errorf("Cannot import beer.yaml: %w", apiError(502)), exitServer},
+		{"connection refused", &url.Error{Op: "Get", URL: "http://microcks/api/", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}, exitServer},
+		{"other", errors.New("cannot write report.xml"), exitFailure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := exitCode(test.err); got != test.want {
+				t.Errorf("exitCode(%v) = %d, want %d", test.err, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ArtifactsFile represents a file listing the artifacts imported by the import command, in order.
+type ArtifactsFile struct {
+	Artifacts []ArtifactSpec `yaml:"artifacts"`
+}
+
+// ArtifactSpec declares an artifact to import, either a path, that may be a directory or a glob pattern,
+// an http or https URL downloaded by Microcks, or a git+<url>//<path>@<ref> reference in a Git repository.
+type ArtifactSpec struct {
+	Path string `yaml:"path,omitempty"`
+	URL  string `yaml:"url,omitempty"`
+	// MainArtifact tells if the artifact is a primary one. Defaults to true, or to the naming conventions
+	// of secondary artifacts for the files of directories and patterns.
+	MainArtifact *bool `yaml:"mainArtifact,omitempty"`
+	// SecretName is the secret Microcks downloads http and https URL with.
+	SecretName string `yaml:"secretName,omitempty"`
+}
+
+// LoadArtifactsFile reads and parses the artifacts file at path, replacing ${NAME} references with the
+// value of environment variables. Unknown keys and undefined variables are errors.
+func LoadArtifactsFile(path string) (*ArtifactsFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, missing := expandEnvReferences(data)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("artifacts file %s references undefined environment variables: %v", path, missing)
+	}
+
+	file := &ArtifactsFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("malformed artifacts file %s: %s", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("invalid artifacts file %s: %s", path, err)
+	}
+	return file, nil
+}
+
+func (f *ArtifactsFile) validate() error {
+	if len(f.Artifacts) == 0 {
+		return fmt.Errorf("no artifacts defined")
+	}
+	for i, artifact := range f.Artifacts {
+		if (len(artifact.Path) > 0) == (len(artifact.URL) > 0) {
+			return fmt.Errorf("artifacts[%d]: one of path or url is required", i)
+		}
+		git := strings.HasPrefix(artifact.URL, "git+")
+		if len(artifact.URL) > 0 && !git && !strings.HasPrefix(artifact.URL, "http://") && !strings.HasPrefix(artifact.URL, "https://") {
+			return fmt.Errorf("artifacts[%d]: url should be an http or https URL, or a git+ reference", i)
+		}
+		if len(artifact.SecretName) > 0 && (len(artifact.URL) == 0 || git) {
+			return fmt.Errorf("artifacts[%d]: secretName is only for artifacts downloaded by Microcks from http or https url", i)
+		}
+	}
+	return nil
+}
@@ -16,6 +16,7 @@
 package config
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -23,41 +24,93 @@ import (
 	"net/http"
 	"net/http/httputil"
 	strings "strings"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
 )
 
+// The following settings are no longer read by connectors, which take a connectors.Config instead.
+// They only feed the configuration built by ConnectorsConfig and will be removed in next release.
 var (
 	// InsecureTLS defines if TLS transport should accept insecure certs.
+	//
+	// Deprecated: use TLSOptions.Insecure.
 	InsecureTLS bool = false
 	// CaCertPaths defines extra paths (comma-separated) of CRT files to add to system CA Roots.
+	//
+	// Deprecated: use TLSOptions.CaCertPaths.
 	CaCertPaths string
-	// Verbose represents a debug flag for HTTP Exchanges
+	// Verbose represents a debug flag for HTTP Exchanges.
+	//
+	// Deprecated: use trace log level or connectors.Config.Verbose.
 	Verbose bool = false
+	// MaxResponseBytes defines the maximum size of API response bodies read in memory. 0 means unbounded.
+	//
+	// Deprecated: use connectors.Config.MaxResponseBytes.
+	MaxResponseBytes int64 = DefaultMaxResponseBytes
+	// CompressUploads defines if artifact uploads should be gzip encoded.
+	//
+	// Deprecated: use connectors.Config.CompressUploads.
+	CompressUploads bool = false
+)
+
+const (
+	// DefaultMaxResponseBytes is the default cap on API response bodies (4 MB).
+	DefaultMaxResponseBytes int64 = 4 * 1024 * 1024
 )
 
-// CreateTLSConfig wraps the creation of tls.Config object for use with HTTP Client for example.
+// ConnectorsConfig builds the connectors configuration from the deprecated settings of this package,
+// the logger and the run ID.
+//
+// Deprecated: build a connectors.Config from explicit settings.
+func ConnectorsConfig() connectors.Config {
+	cfg := connectors.Config{
+		Verbose:          Verbose,
+		Logger:           Logger,
+		RequestID:        RequestID,
+		RequestIDHeader:  RequestIDHeader,
+		MaxResponseBytes: MaxResponseBytes,
+		CompressUploads:  CompressUploads,
+	}
+	if HasCustomTLSConfig() {
+		cfg.TLSConfig = CreateTLSConfig()
+	}
+	return cfg
+}
+
+// CreateTLSConfig wraps the creation of tls.Config object from the deprecated TLS settings.
+//
+// Deprecated: use TLSOptions.TLSConfig.
 func CreateTLSConfig() *tls.Config {
-	tlsConfig := &tls.Config{}
-	if InsecureTLS {
+	return globalTLSSettings().TLSConfig()
+}
+
+// TLSConfig creates the tls.Config object for use with HTTP Client for example.
+func (s TLSOptions) TLSConfig() *tls.Config {
+	tlsConfig := &tls.Config{
+		MinVersion:   s.MinVersion,
+		CipherSuites: s.CipherSuites,
+	}
+	if s.Insecure {
 		tlsConfig.InsecureSkipVerify = true
 	}
-	if len(CaCertPaths) > 0 {
+	if len(s.CaCertPaths) > 0 {
 		// Get the SystemCertPool, continue with an empty pool on error
 		rootCAs, _ := x509.SystemCertPool()
 		if rootCAs == nil {
 			rootCAs = x509.NewCertPool()
 		}
 
-		sepCaFiles := strings.Split(CaCertPaths, ",")
+		sepCaFiles := strings.Split(s.CaCertPaths, ",")
 		for _, f := range sepCaFiles {
 			// Read in the cert file
 			certs, err := ioutil.ReadFile(f)
 			if err != nil {
-				fmt.Println("Unable to read cert file from CaCertPaths: " + f)
+				Logger.Warn("Unable to read cert file from CaCertPaths: " + f)
 			}
 
 			// Append our cert to the system pool
 			if ok := rootCAs.AppendCertsFromPEM(certs); !ok {
-				fmt.Println("Unable to append cert file from CaCertPaths: " + f)
+				Logger.Warn("Unable to append cert file from CaCertPaths: " + f)
 			}
 		}
 		tlsConfig.RootCAs = rootCAs
@@ -66,28 +119,27 @@ func CreateTLSConfig() *tls.Config {
 }
 
 // DumpRequestIfRequired takes care of dumping request if configured that way
+//
+// Deprecated: connectors dump exchanges according to connectors.Config.
 func DumpRequestIfRequired(name string, req *http.Request, body bool) {
-	if Verbose {
-		fmt.Printf("\nDumping request '%s':\n", name)
+	if TraceEnabled() {
 		dump, err := httputil.DumpRequestOut(req, body)
 		if err != nil {
-			fmt.Println("Got error while dumping request out")
+			Logger.Warn("Got error while dumping request out", "error", err)
 		}
-		fmt.Printf("%s", dump)
+		Logger.Log(context.Background(), LevelTrace, fmt.Sprintf("Dumping request '%s':\n%s", name, dump))
 	}
 }
 
 // DumpResponseIfRequired takes care of dumping request if configured that way
+//
+// Deprecated: connectors dump exchanges according to connectors.Config.
 func DumpResponseIfRequired(name string, resp *http.Response, body bool) {
-	if Verbose {
-		fmt.Printf("\nDumping response '%s':\n", name)
+	if TraceEnabled() {
 		dump, err := httputil.DumpResponse(resp, body)
 		if err != nil {
-			fmt.Println("Got error while dumping response")
-		}
-		fmt.Printf("%s", dump)
-		if body {
-			fmt.Println("")
+			Logger.Warn("Got error while dumping response", "error", err)
 		}
+		Logger.Log(context.Background(), LevelTrace, fmt.Sprintf("Dumping response '%s':\n%s", name, dump))
 	}
 }
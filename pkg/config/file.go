@@ -0,0 +1,285 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ProjectFileName is the name of the project-level configuration file looked up in current directory.
+	ProjectFileName = ".microcks.yaml"
+	// ConfigEnvVar is the environment variable that may hold the path of configuration file.
+	ConfigEnvVar = "MICROCKS_CONFIG"
+)
+
+// KeycloakSettings holds the Keycloak Service Account settings.
+type KeycloakSettings struct {
+	ClientID     string `yaml:"clientId,omitempty"`
+	ClientSecret string `yaml:"clientSecret,omitempty"`
+	// ClientAssertionKey is the path of the private key signing client assertions, replacing ClientSecret.
+	ClientAssertionKey string `yaml:"clientAssertionKey,omitempty"`
+	ClientAssertionKid string `yaml:"clientAssertionKid,omitempty"`
+}
+
+// TLSSettings holds the TLS transport settings.
+type TLSSettings struct {
+	Insecure   bool   `yaml:"insecure,omitempty"`
+	CaCerts    string `yaml:"caCerts,omitempty"`
+	MinVersion string `yaml:"minVersion,omitempty"`
+	Ciphers    string `yaml:"ciphers,omitempty"`
+}
+
+// Settings holds the connection settings to a Microcks instance.
+type Settings struct {
+	MicrocksURL string           `yaml:"microcksURL,omitempty"`
+	Keycloak    KeycloakSettings `yaml:"keycloak,omitempty"`
+	TLS         TLSSettings      `yaml:"tls,omitempty"`
+}
+
+// Context holds named connection settings to a Microcks instance.
+type Context struct {
+	Name     string `yaml:"name"`
+	Settings `yaml:",inline"`
+}
+
+// File represents the content of a microcks-cli configuration file.
+type File struct {
+	Settings `yaml:",inline"`
+	// Defaults holds default values of command flags, keyed by flag name (eg. waitFor).
+	Defaults map[string]string `yaml:"defaults,omitempty"`
+	// CurrentContext is the name of the context used when none is specified.
+	CurrentContext string `yaml:"currentContext,omitempty"`
+	// Contexts holds the named connection settings.
+	Contexts []Context `yaml:"contexts,omitempty"`
+}
+
+// settingKeys maps the dotted keys of settings in configuration file to the corresponding command flags.
+var settingKeys = [][2]string{
+	{"microcksURL", "microcksURL"},
+	{"keycloak.clientId", "keycloakClientId"},
+	{"keycloak.clientSecret", "keycloakClientSecret"},
+	{"keycloak.clientAssertionKey", "clientAssertionKey"},
+	{"keycloak.clientAssertionKid", "clientAssertionKid"},
+	{"tls.insecure", "insecure"},
+	{"tls.caCerts", "caCerts"},
+	{"tls.minVersion", "tls-min-version"},
+	{"tls.ciphers", "tls-ciphers"},
+}
+
+// SettingKeys returns the dotted keys of settings (eg. tls.insecure), in file order.
+func SettingKeys() []string {
+	keys := make([]string, len(settingKeys))
+	for i, key := range settingKeys {
+		keys[i] = key[0]
+	}
+	return keys
+}
+
+// SettingFlag returns the name of the command flag corresponding to a dotted settings key.
+func SettingFlag(key string) (string, bool) {
+	for _, settingKey := range settingKeys {
+		if settingKey[0] == key {
+			return settingKey[1], true
+		}
+	}
+	return "", false
+}
+
+// SettingKey returns the dotted settings key corresponding to a command flag.
+func SettingKey(flagName string) (string, bool) {
+	for _, settingKey := range settingKeys {
+		if settingKey[1] == flagName {
+			return settingKey[0], true
+		}
+	}
+	return "", false
+}
+
+// FlagValues returns the settings as values of the corresponding command flags.
+func (s Settings) FlagValues() map[string]string {
+	values := map[string]string{}
+	put := func(name string, value string) {
+		if len(value) > 0 {
+			values[name] = value
+		}
+	}
+	put("microcksURL", s.MicrocksURL)
+	put("keycloakClientId", s.Keycloak.ClientID)
+	put("keycloakClientSecret", s.Keycloak.ClientSecret)
+	put("clientAssertionKey", s.Keycloak.ClientAssertionKey)
+	put("clientAssertionKid", s.Keycloak.ClientAssertionKid)
+	if s.TLS.Insecure {
+		put("insecure", strconv.FormatBool(s.TLS.Insecure))
+	}
+	put("caCerts", s.TLS.CaCerts)
+	put("tls-min-version", s.TLS.MinVersion)
+	put("tls-ciphers", s.TLS.Ciphers)
+	return values
+}
+
+// FlagValues returns the file content as values of the corresponding command flags. Settings
+// of the given context (or current context if empty) take precedence over top-level settings.
+func (f *File) FlagValues(contextName string) (map[string]string, error) {
+	values := f.Settings.FlagValues()
+	if len(contextName) == 0 {
+		contextName = f.CurrentContext
+	}
+	if len(contextName) > 0 {
+		context := f.Context(contextName)
+		if context == nil {
+			return nil, fmt.Errorf("context '%s' is not defined in configuration file", contextName)
+		}
+		for name, value := range context.Settings.FlagValues() {
+			values[name] = value
+		}
+	}
+	for name, value := range f.Defaults {
+		if _, ok := values[name]; !ok {
+			values[name] = value
+		}
+	}
+	return values, nil
+}
+
+// Context returns the context with given name or nil if not defined.
+func (f *File) Context(name string) *Context {
+	for i := range f.Contexts {
+		if f.Contexts[i].Name == name {
+			return &f.Contexts[i]
+		}
+	}
+	return nil
+}
+
+// SetContext adds or replaces the context having the same name.
+func (f *File) SetContext(context Context) {
+	if existing := f.Context(context.Name); existing != nil {
+		*existing = context
+		return
+	}
+	f.Contexts = append(f.Contexts, context)
+}
+
+// FindFile resolves the configuration file path: explicit path first, then MICROCKS_CONFIG env,
+// then .microcks.yaml in current directory and finally ~/.microcks/config.yaml.
+// It returns an empty string if no file is found.
+func FindFile(explicitPath string) string {
+	if len(explicitPath) > 0 {
+		return explicitPath
+	}
+	if path := os.Getenv(ConfigEnvVar); len(path) > 0 {
+		return path
+	}
+	if _, err := os.Stat(ProjectFileName); err == nil {
+		return ProjectFileName
+	}
+	if path := UserFilePath(); len(path) > 0 {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// UserFilePath returns the path of the configuration file in user home directory.
+func UserFilePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".microcks", "config.yaml")
+}
+
+// LoadFile reads and parses the configuration file at path. Unknown keys do not fail
+// loading but are returned as warnings. Malformed YAML fails with line information.
+func LoadFile(path string) (*File, []string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := &File{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(file)
+	if err == nil || errors.Is(err, io.EOF) {
+		return file, nil, nil
+	}
+
+	// Unknown fields are reported as type errors: turn them into warnings and decode leniently.
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return nil, nil, fmt.Errorf("malformed configuration file %s: %s", path, err)
+	}
+	var warnings []string
+	for _, msg := range typeErr.Errors {
+		if !strings.Contains(msg, "not found in type") {
+			return nil, nil, fmt.Errorf("malformed configuration file %s: %s", path, msg)
+		}
+		warnings = append(warnings, fmt.Sprintf("unknown key in configuration file %s: %s", path, msg))
+	}
+	file = &File{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, nil, fmt.Errorf("malformed configuration file %s: %s", path, err)
+	}
+	return file, warnings, nil
+}
+
+// SaveFile writes the configuration file at path, creating parent directories if needed.
+func SaveFile(path string, file *File) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	// File may hold secrets so keep it private.
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// SettingsFromFlagValues builds settings from the values of the corresponding command flags.
+func SettingsFromFlagValues(values map[string]string) Settings {
+	insecure, _ := strconv.ParseBool(values["insecure"])
+	return Settings{
+		MicrocksURL: values["microcksURL"],
+		Keycloak: KeycloakSettings{
+			ClientID:           values["keycloakClientId"],
+			ClientSecret:       values["keycloakClientSecret"],
+			ClientAssertionKey: values["clientAssertionKey"],
+			ClientAssertionKid: values["clientAssertionKid"],
+		},
+		TLS: TLSSettings{
+			Insecure:   insecure,
+			CaCerts:    values["caCerts"],
+			MinVersion: values["tls-min-version"],
+			Ciphers:    values["tls-ciphers"],
+		},
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// LevelTrace is the most verbose log level, used for full HTTP exchanges dumps.
+const LevelTrace = connectors.LevelTrace
+
+// Logger is the logger used by connectors and transport helpers. It defaults to warnings on stderr.
+var Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+// ParseLogLevel converts a level name (error, warn, info, debug, trace) into a slog.Level.
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "error":
+		return slog.LevelError, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "trace":
+		return LevelTrace, nil
+	}
+	return 0, fmt.Errorf("unsupported log level '%s', valid ones are: error, warn, info, debug, trace", name)
+}
+
+// LevelName returns the lower case name of level, handling the trace custom level.
+func LevelName(level slog.Level) string {
+	if level <= LevelTrace {
+		return "trace"
+	}
+	return strings.ToLower(level.String())
+}
+
+// TraceEnabled tells if full exchanges dumps should be produced.
+func TraceEnabled() bool {
+	return Verbose || Logger.Enabled(context.Background(), LevelTrace)
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"net/http"
+)
+
+const (
+	// DefaultRequestIDHeader is the header used for carrying the run ID when none is configured.
+	DefaultRequestIDHeader = "X-Request-Id"
+)
+
+var (
+	// RequestID identifies a CLI invocation and is sent along every API call.
+	RequestID string
+	// RequestIDHeader is the name of the header carrying RequestID.
+	RequestIDHeader = DefaultRequestIDHeader
+)
+
+// NewRequestID generates a run ID, a random UUID.
+func NewRequestID() string {
+	return NewUUID()
+}
+
+// SetRequestIDHeader adds the run ID header to request if a run ID has been defined.
+func SetRequestIDHeader(req *http.Request) {
+	if len(RequestID) > 0 {
+		req.Header.Set(RequestIDHeader, RequestID)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RunFile represents a file describing ordered steps executed by the run command. Top-level
+// connection settings are shared by all steps.
+type RunFile struct {
+	Settings `yaml:",inline"`
+	Steps    []RunStep `yaml:"steps"`
+}
+
+// RunStep is a single step of a run file: an import, a test or a group of steps run in parallel.
+// Connection settings of a step override the top-level ones.
+type RunStep struct {
+	Name            string      `yaml:"name,omitempty"`
+	ContinueOnError bool        `yaml:"continueOnError,omitempty"`
+	Import          *ImportStep `yaml:"import,omitempty"`
+	Test            *TestStep   `yaml:"test,omitempty"`
+	Parallel        []RunStep   `yaml:"parallel,omitempty"`
+	Settings        `yaml:",inline"`
+}
+
+// ImportStep describes API artifacts to import.
+type ImportStep struct {
+	Files []string `yaml:"files"`
+	// MainArtifact tells if files are primary artifacts. Defaults to true.
+	MainArtifact *bool `yaml:"mainArtifact,omitempty"`
+	// Labels are added to the services discovered from files.
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// TestStep describes a test to launch.
+type TestStep struct {
+	ServiceRef         string `yaml:"serviceRef"`
+	Endpoint           string `yaml:"endpoint"`
+	Runner             string `yaml:"runner"`
+	WaitFor            string `yaml:"waitFor,omitempty"`
+	SecretName         string `yaml:"secretName,omitempty"`
+	FilteredOperations string `yaml:"filteredOperations,omitempty"`
+	OperationsHeaders  string `yaml:"operationsHeaders,omitempty"`
+	OAuth2Context      string `yaml:"oAuth2Context,omitempty"`
+}
+
+// envReference matches the ${NAME} references interpolated in run files.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// LoadRunFile reads and parses the run file at path, replacing ${NAME} references with the value of
+// environment variables. Unlike configuration file, unknown keys and undefined variables are errors.
+func LoadRunFile(path string) (*RunFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, missing := expandEnvReferences(data)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("run file %s references undefined environment variables: %v", path, missing)
+	}
+
+	file := &RunFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("malformed run file %s: %s", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("invalid run file %s: %s", path, err)
+	}
+	return file, nil
+}
+
+// ExpandEnvReferences replaces the ${NAME} references of value with the value of environment variables,
+// as done for run files, returning the names of undefined ones.
+func ExpandEnvReferences(value string) (string, []string) {
+	data, missing := expandEnvReferences([]byte(value))
+	return string(data), missing
+}
+
+// expandEnvReferences replaces the ${NAME} references of data with the value of environment variables,
+// returning the names of undefined ones.
+func expandEnvReferences(data []byte) ([]byte, []string) {
+	var missing []string
+	data = envReference.ReplaceAllFunc(data, func(ref []byte) []byte {
+		name := string(envReference.FindSubmatch(ref)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+	return data, missing
+}
+
+func (f *RunFile) validate() error {
+	if len(f.Steps) == 0 {
+		return fmt.Errorf("no steps defined")
+	}
+	for i, step := range f.Steps {
+		if err := step.validate(fmt.Sprintf("steps[%d]", i), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s RunStep) validate(path string, allowParallel bool) error {
+	kinds := 0
+	if s.Import != nil {
+		kinds++
+		if len(s.Import.Files) == 0 {
+			return fmt.Errorf("%s: import requires files", path)
+		}
+	}
+	if s.Test != nil {
+		kinds++
+		if len(s.Test.ServiceRef) == 0 || len(s.Test.Endpoint) == 0 || len(s.Test.Runner) == 0 {
+			return fmt.Errorf("%s: test requires serviceRef, endpoint and runner", path)
+		}
+	}
+	if len(s.Parallel) > 0 {
+		kinds++
+		if !allowParallel {
+			return fmt.Errorf("%s: parallel groups cannot be nested", path)
+		}
+		for i, step := range s.Parallel {
+			if err := step.validate(fmt.Sprintf("%s.parallel[%d]", path, i), false); err != nil {
+				return err
+			}
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("%s: exactly one of import, test or parallel is required", path)
+	}
+	return nil
+}
+
+// Kind returns the kind of step: import, test or parallel.
+func (s RunStep) Kind() string {
+	switch {
+	case s.Import != nil:
+		return "import"
+	case s.Test != nil:
+		return "test"
+	default:
+		return "parallel"
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecretsFile represents a file declaring the whole set of Microcks secrets, reconciled by the
+// secret apply command.
+type SecretsFile struct {
+	Secrets []SecretSpec `yaml:"secrets"`
+}
+
+// SecretSpec declares a secret. Its values should be ${NAME} references to environment variables,
+// so that the file holds no credentials.
+type SecretSpec struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Username    string `yaml:"username,omitempty"`
+	Password    string `yaml:"password,omitempty"`
+	Token       string `yaml:"token,omitempty"`
+	TokenHeader string `yaml:"tokenHeader,omitempty"`
+	CaCertPem   string `yaml:"caCertPem,omitempty"`
+}
+
+// LoadSecretsFile reads and parses the secrets file at path, replacing ${NAME} references with the
+// value of environment variables. Unknown keys, undefined variables and duplicated names are errors.
+func LoadSecretsFile(path string) (*SecretsFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, missing := expandEnvReferences(data)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("secrets file %s references undefined environment variables: %v", path, missing)
+	}
+
+	file := &SecretsFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("malformed secrets file %s: %s", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %s", path, err)
+	}
+	return file, nil
+}
+
+// LoadSecretFile reads and parses the file at path declaring a single secret, replacing ${NAME}
+// references with the value of environment variables like LoadSecretsFile.
+func LoadSecretFile(path string) (*SecretSpec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, missing := expandEnvReferences(data)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("secret file %s references undefined environment variables: %v", path, missing)
+	}
+
+	secret := &SecretSpec{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(secret); err != nil {
+		return nil, fmt.Errorf("malformed secret file %s: %s", path, err)
+	}
+	if len(secret.Name) == 0 {
+		return nil, fmt.Errorf("invalid secret file %s: name is required", path)
+	}
+	return secret, nil
+}
+
+func (f *SecretsFile) validate() error {
+	names := map[string]bool{}
+	for i, secret := range f.Secrets {
+		if len(secret.Name) == 0 {
+			return fmt.Errorf("secrets[%d]: name is required", i)
+		}
+		if names[secret.Name] {
+			return fmt.Errorf("secrets[%d]: secret %s is declared twice", i, secret.Name)
+		}
+		names[secret.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestsFile represents a file listing tests of several services, launched together by the test command.
+type TestsFile struct {
+	Tests []TestStep `yaml:"tests"`
+}
+
+// LoadTestsFile reads and parses the tests file at path, replacing ${NAME} references with the value
+// of environment variables. Unknown keys and undefined variables are errors.
+func LoadTestsFile(path string) (*TestsFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, missing := expandEnvReferences(data)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("tests file %s references undefined environment variables: %v", path, missing)
+	}
+
+	file := &TestsFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("malformed tests file %s: %s", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return nil, fmt.Errorf("invalid tests file %s: %s", path, err)
+	}
+	return file, nil
+}
+
+func (f *TestsFile) validate() error {
+	if len(f.Tests) == 0 {
+		return fmt.Errorf("no tests defined")
+	}
+	for i, test := range f.Tests {
+		if len(test.ServiceRef) == 0 || len(test.Endpoint) == 0 || len(test.Runner) == 0 {
+			return fmt.Errorf("tests[%d]: serviceRef, endpoint and runner are required", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var (
+	// TLSMinVersion defines the minimum TLS version to negotiate. 0 means Go default.
+	//
+	// Deprecated: use TLSOptions.MinVersion.
+	TLSMinVersion uint16
+	// TLSCipherSuites defines the TLS 1.2 cipher suites to enable. Empty means Go default.
+	//
+	// Deprecated: use TLSOptions.CipherSuites.
+	TLSCipherSuites []uint16
+)
+
+// TLSOptions gathers the TLS options used to connect to Microcks and Keycloak.
+type TLSOptions struct {
+	// Insecure defines if TLS transport should accept insecure certs.
+	Insecure bool
+	// CaCertPaths defines extra paths (comma-separated) of CRT files to add to system CA Roots.
+	CaCertPaths string
+	// MinVersion defines the minimum TLS version to negotiate. 0 means Go default.
+	MinVersion uint16
+	// CipherSuites defines the TLS 1.2 cipher suites to enable. Empty means Go default.
+	CipherSuites []uint16
+}
+
+// IsCustom tells if some TLS settings differ from Go defaults.
+func (s TLSOptions) IsCustom() bool {
+	return s.Insecure || len(s.CaCertPaths) > 0 || s.MinVersion != 0 || len(s.CipherSuites) > 0
+}
+
+// HasCustomTLSConfig tells if some of the deprecated TLS settings differ from Go defaults.
+//
+// Deprecated: use TLSOptions.IsCustom.
+func HasCustomTLSConfig() bool {
+	return globalTLSSettings().IsCustom()
+}
+
+func globalTLSSettings() TLSOptions {
+	return TLSOptions{Insecure: InsecureTLS, CaCertPaths: CaCertPaths, MinVersion: TLSMinVersion, CipherSuites: TLSCipherSuites}
+}
+
+// ParseTLSVersion converts a version string like "1.2" or "1.3" into its tls constant.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version '%s', valid ones are: 1.2, 1.3", version)
+}
+
+// ParseCipherSuites converts a comma separated list of IANA cipher suite names into their ids.
+func ParseCipherSuites(names string) ([]uint16, error) {
+	available := map[string]uint16{}
+	var validNames []string
+	for _, suite := range tls.CipherSuites() {
+		// TLS 1.3 suites are not configurable so only keep those applicable to TLS 1.2.
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				available[suite.Name] = suite.ID
+				validNames = append(validNames, suite.Name)
+				break
+			}
+		}
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite '%s', valid ones are: %s", name, strings.Join(validNames, ", "))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
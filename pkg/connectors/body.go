@@ -0,0 +1,74 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	// maxDrainBytes is the amount of unread body we accept to consume so that connection can be reused.
+	maxDrainBytes = 64 * 1024
+	// previewBytes is the number of body bytes reported when response is too large.
+	previewBytes = 256
+)
+
+// readBody reads a bounded response body, raising an error naming the endpoint if too large. Bodies
+// of streamed requests are not bounded by transport, so it never reads more than the limit itself.
+func (cfg *Config) readBody(name string, resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	if cfg.MaxResponseBytes > 0 {
+		r = io.LimitReader(r, cfg.MaxResponseBytes+1)
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxResponseBytes > 0 && int64(len(body)) > cfg.MaxResponseBytes {
+		preview := body
+		if len(preview) > previewBytes {
+			preview = preview[:previewBytes]
+		}
+		return nil, fmt.Errorf("response from %s (%s %s) exceeds the %d bytes limit, body starts with: %q",
+			name, resp.Request.Method, resp.Request.URL.Path, cfg.MaxResponseBytes, preview)
+	}
+	return body, nil
+}
+
+// ReadResponse reads the bounded body of resp named name in errors, then closes it. An APIError is
+// returned if resp status is not expected. It allows connectors living in other packages to handle
+// responses as MicrocksClient does.
+func ReadResponse(cfg Config, name string, resp *http.Response, expected int) ([]byte, error) {
+	defer drainAndClose(resp.Body)
+	body, err := cfg.readBody(name, resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expected {
+		return nil, cfg.newAPIError(name, resp, body)
+	}
+	return body, nil
+}
+
+// drainAndClose consumes a reasonable amount of what's left in body and closes it,
+// allowing the underlying connection to be reused.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/transport"
+)
+
+// countingBody counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(b.n, int64(n))
+	return n, err
+}
+
+func TestResponseSizeLimit(t *testing.T) {
+	const limit = 1024 * 1024
+	// htmlPage is an error page of a misbehaving proxy, far larger than the limit.
+	htmlPage := "<html><body>" + strings.Repeat("Bad gateway ", 4*limit/12) + "</body></html>"
+	service := func(size int) string {
+		return `{"id":"1","name":"Beer","version":"0.9","metadata":{"annotations":{"notes":"` + strings.Repeat("x", size) + `"}}}`
+	}
+	snapshot := `{"services":[],"notes":"` + strings.Repeat("x", 4*limit) + `"}`
+	getService := func(ctx context.Context, mc MicrocksClient) error {
+		_, err := mc.GetServiceByRef(ctx, "Beer", "0.9")
+		return err
+	}
+	tests := []struct {
+		name     string
+		limit    int64
+		status   int
+		body     string
+		call     func(ctx context.Context, mc MicrocksClient) error
+		wantErr  string
+		wantRead int64
+	}{
+		{
+			name:   "JSON within limit",
+			limit:  limit,
+			status: http.StatusOK,
+			body:   service(limit - 100),
+			call:   getService,
+		},
+		{
+			name:     "JSON over limit",
+			limit:    limit,
+			status:   http.StatusOK,
+			body:     htmlPage,
+			call:     getService,
+			wantErr:  `response from Microcks for getting service (GET /api/services/Beer:0.9) exceeds the 1048576 bytes limit, body starts with: "<html><body>Bad gateway Bad gateway `,
+			wantRead: limit + 1 + maxDrainBytes,
+		},
+		{
+			name:   "test result over limit",
+			limit:  limit,
+			status: http.StatusOK,
+			body:   htmlPage,
+			call: func(ctx context.Context, mc MicrocksClient) error {
+				_, err := mc.GetTestResult(ctx, "test-1")
+				return err
+			},
+			wantErr:  "response from Microcks for getting status test (GET /api/tests/test-1) exceeds the 1048576 bytes limit",
+			wantRead: limit + 1 + maxDrainBytes,
+		},
+		{
+			name:     "error over limit",
+			limit:    limit,
+			status:   http.StatusBadGateway,
+			body:     htmlPage,
+			call:     getService,
+			wantErr:  "exceeds the 1048576 bytes limit",
+			wantRead: limit + 1 + maxDrainBytes,
+		},
+		{
+			name:   "unbounded",
+			status: http.StatusOK,
+			body:   service(4 * limit),
+			call:   getService,
+		},
+		{
+			name:   "export streamed",
+			limit:  limit,
+			status: http.StatusOK,
+			body:   snapshot,
+			call: func(ctx context.Context, mc MicrocksClient) error {
+				n, err := mc.ExportServices(ctx, []string{"1"}, io.Discard)
+				if err == nil && n != int64(len(snapshot)) {
+					t.Errorf("ExportServices() = %d bytes, want %d", n, len(snapshot))
+				}
+				return err
+			},
+		},
+		{
+			name:   "export error over limit",
+			limit:  limit,
+			status: http.StatusBadGateway,
+			body:   htmlPage,
+			call: func(ctx context.Context, mc MicrocksClient) error {
+				_, err := mc.ExportServices(ctx, []string{"1"}, io.Discard)
+				return err
+			},
+			wantErr:  "response from Microcks for exporting services (GET /api/export) exceeds the 1048576 bytes limit",
+			wantRead: limit + 1 + maxDrainBytes,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.status)
+				io.WriteString(w, test.body)
+			}))
+			defer srv.Close()
+			var read int64
+			counting := transport.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				if err == nil {
+					resp.Body = countingBody{resp.Body, &read}
+				}
+				return resp, err
+			})
+
+			mc := NewMicrocksClient(srv.URL, WithTransport(counting), WithConfig(Config{MaxResponseBytes: test.limit}))
+			err := test.call(context.Background(), mc)
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("error = %v, want %q", err, test.wantErr)
+			}
+			// The preview holds the first bytes of the body only.
+			if len(err.Error()) > len(test.wantErr)+2*previewBytes {
+				t.Errorf("error of %d bytes holds more than the start of the body", len(err.Error()))
+			}
+			if read > test.wantRead {
+				t.Errorf("read %d bytes of the body, want at most %d", read, test.wantRead)
+			}
+		})
+	}
+}
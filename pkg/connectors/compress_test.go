@@ -0,0 +1,85 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestCompressedUploads(t *testing.T) {
+	// bundle is a highly compressible OpenAPI bundle of several MB.
+	bundle := "openapi: 3.0.0\npaths:\n" + strings.Repeat("  /beer/{name}:\n    get:\n      responses:\n        '200':\n          description: A beer\n", 60000)
+	tests := []struct {
+		name           string
+		compress       bool
+		serverOpts     []microckstest.Option
+		reader         func() io.Reader
+		wantCompressed bool
+		wantSizes      bool
+	}{
+		{name: "compressed", compress: true, reader: func() io.Reader { return strings.NewReader(bundle) }, wantCompressed: true, wantSizes: true},
+		{name: "compressed unsized", compress: true, reader: func() io.Reader { return io.MultiReader(strings.NewReader(bundle)) }, wantCompressed: true, wantSizes: true},
+		{name: "not required", reader: func() io.Reader { return strings.NewReader(bundle) }},
+		{name: "unsupported", compress: true, serverOpts: []microckstest.Option{microckstest.WithoutGzipUploads()}, reader: func() io.Reader { return strings.NewReader(bundle) }},
+		{name: "unsupported unsized", compress: true, serverOpts: []microckstest.Option{microckstest.WithoutGzipUploads()}, reader: func() io.Reader { return io.MultiReader(strings.NewReader(bundle)) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := microckstest.NewServer(test.serverOpts...)
+			defer srv.Close()
+			srv.AddArtifact("beer.yaml", connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			mc := connectors.NewMicrocksClient(srv.URL, connectors.WithConfig(connectors.Config{CompressUploads: test.compress, Logger: logger}))
+
+			if _, err := mc.UploadArtifactContent(context.Background(), test.reader(), "beer.yaml", true); err != nil {
+				t.Fatalf("UploadArtifactContent() error = %v", err)
+			}
+			uploads := srv.Uploads()
+			if len(uploads) != 1 {
+				t.Fatalf("got %d uploads, want 1", len(uploads))
+			}
+			if uploads[0].Compressed != test.wantCompressed {
+				t.Errorf("upload compressed = %v, want %v", uploads[0].Compressed, test.wantCompressed)
+			}
+			if string(uploads[0].Content) != bundle {
+				t.Errorf("uploaded %d bytes differing from the %d bytes of the artifact", len(uploads[0].Content), len(bundle))
+			}
+			sizes := regexp.MustCompile(`rawSize=(\d+) compressedSize=(\d+)`).FindStringSubmatch(logs.String())
+			if (sizes != nil) != test.wantSizes {
+				t.Fatalf("logged sizes = %q, want %v, logs:\n%s", sizes, test.wantSizes, logs.String())
+			}
+			if sizes != nil {
+				raw, _ := strconv.Atoi(sizes[1])
+				compressed, _ := strconv.Atoi(sizes[2])
+				// Multipart envelope is compressed along with the artifact.
+				if raw < len(bundle) || compressed == 0 || compressed > raw/10 {
+					t.Errorf("logged raw size %d and compressed size %d for an artifact of %d bytes", raw, compressed, len(bundle))
+				}
+			}
+		})
+	}
+}
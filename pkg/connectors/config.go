@@ -0,0 +1,143 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/microcks/microcks-cli/pkg/transport"
+	"github.com/microcks/microcks-cli/version"
+)
+
+// LevelTrace is the log level of HTTP exchanges dumps, below slog.LevelDebug.
+const LevelTrace = transport.LevelTrace
+
+// RequestTiming holds the duration of the phases of an API request.
+type RequestTiming = transport.RequestTiming
+
+// RateLimiter paces the requests sent by clients.
+type RateLimiter = transport.RateLimiter
+
+// RateLimiterFunc adapts a function to the RateLimiter interface.
+type RateLimiterFunc = transport.RateLimiterFunc
+
+// CacheStore keeps cached GET responses, see transport.NewMemoryCache and transport.NewDiskCache.
+type CacheStore = transport.CacheStore
+
+// Config holds the settings of Microcks and Keycloak clients. Its zero value uses Go defaults,
+// logs warnings on stderr and sets no limit.
+type Config struct {
+	// TLSConfig is used for HTTPS connections. Nil means Go defaults.
+	TLSConfig *tls.Config
+	// Verbose asks for dumps of HTTP exchanges whatever the level of Logger.
+	Verbose bool
+	// Logger receives API exchanges logs and dumps, retries notices and client warnings. Nil means
+	// warnings on stderr. Clients never write to stdout.
+	Logger *slog.Logger
+	// RequestID is sent with every request using the RequestIDHeader header, unless empty.
+	RequestID       string
+	RequestIDHeader string
+	// MaxResponseBytes defines the maximum size of response bodies read in memory. 0 means unbounded.
+	MaxResponseBytes int64
+	// CompressUploads defines if artifact uploads should be gzip encoded.
+	CompressUploads bool
+	// RateLimiter paces requests. Nil means unlimited.
+	RateLimiter RateLimiter
+	// Retry defines how idempotent requests failing with transient errors are retried. Zero disables retries.
+	Retry transport.RetryPolicy
+	// OnTiming receives the timing of every completed request. Nil disables requests tracing.
+	OnTiming func(timing RequestTiming)
+	// Cache stores GET responses, revalidated using their ETag or reused while fresh according to
+	// their Cache-Control. Nil disables caching.
+	Cache CacheStore
+	// CacheIdentity identifies the credentials of requests in cache keys so that clients authenticated
+	// differently never share entries. Empty means the Authorization header of requests, making entries
+	// unusable once tokens are renewed.
+	CacheIdentity string
+	// Cassette records the API interactions of clients or, when loaded with transport.LoadCassette,
+	// replays them without sending any request. Nil disables recording.
+	Cassette *transport.Cassette
+}
+
+// defaultLogger is used when no logger is configured. It writes on stderr so that the stdout of
+// embedding applications is left untouched.
+var defaultLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+func (cfg *Config) logger() *slog.Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
+	}
+	return defaultLogger
+}
+
+// NewHTTPClient builds an HTTP client sending requests through the middlewares implementing cfg,
+// with the User-Agent of the CLI. It allows connectors living in other packages, like keycloak, to
+// behave as MicrocksClient does. client may be nil and is copied, never modified.
+func NewHTTPClient(cfg Config, client *http.Client) *http.Client {
+	return cfg.newHTTPClient(client, nil, version.UserAgent())
+}
+
+// newHTTPClient builds the HTTP client sending requests through the middlewares implementing cfg.
+// Requests go to base if not nil, then to the transport of client if any, then to a transport
+// honoring TLS configuration. client is copied, never modified.
+func (cfg *Config) newHTTPClient(client *http.Client, base http.RoundTripper, userAgent string) *http.Client {
+	httpClient := &http.Client{}
+	if client != nil {
+		*httpClient = *client
+	}
+	if base == nil {
+		base = httpClient.Transport
+	}
+	if base == nil && cfg.TLSConfig != nil {
+		base = &http.Transport{TLSClientConfig: cfg.TLSConfig}
+	}
+	httpClient.Transport = transport.Chain(base, cfg.middlewares(userAgent)...)
+	return httpClient
+}
+
+// middlewares returns the middlewares implementing cfg, from the outermost to the innermost.
+func (cfg *Config) middlewares(userAgent string) []transport.Middleware {
+	logger := cfg.logger()
+	headers := map[string]string{"User-Agent": userAgent}
+	if len(cfg.RequestIDHeader) > 0 {
+		headers[cfg.RequestIDHeader] = cfg.RequestID
+	}
+	middlewares := []transport.Middleware{
+		transport.Headers(headers),
+	}
+	if cfg.Cache != nil {
+		middlewares = append(middlewares, transport.Cache(cfg.Cache, cfg.CacheIdentity, logger))
+	}
+	middlewares = append(middlewares, transport.Retry(cfg.Retry, logger))
+	if cfg.OnTiming != nil {
+		middlewares = append(middlewares, transport.Timing(logger, cfg.OnTiming))
+	}
+	middlewares = append(middlewares, transport.Dump(logger, cfg.Verbose))
+	if cfg.RateLimiter != nil {
+		middlewares = append(middlewares, transport.RateLimit(cfg.RateLimiter))
+	}
+	middlewares = append(middlewares, transport.Log(logger))
+	if cfg.MaxResponseBytes > 0 {
+		middlewares = append(middlewares, transport.LimitBody(cfg.MaxResponseBytes))
+	}
+	if cfg.Cassette != nil {
+		middlewares = append(middlewares, transport.Record(cfg.Cassette))
+	}
+	return middlewares
+}
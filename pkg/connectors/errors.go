@@ -0,0 +1,125 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// Sentinel errors matched by APIError with errors.Is, depending on its status code.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+)
+
+// APIError is returned when Microcks or Keycloak answers with an unexpected HTTP status.
+type APIError struct {
+	// Name describes the called endpoint (eg. "Microcks for creating test").
+	Name       string
+	Method     string
+	Path       string
+	StatusCode int
+	Body       string
+	// Message is the error message of body when it is a JSON error object, empty otherwise.
+	Message string
+	// RequestID is the run ID sent with the failed request.
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	detail := e.Body
+	if len(e.Message) > 0 {
+		detail = e.Message
+	}
+	// Error pages of proxies may be huge, only their start is worth logging.
+	if len(detail) > previewBytes {
+		cut := previewBytes
+		for cut > 0 && !utf8.RuneStart(detail[cut]) {
+			cut--
+		}
+		detail = fmt.Sprintf("%s…(%d bytes)", detail[:cut], len(detail))
+	}
+	if len(detail) == 0 {
+		return fmt.Sprintf("unexpected status %d from %s (%s %s)", e.StatusCode, e.Name, e.Method, e.Path)
+	}
+	return fmt.Sprintf("unexpected status %d from %s (%s %s): %s", e.StatusCode, e.Name, e.Method, e.Path, detail)
+}
+
+// Is allows to match e against ErrUnauthorized, ErrForbidden, ErrNotFound and ErrConflict.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	}
+	return false
+}
+
+func (cfg *Config) newAPIError(name string, resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Name:       name,
+		Method:     resp.Request.Method,
+		Path:       resp.Request.URL.Path,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Message:    errorMessage(body),
+		RequestID:  cfg.RequestID,
+	}
+}
+
+// errorMessage extracts the message of a JSON error body, as returned by Microcks (Spring Boot) or
+// Keycloak (OAuth2 errors).
+func errorMessage(body []byte) string {
+	var obj map[string]interface{}
+	if json.Unmarshal(body, &obj) != nil {
+		return ""
+	}
+	for _, key := range []string{"message", "error_description", "detail", "error"} {
+		if value, ok := obj[key].(string); ok && len(value) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+// decodeError wraps the error decoding the body of a successful response of the endpoint name.
+func decodeError(name string, err error) error {
+	return fmt.Errorf("cannot decode response of %s: %w", name, err)
+}
+
+// AuthError is returned when authentication against Keycloak fails.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return "authentication failed: " + e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
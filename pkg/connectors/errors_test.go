@@ -0,0 +1,76 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorMessage(t *testing.T) {
+	const prefix = "unexpected status 502 from Microcks for getting service (GET /api/services/Beer:0.9): "
+	htmlPage := "<html><body>" + strings.Repeat("Bad gateway ", 100000) + "</body></html>"
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "JSON message",
+			body: `{"timestamp": "2024-01-01T00:00:00Z", "status": 502, "message": "Upstream is down"}`,
+			want: prefix + "Upstream is down",
+		},
+		{
+			name: "plain text",
+			body: "Upstream is down",
+			want: prefix + "Upstream is down",
+		},
+		{
+			name: "oversized page",
+			body: htmlPage,
+			want: prefix + htmlPage[:previewBytes] + "…(1200026 bytes)",
+		},
+		{
+			name: "oversized JSON message",
+			body: `{"message": "` + strings.Repeat("x", 1000) + `"}`,
+			want: prefix + strings.Repeat("x", previewBytes) + "…(1000 bytes)",
+		},
+		{
+			name: "cut in a character",
+			body: strings.Repeat("x", previewBytes-1) + "é" + strings.Repeat("x", 100),
+			want: prefix + strings.Repeat("x", previewBytes-1) + "…(357 bytes)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				io.WriteString(w, test.body)
+			}))
+			defer srv.Close()
+
+			mc := NewMicrocksClient(srv.URL, WithConfig(Config{MaxResponseBytes: 4 * 1024 * 1024}))
+			_, err := mc.GetServiceByRef(context.Background(), "Beer", "0.9")
+			if err == nil || err.Error() != test.want {
+				t.Errorf("error = %v, want %s", err, test.want)
+			}
+		})
+	}
+}
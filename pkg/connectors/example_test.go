@@ -0,0 +1,48 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func ExampleNewMicrocksClient() {
+	srv := microckstest.NewServer(microckstest.WithToken("token"))
+	defer srv.Close()
+	srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+
+	ctx := context.Background()
+	mc := connectors.NewMicrocksClient(srv.URL+"/",
+		connectors.WithConfig(connectors.Config{MaxResponseBytes: 4 * 1024 * 1024}),
+		connectors.WithHTTPClient(&http.Client{Timeout: 30 * time.Second}),
+		connectors.WithAuthProvider(connectors.StaticToken("token")),
+		connectors.WithUserAgent("my-tool/1.0"))
+	page, err := mc.ListServices(ctx, connectors.ListOptions{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, service := range page.Items {
+		fmt.Println(service.Name, service.Version)
+	}
+	// Output: Beer Catalog API 0.9
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package keycloak
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+)
+
+// clientAssertionType is the client_assertion_type of signed JWT assertions (RFC 7523).
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// clientAssertionLifetime is how long signed client assertions are valid. Each token request
+// signs a new one.
+const clientAssertionLifetime = time.Minute
+
+// ClientAssertion authenticates clients with JWT assertions signed by their private key, the
+// private_key_jwt method of OpenID Connect, instead of a client secret.
+type ClientAssertion struct {
+	// Key is the RSA or ECDSA private key signing assertions.
+	Key crypto.Signer
+	// KeyID is the kid header of assertions, telling Keycloak which key of the client to check
+	// them with. It may be empty when the client has a single key.
+	KeyID string
+}
+
+// WithClientAssertion makes ClientCredentials providers authenticate using JWT assertions
+// signed with assertion key instead of the client secret.
+func WithClientAssertion(assertion ClientAssertion) Option {
+	return func(o *options) {
+		o.assertion = &assertion
+	}
+}
+
+// sign builds the JWT assertion of clientID for the audience token endpoint, issued at now.
+func (a *ClientAssertion) sign(clientID string, audience string, now time.Time) (string, error) {
+	alg, hash, err := signingAlgorithm(a.Key)
+	if err != nil {
+		return "", err
+	}
+	// Keycloak refuses assertions whose jti was already used.
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if len(a.KeyID) > 0 {
+		header["kid"] = a.KeyID
+	}
+	claims := map[string]interface{}{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": audience,
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"exp": now.Add(clientAssertionLifetime).Unix(),
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	signature, err := a.Key.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return "", fmt.Errorf("cannot sign client assertion: %w", err)
+	}
+	if key, ok := a.Key.Public().(*ecdsa.PublicKey); ok {
+		// JWS wants the fixed size r || s concatenation instead of the ASN.1 signature.
+		if signature, err = jwsECDSASignature(signature, key.Curve); err != nil {
+			return "", err
+		}
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// authenticate adds the client assertion parameters to the form of a token request.
+func (a *ClientAssertion) authenticate(form url.Values, clientID string, audience string, now time.Time) error {
+	assertion, err := a.sign(clientID, audience, now)
+	if err != nil {
+		return err
+	}
+	form.Set("client_id", clientID)
+	form.Set("client_assertion_type", clientAssertionType)
+	form.Set("client_assertion", assertion)
+	return nil
+}
+
+// signingAlgorithm returns the JWS algorithm and hash of key: RS256 for RSA keys, ES256, ES384
+// or ES512 depending on the curve of ECDSA keys.
+func signingAlgorithm(key crypto.Signer) (string, crypto.Hash, error) {
+	switch public := key.Public().(type) {
+	case *rsa.PublicKey:
+		return "RS256", crypto.SHA256, nil
+	case *ecdsa.PublicKey:
+		switch public.Curve {
+		case elliptic.P256():
+			return "ES256", crypto.SHA256, nil
+		case elliptic.P384():
+			return "ES384", crypto.SHA384, nil
+		case elliptic.P521():
+			return "ES512", crypto.SHA512, nil
+		}
+		return "", 0, fmt.Errorf("unsupported curve %s for client assertion key", public.Curve.Params().Name)
+	}
+	return "", 0, fmt.Errorf("unsupported client assertion key type %T, only RSA and ECDSA keys are", key)
+}
+
+// jwsECDSASignature converts an ASN.1 ECDSA signature to the JWS format.
+func jwsECDSASignature(der []byte, curve elliptic.Curve) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("cannot decode client assertion signature: %w", err)
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package keycloak
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientAssertion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p521Key, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	tests := []struct {
+		name    string
+		key     crypto.Signer
+		keyID   string
+		wantAlg string
+		hash    crypto.Hash
+	}{
+		{name: "RSA", key: rsaKey, keyID: "rsa-1", wantAlg: "RS256", hash: crypto.SHA256},
+		{name: "P-256", key: p256Key, wantAlg: "ES256", hash: crypto.SHA256},
+		{name: "P-384", key: p384Key, wantAlg: "ES384", hash: crypto.SHA384},
+		{name: "P-521", key: p521Key, keyID: "ec-3", wantAlg: "ES512", hash: crypto.SHA512},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var form url.Values
+			var authorization string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				form, authorization = r.PostForm, r.Header.Get("Authorization")
+				json.NewEncoder(w).Encode(tokenResponse{AccessToken: "token", ExpiresIn: 300})
+			}))
+			defer srv.Close()
+
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			p := NewClientCredentials(srv.URL+"/realms/microcks", "microcks-serviceaccount", "",
+				WithClientAssertion(ClientAssertion{Key: test.key, KeyID: test.keyID}), WithClock(&fakeClock{now: now}))
+			if _, err := p.Token(context.Background()); err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+			if len(authorization) > 0 {
+				t.Errorf("client secret sent along with assertion: %s", authorization)
+			}
+			if form.Get("client_assertion_type") != clientAssertionType || form.Get("client_id") != "microcks-serviceaccount" {
+				t.Errorf("token request form = %v", form)
+			}
+
+			parts := strings.Split(form.Get("client_assertion"), ".")
+			if len(parts) != 3 {
+				t.Fatalf("assertion %q is not a JWS", form.Get("client_assertion"))
+			}
+			var header map[string]string
+			var claims map[string]interface{}
+			for i, v := range []interface{}{&header, &claims} {
+				content, err := base64.RawURLEncoding.DecodeString(parts[i])
+				if err != nil {
+					t.Fatalf("cannot decode assertion part %d: %v", i, err)
+				}
+				if err := json.Unmarshal(content, v); err != nil {
+					t.Fatalf("cannot decode assertion part %s: %v", content, err)
+				}
+			}
+			if header["alg"] != test.wantAlg || header["typ"] != "JWT" || header["kid"] != test.keyID {
+				t.Errorf("assertion header = %v", header)
+			}
+			tokenURL := srv.URL + "/realms/microcks/protocol/openid-connect/token"
+			if claims["iss"] != "microcks-serviceaccount" || claims["sub"] != "microcks-serviceaccount" || claims["aud"] != tokenURL {
+				t.Errorf("assertion claims = %v", claims)
+			}
+			if claims["iat"] != float64(now.Unix()) || claims["exp"] != float64(now.Add(time.Minute).Unix()) {
+				t.Errorf("assertion lifetime iat = %v, exp = %v", claims["iat"], claims["exp"])
+			}
+			if jti, _ := claims["jti"].(string); len(jti) < 16 {
+				t.Errorf("assertion jti = %v", claims["jti"])
+			}
+
+			signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+			if err != nil {
+				t.Fatalf("cannot decode signature: %v", err)
+			}
+			h := test.hash.New()
+			h.Write([]byte(parts[0] + "." + parts[1]))
+			digest := h.Sum(nil)
+			switch public := test.key.Public().(type) {
+			case *rsa.PublicKey:
+				if err := rsa.VerifyPKCS1v15(public, test.hash, digest, signature); err != nil {
+					t.Errorf("invalid RSA signature: %v", err)
+				}
+			case *ecdsa.PublicKey:
+				size := (public.Curve.Params().BitSize + 7) / 8
+				if len(signature) != 2*size {
+					t.Fatalf("ECDSA signature has %d bytes, want %d", len(signature), 2*size)
+				}
+				r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+				if !ecdsa.Verify(public, digest, r, s) {
+					t.Errorf("invalid ECDSA signature")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package keycloak
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/transport"
+)
+
+// TokenProvider provides OAuth tokens issued by Keycloak. Providers are connectors.AuthProvider
+// and can be given to MicrocksClient using connectors.WithAuthProvider.
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+var _ connectors.AuthProvider = TokenProvider(nil)
+
+// ClientCredentials is a TokenProvider using the OAuth client credentials grant of a service
+// account, authenticated by its client secret or, with WithClientAssertion, by signed JWT
+// assertions. Tokens are cached until they're about to expire, then refreshed using the refresh
+// token if Keycloak issued one, or requested again. It is safe for concurrent use.
+type ClientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	opts         options
+	httpClient   *http.Client
+
+	mu    sync.Mutex
+	token *cachedToken
+}
+
+type cachedToken struct {
+	accessToken   string
+	expiry        time.Time
+	refreshToken  string
+	refreshExpiry time.Time
+}
+
+// tokenResponse is the successful response of Keycloak token endpoint.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+// NewClientCredentials builds a ClientCredentials provider for the clientID service account of the
+// realm at realmURL, as returned by Config.RealmURL.
+func NewClientCredentials(realmURL string, clientID string, clientSecret string, opts ...Option) *ClientCredentials {
+	if !strings.HasSuffix(realmURL, "/") {
+		realmURL += "/"
+	}
+	p := &ClientCredentials{
+		tokenURL:     realmURL + "protocol/openid-connect/token",
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		opts:         newOptions(opts),
+	}
+	p.httpClient = connectors.NewHTTPClient(p.opts.cfg, p.opts.httpClient)
+	return p
+}
+
+// Token implements TokenProvider, returning the cached token if still valid. Errors answered by
+// Keycloak are returned as connectors.AuthError wrapping a connectors.APIError whose Message is
+// the error description of Keycloak. Connection errors are returned as is.
+func (p *ClientCredentials) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	if p.token != nil && now.Before(p.token.expiry) {
+		return p.token.accessToken, nil
+	}
+	if p.token != nil && len(p.token.refreshToken) > 0 && now.Before(p.token.refreshExpiry) {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {p.token.refreshToken}}
+		token, err := p.requestToken(ctx, "Keycloak for refreshing token", form)
+		if err == nil {
+			p.token = token
+			return token.accessToken, nil
+		}
+		if connectors.IsConnectionError(err) || ctx.Err() != nil {
+			return "", err
+		}
+		// Refresh token was refused (revoked, session ended...), start over with credentials.
+	}
+
+	p.token = nil
+	token, err := p.requestToken(ctx, "Keycloak for getting token", url.Values{"grant_type": {"client_credentials"}})
+	if err != nil {
+		return "", err
+	}
+	p.token = token
+	return token.accessToken, nil
+}
+
+func (p *ClientCredentials) now() time.Time {
+	if p.opts.clock != nil {
+		return p.opts.clock.Now()
+	}
+	return time.Now()
+}
+
+// requestToken posts form to the token endpoint and computes the expiry of the issued tokens.
+func (p *ClientCredentials) requestToken(ctx context.Context, name string, form url.Values) (*cachedToken, error) {
+	if p.opts.assertion != nil {
+		if err := p.opts.assertion.authenticate(form, p.clientID, p.tokenURL, p.now()); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.opts.assertion == nil {
+		credential := base64.StdEncoding.EncodeToString([]byte(p.clientID + ":" + p.clientSecret))
+		req.Header.Set("Authorization", "Basic "+credential)
+	}
+
+	// Lifetimes are counted from the sending of the request, before Keycloak issued the tokens.
+	sent := p.now()
+	// Name request for logs and dumps.
+	req = transport.Describe(req, name, false)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		// Keep connection errors as is, they're not authentication failures.
+		return nil, err
+	}
+	body, err := connectors.ReadResponse(p.opts.cfg, name, resp, http.StatusOK)
+	if err != nil {
+		if _, ok := err.(*connectors.APIError); ok {
+			return nil, &connectors.AuthError{Err: err}
+		}
+		return nil, err
+	}
+
+	var tokenResp tokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, &connectors.AuthError{Err: err}
+	}
+	if len(tokenResp.AccessToken) == 0 {
+		return nil, &connectors.AuthError{Err: fmt.Errorf("no access_token in Keycloak response: %s", string(body))}
+	}
+
+	token := &cachedToken{
+		accessToken: tokenResp.AccessToken,
+		expiry:      p.expiry(sent, tokenResp.ExpiresIn),
+	}
+	if tokenResp.RefreshExpiresIn > 0 {
+		token.refreshToken = tokenResp.RefreshToken
+		token.refreshExpiry = p.expiry(sent, tokenResp.RefreshExpiresIn)
+	}
+	return token, nil
+}
+
+// expiry returns the time a token issued at sent for lifetime seconds must be renewed, taking the
+// expiry margin into account. Tokens without lifetime are not cached.
+func (p *ClientCredentials) expiry(sent time.Time, lifetime int64) time.Time {
+	if lifetime <= 0 {
+		return sent
+	}
+	d := time.Duration(lifetime) * time.Second
+	margin := p.opts.expiryMargin
+	if margin > d/2 {
+		margin = d / 2
+	}
+	return sent.Add(d - margin)
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+// fakeClock is a connectors.Clock whose time only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClientCredentialsCaching(t *testing.T) {
+	tests := []struct {
+		name             string
+		expiresIn        int64
+		refreshExpiresIn int64
+		refuseRefresh    bool
+		latency          time.Duration
+		margin           time.Duration
+		calls            []time.Duration
+		want             []string
+	}{
+		{
+			name:      "cached until expiry margin",
+			expiresIn: 300,
+			calls:     []time.Duration{0, time.Minute, 269 * time.Second},
+			want:      []string{"client_credentials"},
+		},
+		{
+			name:      "renewed within expiry margin",
+			expiresIn: 300,
+			calls:     []time.Duration{0, 271 * time.Second},
+			want:      []string{"client_credentials", "client_credentials"},
+		},
+		{
+			name:      "custom expiry margin",
+			expiresIn: 300,
+			margin:    2 * time.Minute,
+			calls:     []time.Duration{0, 179 * time.Second, 181 * time.Second},
+			want:      []string{"client_credentials", "client_credentials"},
+		},
+		{
+			name:      "margin capped to half of short lifetimes",
+			expiresIn: 40,
+			calls:     []time.Duration{0, 19 * time.Second, 21 * time.Second},
+			want:      []string{"client_credentials", "client_credentials"},
+		},
+		{
+			name:      "lifetime counted from request sending",
+			expiresIn: 300,
+			latency:   20 * time.Second,
+			calls:     []time.Duration{0, 271 * time.Second},
+			want:      []string{"client_credentials", "client_credentials"},
+		},
+		{
+			name:  "not cached without lifetime",
+			calls: []time.Duration{0, 0},
+			want:  []string{"client_credentials", "client_credentials"},
+		},
+		{
+			name:             "refreshed with refresh token",
+			expiresIn:        300,
+			refreshExpiresIn: 1800,
+			calls:            []time.Duration{0, 271 * time.Second},
+			want:             []string{"client_credentials", "refresh_token"},
+		},
+		{
+			name:             "refresh token expired",
+			expiresIn:        300,
+			refreshExpiresIn: 300,
+			calls:            []time.Duration{0, 271 * time.Second},
+			want:             []string{"client_credentials", "client_credentials"},
+		},
+		{
+			name:             "refresh refused",
+			expiresIn:        300,
+			refreshExpiresIn: 1800,
+			refuseRefresh:    true,
+			calls:            []time.Duration{0, 271 * time.Second},
+			want:             []string{"client_credentials", "refresh_token", "client_credentials"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			start := clock.Now()
+			var grants []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				grant := r.PostForm.Get("grant_type")
+				grants = append(grants, grant)
+				clock.advance(test.latency)
+				if grant == "refresh_token" && test.refuseRefresh {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "Session not active"})
+					return
+				}
+				json.NewEncoder(w).Encode(tokenResponse{
+					AccessToken:      "token-" + strconv.Itoa(len(grants)),
+					ExpiresIn:        test.expiresIn,
+					RefreshToken:     "refresh-" + strconv.Itoa(len(grants)),
+					RefreshExpiresIn: test.refreshExpiresIn,
+				})
+			}))
+			defer srv.Close()
+
+			opts := []Option{WithClock(clock)}
+			if test.margin > 0 {
+				opts = append(opts, WithExpiryMargin(test.margin))
+			}
+			p := NewClientCredentials(srv.URL+"/realms/microcks", "c", "s", opts...)
+			for _, call := range test.calls {
+				clock.advance(start.Add(call).Sub(clock.Now()))
+				if _, err := p.Token(context.Background()); err != nil {
+					t.Fatalf("Token() at %s error = %v", call, err)
+				}
+			}
+			if !reflect.DeepEqual(grants, test.want) {
+				t.Errorf("token requests %q, want %q", grants, test.want)
+			}
+		})
+	}
+}
+
+// TestConcurrentRequests is meant to run with -race: requests share a client while its token is renewed
+// or replaced.
+func TestConcurrentRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		keycloak bool
+	}{
+		{"token renewed by provider", true},
+		{"token set while requesting", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var srv *microckstest.Server
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			var mc connectors.MicrocksClient
+			if test.keycloak {
+				srv = microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+				p := NewClientCredentials(srv.URL+"/auth/realms/microcks", "c", "s", WithClock(clock))
+				mc = connectors.NewMicrocksClient(srv.URL, connectors.WithAuthProvider(p))
+			} else {
+				srv = microckstest.NewServer(microckstest.WithToken("secret"))
+				mc = connectors.NewMicrocksClient(srv.URL)
+				mc.SetOAuthToken("secret")
+			}
+			defer srv.Close()
+			srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST})
+
+			var wg sync.WaitGroup
+			errs := make(chan error, 200)
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 10; j++ {
+						if test.keycloak {
+							// Every few requests, the token is about to expire and is renewed.
+							clock.advance(time.Minute)
+						} else {
+							mc.SetOAuthToken("secret")
+						}
+						if _, err := mc.GetServiceByRef(context.Background(), "Beer Catalog API", "0.9"); err != nil {
+							errs <- err
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Errorf("GetServiceByRef() error = %v", err)
+			}
+		})
+	}
+}
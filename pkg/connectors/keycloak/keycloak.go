@@ -0,0 +1,146 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package keycloak connects to the Keycloak server securing Microcks. It discovers the realm
+// Microcks relies on and provides the OAuth tokens sent to Microcks:
+//
+//	kc, err := keycloak.DiscoverConfig(ctx, "https://microcks.example.com/api")
+//	if err != nil {
+//		return err
+//	}
+//	var opts []connectors.Option
+//	if kc.Enabled {
+//		provider := keycloak.NewClientCredentials(kc.RealmURL(), clientID, clientSecret)
+//		opts = append(opts, connectors.WithAuthProvider(provider))
+//	}
+//	mc := connectors.NewMicrocksClient("https://microcks.example.com/api", opts...)
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/transport"
+)
+
+// Config is the Keycloak configuration advertised by Microcks.
+type Config struct {
+	// Enabled tells if Microcks requires authentication. Other fields are meaningless otherwise.
+	Enabled       bool   `json:"enabled"`
+	AuthServerURL string `json:"auth-server-url"`
+	Realm         string `json:"realm"`
+}
+
+// RealmURL returns the URL of the realm of Microcks, ending with a slash.
+func (c *Config) RealmURL() string {
+	return strings.TrimSuffix(c.AuthServerURL, "/") + "/realms/" + c.Realm + "/"
+}
+
+// Option configures DiscoverConfig and token providers.
+type Option func(*options)
+
+type options struct {
+	cfg          connectors.Config
+	httpClient   *http.Client
+	expiryMargin time.Duration
+	clock        connectors.Clock
+	assertion    *ClientAssertion
+}
+
+// defaultExpiryMargin is how long before their expiry tokens are renewed, covering clock skew
+// with Keycloak and the latency of requests using them.
+const defaultExpiryMargin = 30 * time.Second
+
+func newOptions(opts []Option) options {
+	o := options{expiryMargin: defaultExpiryMargin}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClientConfig sets the configuration of requests (TLS, logs, dumps, retries...), as done
+// for MicrocksClient with connectors.WithConfig.
+func WithClientConfig(cfg connectors.Config) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send requests. The client is copied and its
+// transport, if any, replaces the one built from the TLS configuration.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithExpiryMargin sets how long before their expiry cached tokens are renewed (default to 30
+// seconds). The margin is capped to half the lifetime of tokens.
+func WithExpiryMargin(margin time.Duration) Option {
+	return func(o *options) {
+		o.expiryMargin = margin
+	}
+}
+
+// WithClock sets the clock checking the expiry of tokens, allowing tests to fake it.
+func WithClock(clock connectors.Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// DiscoverConfig retrieves the Keycloak configuration of the Microcks instance at microcksURL.
+func DiscoverConfig(ctx context.Context, microcksURL string, opts ...Option) (*Config, error) {
+	o := newOptions(opts)
+	if !strings.HasSuffix(microcksURL, "/") {
+		microcksURL += "/"
+	}
+	base, err := url.Parse(microcksURL)
+	if err != nil {
+		return nil, err
+	}
+	u := base.ResolveReference(&url.URL{Path: "api/keycloak/config"})
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// Name request for logs and dumps.
+	const name = "Microcks for getting Keycloak config"
+	req = transport.Describe(req, name, true)
+	resp, err := connectors.NewHTTPClient(o.cfg, o.httpClient).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := connectors.ReadResponse(o.cfg, name, resp, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
@@ -16,19 +16,23 @@
 package connectors
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 
-	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/transport"
+	"github.com/microcks/microcks-cli/version"
 )
 
-// KeycloakClient defines methods for cinteracting with Keycloak
+// KeycloakClient defines methods for cinteracting with Keycloak.
+//
+// Deprecated: use keycloak.NewClientCredentials, which caches tokens and refreshes them when expiring.
 type KeycloakClient interface {
-	ConnectAndGetToken() (string, error)
+	ConnectAndGetToken(ctx context.Context) (string, error)
 }
 
 type keycloakClient struct {
@@ -36,12 +40,18 @@ type keycloakClient struct {
 	Username string
 	Password string
 
+	cfg        Config
 	httpClient *http.Client
 }
 
-// NewKeycloakClient build a new KeycloakClient implementation
+// NewKeycloakClient build a new KeycloakClient implementation using the default configuration.
 func NewKeycloakClient(realmURL string, username string, password string) KeycloakClient {
-	kc := keycloakClient{}
+	return NewKeycloakClientWithConfig(realmURL, username, password, Config{})
+}
+
+// NewKeycloakClientWithConfig build a new KeycloakClient implementation using cfg.
+func NewKeycloakClientWithConfig(realmURL string, username string, password string, cfg Config) KeycloakClient {
+	kc := keycloakClient{cfg: cfg}
 
 	u, err := url.Parse(realmURL)
 	if err != nil {
@@ -50,25 +60,16 @@ func NewKeycloakClient(realmURL string, username string, password string) Keyclo
 	kc.BaseURL = u
 	kc.Username = username
 	kc.Password = password
-
-	if config.InsecureTLS || len(config.CaCertPaths) > 0 {
-		tlsConfig := config.CreateTLSConfig()
-		tr := &http.Transport{
-			TLSClientConfig: tlsConfig,
-		}
-		kc.httpClient = &http.Client{Transport: tr}
-	} else {
-		kc.httpClient = http.DefaultClient
-	}
+	kc.httpClient = cfg.newHTTPClient(nil, nil, version.UserAgent())
 	return &kc
 }
 
 // ConnectAndGetToken implementation on keycloakClient structure
-func (c *keycloakClient) ConnectAndGetToken() (string, error) {
+func (c *keycloakClient) ConnectAndGetToken(ctx context.Context) (string, error) {
 	rel := &url.URL{Path: "protocol/openid-connect/token"}
 	u := c.BaseURL.ResolveReference(rel)
 
-	req, err := http.NewRequest("POST", u.String(), strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
 	if err != nil {
 		return "", err
 	}
@@ -78,28 +79,31 @@ func (c *keycloakClient) ConnectAndGetToken() (string, error) {
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", "Basic "+credential)
 
-	// Dump request if verbose required.
-	config.DumpRequestIfRequired("Keycloak for getting token", req, false)
-
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Keycloak for getting token", false)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		// Keep connection errors as is, they're not authentication failures.
 		return "", err
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
-	// Dump response if verbose required.
-	config.DumpResponseIfRequired("Keycloak for getting token", resp, true)
-
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := c.cfg.readBody("Keycloak for getting token", resp)
 	if err != nil {
-		panic(err.Error())
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", &AuthError{Err: c.cfg.newAPIError("Keycloak for getting token", resp, body)}
 	}
 
 	var openIDResp map[string]interface{}
 	if err := json.Unmarshal(body, &openIDResp); err != nil {
-		panic(err)
+		return "", &AuthError{Err: err}
 	}
 
-	accessToken := openIDResp["access_token"].(string)
-	return accessToken, err
+	accessToken, ok := openIDResp["access_token"].(string)
+	if !ok {
+		return "", &AuthError{Err: fmt.Errorf("no access_token in Keycloak response: %s", string(body))}
+	}
+	return accessToken, nil
 }
@@ -0,0 +1,99 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import "context"
+
+// LegacyMicrocksClient is the MicrocksClient API whose methods do not take a context.
+//
+// Deprecated: use MicrocksClient, whose methods take a context allowing to cancel requests.
+type LegacyMicrocksClient interface {
+	GetKeycloakURL() (string, error)
+	SetOAuthToken(oauthToken string)
+	CreateTestResult(serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error)
+	GetTestResult(testResultID string) (*TestResultSummary, error)
+	UploadArtifact(specificationFilePath string, mainArtifact bool) (string, error)
+	UpdateServiceLabels(serviceRef string, labels map[string]string) error
+	ListServices() ([]Service, error)
+	ListSecrets() ([]Secret, error)
+}
+
+// NewLegacyMicrocksClient build a LegacyMicrocksClient implementation sending requests without deadline.
+//
+// Deprecated: use NewMicrocksClient.
+func NewLegacyMicrocksClient(apiURL string) LegacyMicrocksClient {
+	return &legacyMicrocksClient{mc: NewMicrocksClient(apiURL)}
+}
+
+// legacyMicrocksClient calls a MicrocksClient with the background context.
+type legacyMicrocksClient struct {
+	mc MicrocksClient
+}
+
+func (c *legacyMicrocksClient) GetKeycloakURL() (string, error) {
+	return c.mc.GetKeycloakURL(context.Background())
+}
+
+func (c *legacyMicrocksClient) SetOAuthToken(oauthToken string) {
+	c.mc.SetOAuthToken(oauthToken)
+}
+
+func (c *legacyMicrocksClient) CreateTestResult(serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error) {
+	return c.mc.CreateTestResult(context.Background(), serviceID, testEndpoint, runnerType, secretName, timeout, filteredOperations, operationsHeaders, oAuth2Context)
+}
+
+func (c *legacyMicrocksClient) GetTestResult(testResultID string) (*TestResultSummary, error) {
+	return c.mc.GetTestResult(context.Background(), testResultID)
+}
+
+func (c *legacyMicrocksClient) UploadArtifact(specificationFilePath string, mainArtifact bool) (string, error) {
+	return c.mc.UploadArtifact(context.Background(), specificationFilePath, mainArtifact)
+}
+
+func (c *legacyMicrocksClient) UpdateServiceLabels(serviceRef string, labels map[string]string) error {
+	return c.mc.UpdateServiceLabels(context.Background(), serviceRef, labels)
+}
+
+func (c *legacyMicrocksClient) ListServices() ([]Service, error) {
+	return ListAll(context.Background(), c.mc.ListServices, 0)
+}
+
+func (c *legacyMicrocksClient) ListSecrets() ([]Secret, error) {
+	return ListAll(context.Background(), c.mc.ListSecrets, 0)
+}
+
+// LegacyKeycloakClient is the KeycloakClient API whose methods do not take a context.
+//
+// Deprecated: use KeycloakClient, whose methods take a context allowing to cancel requests.
+type LegacyKeycloakClient interface {
+	ConnectAndGetToken() (string, error)
+}
+
+// NewLegacyKeycloakClient build a LegacyKeycloakClient implementation sending requests without deadline.
+//
+// Deprecated: use NewKeycloakClient.
+func NewLegacyKeycloakClient(realmURL string, username string, password string) LegacyKeycloakClient {
+	return &legacyKeycloakClient{kc: NewKeycloakClient(realmURL, username, password)}
+}
+
+// legacyKeycloakClient calls a KeycloakClient with the background context.
+type legacyKeycloakClient struct {
+	kc KeycloakClient
+}
+
+func (c *legacyKeycloakClient) ConnectAndGetToken() (string, error) {
+	return c.kc.ConnectAndGetToken(context.Background())
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// listPageSize is the default number of items retrieved by list operations.
+const listPageSize = 1000
+
+// totalCountHeader is the response header holding the total count of items of a list, when available.
+const totalCountHeader = "X-Total-Count"
+
+// ListOptions selects the page of a list to retrieve.
+type ListOptions struct {
+	// Page is the zero-based index of the page.
+	Page int
+	// Size is the maximum number of items of the page. 0 means 1000.
+	Size int
+	// Name keeps the items whose name contains Name, ignoring case.
+	Name string
+	// Labels keeps the services having all these labels values. Secrets have no labels.
+	Labels map[string]string
+}
+
+// filtered tells if opts select items, requiring the search endpoint of Microcks.
+func (o ListOptions) filtered() bool {
+	return len(o.Name) > 0 || len(o.Labels) > 0
+}
+
+func (o ListOptions) size() int {
+	if o.Size <= 0 {
+		return listPageSize
+	}
+	return o.Size
+}
+
+// query returns the URL query selecting the page.
+func (o ListOptions) query() string {
+	return url.Values{"page": {strconv.Itoa(o.Page)}, "size": {strconv.Itoa(o.size())}}.Encode()
+}
+
+// searchQuery returns the URL query of the search endpoint of Microcks selecting items.
+func (o ListOptions) searchQuery() string {
+	query := url.Values{}
+	if len(o.Name) > 0 {
+		query.Set("name", o.Name)
+	}
+	for name, value := range o.Labels {
+		query.Set("labels."+name, value)
+	}
+	return query.Encode()
+}
+
+// Page is a page of a list returned by Microcks.
+type Page[T any] struct {
+	Items []T
+	// Total is the count of items of the whole list if reported by Microcks, -1 otherwise.
+	Total int
+}
+
+// totalCount returns the total count of items reported in header, -1 if not available.
+func totalCount(header http.Header) int {
+	total, err := strconv.Atoi(header.Get(totalCountHeader))
+	if err != nil || total < 0 {
+		return -1
+	}
+	return total
+}
+
+// listPage retrieves the page of the list at path selected by opts. Searches of Microcks are not
+// paginated so filtered lists are fully retrieved, then paginated here.
+func listPage[T any](ctx context.Context, c *microcksClient, name string, path string, opts ListOptions) (*Page[T], error) {
+	page := &Page[T]{}
+	if !opts.filtered() {
+		rel := &url.URL{Path: path, RawQuery: opts.query()}
+		header, err := c.getJSON(ctx, name, rel, &page.Items)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = totalCount(header)
+		return page, nil
+	}
+
+	var items []T
+	rel := &url.URL{Path: path + "/search", RawQuery: opts.searchQuery()}
+	if _, err := c.getJSON(ctx, name, rel, &items); err != nil {
+		return nil, err
+	}
+	start := min(max(opts.Page, 0)*opts.size(), len(items))
+	end := min(start+opts.size(), len(items))
+	page.Items = items[start:end]
+	page.Total = len(items)
+	return page, nil
+}
+
+// ForEach calls fn with every item of the list retrieved by list, walking all its pages of size
+// items (0 meaning 1000) until one is incomplete or the reported total is reached. It stops at the
+// first error of list or fn and checks ctx between pages:
+//
+//	err := connectors.ForEach(ctx, mc.ListServices, 100, func(service connectors.Service) error {
+//		fmt.Println(service.Name)
+//		return nil
+//	})
+func ForEach[T any](ctx context.Context, list func(ctx context.Context, opts ListOptions) (*Page[T], error), size int, fn func(item T) error) error {
+	opts := ListOptions{Size: size}
+	opts.Size = opts.size()
+	seen := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := list(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if page == nil {
+			return nil
+		}
+		for _, item := range page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		seen += len(page.Items)
+		if len(page.Items) < opts.Size || (page.Total >= 0 && seen >= page.Total) {
+			return nil
+		}
+		opts.Page++
+	}
+}
+
+// ListAll returns all the items of the list retrieved by list, walking its pages like ForEach.
+func ListAll[T any](ctx context.Context, list func(ctx context.Context, opts ListOptions) (*Page[T], error), size int) ([]T, error) {
+	var items []T
+	err := ForEach(ctx, list, size, func(item T) error {
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestForEachPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		withTotal bool
+		cancel    bool
+		wantPages []string
+		wantErr   error
+	}{
+		{name: "three full pages", count: 6, wantPages: []string{"0", "1", "2", "3"}},
+		{name: "three full pages with total", count: 6, withTotal: true, wantPages: []string{"0", "1", "2"}},
+		{name: "short last page", count: 5, wantPages: []string{"0", "1", "2"}},
+		{name: "empty", count: 0, wantPages: []string{"0"}},
+		{name: "cancelled between pages", count: 6, cancel: true, wantPages: []string{"0"}, wantErr: context.Canceled},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var pages []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+				mutex.Lock()
+				pages = append(pages, r.URL.Query().Get("page"))
+				mutex.Unlock()
+
+				services := []Service{}
+				for i := page * size; i < min((page+1)*size, test.count); i++ {
+					services = append(services, Service{ID: strconv.Itoa(i)})
+				}
+				if test.withTotal {
+					w.Header().Set(totalCountHeader, strconv.Itoa(test.count))
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(services)
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var ids []string
+			err := ForEach(ctx, NewMicrocksClient(srv.URL).ListServices, 2, func(service Service) error {
+				ids = append(ids, service.ID)
+				if test.cancel {
+					cancel()
+				}
+				return nil
+			})
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("ForEach() error = %v, want %v", err, test.wantErr)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			if !reflect.DeepEqual(pages, test.wantPages) {
+				t.Errorf("requested pages %q, want %q", pages, test.wantPages)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			var want []string
+			for i := 0; i < test.count; i++ {
+				want = append(want, strconv.Itoa(i))
+			}
+			if !reflect.DeepEqual(ids, want) {
+				t.Errorf("ForEach() walked %q, want %q", ids, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+	"github.com/microcks/microcks-cli/pkg/connectors/keycloak"
+	"github.com/microcks/microcks-cli/pkg/microckstest"
+)
+
+func TestClientStdoutSilence(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     connectors.Config
+		level   slog.Level
+		wantLog bool
+	}{
+		{name: "verbose dumps", cfg: connectors.Config{Verbose: true}, level: slog.LevelDebug, wantLog: true},
+		{name: "debug logs", level: slog.LevelDebug, wantLog: true},
+		{name: "quiet", level: slog.LevelError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := microckstest.NewServer(microckstest.WithKeycloak("c", "s"))
+			defer srv.Close()
+			beer := connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: connectors.ServiceTypeREST}
+			srv.AddArtifact("beer.yaml", beer)
+			srv.ScriptTest("Beer Catalog API:0.9", microckstest.TestScript{InProgressPolls: 1, Success: true,
+				TestCaseResults: []connectors.TestCaseResult{{OperationName: "GET /beer", Success: true}}})
+
+			stdout, pipe, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			captured := make(chan []byte)
+			go func() {
+				content, _ := io.ReadAll(stdout)
+				captured <- content
+			}()
+			original := os.Stdout
+			os.Stdout = pipe
+			defer func() { os.Stdout = original }()
+
+			var logs bytes.Buffer
+			cfg := test.cfg
+			cfg.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: test.level}))
+			provider := keycloak.NewClientCredentials(srv.URL+"/auth/realms/microcks", "c", "s", keycloak.WithClientConfig(cfg))
+			mc := connectors.NewMicrocksClient(srv.URL, connectors.WithConfig(cfg), connectors.WithAuthProvider(provider))
+
+			ctx := context.Background()
+			if _, err := mc.UploadArtifactContent(ctx, strings.NewReader("openapi: 3.0.0"), "beer.yaml", true); err != nil {
+				t.Fatalf("UploadArtifactContent() error = %v", err)
+			}
+			service, err := mc.GetServiceByRef(ctx, "Beer Catalog API", "0.9")
+			if err != nil {
+				t.Fatalf("GetServiceByRef() error = %v", err)
+			}
+			testResultID, err := mc.CreateTestResult(ctx, "Beer Catalog API:0.9", "http://beers", "HTTP", "", 5000, "", "", "")
+			if err != nil {
+				t.Fatalf("CreateTestResult() error = %v", err)
+			}
+			if _, err := mc.WaitForTestResult(ctx, testResultID, connectors.PollOptions{InitialDelay: -1, Interval: 10 * time.Millisecond, FetchFull: true}); err != nil {
+				t.Fatalf("WaitForTestResult() error = %v", err)
+			}
+			if _, err := mc.ExportServices(ctx, []string{service.ID}, io.Discard); err != nil {
+				t.Fatalf("ExportServices() error = %v", err)
+			}
+			if _, err := mc.GetTestResult(ctx, "unknown"); err == nil {
+				t.Fatalf("GetTestResult() of unknown test succeeded")
+			}
+
+			os.Stdout = original
+			pipe.Close()
+			if content := <-captured; len(content) > 0 {
+				t.Errorf("client wrote on stdout:\n%s", content)
+			}
+			if (logs.Len() > 0) != test.wantLog {
+				t.Errorf("logger got %d bytes, want some %v", logs.Len(), test.wantLog)
+			}
+		})
+	}
+}
@@ -17,11 +17,13 @@ package connectors
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -29,21 +31,83 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/microcks/microcks-cli/pkg/config"
+	"github.com/microcks/microcks-cli/pkg/transport"
+	"github.com/microcks/microcks-cli/version"
 )
 
 var (
 	grantTypeChoices = map[string]bool{"PASSWORD": true, "CLIENT_CREDENTIALS": true, "REFRESH_TOKEN": true}
 )
 
-// MicrocksClient allows interacting with Microcks APIs
+// MicrocksClient allows interacting with Microcks APIs. Use NewMicrocksClient to build an implementation
+// or testutil.MockMicrocksClient to fake it in tests.
+//
+// Both are safe for concurrent use by multiple goroutines, so a single client should be shared by
+// parallel uploads or tests. SetOAuthToken may be called while requests are in flight: each request
+// uses the token in effect when it is sent. The AuthProvider, RateLimiter, Logger and OnTiming given
+// to a client are called from these goroutines and must be safe for concurrent use too.
 type MicrocksClient interface {
-	GetKeycloakURL() (string, error)
+	// GetKeycloakURL returns the URL of Keycloak realm securing Microcks, "null" if disabled.
+	GetKeycloakURL(ctx context.Context) (string, error)
+	// SetOAuthToken sets the token sent with authenticated requests.
 	SetOAuthToken(oauthToken string)
-	CreateTestResult(serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error)
-	GetTestResult(testResultID string) (*TestResultSummary, error)
-	UploadArtifact(specificationFilePath string, mainArtifact bool) (string, error)
+	// CreateTestResult launches a test and returns its identifier.
+	CreateTestResult(ctx context.Context, serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error)
+	// GetTestResult returns the current status of a test. It is lightweight enough for polling.
+	GetTestResult(ctx context.Context, testResultID string) (*TestResultSummary, error)
+	// GetFullTestResult returns a test with the results of its test cases and steps.
+	GetFullTestResult(ctx context.Context, testResultID string) (*TestResult, error)
+	// CopyTestCaseMessages streams to w the JSON array of the messages exchanged while testing
+	// operationName in testResult, request and response pairs or received events for AsyncAPI tests,
+	// and returns the number of bytes written. The body is not bounded by Config.MaxResponseBytes.
+	CopyTestCaseMessages(ctx context.Context, testResult *TestResult, operationName string, w io.Writer) (int64, error)
+	// ListTestResults returns a page of the tests of the service having identifier serviceID, the most
+	// recent first, with the results of their test cases. Tests cannot be filtered by name or labels.
+	ListTestResults(ctx context.Context, serviceID string, opts ListOptions) (*Page[TestResult], error)
+	// WaitForTestResult polls a test until it is completed, see PollOptions.
+	WaitForTestResult(ctx context.Context, testResultID string, opts PollOptions) (*TestResult, error)
+	// UploadArtifact imports an artifact file and returns the name and version of the service it defines.
+	UploadArtifact(ctx context.Context, specificationFilePath string, mainArtifact bool) (string, error)
+	// UploadArtifactContent imports an artifact read from r, filename telling Microcks its type, and returns
+	// the name and version of the service it defines. Content is streamed, with a Content-Length if its size
+	// is known (see SizedReader).
+	UploadArtifactContent(ctx context.Context, r io.Reader, filename string, mainArtifact bool) (string, error)
+	// DownloadArtifact makes Microcks download and import the artifact at artifactURL, using the secret named
+	// secretName if not empty, and returns the name and version of the service it defines.
+	DownloadArtifact(ctx context.Context, artifactURL string, mainArtifact bool, secretName string) (string, error)
+	// UpdateServiceLabels merges labels into the ones of a service identified by name:version.
+	UpdateServiceLabels(ctx context.Context, serviceRef string, labels map[string]string) error
+	// ListServices returns a page of the services known by Microcks with their operations, possibly
+	// filtered by name and labels. Use ForEach or ListAll to walk all pages.
+	ListServices(ctx context.Context, opts ListOptions) (*Page[Service], error)
+	// GetServiceByRef returns the service with exactly this name and version, with its operations and
+	// metadata. An error matching ErrNotFound is returned if it does not exist.
+	GetServiceByRef(ctx context.Context, name string, version string) (*Service, error)
+	// GetService returns the service having identifier serviceID, with its operations and metadata. An
+	// error matching ErrNotFound is returned if it does not exist.
+	GetService(ctx context.Context, serviceID string) (*Service, error)
+	// GetServiceResources returns the resources of the service having identifier serviceID: the
+	// contracts imported for it, like its OpenAPI specification.
+	GetServiceResources(ctx context.Context, serviceID string) ([]Resource, error)
+	// ListSecrets returns a page of the secrets known by Microcks, possibly filtered by name.
+	ListSecrets(ctx context.Context, opts ListOptions) (*Page[Secret], error)
+	// CreateSecret creates a secret and returns its identifier.
+	CreateSecret(ctx context.Context, secret Secret) (string, error)
+	// UpdateSecret replaces the secret having the identifier of secret.
+	UpdateSecret(ctx context.Context, secret Secret) error
+	// DeleteSecret deletes the secret having identifier id.
+	DeleteSecret(ctx context.Context, id string) error
+	// ExportServices streams to w the JSON snapshot of the services having identifiers serviceIDs, with
+	// their resources and examples, that Microcks can import back in the same or another instance.
+	ExportServices(ctx context.Context, serviceIDs []string, w io.Writer) (int64, error)
+	// ImportSnapshot imports the JSON snapshot read from r, named filename, creating or replacing the
+	// services it holds with their resources and examples.
+	ImportSnapshot(ctx context.Context, r io.Reader, filename string) error
+	// GetServerInfo returns the version and the features configuration of Microcks. They are fetched
+	// on first call only, later calls return the same info.
+	GetServerInfo(ctx context.Context) (*ServerInfo, error)
 }
 
 // TestResultSummary represents a simple view on Microcks TestResult
@@ -59,6 +123,55 @@ type TestResultSummary struct {
 	InProgress     bool   `json:"inProgress"`
 }
 
+// TestResult represents a complete Microcks TestResult, with the results of its test cases.
+type TestResult struct {
+	ID                string                  `json:"id"`
+	Version           int32                   `json:"version"`
+	TestNumber        int32                   `json:"testNumber"`
+	TestDate          int64                   `json:"testDate"`
+	TestedEndpoint    string                  `json:"testedEndpoint"`
+	ServiceID         string                  `json:"serviceId"`
+	RunnerType        string                  `json:"runnerType"`
+	Timeout           int64                   `json:"timeout,omitempty"`
+	ElapsedTime       int64                   `json:"elapsedTime"`
+	Success           bool                    `json:"success"`
+	InProgress        bool                    `json:"inProgress"`
+	SecretRef         *SecretRef              `json:"secretRef,omitempty"`
+	OperationsHeaders map[string][]TestHeader `json:"operationsHeaders,omitempty"`
+	OAuth2Context     *OAuth2ClientContext    `json:"oAuth2Context,omitempty"`
+	TestCaseResults   []TestCaseResult        `json:"testCaseResults"`
+}
+
+// TestCaseResult represents the result of testing an operation of a Service.
+type TestCaseResult struct {
+	OperationName   string           `json:"operationName"`
+	ElapsedTime     int64            `json:"elapsedTime"`
+	Success         bool             `json:"success"`
+	TestStepResults []TestStepResult `json:"testStepResults"`
+}
+
+// TestStepResult represents the result of testing a request of an operation. Steps of AsyncAPI tests
+// check received events so they hold an EventMessageName instead of a RequestName.
+type TestStepResult struct {
+	RequestName      string `json:"requestName,omitempty"`
+	EventMessageName string `json:"eventMessageName,omitempty"`
+	ElapsedTime      int64  `json:"elapsedTime"`
+	Success          bool   `json:"success"`
+	Message          string `json:"message,omitempty"`
+}
+
+// SecretRef represents a reference to the Secret used by a test
+type SecretRef struct {
+	SecretID string `json:"secretId"`
+	Name     string `json:"name"`
+}
+
+// TestHeader represents a header sent with test requests
+type TestHeader struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
 // HeaderDTO represents an operation header passed for Test
 type HeaderDTO struct {
 	Name   string `json:"name"`
@@ -78,16 +191,37 @@ type OAuth2ClientContext struct {
 }
 
 type microcksClient struct {
-	APIURL     *url.URL
-	OAuthToken string
+	APIURL *url.URL
 
+	baseURL    string
+	authMutex  sync.RWMutex
+	auth       AuthProvider
+	userAgent  string
+	cfg        Config
 	httpClient *http.Client
+	transport  http.RoundTripper
+
+	infoMutex sync.Mutex
+	info      *ServerInfo
 }
 
-// NewMicrocksClient build a new MicrocksClient implementation
-func NewMicrocksClient(apiURL string) MicrocksClient {
-	mc := microcksClient{}
+// NewMicrocksClient build a new MicrocksClient implementation on apiURL. Without options, the client
+// uses Go default TLS settings, logs warnings on stderr and uses the User-Agent of the CLI. Tools embedding
+// this package may override them:
+//
+//	mc := connectors.NewMicrocksClient("https://microcks.example.com/api",
+//		connectors.WithConfig(connectors.Config{MaxResponseBytes: 4 * 1024 * 1024}),
+//		connectors.WithHTTPClient(&http.Client{Timeout: 30 * time.Second}),
+//		connectors.WithAuthProvider(connectors.StaticToken(token)),
+//		connectors.WithUserAgent("my-tool/1.0"))
+//	page, err := mc.ListServices(ctx, connectors.ListOptions{})
+func NewMicrocksClient(apiURL string, opts ...Option) MicrocksClient {
+	mc := microcksClient{baseURL: apiURL, userAgent: version.UserAgent()}
+	for _, opt := range opts {
+		opt(&mc)
+	}
 
+	apiURL = mc.baseURL
 	if !strings.HasSuffix(apiURL, "/") {
 		apiURL += "/"
 	}
@@ -98,56 +232,64 @@ func NewMicrocksClient(apiURL string) MicrocksClient {
 	}
 	mc.APIURL = u
 
-	if config.InsecureTLS || len(config.CaCertPaths) > 0 {
-		tlsConfig := config.CreateTLSConfig()
-		tr := &http.Transport{
-			TLSClientConfig: tlsConfig,
-		}
-		mc.httpClient = &http.Client{Transport: tr}
-	} else {
-		mc.httpClient = http.DefaultClient
-	}
+	mc.httpClient = mc.cfg.newHTTPClient(mc.httpClient, mc.transport, mc.userAgent)
 	return &mc
 }
 
-func (c *microcksClient) GetKeycloakURL() (string, error) {
+// authorize sets the bearer token provided by the auth provider of client on req, if any.
+func (c *microcksClient) authorize(req *http.Request) error {
+	c.authMutex.RLock()
+	auth := c.auth
+	c.authMutex.RUnlock()
+	if auth == nil {
+		return nil
+	}
+	token, err := auth.Token(req.Context())
+	if err != nil {
+		return &AuthError{Err: err}
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (c *microcksClient) GetKeycloakURL(ctx context.Context) (string, error) {
 	// Ensure we have a correct URL for retrieving Keycloal configuration.
 	rel := &url.URL{Path: "api/keycloak/config"}
 	u := c.APIURL.ResolveReference(rel)
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Accept", "application/json")
 
-	// Dump request if verbose required.
-	config.DumpRequestIfRequired("Microcks for getting Keycloak config", req, true)
-
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Microcks for getting Keycloak config", true)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	// Dump request if verbose required.
-	config.DumpResponseIfRequired("Microcks for getting Keycloak config", resp, true)
+	defer drainAndClose(resp.Body)
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := c.cfg.readBody("Microcks for getting Keycloak config", resp)
 	if err != nil {
-		panic(err.Error())
+		return "", err
+	}
+
+	if resp.StatusCode != 200 {
+		return "", c.cfg.newAPIError("Microcks for getting Keycloak config", resp, body)
 	}
 
 	var configResp map[string]interface{}
 	if err := json.Unmarshal(body, &configResp); err != nil {
-		panic(err)
+		return "", err
 	}
 
 	// Retrieve auth server url and realm name.
-	enabled := configResp["enabled"].(bool)
-	authServerURL := configResp["auth-server-url"].(string)
-	realmName := configResp["realm"].(string)
+	enabled, _ := configResp["enabled"].(bool)
+	authServerURL, _ := configResp["auth-server-url"].(string)
+	realmName, _ := configResp["realm"].(string)
 
 	// Return a proper URL or 'null' if Keycloak is disables.
 	if enabled {
@@ -157,202 +299,778 @@ func (c *microcksClient) GetKeycloakURL() (string, error) {
 }
 
 func (c *microcksClient) SetOAuthToken(oauthToken string) {
-	c.OAuthToken = oauthToken
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+	c.auth = StaticToken(oauthToken)
 }
 
-func (c *microcksClient) CreateTestResult(serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error) {
-	// Ensure we have a correct URL.
-	rel := &url.URL{Path: "api/tests"}
-	u := c.APIURL.ResolveReference(rel)
+// testRequestBody is the JSON body launching a test. JSON options are embedded as they are given.
+type testRequestBody struct {
+	ServiceID          string          `json:"serviceId"`
+	TestEndpoint       string          `json:"testEndpoint"`
+	RunnerType         string          `json:"runnerType"`
+	Timeout            int64           `json:"timeout"`
+	SecretName         string          `json:"secretName,omitempty"`
+	FilteredOperations json.RawMessage `json:"filteredOperations,omitempty"`
+	OperationsHeaders  json.RawMessage `json:"operationsHeaders,omitempty"`
+	OAuth2Context      json.RawMessage `json:"oAuth2Context,omitempty"`
+}
 
-	// Prepare an input string as body.
-	var input = "{"
-	input += ("\"serviceId\": \"" + serviceID + "\", ")
-	input += ("\"testEndpoint\": \"" + testEndpoint + "\", ")
-	input += ("\"runnerType\": \"" + runnerType + "\", ")
-	input += ("\"timeout\":  " + strconv.FormatInt(timeout, 10))
-	if len(secretName) > 0 {
-		input += (", \"secretName\": \"" + secretName + "\"")
+// TestRequestBody builds the JSON body sent by CreateTestResult to launch a test. Invalid
+// filteredOperations, operationsHeaders and oAuth2Context are logged as warnings with logger and
+// left out, see ValidateTestOptions to check them beforehand.
+func TestRequestBody(logger *slog.Logger, serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) ([]byte, error) {
+	input := testRequestBody{
+		ServiceID:    serviceID,
+		TestEndpoint: testEndpoint,
+		RunnerType:   runnerType,
+		Timeout:      timeout,
+		SecretName:   secretName,
+	}
+	if len(filteredOperations) > 0 && ensureValidOperationsList(logger, filteredOperations) {
+		input.FilteredOperations = json.RawMessage(filteredOperations)
+	}
+	if len(operationsHeaders) > 0 && ensureValidOperationsHeaders(logger, operationsHeaders) {
+		input.OperationsHeaders = json.RawMessage(operationsHeaders)
 	}
-	if len(filteredOperations) > 0 && ensureValidOperationsList(filteredOperations) {
-		input += (", \"filteredOperations\": " + filteredOperations)
+	if len(oAuth2Context) > 0 && ensureValieOAuth2Context(logger, oAuth2Context) {
+		input.OAuth2Context = json.RawMessage(oAuth2Context)
+	}
+	return json.Marshal(input)
+}
+
+// ValidateTestOptions checks the JSON options of a test, returning an error describing the first
+// invalid one. CreateTestResult leaves them out of the request instead.
+func ValidateTestOptions(filteredOperations string, operationsHeaders string, oAuth2Context string) error {
+	if len(filteredOperations) > 0 {
+		if err := validateOperationsList(filteredOperations); err != nil {
+			return fmt.Errorf("invalid filteredOperations: %w", err)
+		}
 	}
-	if len(operationsHeaders) > 0 && ensureValidOperationsHeaders(operationsHeaders) {
-		input += (", \"operationsHeaders\": " + operationsHeaders)
+	if len(operationsHeaders) > 0 {
+		if err := validateOperationsHeaders(operationsHeaders); err != nil {
+			return fmt.Errorf("invalid operationsHeaders: %w", err)
+		}
 	}
-	if len(oAuth2Context) > 0 && ensureValieOAuth2Context(oAuth2Context) {
-		input += (", \"oAuth2Context\": " + oAuth2Context)
+	if len(oAuth2Context) > 0 {
+		if err := validateOAuth2Context(oAuth2Context); err != nil {
+			return fmt.Errorf("invalid oAuth2Context: %w", err)
+		}
 	}
+	return nil
+}
 
-	input += "}"
+func (c *microcksClient) CreateTestResult(ctx context.Context, serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error) {
+	// Ensure we have a correct URL.
+	rel := &url.URL{Path: "api/tests"}
+	u := c.APIURL.ResolveReference(rel)
 
-	req, err := http.NewRequest("POST", u.String(), strings.NewReader(input))
+	input, err := TestRequestBody(c.cfg.logger(), serviceID, testEndpoint, runnerType, secretName, timeout, filteredOperations, operationsHeaders, oAuth2Context)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewReader(input))
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.OAuthToken)
-
-	// Dump request if verbose required.
-	config.DumpRequestIfRequired("Microcks for creating test", req, true)
+	if err := c.authorize(req); err != nil {
+		return "", err
+	}
 
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Microcks for creating test", true)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	// Dump response if verbose required.
-	config.DumpResponseIfRequired("Microcks for creating test", resp, true)
+	defer drainAndClose(resp.Body)
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := c.cfg.readBody("Microcks for creating test", resp)
 	if err != nil {
-		panic(err.Error())
+		return "", err
 	}
-
-	var createTestResp map[string]interface{}
-	if err := json.Unmarshal(body, &createTestResp); err != nil {
-		panic(err)
+	if resp.StatusCode != 201 {
+		return "", c.cfg.newAPIError("Microcks for creating test", resp, body)
 	}
 
-	testID := createTestResp["id"].(string)
-	return testID, err
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", decodeError("Microcks for creating test", err)
+	}
+	if len(created.ID) == 0 {
+		apiErr := c.cfg.newAPIError("Microcks for creating test", resp, body)
+		apiErr.Message = "no test identifier in response"
+		return "", apiErr
+	}
+	return created.ID, nil
 }
 
-func (c *microcksClient) GetTestResult(testResultID string) (*TestResultSummary, error) {
+func (c *microcksClient) GetTestResult(ctx context.Context, testResultID string) (*TestResultSummary, error) {
 	// Ensure we have a correct URL.
 	rel := &url.URL{Path: "api/tests/" + testResultID}
 	u := c.APIURL.ResolveReference(rel)
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.OAuthToken)
-
-	// Dump request if verbose required.
-	config.DumpRequestIfRequired("Microcks for getting status", req, false)
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
 
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Microcks for getting status", false)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	// Dump response if verbose required.
-	config.DumpResponseIfRequired("Microcks for getting status test", resp, true)
+	defer drainAndClose(resp.Body)
 
-	body, err := ioutil.ReadAll(resp.Body)
+	body, err := c.cfg.readBody("Microcks for getting status test", resp)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, c.cfg.newAPIError("Microcks for getting status test", resp, body)
 	}
 
 	result := TestResultSummary{}
-	json.Unmarshal([]byte(body), &result)
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, decodeError("Microcks for getting status test", err)
+	}
+	return &result, nil
+}
 
-	return &result, err
+func (c *microcksClient) GetFullTestResult(ctx context.Context, testResultID string) (*TestResult, error) {
+	result := TestResult{}
+	rel := &url.URL{Path: "api/tests/" + testResultID}
+	if _, err := c.getJSON(ctx, "Microcks for getting test result", rel, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
 }
 
-func (c *microcksClient) UploadArtifact(specificationFilePath string, mainArtifact bool) (string, error) {
-	// Ensure file exists on fs.
-	file, err := os.Open(specificationFilePath)
+func (c *microcksClient) CopyTestCaseMessages(ctx context.Context, testResult *TestResult, operationName string, w io.Writer) (int64, error) {
+	// Messages of AsyncAPI tests are the events received from the broker.
+	kind := "messages"
+	if testResult.RunnerType == "ASYNC_API_SCHEMA" {
+		kind = "events"
+	}
+	testCaseID := testResult.ID + "-" + strconv.Itoa(int(testResult.TestNumber)) + "-"
+	rel := &url.URL{
+		Path:    "api/tests/" + testResult.ID + "/" + kind + "/" + testCaseID + operationName,
+		RawPath: "api/tests/" + url.PathEscape(testResult.ID) + "/" + kind + "/" + url.PathEscape(testCaseID) + url.PathEscape(operationName),
+	}
+	u := c.APIURL.ResolveReference(rel)
+
+	// Messages may be large, they are streamed to w rather than bounded and read in memory.
+	req, err := http.NewRequestWithContext(transport.WithStreamedBody(ctx), "GET", u.String(), nil)
 	if err != nil {
-		return "", err
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req); err != nil {
+		return 0, err
+	}
+
+	name := "Microcks for getting test case " + kind
+	req = transport.Describe(req, name, false)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode != 200 {
+		body, err := c.cfg.readBody(name, resp)
+		if err != nil {
+			return 0, err
+		}
+		return 0, c.cfg.newAPIError(name, resp, body)
+	}
+	return io.Copy(w, resp.Body)
+}
+
+func (c *microcksClient) ExportServices(ctx context.Context, serviceIDs []string, w io.Writer) (int64, error) {
+	if len(serviceIDs) == 0 {
+		return 0, errors.New("at least one service is required to export a snapshot")
+	}
+	query := url.Values{"serviceIds": serviceIDs}
+	u := c.APIURL.ResolveReference(&url.URL{Path: "api/export", RawQuery: query.Encode()})
+
+	// Snapshots hold all the examples of services, they are streamed to w rather than read in memory.
+	req, err := http.NewRequestWithContext(transport.WithStreamedBody(ctx), "GET", u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req); err != nil {
+		return 0, err
 	}
-	defer file.Close()
 
-	// Create a multipart request body, reading the file.
+	name := "Microcks for exporting services"
+	req = transport.Describe(req, name, false)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer drainAndClose(resp.Body)
+
+	if resp.StatusCode != 200 {
+		body, err := c.cfg.readBody(name, resp)
+		if err != nil {
+			return 0, err
+		}
+		return 0, c.cfg.newAPIError(name, resp, body)
+	}
+	return io.Copy(w, resp.Body)
+}
+
+func (c *microcksClient) ImportSnapshot(ctx context.Context, r io.Reader, filename string) error {
+	// Snapshots are buffered so that the request can be sent again when throttled.
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("file", filepath.Base(specificationFilePath))
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	u := c.APIURL.ResolveReference(&url.URL{Path: "api/import"})
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := c.authorize(req); err != nil {
+		return err
+	}
+
+	name := "Microcks for importing snapshot"
+	req = transport.Describe(req, name, false)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+
+	respBody, err := c.cfg.readBody(name, resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 201 {
+		return c.cfg.newAPIError(name, resp, respBody)
+	}
+	return nil
+}
+
+func (c *microcksClient) UploadArtifact(ctx context.Context, specificationFilePath string, mainArtifact bool) (string, error) {
+	// Ensure file exists on fs.
+	file, err := os.Open(specificationFilePath)
 	if err != nil {
 		return "", err
 	}
-	_, err = io.Copy(part, file)
+	defer file.Close()
+
+	return c.UploadArtifactContent(ctx, file, filepath.Base(specificationFilePath), mainArtifact)
+}
+
+func (c *microcksClient) UploadArtifactContent(ctx context.Context, r io.Reader, filename string, mainArtifact bool) (string, error) {
+	upload, err := newArtifactUpload(r, filename, mainArtifact)
 	if err != nil {
-		panic(err.Error())
+		return "", err
 	}
 
-	// Add the mainArtifact flag to request.
-	_ = writer.WriteField("mainArtifact", strconv.FormatBool(mainArtifact))
+	// Try a gzip encoded upload first if required, falling back to raw content if server does not support it.
+	if c.cfg.CompressUploads {
+		if err := upload.rewindable(); err != nil {
+			return "", err
+		}
+		respBody, resp, err := c.sendArtifact(ctx, upload, true)
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			return c.checkArtifactUpload(respBody, resp)
+		}
+		c.cfg.logger().Debug("Microcks does not accept gzip encoded uploads, retrying uncompressed")
+		if err := upload.rewind(); err != nil {
+			return "", err
+		}
+	}
 
-	err = writer.Close()
+	respBody, resp, err := c.sendArtifact(ctx, upload, false)
 	if err != nil {
 		return "", err
 	}
+	return c.checkArtifactUpload(respBody, resp)
+}
 
+func (c *microcksClient) sendArtifact(ctx context.Context, upload *artifactUpload, compress bool) ([]byte, *http.Response, error) {
 	// Ensure we have a correct URL.
 	rel := &url.URL{Path: "api/artifact/upload"}
 	u := c.APIURL.ResolveReference(rel)
 
-	req, err := http.NewRequest("POST", u.String(), body)
-	if err != nil {
-		return "", err
+	var body io.Reader = upload.reader()
+	var rawSize, compressedSize int64
+	compressed := make(chan struct{})
+	if compress {
+		// Stream gzip compression of content while request is being sent.
+		pr, pw := io.Pipe()
+		raw := body
+		go func() {
+			defer close(compressed)
+			counter := &countingWriter{w: pw}
+			gz := gzip.NewWriter(counter)
+			n, err := io.Copy(gz, raw)
+			if err == nil {
+				err = gz.Close()
+			}
+			rawSize, compressedSize = n, counter.n
+			pw.CloseWithError(err)
+		}()
+		body = pr
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+c.OAuthToken)
 
-	// Dump request if verbose required.
-	config.DumpRequestIfRequired("Microcks for uploading artifact", req, true)
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !compress && upload.length() >= 0 {
+		req.ContentLength = upload.length()
+	}
+	req.Header.Set("Content-Type", upload.contentType)
+	if err := c.authorize(req); err != nil {
+		return nil, nil, err
+	}
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
+	// Name request for logs and dumps (not binary compressed body).
+	req = transport.Describe(req, "Microcks for uploading artifact", !compress)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
+	defer drainAndClose(resp.Body)
 
-	// Dump response if verbose required.
-	config.DumpResponseIfRequired("Microcks for uploading artifact", resp, true)
+	if compress {
+		<-compressed
+		// Rejected gzip uploads are sent again uncompressed, their sizes would be misleading.
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			c.cfg.logger().Debug("Uploaded artifact compressed", "rawSize", rawSize, "compressedSize", compressedSize)
+		}
+	}
 
-	respBody, err := ioutil.ReadAll(resp.Body)
+	respBody, err := c.cfg.readBody("Microcks for uploading artifact", resp)
 	if err != nil {
-		panic(err.Error())
+		return nil, nil, err
 	}
+	return respBody, resp, nil
+}
 
+func (c *microcksClient) checkArtifactUpload(respBody []byte, resp *http.Response) (string, error) {
 	// Raise exception if not created.
 	if resp.StatusCode != 201 {
-		return "", errors.New(string(respBody))
+		return "", c.cfg.newAPIError("Microcks for uploading artifact", resp, respBody)
 	}
+	return string(respBody), nil
+}
 
-	return string(respBody), err
+func (c *microcksClient) DownloadArtifact(ctx context.Context, artifactURL string, mainArtifact bool, secretName string) (string, error) {
+	// Ensure we have a correct URL.
+	rel := &url.URL{Path: "api/artifact/download"}
+	u := c.APIURL.ResolveReference(rel)
+
+	form := url.Values{"url": {artifactURL}, "mainArtifact": {strconv.FormatBool(mainArtifact)}}
+	if len(secretName) > 0 {
+		form.Set("secretName", secretName)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := c.authorize(req); err != nil {
+		return "", err
+	}
+
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Microcks for downloading artifact", true)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer drainAndClose(resp.Body)
+
+	body, err := c.cfg.readBody("Microcks for downloading artifact", resp)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 201 {
+		return "", c.cfg.newAPIError("Microcks for downloading artifact", resp, body)
+	}
+	return string(body), nil
 }
 
-func ensureValidOperationsList(filteredOperations string) bool {
-	// Unmarshal using a generic interface
-	var list = []string{}
-	err := json.Unmarshal([]byte(filteredOperations), &list)
+// ServiceMetadata represents the metadata of a Microcks Service
+type ServiceMetadata struct {
+	CreatedOn   int64             `json:"createdOn,omitempty" yaml:"createdOn,omitempty"`
+	LastUpdate  int64             `json:"lastUpdate,omitempty" yaml:"lastUpdate,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+func (c *microcksClient) UpdateServiceLabels(ctx context.Context, serviceRef string, labels map[string]string) error {
+	// Retrieve service first as metadata update requires its identifier and replaces existing labels.
+	rel := &url.URL{Path: "api/services/" + serviceRef, RawQuery: "messages=false"}
+	u := c.APIURL.ResolveReference(rel)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req); err != nil {
+		return err
+	}
+
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Microcks for getting service", false)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(resp.Body)
+
+	body, err := c.cfg.readBody("Microcks for getting service", resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return c.cfg.newAPIError("Microcks for getting service", resp, body)
+	}
+
+	var service struct {
+		ID       string          `json:"id"`
+		Metadata ServiceMetadata `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &service); err != nil {
+		return err
+	}
+
+	metadata := service.Metadata
+	if metadata.Labels == nil {
+		metadata.Labels = map[string]string{}
+	}
+	for name, value := range labels {
+		metadata.Labels[name] = value
+	}
+	input, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	rel = &url.URL{Path: "api/services/" + service.ID + "/metadata"}
+	u = c.APIURL.ResolveReference(rel)
+
+	req, err = http.NewRequestWithContext(ctx, "PUT", u.String(), bytes.NewReader(input))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req); err != nil {
+		return err
+	}
+
+	// Name request for logs and dumps.
+	req = transport.Describe(req, "Microcks for updating service labels", true)
+	updateResp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainAndClose(updateResp.Body)
+
+	body, err = c.cfg.readBody("Microcks for updating service labels", updateResp)
+	if err != nil {
+		return err
+	}
+	if updateResp.StatusCode != 200 && updateResp.StatusCode != 204 {
+		return c.cfg.newAPIError("Microcks for updating service labels", updateResp, body)
+	}
+	return nil
+}
+
+// ServiceType is the kind of API a Microcks Service stands for.
+type ServiceType string
+
+// Service types known by Microcks.
+const (
+	ServiceTypeREST         ServiceType = "REST"
+	ServiceTypeSOAP         ServiceType = "SOAP_HTTP"
+	ServiceTypeEvent        ServiceType = "EVENT"
+	ServiceTypeGRPC         ServiceType = "GRPC"
+	ServiceTypeGraphQL      ServiceType = "GRAPHQL"
+	ServiceTypeGenericREST  ServiceType = "GENERIC_REST"
+	ServiceTypeGenericEvent ServiceType = "GENERIC_EVENT"
+)
+
+// Service represents a Microcks Service or API with its operations
+type Service struct {
+	ID             string           `json:"id" yaml:"id"`
+	Name           string           `json:"name" yaml:"name"`
+	Version        string           `json:"version" yaml:"version"`
+	XmlNS          string           `json:"xmlNS,omitempty" yaml:"xmlNS,omitempty"`
+	Type           ServiceType      `json:"type" yaml:"type"`
+	SourceArtifact string           `json:"sourceArtifact,omitempty" yaml:"sourceArtifact,omitempty"`
+	Metadata       *ServiceMetadata `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Operations     []Operation      `json:"operations" yaml:"operations"`
+}
+
+// Operation represents an operation of a Microcks Service or API. Method is the HTTP verb of REST
+// operations, SUBSCRIBE or PUBLISH for EVENT ones, QUERY or MUTATION for GRAPHQL ones and POST
+// for GRPC ones, whose InputName and OutputName are the Protobuf messages types.
+type Operation struct {
+	Name                 string                `json:"name" yaml:"name"`
+	Method               string                `json:"method" yaml:"method"`
+	Action               string                `json:"action,omitempty" yaml:"action,omitempty"`
+	InputName            string                `json:"inputName,omitempty" yaml:"inputName,omitempty"`
+	OutputName           string                `json:"outputName,omitempty" yaml:"outputName,omitempty"`
+	Bindings             map[string]Binding    `json:"bindings,omitempty" yaml:"bindings,omitempty"`
+	Dispatcher           string                `json:"dispatcher,omitempty" yaml:"dispatcher,omitempty"`
+	DispatcherRules      string                `json:"dispatcherRules,omitempty" yaml:"dispatcherRules,omitempty"`
+	DefaultDelay         int64                 `json:"defaultDelay,omitempty" yaml:"defaultDelay,omitempty"`
+	ResourcePaths        []string              `json:"resourcePaths,omitempty" yaml:"resourcePaths,omitempty"`
+	ParameterConstraints []ParameterConstraint `json:"parameterConstraints,omitempty" yaml:"parameterConstraints,omitempty"`
+}
+
+// Binding represents how an EVENT operation is bound to a protocol (eg. KAFKA, MQTT, AMQP).
+type Binding struct {
+	Type            string `json:"type" yaml:"type"`
+	KeyType         string `json:"keyType,omitempty" yaml:"keyType,omitempty"`
+	DestinationType string `json:"destinationType,omitempty" yaml:"destinationType,omitempty"`
+	DestinationName string `json:"destinationName,omitempty" yaml:"destinationName,omitempty"`
+	Method          string `json:"method,omitempty" yaml:"method,omitempty"`
+	QoS             string `json:"qoS,omitempty" yaml:"qoS,omitempty"`
+	Persistent      bool   `json:"persistent,omitempty" yaml:"persistent,omitempty"`
+}
+
+// ParameterConstraint represents a constraint checked on a parameter of an operation requests.
+type ParameterConstraint struct {
+	Name            string `json:"name" yaml:"name"`
+	In              string `json:"in" yaml:"in"`
+	Required        bool   `json:"required" yaml:"required"`
+	Recopy          bool   `json:"recopy" yaml:"recopy"`
+	MustMatchRegexp string `json:"mustMatchRegexp,omitempty" yaml:"mustMatchRegexp,omitempty"`
+}
+
+// ResourceType is the kind of contract a Microcks Resource holds.
+type ResourceType string
+
+// Resource types of contracts known by Microcks.
+const (
+	ResourceTypeOpenAPISpec  ResourceType = "OPEN_API_SPEC"
+	ResourceTypeAsyncAPISpec ResourceType = "ASYNC_API_SPEC"
+	ResourceTypeGraphQL      ResourceType = "GRAPHQL_SCHEMA"
+	ResourceTypeProtobuf     ResourceType = "PROTOBUF_SCHEMA"
+	ResourceTypeWSDL         ResourceType = "WSDL"
+)
+
+// Resource represents a contract of a Microcks Service, as imported from an artifact.
+type Resource struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Type      ResourceType `json:"type"`
+	Content   string       `json:"content"`
+	ServiceID string       `json:"serviceId"`
+}
+
+// Secret represents a Microcks Secret usable for testing. Its values are credentials: a username and
+// password, a token sent in TokenHeader (Authorization by default) and a PEM CA certificate.
+type Secret struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	Token       string `json:"token,omitempty"`
+	TokenHeader string `json:"tokenHeader,omitempty"`
+	CaCertPem   string `json:"caCertPem,omitempty"`
+}
+
+func (c *microcksClient) ListServices(ctx context.Context, opts ListOptions) (*Page[Service], error) {
+	return listPage[Service](ctx, c, "Microcks for listing services", "api/services", opts)
+}
+
+func (c *microcksClient) ListSecrets(ctx context.Context, opts ListOptions) (*Page[Secret], error) {
+	// Secrets have no labels.
+	opts.Labels = nil
+	return listPage[Secret](transport.WithSecretBodies(ctx), c, "Microcks for listing secrets", "api/secrets", opts)
+}
+
+func (c *microcksClient) ListTestResults(ctx context.Context, serviceID string, opts ListOptions) (*Page[TestResult], error) {
+	// Tests have no search endpoint.
+	opts.Name, opts.Labels = "", nil
+	return listPage[TestResult](ctx, c, "Microcks for listing tests", "api/tests/service/"+serviceID, opts)
+}
+
+func (c *microcksClient) GetServiceResources(ctx context.Context, serviceID string) ([]Resource, error) {
+	rel := &url.URL{Path: "api/resources/service/" + serviceID}
+	resources := []Resource{}
+	if _, err := c.getJSON(ctx, "Microcks for getting service resources", rel, &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+func (c *microcksClient) GetServiceByRef(ctx context.Context, name string, version string) (*Service, error) {
+	// Escape name and version separately as they may hold spaces or slashes.
+	ref := url.PathEscape(name) + ":" + url.PathEscape(version)
+	rel := &url.URL{Path: "api/services/" + name + ":" + version, RawPath: "api/services/" + ref, RawQuery: "messages=false"}
+	service := &Service{}
+	if _, err := c.getJSON(ctx, "Microcks for getting service", rel, service); err != nil {
+		return nil, err
+	}
+	if service.Name != name || service.Version != version {
+		return nil, fmt.Errorf("%w: Microcks returned service %s:%s when getting %s:%s", ErrNotFound, service.Name, service.Version, name, version)
+	}
+	return service, nil
+}
+
+func (c *microcksClient) GetService(ctx context.Context, serviceID string) (*Service, error) {
+	rel := &url.URL{Path: "api/services/" + serviceID, RawQuery: "messages=false"}
+	service := &Service{}
+	if _, err := c.getJSON(ctx, "Microcks for getting service", rel, service); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// getJSON sends an authenticated GET request to rel and decodes the JSON response into v, returning
+// the response headers.
+func (c *microcksClient) getJSON(ctx context.Context, name string, rel *url.URL, v interface{}) (http.Header, error) {
+	u := c.APIURL.ResolveReference(rel)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
-		fmt.Println("Error parsing JSON in filteredOperations: ", err)
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
+
+	// Name request for logs and dumps.
+	req = transport.Describe(req, name, false)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+
+	body, err := c.cfg.readBody(name, resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, c.cfg.newAPIError(name, resp, body)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, decodeError(name, err)
+	}
+	return resp.Header, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func ensureValidOperationsList(logger *slog.Logger, filteredOperations string) bool {
+	if err := validateOperationsList(filteredOperations); err != nil {
+		logger.Warn("Error parsing JSON in filteredOperations", "error", err)
 		return false
 	}
 	return true
 }
 
-func ensureValidOperationsHeaders(operationsHeaders string) bool {
+func validateOperationsList(filteredOperations string) error {
 	// Unmarshal using a generic interface
-	var headers = map[string][]HeaderDTO{}
-	err := json.Unmarshal([]byte(operationsHeaders), &headers)
-	if err != nil {
-		fmt.Println("Error parsing JSON in operationsHeaders: ", err)
+	var list = []string{}
+	return json.Unmarshal([]byte(filteredOperations), &list)
+}
+
+func ensureValidOperationsHeaders(logger *slog.Logger, operationsHeaders string) bool {
+	if err := validateOperationsHeaders(operationsHeaders); err != nil {
+		logger.Warn("Error parsing JSON in operationsHeaders", "error", err)
 		return false
 	}
 	return true
 }
 
-func ensureValieOAuth2Context(oAuth2Context string) bool {
+func validateOperationsHeaders(operationsHeaders string) error {
+	// Unmarshal using a generic interface
+	var headers = map[string][]HeaderDTO{}
+	return json.Unmarshal([]byte(operationsHeaders), &headers)
+}
+
+func ensureValieOAuth2Context(logger *slog.Logger, oAuth2Context string) bool {
 	var oContext = OAuth2ClientContext{}
 	err := json.Unmarshal([]byte(oAuth2Context), &oContext)
 	if err != nil {
-		fmt.Println("Error parsing JSON in oAuth2Context: ", err)
+		logger.Warn("Error parsing JSON in oAuth2Context", "error", err)
 		return false
 	}
 	if !grantTypeChoices[oContext.GrantType] {
-		fmt.Println("grantType in oAuth2Context is not supported. OAuth2 is turned off.")
+		logger.Warn("grantType in oAuth2Context is not supported. OAuth2 is turned off.")
 		return false
 	}
 	return true
 }
+
+func validateOAuth2Context(oAuth2Context string) error {
+	var oContext = OAuth2ClientContext{}
+	if err := json.Unmarshal([]byte(oAuth2Context), &oContext); err != nil {
+		return err
+	}
+	if !grantTypeChoices[oContext.GrantType] {
+		return fmt.Errorf("grantType '%s' is not supported", oContext.GrantType)
+	}
+	return nil
+}
+
+// IsConnectionError tells if err comes from a connection-level failure (DNS, TCP, TLS, timeout)
+// rather than from an application response of Microcks server. Requests interrupted by the
+// cancellation or deadline of their context are not connection errors, and neither are requests
+// missing from a replayed cassette.
+func IsConnectionError(err error) bool {
+	var urlErr *url.Error
+	var unmatchedErr *transport.UnmatchedRequestError
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &unmatchedErr) {
+		return false
+	}
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,451 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTestResultMalformedResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		call    func(ctx context.Context, mc MicrocksClient) error
+		wantAPI bool
+	}{
+		{
+			name:   "create with invalid JSON",
+			status: http.StatusCreated,
+			body:   `<html>`,
+			call: func(ctx context.Context, mc MicrocksClient) error {
+				_, err := mc.CreateTestResult(ctx, "svc-1", "http://localhost:8080", "HTTP", "", 1000, "", "", "")
+				return err
+			},
+		},
+		{
+			name:   "create with non string id",
+			status: http.StatusCreated,
+			body:   `{"id": 42}`,
+			call: func(ctx context.Context, mc MicrocksClient) error {
+				_, err := mc.CreateTestResult(ctx, "svc-1", "http://localhost:8080", "HTTP", "", 1000, "", "", "")
+				return err
+			},
+		},
+		{
+			name:   "create without id",
+			status: http.StatusCreated,
+			body:   `{}`,
+			call: func(ctx context.Context, mc MicrocksClient) error {
+				_, err := mc.CreateTestResult(ctx, "svc-1", "http://localhost:8080", "HTTP", "", 1000, "", "", "")
+				return err
+			},
+			wantAPI: true,
+		},
+		{
+			name:   "get with invalid JSON",
+			status: http.StatusOK,
+			body:   `{"id": "test-1", "success": "yes"}`,
+			call: func(ctx context.Context, mc MicrocksClient) error {
+				_, err := mc.GetTestResult(ctx, "test-1")
+				return err
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer srv.Close()
+
+			err := test.call(context.Background(), NewMicrocksClient(srv.URL))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var apiErr *APIError
+			if isAPI := errors.As(err, &apiErr); isAPI != test.wantAPI {
+				t.Errorf("error = %v, want APIError: %v", err, test.wantAPI)
+			}
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if !test.wantAPI && !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+				t.Errorf("error = %v, want a wrapped decode error", err)
+			}
+		})
+	}
+}
+
+func TestTestRequestBody(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tests := []struct {
+		name               string
+		serviceID          string
+		testEndpoint       string
+		secretName         string
+		filteredOperations string
+		operationsHeaders  string
+		oAuth2Context      string
+		want               string
+	}{
+		{
+			name:         "required fields only",
+			serviceID:    "Beer Catalog API:0.9",
+			testEndpoint: "http://beers:8080/api",
+			want:         `{"serviceId":"Beer Catalog API:0.9","testEndpoint":"http://beers:8080/api","runnerType":"HTTP","timeout":10000}`,
+		},
+		{
+			name:         "escaped strings",
+			serviceID:    `Quote "API":1.0`,
+			testEndpoint: "http://host/path?a=1&b=\\x\n",
+			secretName:   "tab\tsecret",
+			want:         `{"serviceId":"Quote \"API\":1.0","testEndpoint":"http://host/path?a=1\u0026b=\\x\n","runnerType":"HTTP","timeout":10000,"secretName":"tab\tsecret"}`,
+		},
+		{
+			name:               "JSON options",
+			serviceID:          "Pastry API:2.0",
+			testEndpoint:       "http://pastries",
+			filteredOperations: `["GET /pastries"]`,
+			operationsHeaders:  `{"globals": [{"name": "x-tenant", "values": "acme"}]}`,
+			oAuth2Context:      `{"clientId": "c", "clientSecret": "s", "tokenUri": "http://kc/token", "grantType": "CLIENT_CREDENTIALS"}`,
+			want:               `{"serviceId":"Pastry API:2.0","testEndpoint":"http://pastries","runnerType":"HTTP","timeout":10000,"filteredOperations":["GET /pastries"],"operationsHeaders":{"globals":[{"name":"x-tenant","values":"acme"}]},"oAuth2Context":{"clientId":"c","clientSecret":"s","tokenUri":"http://kc/token","grantType":"CLIENT_CREDENTIALS"}}`,
+		},
+		{
+			name:               "invalid JSON options left out",
+			serviceID:          "Pastry API:2.0",
+			testEndpoint:       "http://pastries",
+			filteredOperations: `["GET /pastries"`,
+			operationsHeaders:  `globals`,
+			oAuth2Context:      `{"grantType": "UNKNOWN"}`,
+			want:               `{"serviceId":"Pastry API:2.0","testEndpoint":"http://pastries","runnerType":"HTTP","timeout":10000}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, err := TestRequestBody(logger, test.serviceID, test.testEndpoint, "HTTP", test.secretName, 10000, test.filteredOperations, test.operationsHeaders, test.oAuth2Context)
+			if err != nil {
+				t.Fatalf("TestRequestBody() error = %v", err)
+			}
+			if string(body) != test.want {
+				t.Errorf("TestRequestBody() = %s, want %s", body, test.want)
+			}
+		})
+	}
+}
+
+func TestCancelInFlightCalls(t *testing.T) {
+	calls := []struct {
+		name string
+		call func(ctx context.Context, mc MicrocksClient) error
+	}{
+		{"create test", func(ctx context.Context, mc MicrocksClient) error {
+			_, err := mc.CreateTestResult(ctx, "Beer Catalog API:0.9", "http://beers", "HTTP", "", 5000, "", "", "")
+			return err
+		}},
+		{"get test", func(ctx context.Context, mc MicrocksClient) error {
+			_, err := mc.GetTestResult(ctx, "65f1")
+			return err
+		}},
+		{"wait for test", func(ctx context.Context, mc MicrocksClient) error {
+			_, err := mc.WaitForTestResult(ctx, "65f1", PollOptions{InitialDelay: -1})
+			return err
+		}},
+		{"upload artifact", func(ctx context.Context, mc MicrocksClient) error {
+			_, err := mc.UploadArtifactContent(ctx, strings.NewReader("openapi: 3.0.0"), "beer.yaml", true)
+			return err
+		}},
+		{"export services", func(ctx context.Context, mc MicrocksClient) error {
+			_, err := mc.ExportServices(ctx, []string{"s1"}, io.Discard)
+			return err
+		}},
+	}
+	stops := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{"cancelled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			return ctx, cancel
+		}, context.Canceled},
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 100*time.Millisecond)
+		}, context.DeadlineExceeded},
+	}
+	for _, call := range calls {
+		for _, stop := range stops {
+			t.Run(call.name+" "+stop.name, func(t *testing.T) {
+				release := make(chan struct{})
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					// Stream the start of the body so that reading it blocks too.
+					w.WriteHeader(http.StatusOK)
+					io.WriteString(w, `{"services": [`)
+					w.(http.Flusher).Flush()
+					select {
+					case <-r.Context().Done():
+					case <-release:
+					}
+				}))
+				defer srv.Close()
+				defer close(release)
+
+				ctx, cancel := stop.ctx()
+				defer cancel()
+				start := time.Now()
+				err := call.call(ctx, NewMicrocksClient(srv.URL))
+				if !errors.Is(err, stop.want) {
+					t.Fatalf("error = %v, want %v", err, stop.want)
+				}
+				if elapsed := time.Since(start); elapsed > 5*time.Second {
+					t.Errorf("call returned after %s", elapsed)
+				}
+			})
+		}
+	}
+}
+
+func TestTestResultRoundTrip(t *testing.T) {
+	tests := []struct {
+		file       string
+		wantCases  int
+		wantSteps  int
+		wantFailed string
+		wantEvents bool
+	}{
+		{file: "test-result-http.json", wantCases: 2, wantSteps: 3, wantFailed: "GET /beer/{name}"},
+		{file: "test-result-async.json", wantCases: 1, wantSteps: 2, wantEvents: true},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var result TestResult
+			if err := json.Unmarshal(payload, &result); err != nil {
+				t.Fatalf("cannot decode %s: %v", test.file, err)
+			}
+			steps := 0
+			var failed []string
+			for _, testCase := range result.TestCaseResults {
+				steps += len(testCase.TestStepResults)
+				if !testCase.Success {
+					failed = append(failed, testCase.OperationName)
+				}
+				for _, step := range testCase.TestStepResults {
+					if test.wantEvents != (len(step.EventMessageName) > 0) || test.wantEvents == (len(step.RequestName) > 0) {
+						t.Errorf("step = %+v, want event %v", step, test.wantEvents)
+					}
+				}
+			}
+			if len(result.TestCaseResults) != test.wantCases || steps != test.wantSteps || strings.Join(failed, ",") != test.wantFailed {
+				t.Errorf("decoded %d test cases, %d steps, failed %q", len(result.TestCaseResults), steps, failed)
+			}
+
+			// Encoded again, fields of the model keep the values of the API, null ones being left out.
+			encoded, err := json.Marshal(result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var original, roundTripped interface{}
+			json.Unmarshal(payload, &original)
+			json.Unmarshal(encoded, &roundTripped)
+			var compare func(path string, got, want interface{})
+			compare = func(path string, got, want interface{}) {
+				switch got := got.(type) {
+				case map[string]interface{}:
+					want, _ := want.(map[string]interface{})
+					for key, value := range got {
+						compare(path+"."+key, value, want[key])
+					}
+				case []interface{}:
+					want, _ := want.([]interface{})
+					if len(got) != len(want) {
+						t.Errorf("%s has %d items, want %d", path, len(got), len(want))
+						return
+					}
+					for i := range got {
+						compare(path+"["+strconv.Itoa(i)+"]", got[i], want[i])
+					}
+				default:
+					if got != want {
+						t.Errorf("%s = %v, want %v", path, got, want)
+					}
+				}
+			}
+			compare("result", roundTripped, original)
+		})
+	}
+}
+
+func TestServiceShapes(t *testing.T) {
+	tests := []struct {
+		file string
+		want Service
+	}{
+		{file: "service-rest.json", want: Service{
+			ID: "65e0a1b2c3d4e5f607182930", Name: "Beer Catalog API", Version: "0.9", Type: ServiceTypeREST,
+			SourceArtifact: "beer-catalog-api-openapi.yaml",
+			Metadata: &ServiceMetadata{CreatedOn: 1709214012345, LastUpdate: 1710425399001, Annotations: map[string]string{},
+				Labels: map[string]string{"domain": "beers", "status": "stable"}},
+			Operations: []Operation{
+				{Name: "GET /beer", Method: "GET", Dispatcher: "URI_PARAMS", DispatcherRules: "page",
+					ResourcePaths: []string{"/beer?page=0", "/beer?page=1"}},
+				{Name: "GET /beer/{name}", Method: "GET", Dispatcher: "URI_PARTS", DispatcherRules: "name", DefaultDelay: 100,
+					ResourcePaths: []string{"/beer/Rodenbach", "/beer/Westmalle%20Triple"},
+					ParameterConstraints: []ParameterConstraint{
+						{Name: "Accept-Language", In: "header", Required: true, MustMatchRegexp: "^(en|fr)$"},
+					}},
+			},
+		}},
+		{file: "service-event.json", want: Service{
+			ID: "65e0a1b2c3d4e5f607183040", Name: "User signed-up API", Version: "0.1.1", Type: ServiceTypeEvent,
+			SourceArtifact: "user-signedup-asyncapi.yaml",
+			Metadata:       &ServiceMetadata{CreatedOn: 1709214012999, LastUpdate: 1709214012999, Annotations: map[string]string{}, Labels: map[string]string{}},
+			Operations: []Operation{
+				{Name: "SUBSCRIBE user/signedup", Method: "SUBSCRIBE", DefaultDelay: 3000, ResourcePaths: []string{},
+					Bindings: map[string]Binding{
+						"KAFKA": {Type: "KAFKA", KeyType: "string"},
+						"MQTT":  {Type: "MQTT", QoS: "1", Persistent: true},
+					}},
+			},
+		}},
+		{file: "service-grpc.json", want: Service{
+			ID: "65e0a1b2c3d4e5f607183150", Name: "org.acme.petstore.v1.PetstoreService", Version: "v1", XmlNS: "org.acme.petstore.v1",
+			Type: ServiceTypeGRPC, SourceArtifact: "petstore-v1.proto",
+			Metadata: &ServiceMetadata{CreatedOn: 1709214013500, LastUpdate: 1709214013500, Annotations: map[string]string{},
+				Labels: map[string]string{"domain": "pets"}},
+			Operations: []Operation{
+				{Name: "getPets", Method: "POST", InputName: ".org.acme.petstore.v1.Empty", OutputName: ".org.acme.petstore.v1.PetsResponse",
+					ResourcePaths: []string{}},
+				{Name: "searchPets", Method: "POST", InputName: ".org.acme.petstore.v1.PetSearchRequest", OutputName: ".org.acme.petstore.v1.PetsResponse",
+					Dispatcher: "QUERY_ARGS", DispatcherRules: "name", ResourcePaths: []string{}},
+			},
+		}},
+		{file: "service-graphql.json", want: Service{
+			ID: "65e0a1b2c3d4e5f607183260", Name: "Movie Graph API", Version: "1.0", Type: ServiceTypeGraphQL,
+			SourceArtifact: "films.graphql",
+			Metadata:       &ServiceMetadata{CreatedOn: 1709214014000, LastUpdate: 1709214014000, Annotations: map[string]string{}, Labels: map[string]string{}},
+			Operations: []Operation{
+				{Name: "allFilms", Method: "QUERY", OutputName: "FilmsConnection", ResourcePaths: []string{}},
+				{Name: "addStar", Method: "MUTATION", InputName: "String", OutputName: "Film", Dispatcher: "QUERY_ARGS", DispatcherRules: "filmId",
+					ResourcePaths: []string{}},
+			},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(payload)
+			}))
+			defer srv.Close()
+
+			got, err := NewMicrocksClient(srv.URL+"/api/").GetServiceByRef(context.Background(), test.want.Name, test.want.Version)
+			if err != nil {
+				t.Fatalf("GetServiceByRef() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("decoded service:\n%+v\nwant:\n%+v", *got, test.want)
+			}
+		})
+	}
+}
+
+func TestTLSConfigIsolation(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     func(srv *httptest.Server) *tls.Config
+		wantErr bool
+	}{
+		{
+			name: "trusted CA",
+			tls: func(srv *httptest.Server) *tls.Config {
+				pool := x509.NewCertPool()
+				pool.AddCert(srv.Certificate())
+				return &tls.Config{RootCAs: pool}
+			},
+		},
+		{
+			name:    "untrusted CA",
+			tls:     func(srv *httptest.Server) *tls.Config { return &tls.Config{RootCAs: x509.NewCertPool()} },
+			wantErr: true,
+		},
+		{
+			name: "insecure",
+			tls:  func(srv *httptest.Server) *tls.Config { return &tls.Config{InsecureSkipVerify: true} },
+		},
+		{
+			name:    "default",
+			tls:     func(srv *httptest.Server) *tls.Config { return nil },
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			// Clients are built and used concurrently, each against its own server.
+			t.Parallel()
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `{"id":"1","name":"Beer Catalog API","version":"0.9"}`)
+			}))
+			defer srv.Close()
+			srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+			mc := NewMicrocksClient(srv.URL, WithTLSConfig(test.tls(srv)))
+			for i := 0; i < 5; i++ {
+				_, err := mc.GetServiceByRef(context.Background(), "Beer Catalog API", "0.9")
+				var unknownAuthority x509.UnknownAuthorityError
+				var verification *tls.CertificateVerificationError
+				if test.wantErr && !errors.As(err, &unknownAuthority) && !errors.As(err, &verification) {
+					t.Fatalf("GetServiceByRef() error = %v, want a certificate verification error", err)
+				}
+				if !test.wantErr && err != nil {
+					t.Fatalf("GetServiceByRef() error = %v", err)
+				}
+			}
+		})
+	}
+	t.Cleanup(func() {
+		if config := http.DefaultTransport.(*http.Transport).TLSClientConfig; config != nil && (config.RootCAs != nil || config.InsecureSkipVerify) {
+			t.Errorf("default transport was changed to %+v", config)
+		}
+	})
+}
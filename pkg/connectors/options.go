@@ -0,0 +1,109 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+)
+
+// Option configures the MicrocksClient built by NewMicrocksClient.
+type Option func(*microcksClient)
+
+// AuthProvider provides the OAuth token sent as bearer with the authenticated requests to Microcks.
+// Token is called before every request, allowing implementations to refresh expired tokens.
+type AuthProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is an AuthProvider always returning the same token.
+type StaticToken string
+
+// Token implements AuthProvider for StaticToken.
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// WithConfig sets the configuration of the client. It replaces the whole configuration so options
+// setting part of it, like WithTLSConfig or WithLogger, must come after.
+func WithConfig(cfg Config) Option {
+	return func(c *microcksClient) {
+		c.cfg = cfg
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send requests. The client is copied and its
+// transport, if any, replaces the one built from the TLS configuration.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *microcksClient) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the RoundTripper requests are finally sent to, replacing the one built from
+// the TLS configuration or given with WithHTTPClient. Headers, dumps, retries, rate limiting and
+// timing are still applied on top of it, as middlewares of the transport package.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *microcksClient) {
+		c.transport = rt
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to Microcks.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *microcksClient) {
+		c.cfg.TLSConfig = tlsConfig
+	}
+}
+
+// WithAuthProvider sets the provider of the token sent with authenticated requests.
+// It replaces any token set using SetOAuthToken.
+func WithAuthProvider(provider AuthProvider) Option {
+	return func(c *microcksClient) {
+		c.auth = provider
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with requests (default to the one of this CLI).
+func WithUserAgent(userAgent string) Option {
+	return func(c *microcksClient) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithLogger sets the logger of API exchanges and client warnings (default to warnings on stderr).
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *microcksClient) {
+		c.cfg.Logger = logger
+	}
+}
+
+// WithLogHandler sets the handler of API exchanges logs and client warnings, allowing to plug
+// the logging library of embedding applications. Dumps are logged at LevelTrace.
+func WithLogHandler(handler slog.Handler) Option {
+	return func(c *microcksClient) {
+		c.cfg.Logger = slog.New(handler)
+	}
+}
+
+// WithBaseURL sets the Microcks API URL, overriding the one given to NewMicrocksClient.
+func WithBaseURL(apiURL string) Option {
+	return func(c *microcksClient) {
+		c.baseURL = apiURL
+	}
+}
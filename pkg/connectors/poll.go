@@ -0,0 +1,340 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrWaitTimeout is returned by WaitForTestResult, with the last known result, when the test is
+// still in progress at the end of PollOptions.Timeout.
+var ErrWaitTimeout = errors.New("test still in progress at the end of wait")
+
+// ErrTestCaseFailed is returned by WaitForTestResult, with the last known result, when PollOptions.FailFast
+// is set and a test case of the test in progress failed.
+var ErrTestCaseFailed = errors.New("test case failed while test in progress")
+
+// Clock provides the time to polling, allowing tests to fake it.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Names of the poll strategies, see ParsePollStrategy.
+const (
+	PollFixed    = "fixed"
+	PollAdaptive = "adaptive"
+	// PollBackoff is another name of PollAdaptive.
+	PollBackoff = "backoff"
+)
+
+// PollStrategy schedules the polls of a test in progress. It may keep state, so a strategy must be
+// used by a single wait.
+type PollStrategy interface {
+	// Next returns the delay before the next poll. changed tells if the last poll observed a change
+	// of the test status since the previous one.
+	Next(changed bool) time.Duration
+}
+
+// ParsePollStrategy returns a new strategy from its name: nil for PollFixed, meaning the Interval,
+// Backoff and MaxInterval of PollOptions, and an AdaptivePoll for PollAdaptive, PollBackoff or an empty
+// name.
+func ParsePollStrategy(name string) (PollStrategy, error) {
+	switch name {
+	case PollFixed:
+		return nil, nil
+	case "", PollAdaptive, PollBackoff:
+		return &AdaptivePoll{}, nil
+	}
+	return nil, fmt.Errorf("unknown poll strategy '%s' (one of: %s, %s)", name, PollAdaptive, PollFixed)
+}
+
+// DefaultPollSteps are the first delays of an AdaptivePoll without Steps: short tests complete after a
+// few quick polls.
+var DefaultPollSteps = []time.Duration{time.Second, 2 * time.Second, 5 * time.Second}
+
+// AdaptivePoll waits for each of Steps in turn between the first polls. The delay then grows
+// geometrically by Factor, up to MaxInterval, while the test status does not change, and goes back to
+// the last step after any change. Delays are spread randomly by Jitter so that parallel jobs waiting
+// for their tests don't poll in step.
+type AdaptivePoll struct {
+	// Steps are the delays between the first polls. Empty means DefaultPollSteps.
+	Steps []time.Duration
+	// MaxInterval caps the growth of the delay. 0 means 30 seconds, or the last step if longer.
+	MaxInterval time.Duration
+	// Factor multiplies the delay while the test is unchanged. Values up to 1 mean 1.5.
+	Factor float64
+	// Jitter is the maximum ratio of the delay randomly added or removed. 0 means 0.2, a negative
+	// value means no jitter.
+	Jitter float64
+	// Rand returns random numbers in [0, 1) for the jitter. Nil means math/rand.
+	Rand func() float64
+
+	polls   int
+	current time.Duration
+}
+
+func (p *AdaptivePoll) Next(changed bool) time.Duration {
+	steps, maxInterval, factor, jitter := p.Steps, p.MaxInterval, p.Factor, p.Jitter
+	if len(steps) == 0 {
+		steps = DefaultPollSteps
+	}
+	if maxInterval <= 0 {
+		maxInterval = 30 * time.Second
+	}
+	maxInterval = max(maxInterval, steps[len(steps)-1])
+	if factor <= 1 {
+		factor = 1.5
+	}
+	if jitter == 0 {
+		jitter = 0.2
+	}
+
+	switch {
+	case p.polls < len(steps):
+		p.current = steps[p.polls]
+	case changed:
+		p.current = steps[len(steps)-1]
+	default:
+		p.current = min(time.Duration(float64(p.current)*factor), maxInterval)
+	}
+	p.polls++
+	if jitter < 0 {
+		return p.current
+	}
+	random := rand.Float64
+	if p.Rand != nil {
+		random = p.Rand
+	}
+	// Spread the delay in [current * (1 - jitter), current * (1 + jitter)).
+	return time.Duration(float64(p.current) * (1 + jitter*(2*random()-1))).Round(time.Millisecond)
+}
+
+// backoffPoll implements the Interval, Backoff and MaxInterval of PollOptions, ignoring changes.
+type backoffPoll struct {
+	interval    time.Duration
+	backoff     float64
+	maxInterval time.Duration
+	polls       int
+}
+
+func (p *backoffPoll) Next(changed bool) time.Duration {
+	if p.polls > 0 && p.backoff > 1 {
+		p.interval = time.Duration(float64(p.interval) * p.backoff)
+		if p.maxInterval > 0 && p.interval > p.maxInterval {
+			p.interval = p.maxInterval
+		}
+	}
+	p.polls++
+	return p.interval
+}
+
+// PollOptions defines how WaitForTestResult polls a test. Its zero value waits 1 second before
+// the first poll then polls every 2 seconds with no other limit than the context.
+type PollOptions struct {
+	// InitialDelay is the delay before the first poll, letting Microcks start the test.
+	// 0 means 1 second, a negative value means no delay.
+	InitialDelay time.Duration
+	// Interval is the delay between polls. 0 means 2 seconds.
+	Interval time.Duration
+	// Backoff multiplies Interval after each poll. Values up to 1 keep it constant.
+	Backoff float64
+	// MaxInterval caps the growth of Interval by Backoff. 0 means no cap.
+	MaxInterval time.Duration
+	// Strategy schedules the polls instead of Interval, Backoff and MaxInterval when set.
+	Strategy PollStrategy
+	// Timeout bounds the time spent polling after the initial delay. 0 means no limit.
+	Timeout time.Duration
+	// FetchFull asks for the complete result, with test cases, once the test is completed.
+	// Otherwise, only the fields of TestResultSummary are set.
+	FetchFull bool
+	// OnPoll is called with the status got by every poll and the delay before the next one,
+	// 0 if the test is completed.
+	OnPoll func(summary *TestResultSummary, next time.Duration)
+	// OnTestCase is called once for every test case, as soon as a poll finds it completed. Setting it
+	// makes every poll get the complete result instead of the lightweight status.
+	OnTestCase func(testCase TestCaseResult)
+	// FailFast stops polling as soon as a test case fails, without waiting for the test to complete.
+	// Setting it makes every poll get the complete result instead of the lightweight status.
+	FailFast bool
+	// Clock provides the time. Nil means the system clock.
+	Clock Clock
+}
+
+func (c *microcksClient) WaitForTestResult(ctx context.Context, testResultID string, opts PollOptions) (*TestResult, error) {
+	return PollTestResult(ctx, c, testResultID, opts)
+}
+
+// PollTestResult implements WaitForTestResult on top of the GetTestResult and GetFullTestResult methods
+// of mc, making it available to any MicrocksClient implementation. Errors of the API are returned as is,
+// ErrWaitTimeout if the test is still in progress at the end of timeout and the context error if it is
+// done while waiting.
+func PollTestResult(ctx context.Context, mc MicrocksClient, testResultID string, opts PollOptions) (*TestResult, error) {
+	clock := opts.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	strategy := opts.Strategy
+	if strategy == nil {
+		interval := opts.Interval
+		if interval <= 0 {
+			interval = 2 * time.Second
+		}
+		strategy = &backoffPoll{interval: interval, backoff: opts.Backoff, maxInterval: opts.MaxInterval}
+	}
+	initialDelay := opts.InitialDelay
+	if initialDelay == 0 {
+		initialDelay = time.Second
+	}
+	if initialDelay > 0 {
+		if err := wait(ctx, clock, initialDelay); err != nil {
+			return nil, err
+		}
+	}
+
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = clock.Now().Add(opts.Timeout)
+	}
+	var previous *TestResultSummary
+	// Test cases are identified by their operation, the ones already given to OnTestCase are skipped.
+	reported := map[string]bool{}
+	for {
+		summary, result, err := pollTestResult(ctx, mc, testResultID, opts.OnTestCase != nil || opts.FailFast)
+		if err != nil {
+			return nil, err
+		}
+		changed := previous != nil && *summary != *previous
+		failed := false
+		if result != nil {
+			for _, testCase := range result.TestCaseResults {
+				failed = failed || !testCase.Success
+				if !reported[testCase.OperationName] {
+					reported[testCase.OperationName], changed = true, true
+					if opts.OnTestCase != nil {
+						opts.OnTestCase(testCase)
+					}
+				}
+			}
+		}
+		if opts.FailFast && failed && summary.InProgress {
+			if opts.OnPoll != nil {
+				opts.OnPoll(summary, 0)
+			}
+			return result, ErrTestCaseFailed
+		}
+		if !summary.InProgress {
+			if opts.OnPoll != nil {
+				opts.OnPoll(summary, 0)
+			}
+			switch {
+			case result != nil:
+				return result, nil
+			case opts.FetchFull:
+				return mc.GetFullTestResult(ctx, testResultID)
+			}
+			return summary.testResult(), nil
+		}
+
+		next := strategy.Next(changed)
+		previous = summary
+		// Long delays must not go much beyond the timeout.
+		if !deadline.IsZero() {
+			if left := deadline.Sub(clock.Now()); left > 0 && next > left {
+				next = left
+			}
+		}
+		if opts.OnPoll != nil {
+			opts.OnPoll(summary, next)
+		}
+		if err := wait(ctx, clock, next); err != nil {
+			return nil, err
+		}
+		if !deadline.IsZero() && !clock.Now().Before(deadline) {
+			if result != nil {
+				return result, ErrWaitTimeout
+			}
+			return summary.testResult(), ErrWaitTimeout
+		}
+	}
+}
+
+// pollTestResult gets the status of a test, and its complete result if full is set.
+func pollTestResult(ctx context.Context, mc MicrocksClient, testResultID string, full bool) (*TestResultSummary, *TestResult, error) {
+	if !full {
+		summary, err := mc.GetTestResult(ctx, testResultID)
+		return summary, nil, err
+	}
+	result, err := mc.GetFullTestResult(ctx, testResultID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.summary(), result, nil
+}
+
+// wait pauses for d on clock, returning early with the context error if ctx is done before.
+func wait(ctx context.Context, clock Clock, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-clock.After(d):
+		return nil
+	}
+}
+
+// summary returns the TestResultSummary of r.
+func (r *TestResult) summary() *TestResultSummary {
+	return &TestResultSummary{
+		ID:             r.ID,
+		Version:        r.Version,
+		TestNumber:     r.TestNumber,
+		TestDate:       r.TestDate,
+		TestedEndpoint: r.TestedEndpoint,
+		ServiceID:      r.ServiceID,
+		ElapsedTime:    int32(r.ElapsedTime),
+		Success:        r.Success,
+		InProgress:     r.InProgress,
+	}
+}
+
+// testResult returns a TestResult holding the fields of summary.
+func (s *TestResultSummary) testResult() *TestResult {
+	return &TestResult{
+		ID:             s.ID,
+		Version:        s.Version,
+		TestNumber:     s.TestNumber,
+		TestDate:       s.TestDate,
+		TestedEndpoint: s.TestedEndpoint,
+		ServiceID:      s.ServiceID,
+		ElapsedTime:    int64(s.ElapsedTime),
+		Success:        s.Success,
+		InProgress:     s.InProgress,
+	}
+}
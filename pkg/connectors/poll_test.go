@@ -0,0 +1,233 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAdaptivePoll(t *testing.T) {
+	const s = time.Second
+	tests := []struct {
+		name    string
+		poll    AdaptivePoll
+		changes []bool
+		want    []time.Duration
+	}{
+		{
+			name:    "default steps then growth",
+			poll:    AdaptivePoll{Jitter: -1},
+			changes: []bool{false, false, false, false, false, false},
+			want:    []time.Duration{1 * s, 2 * s, 5 * s, 7500 * time.Millisecond, 11250 * time.Millisecond, 16875 * time.Millisecond},
+		},
+		{
+			name:    "capped growth",
+			poll:    AdaptivePoll{Jitter: -1, MaxInterval: 10 * s, Factor: 2},
+			changes: []bool{false, false, false, false, false},
+			want:    []time.Duration{1 * s, 2 * s, 5 * s, 10 * s, 10 * s},
+		},
+		{
+			name:    "changes go back to last step",
+			poll:    AdaptivePoll{Jitter: -1, Factor: 2},
+			changes: []bool{true, false, false, false, true, false},
+			want:    []time.Duration{1 * s, 2 * s, 5 * s, 10 * s, 5 * s, 10 * s},
+		},
+		{
+			name:    "single step longer than max",
+			poll:    AdaptivePoll{Jitter: -1, Steps: []time.Duration{time.Minute}},
+			changes: []bool{false, false},
+			want:    []time.Duration{time.Minute, time.Minute},
+		},
+		{
+			name:    "jitter bounds",
+			poll:    AdaptivePoll{Jitter: 0.5, Rand: func() float64 { return 0 }},
+			changes: []bool{false, false},
+			want:    []time.Duration{500 * time.Millisecond, 1 * s},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			poll := test.poll
+			for i, changed := range test.changes {
+				if got := poll.Next(changed); got != test.want[i] {
+					t.Errorf("Next() #%d = %s, want %s", i+1, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParsePollStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		adaptive bool
+		wantErr  bool
+	}{
+		{"", true, false},
+		{PollAdaptive, true, false},
+		{PollBackoff, true, false},
+		{PollFixed, false, false},
+		{"exponential", false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strategy, err := ParsePollStrategy(test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParsePollStrategy(%q) error = %v", test.name, err)
+			}
+			if _, ok := strategy.(*AdaptivePoll); ok != test.adaptive {
+				t.Errorf("ParsePollStrategy(%q) = %T", test.name, strategy)
+			}
+		})
+	}
+}
+
+// fakeClock is a Clock whose waits return at once, recording their durations.
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// scriptedClient serves the results of script, one per poll, the last one being repeated.
+type scriptedClient struct {
+	MicrocksClient
+	script []*TestResult
+	err    error
+	polls  int
+}
+
+func (c *scriptedClient) GetFullTestResult(ctx context.Context, testResultID string) (*TestResult, error) {
+	c.polls++
+	if c.err != nil && c.polls > 1 {
+		return nil, c.err
+	}
+	return c.script[min(c.polls, len(c.script))-1], nil
+}
+
+func (c *scriptedClient) GetTestResult(ctx context.Context, testResultID string) (*TestResultSummary, error) {
+	result, err := c.GetFullTestResult(ctx, testResultID)
+	if err != nil {
+		return nil, err
+	}
+	return result.summary(), nil
+}
+
+func TestPollTestResult(t *testing.T) {
+	const s = time.Second
+	inProgress := &TestResult{ID: "t1", InProgress: true}
+	failing := &TestResult{ID: "t1", InProgress: true, TestCaseResults: []TestCaseResult{{OperationName: "GET /beer", Success: false}}}
+	passed := &TestResult{ID: "t1", Success: true}
+	errAPI := &APIError{Name: "Microcks for getting test", StatusCode: 500}
+	tests := []struct {
+		name      string
+		opts      PollOptions
+		script    []*TestResult
+		err       error
+		want      *TestResult
+		wantErr   error
+		wantPolls int
+		wantWaits []time.Duration
+	}{
+		{
+			name:      "default schedule",
+			script:    []*TestResult{inProgress, inProgress, passed},
+			want:      passed,
+			wantPolls: 3,
+			wantWaits: []time.Duration{1 * s, 2 * s, 2 * s},
+		},
+		{
+			name:      "no initial delay",
+			opts:      PollOptions{InitialDelay: -1, Interval: 500 * time.Millisecond},
+			script:    []*TestResult{inProgress, passed},
+			want:      passed,
+			wantPolls: 2,
+			wantWaits: []time.Duration{500 * time.Millisecond},
+		},
+		{
+			name:      "capped backoff",
+			opts:      PollOptions{InitialDelay: -1, Interval: 1 * s, Backoff: 2, MaxInterval: 3 * s},
+			script:    []*TestResult{inProgress, inProgress, inProgress, inProgress, passed},
+			want:      passed,
+			wantPolls: 5,
+			wantWaits: []time.Duration{1 * s, 2 * s, 3 * s, 3 * s},
+		},
+		{
+			name:      "timeout",
+			opts:      PollOptions{Interval: 2 * s, Timeout: 5 * s},
+			script:    []*TestResult{inProgress},
+			want:      inProgress,
+			wantErr:   ErrWaitTimeout,
+			wantPolls: 3,
+			wantWaits: []time.Duration{1 * s, 2 * s, 2 * s, 1 * s},
+		},
+		{
+			name:      "API failure",
+			opts:      PollOptions{Timeout: time.Minute},
+			script:    []*TestResult{inProgress},
+			err:       errAPI,
+			wantErr:   errAPI,
+			wantPolls: 2,
+			wantWaits: []time.Duration{1 * s, 2 * s},
+		},
+		{
+			name:      "fail fast",
+			opts:      PollOptions{FailFast: true},
+			script:    []*TestResult{inProgress, failing, passed},
+			want:      failing,
+			wantErr:   ErrTestCaseFailed,
+			wantPolls: 2,
+			wantWaits: []time.Duration{1 * s, 2 * s},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			mc := &scriptedClient{script: test.script, err: test.err}
+			opts := test.opts
+			opts.Clock = clock
+
+			got, err := PollTestResult(context.Background(), mc, "t1", opts)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("PollTestResult() error = %v, want %v", err, test.wantErr)
+			}
+			if test.want != nil && (got == nil || got.Success != test.want.Success || got.InProgress != test.want.InProgress || len(got.TestCaseResults) != len(test.want.TestCaseResults)) {
+				t.Errorf("PollTestResult() = %+v, want %+v", got, test.want)
+			}
+			if mc.polls != test.wantPolls {
+				t.Errorf("got %d polls, want %d", mc.polls, test.wantPolls)
+			}
+			if !reflect.DeepEqual(clock.waits, test.wantWaits) {
+				t.Errorf("waited %v, want %v", clock.waits, test.wantWaits)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/microcks/microcks-cli/pkg/transport"
+)
+
+func (c *microcksClient) CreateSecret(ctx context.Context, secret Secret) (string, error) {
+	secret.ID = ""
+	body, err := c.sendSecret(ctx, "POST", "Microcks for creating secret", &url.URL{Path: "api/secrets"}, &secret, http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+func (c *microcksClient) UpdateSecret(ctx context.Context, secret Secret) error {
+	rel := &url.URL{Path: "api/secrets/" + secret.ID}
+	_, err := c.sendSecret(ctx, "PUT", "Microcks for updating secret", rel, &secret, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+func (c *microcksClient) DeleteSecret(ctx context.Context, id string) error {
+	rel := &url.URL{Path: "api/secrets/" + id}
+	_, err := c.sendSecret(ctx, "DELETE", "Microcks for deleting secret", rel, nil, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+// sendSecret sends an authenticated request to rel with secret as JSON body, if not nil, and returns
+// the response body if its status is one of expected. Neither body is ever dumped.
+func (c *microcksClient) sendSecret(ctx context.Context, method string, name string, rel *url.URL, secret *Secret, expected ...int) ([]byte, error) {
+	u := c.APIURL.ResolveReference(rel)
+
+	var input io.Reader
+	if secret != nil {
+		data, err := json.Marshal(secret)
+		if err != nil {
+			return nil, err
+		}
+		input = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(transport.WithSecretBodies(ctx), method, u.String(), input)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
+
+	// Name request for logs.
+	req = transport.Describe(req, name, false)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+
+	body, err := c.cfg.readBody(name, resp)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return body, nil
+		}
+	}
+	return nil, c.cfg.newAPIError(name, resp, body)
+}
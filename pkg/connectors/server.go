@@ -0,0 +1,70 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ServerInfo describes the version and the optional features of a Microcks server.
+type ServerInfo struct {
+	// Version is the version reported by Microcks, eg. 1.9.0 or 1.10.0-SNAPSHOT. It is empty for
+	// servers too old to report it.
+	Version string `json:"version,omitempty"`
+	// Features holds the configuration of optional features by name, eg. "async-api": {"enabled": "true"}.
+	Features map[string]map[string]string `json:"features,omitempty"`
+}
+
+// FeatureEnabled tells if the optional feature name is enabled on the server.
+func (i *ServerInfo) FeatureEnabled(name string) bool {
+	return strings.EqualFold(i.Features[name]["enabled"], "true")
+}
+
+func (c *microcksClient) GetServerInfo(ctx context.Context) (*ServerInfo, error) {
+	c.infoMutex.Lock()
+	defer c.infoMutex.Unlock()
+	if c.info != nil {
+		return c.info, nil
+	}
+
+	var versionInfo struct {
+		VersionID string `json:"versionId"`
+	}
+	rel := &url.URL{Path: "api/version/info"}
+	if _, err := c.getJSON(ctx, "Microcks for getting version", rel, &versionInfo); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	// Values of features config are mostly strings but may be booleans or numbers.
+	var features map[string]map[string]interface{}
+	rel = &url.URL{Path: "api/features/config"}
+	if _, err := c.getJSON(ctx, "Microcks for getting features config", rel, &features); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	info := &ServerInfo{Version: versionInfo.VersionID, Features: map[string]map[string]string{}}
+	for name, properties := range features {
+		info.Features[name] = map[string]string{}
+		for property, value := range properties {
+			info.Features[name][property] = fmt.Sprint(value)
+		}
+	}
+	c.info = info
+	return info, nil
+}
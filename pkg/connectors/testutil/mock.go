@@ -0,0 +1,290 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package testutil provides fakes of connectors interfaces for the tests of tools embedding them.
+package testutil
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// MockMicrocksClient is a connectors.MicrocksClient calling the function field matching each method.
+// Methods whose function is nil return zero values, empty results for GetTestResult, GetFullTestResult,
+// GetServerInfo, GetServiceResources and lists, an empty array for CopyTestCaseMessages and an empty
+// object for ExportServices.
+// GetServiceByRef returns a service with the requested name and version by default, GetService a service
+// with the requested identifier.
+// WaitForTestResult polls GetTestResult by default, use a fake Clock in PollOptions to avoid sleeping.
+// Calls are recorded by method name, making it usable from concurrent goroutines:
+//
+//	mc := &testutil.MockMicrocksClient{
+//		UploadArtifactFunc: func(ctx context.Context, path string, mainArtifact bool) (string, error) {
+//			return "Beer Catalog API:0.9", nil
+//		},
+//	}
+//	runImport(ctx, mc)
+//	if mc.CallCount("UploadArtifact") != 1 {
+//		t.Errorf("expected one upload")
+//	}
+type MockMicrocksClient struct {
+	GetKeycloakURLFunc        func(ctx context.Context) (string, error)
+	CreateTestResultFunc      func(ctx context.Context, serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error)
+	GetTestResultFunc         func(ctx context.Context, testResultID string) (*connectors.TestResultSummary, error)
+	GetFullTestResultFunc     func(ctx context.Context, testResultID string) (*connectors.TestResult, error)
+	CopyTestCaseMessagesFunc  func(ctx context.Context, testResult *connectors.TestResult, operationName string, w io.Writer) (int64, error)
+	WaitForTestResultFunc     func(ctx context.Context, testResultID string, opts connectors.PollOptions) (*connectors.TestResult, error)
+	UploadArtifactFunc        func(ctx context.Context, specificationFilePath string, mainArtifact bool) (string, error)
+	UploadArtifactContentFunc func(ctx context.Context, r io.Reader, filename string, mainArtifact bool) (string, error)
+	DownloadArtifactFunc      func(ctx context.Context, artifactURL string, mainArtifact bool, secretName string) (string, error)
+	UpdateServiceLabelsFunc   func(ctx context.Context, serviceRef string, labels map[string]string) error
+	ListServicesFunc          func(ctx context.Context, opts connectors.ListOptions) (*connectors.Page[connectors.Service], error)
+	GetServiceByRefFunc       func(ctx context.Context, name string, version string) (*connectors.Service, error)
+	GetServiceFunc            func(ctx context.Context, serviceID string) (*connectors.Service, error)
+	ListTestResultsFunc       func(ctx context.Context, serviceID string, opts connectors.ListOptions) (*connectors.Page[connectors.TestResult], error)
+	ListSecretsFunc           func(ctx context.Context, opts connectors.ListOptions) (*connectors.Page[connectors.Secret], error)
+	GetServiceResourcesFunc   func(ctx context.Context, serviceID string) ([]connectors.Resource, error)
+	GetServerInfoFunc         func(ctx context.Context) (*connectors.ServerInfo, error)
+	ExportServicesFunc        func(ctx context.Context, serviceIDs []string, w io.Writer) (int64, error)
+	ImportSnapshotFunc        func(ctx context.Context, r io.Reader, filename string) error
+	CreateSecretFunc          func(ctx context.Context, secret connectors.Secret) (string, error)
+	UpdateSecretFunc          func(ctx context.Context, secret connectors.Secret) error
+	DeleteSecretFunc          func(ctx context.Context, id string) error
+
+	mutex      sync.Mutex
+	calls      []string
+	oauthToken string
+}
+
+var _ connectors.MicrocksClient = (*MockMicrocksClient)(nil)
+
+func (m *MockMicrocksClient) record(method string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls = append(m.calls, method)
+}
+
+// Calls returns the names of the methods called so far, in call order.
+func (m *MockMicrocksClient) Calls() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+// CallCount returns the number of calls to method.
+func (m *MockMicrocksClient) CallCount(method string) int {
+	count := 0
+	for _, call := range m.Calls() {
+		if call == method {
+			count++
+		}
+	}
+	return count
+}
+
+// OAuthToken returns the last token given to SetOAuthToken.
+func (m *MockMicrocksClient) OAuthToken() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.oauthToken
+}
+
+func (m *MockMicrocksClient) GetKeycloakURL(ctx context.Context) (string, error) {
+	m.record("GetKeycloakURL")
+	if m.GetKeycloakURLFunc == nil {
+		return "", nil
+	}
+	return m.GetKeycloakURLFunc(ctx)
+}
+
+func (m *MockMicrocksClient) SetOAuthToken(oauthToken string) {
+	m.record("SetOAuthToken")
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.oauthToken = oauthToken
+}
+
+func (m *MockMicrocksClient) CreateTestResult(ctx context.Context, serviceID string, testEndpoint string, runnerType string, secretName string, timeout int64, filteredOperations string, operationsHeaders string, oAuth2Context string) (string, error) {
+	m.record("CreateTestResult")
+	if m.CreateTestResultFunc == nil {
+		return "", nil
+	}
+	return m.CreateTestResultFunc(ctx, serviceID, testEndpoint, runnerType, secretName, timeout, filteredOperations, operationsHeaders, oAuth2Context)
+}
+
+func (m *MockMicrocksClient) GetTestResult(ctx context.Context, testResultID string) (*connectors.TestResultSummary, error) {
+	m.record("GetTestResult")
+	if m.GetTestResultFunc == nil {
+		return &connectors.TestResultSummary{}, nil
+	}
+	return m.GetTestResultFunc(ctx, testResultID)
+}
+
+func (m *MockMicrocksClient) GetFullTestResult(ctx context.Context, testResultID string) (*connectors.TestResult, error) {
+	m.record("GetFullTestResult")
+	if m.GetFullTestResultFunc == nil {
+		return &connectors.TestResult{}, nil
+	}
+	return m.GetFullTestResultFunc(ctx, testResultID)
+}
+
+func (m *MockMicrocksClient) CopyTestCaseMessages(ctx context.Context, testResult *connectors.TestResult, operationName string, w io.Writer) (int64, error) {
+	m.record("CopyTestCaseMessages")
+	if m.CopyTestCaseMessagesFunc == nil {
+		n, err := io.WriteString(w, "[]")
+		return int64(n), err
+	}
+	return m.CopyTestCaseMessagesFunc(ctx, testResult, operationName, w)
+}
+
+func (m *MockMicrocksClient) WaitForTestResult(ctx context.Context, testResultID string, opts connectors.PollOptions) (*connectors.TestResult, error) {
+	m.record("WaitForTestResult")
+	if m.WaitForTestResultFunc == nil {
+		return connectors.PollTestResult(ctx, m, testResultID, opts)
+	}
+	return m.WaitForTestResultFunc(ctx, testResultID, opts)
+}
+
+func (m *MockMicrocksClient) UploadArtifact(ctx context.Context, specificationFilePath string, mainArtifact bool) (string, error) {
+	m.record("UploadArtifact")
+	if m.UploadArtifactFunc == nil {
+		return "", nil
+	}
+	return m.UploadArtifactFunc(ctx, specificationFilePath, mainArtifact)
+}
+
+func (m *MockMicrocksClient) UploadArtifactContent(ctx context.Context, r io.Reader, filename string, mainArtifact bool) (string, error) {
+	m.record("UploadArtifactContent")
+	if m.UploadArtifactContentFunc == nil {
+		return "", nil
+	}
+	return m.UploadArtifactContentFunc(ctx, r, filename, mainArtifact)
+}
+
+func (m *MockMicrocksClient) DownloadArtifact(ctx context.Context, artifactURL string, mainArtifact bool, secretName string) (string, error) {
+	m.record("DownloadArtifact")
+	if m.DownloadArtifactFunc == nil {
+		return "", nil
+	}
+	return m.DownloadArtifactFunc(ctx, artifactURL, mainArtifact, secretName)
+}
+
+func (m *MockMicrocksClient) UpdateServiceLabels(ctx context.Context, serviceRef string, labels map[string]string) error {
+	m.record("UpdateServiceLabels")
+	if m.UpdateServiceLabelsFunc == nil {
+		return nil
+	}
+	return m.UpdateServiceLabelsFunc(ctx, serviceRef, labels)
+}
+
+func (m *MockMicrocksClient) ListServices(ctx context.Context, opts connectors.ListOptions) (*connectors.Page[connectors.Service], error) {
+	m.record("ListServices")
+	if m.ListServicesFunc == nil {
+		return &connectors.Page[connectors.Service]{Total: -1}, nil
+	}
+	return m.ListServicesFunc(ctx, opts)
+}
+
+func (m *MockMicrocksClient) GetServiceByRef(ctx context.Context, name string, version string) (*connectors.Service, error) {
+	m.record("GetServiceByRef")
+	if m.GetServiceByRefFunc == nil {
+		return &connectors.Service{Name: name, Version: version}, nil
+	}
+	return m.GetServiceByRefFunc(ctx, name, version)
+}
+
+func (m *MockMicrocksClient) GetService(ctx context.Context, serviceID string) (*connectors.Service, error) {
+	m.record("GetService")
+	if m.GetServiceFunc == nil {
+		return &connectors.Service{ID: serviceID}, nil
+	}
+	return m.GetServiceFunc(ctx, serviceID)
+}
+
+func (m *MockMicrocksClient) ListTestResults(ctx context.Context, serviceID string, opts connectors.ListOptions) (*connectors.Page[connectors.TestResult], error) {
+	m.record("ListTestResults")
+	if m.ListTestResultsFunc == nil {
+		return &connectors.Page[connectors.TestResult]{Total: -1}, nil
+	}
+	return m.ListTestResultsFunc(ctx, serviceID, opts)
+}
+
+func (m *MockMicrocksClient) ListSecrets(ctx context.Context, opts connectors.ListOptions) (*connectors.Page[connectors.Secret], error) {
+	m.record("ListSecrets")
+	if m.ListSecretsFunc == nil {
+		return &connectors.Page[connectors.Secret]{Total: -1}, nil
+	}
+	return m.ListSecretsFunc(ctx, opts)
+}
+
+func (m *MockMicrocksClient) GetServiceResources(ctx context.Context, serviceID string) ([]connectors.Resource, error) {
+	m.record("GetServiceResources")
+	if m.GetServiceResourcesFunc == nil {
+		return []connectors.Resource{}, nil
+	}
+	return m.GetServiceResourcesFunc(ctx, serviceID)
+}
+
+func (m *MockMicrocksClient) CreateSecret(ctx context.Context, secret connectors.Secret) (string, error) {
+	m.record("CreateSecret")
+	if m.CreateSecretFunc == nil {
+		return "", nil
+	}
+	return m.CreateSecretFunc(ctx, secret)
+}
+
+func (m *MockMicrocksClient) UpdateSecret(ctx context.Context, secret connectors.Secret) error {
+	m.record("UpdateSecret")
+	if m.UpdateSecretFunc == nil {
+		return nil
+	}
+	return m.UpdateSecretFunc(ctx, secret)
+}
+
+func (m *MockMicrocksClient) DeleteSecret(ctx context.Context, id string) error {
+	m.record("DeleteSecret")
+	if m.DeleteSecretFunc == nil {
+		return nil
+	}
+	return m.DeleteSecretFunc(ctx, id)
+}
+
+func (m *MockMicrocksClient) ImportSnapshot(ctx context.Context, r io.Reader, filename string) error {
+	m.record("ImportSnapshot")
+	if m.ImportSnapshotFunc == nil {
+		return nil
+	}
+	return m.ImportSnapshotFunc(ctx, r, filename)
+}
+
+func (m *MockMicrocksClient) GetServerInfo(ctx context.Context) (*connectors.ServerInfo, error) {
+	m.record("GetServerInfo")
+	if m.GetServerInfoFunc == nil {
+		return &connectors.ServerInfo{}, nil
+	}
+	return m.GetServerInfoFunc(ctx)
+}
+
+func (m *MockMicrocksClient) ExportServices(ctx context.Context, serviceIDs []string, w io.Writer) (int64, error) {
+	m.record("ExportServices")
+	if m.ExportServicesFunc == nil {
+		n, err := io.WriteString(w, "{}")
+		return int64(n), err
+	}
+	return m.ExportServicesFunc(ctx, serviceIDs, w)
+}
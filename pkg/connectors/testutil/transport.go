@@ -0,0 +1,117 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RecordedRequest is a request captured by RecordingTransport.
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+}
+
+// CannedResponse is a response served by RecordingTransport.
+type CannedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// RecordingTransport is an http.RoundTripper recording requests and answering them with canned
+// responses, matched on method and path. Unmatched requests get a 404 response. Use it with
+// connectors.WithTransport to test the requests sent by a client without a server:
+//
+//	rt := &testutil.RecordingTransport{}
+//	rt.Handle("GET", "/api/keycloak/config", testutil.CannedResponse{StatusCode: 200, Body: `{"enabled":false}`})
+//	mc := connectors.NewMicrocksClient("http://microcks.example.com/api", connectors.WithTransport(rt))
+//	mc.GetKeycloakURL(ctx)
+//	if rt.Requests()[0].Header.Get("User-Agent") == "" {
+//		t.Errorf("expected a User-Agent")
+//	}
+type RecordingTransport struct {
+	mutex     sync.Mutex
+	responses map[string]CannedResponse
+	requests  []RecordedRequest
+}
+
+var _ http.RoundTripper = (*RecordingTransport)(nil)
+
+// Handle registers resp as the response to requests matching method and path.
+func (t *RecordingTransport) Handle(method string, path string, resp CannedResponse) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.responses == nil {
+		t.responses = map[string]CannedResponse{}
+	}
+	t.responses[method+" "+path] = resp
+}
+
+// RoundTrip implements http.RoundTripper for RecordingTransport.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded := RecordedRequest{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery, Header: req.Header.Clone()}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		recorded.Body = body
+	}
+
+	t.mutex.Lock()
+	t.requests = append(t.requests, recorded)
+	canned, ok := t.responses[req.Method+" "+req.URL.Path]
+	t.mutex.Unlock()
+
+	if !ok {
+		canned = CannedResponse{StatusCode: http.StatusNotFound, Body: `{"message":"no canned response"}`}
+	}
+	header := canned.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if body := strings.TrimSpace(canned.Body); header.Get("Content-Type") == "" && (strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[")) {
+		header.Set("Content-Type", "application/json")
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", canned.StatusCode, http.StatusText(canned.StatusCode)),
+		StatusCode:    canned.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewBufferString(canned.Body)),
+		ContentLength: int64(len(canned.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Requests returns the requests recorded so far, in order.
+func (t *RecordingTransport) Requests() []RecordedRequest {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]RecordedRequest(nil), t.requests...)
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sizer is implemented by readers knowing the size of their content, like the ones returned by SizedReader.
+type sizer interface {
+	Size() int64
+}
+
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+func (r sizedReader) Size() int64 {
+	return r.size
+}
+
+// SizedReader returns r announcing size as the exact size of its content. UploadArtifactContent
+// then sends a Content-Length instead of a chunked body. Size of *bytes.Reader, *strings.Reader,
+// *bytes.Buffer and regular *os.File is already known and doesn't require this hint.
+func SizedReader(r io.Reader, size int64) io.Reader {
+	return sizedReader{Reader: r, size: size}
+}
+
+// artifactUpload is the multipart body of an artifact upload, streaming content between a
+// prefix and a suffix built upfront so that the body length is known when content size is.
+type artifactUpload struct {
+	prefix      []byte
+	suffix      []byte
+	content     io.Reader
+	size        int64
+	contentType string
+
+	seeker io.Seeker
+	offset int64
+}
+
+// newArtifactUpload prepares the upload of content as filename.
+func newArtifactUpload(content io.Reader, filename string, mainArtifact bool) (*artifactUpload, error) {
+	if len(filename) == 0 {
+		return nil, errors.New("filename of artifact content is required")
+	}
+	// Line breaks cannot be escaped in the part header, quotes and backslashes are by multipart.
+	filename = strings.NewReplacer("\r", "", "\n", "").Replace(filename)
+
+	upload := &artifactUpload{content: content, size: contentSize(content)}
+	out := &switchWriter{w: &bytes.Buffer{}}
+	writer := multipart.NewWriter(out)
+	if _, err := writer.CreateFormFile("file", filename); err != nil {
+		return nil, err
+	}
+	upload.prefix = out.switchTo(&bytes.Buffer{})
+
+	// Add the mainArtifact flag to request.
+	if err := writer.WriteField("mainArtifact", strconv.FormatBool(mainArtifact)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	upload.suffix = out.switchTo(nil)
+	upload.contentType = writer.FormDataContentType()
+	return upload, nil
+}
+
+// reader returns the multipart body.
+func (u *artifactUpload) reader() io.Reader {
+	return io.MultiReader(bytes.NewReader(u.prefix), u.content, bytes.NewReader(u.suffix))
+}
+
+// length returns the length of the multipart body, -1 if unknown.
+func (u *artifactUpload) length() int64 {
+	if u.size < 0 {
+		return -1
+	}
+	return int64(len(u.prefix)) + u.size + int64(len(u.suffix))
+}
+
+// rewindable makes sure content can be sent again using rewind, buffering it in memory if not seekable.
+func (u *artifactUpload) rewindable() error {
+	r := u.content
+	if sized, ok := r.(sizedReader); ok {
+		r = sized.Reader
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			u.seeker, u.offset = seeker, offset
+			return nil
+		}
+	}
+	content, err := io.ReadAll(u.content)
+	if err != nil {
+		return err
+	}
+	reader := bytes.NewReader(content)
+	u.content, u.size, u.seeker, u.offset = reader, int64(len(content)), reader, 0
+	return nil
+}
+
+// rewind moves content back to where it was when rewindable was called.
+func (u *artifactUpload) rewind() error {
+	_, err := u.seeker.Seek(u.offset, io.SeekStart)
+	return err
+}
+
+// contentSize returns the number of bytes left in r if known, -1 otherwise.
+func contentSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case sizer:
+		return v.Size()
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// switchWriter writes to a buffer that can be switched, allowing to capture parts of a multipart body.
+type switchWriter struct {
+	w *bytes.Buffer
+}
+
+func (sw *switchWriter) Write(p []byte) (int, error) {
+	return sw.w.Write(p)
+}
+
+// switchTo returns the bytes written so far and writes the next ones to w.
+func (sw *switchWriter) switchTo(w *bytes.Buffer) []byte {
+	written := sw.w.Bytes()
+	sw.w = w
+	return written
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadArtifactContent(t *testing.T) {
+	large := bytes.Repeat([]byte("openapi: 3.0.0\n"), 1<<19)
+	tests := []struct {
+		name         string
+		content      func() io.Reader
+		filename     string
+		want         []byte
+		wantFilename string
+		wantLength   bool
+		wantErr      bool
+	}{
+		{
+			name:       "large reader of known size",
+			content:    func() io.Reader { return bytes.NewReader(large) },
+			filename:   "beer.yaml",
+			want:       large,
+			wantLength: true,
+		},
+		{
+			name:       "large reader with size hint",
+			content:    func() io.Reader { return SizedReader(struct{ io.Reader }{bytes.NewReader(large)}, int64(len(large))) },
+			filename:   "beer.yaml",
+			want:       large,
+			wantLength: true,
+		},
+		{
+			name:     "large reader of unknown size",
+			content:  func() io.Reader { return struct{ io.Reader }{bytes.NewReader(large)} },
+			filename: "beer.yaml",
+			want:     large,
+		},
+		{
+			name:       "empty reader",
+			content:    func() io.Reader { return strings.NewReader("") },
+			filename:   "beer.yaml",
+			want:       []byte{},
+			wantLength: true,
+		},
+		{
+			name:     "empty reader of unknown size",
+			content:  func() io.Reader { return struct{ io.Reader }{strings.NewReader("")} },
+			filename: "beer.yaml",
+			want:     []byte{},
+		},
+		{
+			name:         "quotes in filename",
+			content:      func() io.Reader { return strings.NewReader("openapi: 3.0.0") },
+			filename:     `beer "catalog".yaml`,
+			want:         []byte("openapi: 3.0.0"),
+			wantFilename: `beer "catalog".yaml`,
+			wantLength:   true,
+		},
+		{
+			name:         "unicode filename",
+			content:      func() io.Reader { return strings.NewReader("openapi: 3.0.0") },
+			filename:     "pâtisserie API.yaml",
+			want:         []byte("openapi: 3.0.0"),
+			wantFilename: "pâtisserie API.yaml",
+			wantLength:   true,
+		},
+		{
+			name:         "line breaks in filename",
+			content:      func() io.Reader { return strings.NewReader("openapi: 3.0.0") },
+			filename:     "beer\r\nX-Injected: true.yaml",
+			want:         []byte("openapi: 3.0.0"),
+			wantFilename: "beerX-Injected: true.yaml",
+			wantLength:   true,
+		},
+		{
+			name:    "no filename",
+			content: func() io.Reader { return strings.NewReader("openapi: 3.0.0") },
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []byte
+			var filename, mainArtifact string
+			var length int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				length = r.ContentLength
+				file, header, err := r.FormFile("file")
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				defer file.Close()
+				got, _ = io.ReadAll(file)
+				filename, mainArtifact = header.Filename, r.FormValue("mainArtifact")
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "Beer Catalog API:0.9")
+			}))
+			defer srv.Close()
+
+			serviceRef, err := NewMicrocksClient(srv.URL).UploadArtifactContent(context.Background(), test.content(), test.filename, true)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("UploadArtifactContent() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if serviceRef != "Beer Catalog API:0.9" {
+				t.Errorf("UploadArtifactContent() = %q", serviceRef)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("uploaded %d bytes, want %d", len(got), len(test.want))
+			}
+			wantFilename := test.wantFilename
+			if len(wantFilename) == 0 {
+				wantFilename = test.filename
+			}
+			if filename != wantFilename || mainArtifact != "true" {
+				t.Errorf("uploaded filename %q, mainArtifact %q", filename, mainArtifact)
+			}
+			if (length >= 0) != test.wantLength {
+				t.Errorf("Content-Length = %d, want known %v", length, test.wantLength)
+			}
+		})
+	}
+}
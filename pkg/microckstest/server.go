@@ -0,0 +1,812 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package microckstest provides an in-memory fake of Microcks APIs, and of the Keycloak realm securing
+// them, for the tests of tools built on the connectors package:
+//
+//	srv := microckstest.NewServer(microckstest.WithKeycloak("client", "secret"))
+//	defer srv.Close()
+//	svc := srv.AddService(connectors.Service{Name: "Beer Catalog API", Version: "0.9", Type: "REST"})
+//	srv.ScriptTest("Beer Catalog API:0.9", microckstest.TestScript{InProgressPolls: 2, Success: true})
+//	mc := connectors.NewMicrocksClient(srv.URL)
+//
+// It only depends on the standard library and on connectors models.
+package microckstest
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microcks/microcks-cli/pkg/connectors"
+)
+
+// realm is the name of the fake Keycloak realm.
+const realm = "microcks"
+
+// TestScript describes the lifecycle of the tests launched on a service: they stay in progress
+// for InProgressPolls polls, a test case of TestCaseResults being completed at each one, then
+// complete with Success and all TestCaseResults. Messages holds the JSON array of messages
+// exchanged for each operation, an empty array being served for the other ones.
+type TestScript struct {
+	InProgressPolls int
+	Success         bool
+	TestCaseResults []connectors.TestCaseResult
+	Messages        map[string]string
+}
+
+// Upload is an artifact uploaded to the server.
+type Upload struct {
+	Filename     string
+	MainArtifact bool
+	Content      []byte
+	// Compressed tells if the upload was gzip encoded.
+	Compressed bool
+	// URL is the location Microcks was asked to download the artifact from, with the secret named
+	// SecretName, Filename and Content being empty.
+	URL        string
+	SecretName string
+}
+
+// Option configures the Server built by NewServer.
+type Option func(*Server)
+
+// WithKeycloak enables authentication: Microcks reports a Keycloak realm served by the fake, whose
+// token endpoint grants a token to clientID and clientSecret. Authenticated APIs then require it.
+func WithKeycloak(clientID string, clientSecret string) Option {
+	return func(s *Server) {
+		s.clientID, s.clientSecret = clientID, clientSecret
+		s.token = "token-" + clientID
+	}
+}
+
+// WithToken requires token as bearer of authenticated API calls, without enabling Keycloak.
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithoutGzipUploads makes the server reject gzip encoded uploads with 415 Unsupported Media Type.
+func WithoutGzipUploads() Option {
+	return func(s *Server) {
+		s.rejectGzip = true
+	}
+}
+
+// WithServerInfo makes the server report the version and features configuration of info. Without it,
+// the server does not report its version, like Microcks servers older than the version API, and has
+// no features configuration.
+func WithServerInfo(info connectors.ServerInfo) Option {
+	return func(s *Server) {
+		s.info = info
+	}
+}
+
+// Server is a fake Microcks server. Its URL is the one to give to connectors or to the --microcksURL
+// flag of the CLI. Its methods are safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	clientID     string
+	clientSecret string
+	token        string
+	rejectGzip   bool
+	info         connectors.ServerInfo
+
+	mu        sync.Mutex
+	services  []connectors.Service
+	metadata  map[string]connectors.ServiceMetadata
+	secrets   []connectors.Secret
+	artifacts map[string]string
+	resources map[string][]connectors.Resource
+	scripts   map[string]TestScript
+	tests     []*fakeTest
+	uploads   []Upload
+	imports   []Upload
+	failures  map[string]int
+}
+
+// fakeTest is a launched test with the script it follows.
+type fakeTest struct {
+	result connectors.TestResult
+	script TestScript
+	polls  int
+}
+
+// NewServer starts a fake server. Close it at the end of test.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		metadata:  map[string]connectors.ServiceMetadata{},
+		artifacts: map[string]string{},
+		resources: map[string][]connectors.Resource{},
+		scripts:   map[string]TestScript{},
+		failures:  map[string]int{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/keycloak/config", s.handleKeycloakConfig)
+	mux.HandleFunc("/api/version/info", s.handleVersionInfo)
+	mux.HandleFunc("/api/features/config", s.handleFeaturesConfig)
+	mux.HandleFunc("/auth/realms/"+realm+"/protocol/openid-connect/token", s.handleToken)
+	mux.HandleFunc("/api/services", s.authenticated(s.handleServices))
+	mux.HandleFunc("/api/services/", s.authenticated(s.handleService))
+	mux.HandleFunc("/api/services/search", s.authenticated(s.handleSearchServices))
+	mux.HandleFunc("/api/resources/service/", s.authenticated(s.handleServiceResources))
+	mux.HandleFunc("/api/secrets", s.authenticated(s.handleSecrets))
+	mux.HandleFunc("/api/secrets/", s.authenticated(s.handleSecret))
+	mux.HandleFunc("/api/secrets/search", s.authenticated(s.handleSearchSecrets))
+	mux.HandleFunc("/api/tests", s.authenticated(s.handleCreateTest))
+	mux.HandleFunc("/api/tests/", s.authenticated(s.handleTest))
+	mux.HandleFunc("/api/artifact/upload", s.authenticated(s.handleUpload))
+	mux.HandleFunc("/api/artifact/download", s.authenticated(s.handleDownload))
+	mux.HandleFunc("/api/export", s.authenticated(s.handleExport))
+	mux.HandleFunc("/api/import", s.authenticated(s.handleImport))
+	s.Server = httptest.NewServer(s.failing(mux))
+	return s
+}
+
+// Token returns the token granted by the fake Keycloak, empty if authentication is disabled.
+func (s *Server) Token() string {
+	return s.token
+}
+
+// AddService registers service, assigning it an identifier if it has none, and returns it.
+func (s *Server) AddService(service connectors.Service) connectors.Service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addService(service)
+}
+
+func (s *Server) addService(service connectors.Service) connectors.Service {
+	for i, existing := range s.services {
+		if existing.Name == service.Name && existing.Version == service.Version {
+			service.ID = existing.ID
+			s.services[i] = service
+			s.setMetadata(service)
+			return service
+		}
+	}
+	if len(service.ID) == 0 {
+		service.ID = "service-" + strconv.Itoa(len(s.services)+1)
+	}
+	s.services = append(s.services, service)
+	s.setMetadata(service)
+	return service
+}
+
+// setMetadata keeps the metadata of service, if any, so that they can be updated.
+func (s *Server) setMetadata(service connectors.Service) {
+	if service.Metadata != nil {
+		s.metadata[service.ID] = *service.Metadata
+	}
+}
+
+// AddSecret registers secret, assigning it an identifier if it has none.
+func (s *Server) AddSecret(secret connectors.Secret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(secret.ID) == 0 {
+		secret.ID = "secret-" + strconv.Itoa(len(s.secrets)+1)
+	}
+	s.secrets = append(s.secrets, secret)
+}
+
+// AddArtifact makes the upload of an artifact named filename, or its download when filename is a URL,
+// discover service, registered if needed. Uploads of unknown artifacts are rejected with 400 Bad Request,
+// like unparseable ones by Microcks.
+func (s *Server) AddArtifact(filename string, service connectors.Service) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.artifacts[filename] = service.Name + ":" + service.Version
+	s.addService(service)
+}
+
+// AddResource registers a contract of the service identified by name:version, like the OpenAPI
+// specification it was imported from. The service must have been added before.
+func (s *Server) AddResource(serviceRef string, resource connectors.Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	service, _ := s.findService(serviceRef)
+	if len(resource.ID) == 0 {
+		resource.ID = "resource-" + strconv.Itoa(len(s.resources[service.ID])+1)
+	}
+	resource.ServiceID = service.ID
+	s.resources[service.ID] = append(s.resources[service.ID], resource)
+}
+
+// ScriptTest sets the lifecycle of tests launched on the service identified by name:version.
+// Without script, tests complete successfully at first poll.
+func (s *Server) ScriptTest(serviceRef string, script TestScript) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[serviceRef] = script
+}
+
+// ScriptEndpointTest sets the lifecycle of tests launched on the service identified by name:version
+// against endpoint, taking precedence over the script of the service.
+func (s *Server) ScriptEndpointTest(serviceRef string, endpoint string, script TestScript) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[serviceRef+" "+endpoint] = script
+}
+
+// FailRequests makes the server answer the requests to path with method by status and a JSON error,
+// like a Microcks failing to handle them, instead of serving them.
+func (s *Server) FailRequests(method string, path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method+" "+path] = status
+}
+
+// Tests returns the current state of launched tests.
+func (s *Server) Tests() []connectors.TestResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]connectors.TestResult, 0, len(s.tests))
+	for _, test := range s.tests {
+		results = append(results, test.result)
+	}
+	return results
+}
+
+// Uploads returns the artifacts uploaded so far, in order.
+func (s *Server) Uploads() []Upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Upload(nil), s.uploads...)
+}
+
+// Imports returns the snapshots imported on the server, in order.
+func (s *Server) Imports() []Upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Upload(nil), s.imports...)
+}
+
+// Secrets returns the secrets of the server, with their values.
+func (s *Server) Secrets() []connectors.Secret {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]connectors.Secret(nil), s.secrets...)
+}
+
+// Labels returns the labels of the service identified by name:version.
+func (s *Server) Labels(serviceRef string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if service, ok := s.findService(serviceRef); ok {
+		return s.metadata[service.ID].Labels
+	}
+	return nil
+}
+
+// findService looks a service up by identifier or name:version.
+func (s *Server) findService(ref string) (connectors.Service, bool) {
+	for _, service := range s.services {
+		if service.ID == ref || service.Name+":"+service.Version == ref {
+			return service, true
+		}
+	}
+	return connectors.Service{}, false
+}
+
+// authenticated wraps handler to require the bearer token if any.
+func (s *Server) failing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status, failing := s.failures[r.Method+" "+r.URL.Path]
+		s.mu.Unlock()
+		if failing {
+			writeError(w, status, "Request failed as scripted")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.token) > 0 && r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeError(w, http.StatusUnauthorized, "Full authentication is required to access this resource")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (s *Server) handleKeycloakConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled":         len(s.clientID) > 0,
+		"auth-server-url": s.URL + "/auth",
+		"realm":           realm,
+	})
+}
+
+func (s *Server) handleVersionInfo(w http.ResponseWriter, r *http.Request) {
+	if len(s.info.Version) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"versionId": s.info.Version, "buildTimestamp": "2024-01-01T00:00:00Z"})
+}
+
+func (s *Server) handleFeaturesConfig(w http.ResponseWriter, r *http.Request) {
+	features := s.info.Features
+	if features == nil {
+		features = map[string]map[string]string{}
+	}
+	writeJSON(w, http.StatusOK, features)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	expected := base64.StdEncoding.EncodeToString([]byte(s.clientID + ":" + s.clientSecret))
+	if r.Method != http.MethodPost || len(s.clientID) == 0 || r.Header.Get("Authorization") != "Basic "+expected {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized_client", "error_description": "Invalid client credentials"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": s.token, "token_type": "Bearer", "expires_in": 300})
+}
+
+func (s *Server) handleServices(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Microcks lists services with their metadata.
+	services := make([]connectors.Service, len(s.services))
+	for i, service := range s.services {
+		if metadata, ok := s.metadata[service.ID]; ok {
+			service.Metadata = &metadata
+		}
+		services[i] = service
+	}
+	writePage(w, r, services)
+}
+
+func (s *Server) handleSecrets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method == http.MethodPost {
+		var secret connectors.Secret
+		if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		secret.ID = "secret-" + strconv.Itoa(len(s.secrets)+1)
+		s.secrets = append(s.secrets, secret)
+		writeJSON(w, http.StatusCreated, secret)
+		return
+	}
+	writePage(w, r, s.secrets)
+}
+
+func (s *Server) handleServiceResources(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resources := s.resources[strings.TrimPrefix(r.URL.Path, "/api/resources/service/")]
+	if resources == nil {
+		resources = []connectors.Resource{}
+	}
+	writeJSON(w, http.StatusOK, resources)
+}
+
+// handleExport writes the snapshot of the services whose identifiers are serviceIds query parameters,
+// with their resources, ignoring unknown identifiers like Microcks does.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := struct {
+		Services  []connectors.Service  `json:"services"`
+		Resources []connectors.Resource `json:"resources"`
+	}{Services: []connectors.Service{}, Resources: []connectors.Resource{}}
+	for _, id := range r.URL.Query()["serviceIds"] {
+		for _, service := range s.services {
+			if service.ID == id {
+				snapshot.Services = append(snapshot.Services, service)
+				snapshot.Resources = append(snapshot.Resources, s.resources[id]...)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleImport registers the services of the snapshot uploaded as file part, with their resources,
+// replacing the services having the same name and version.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Required request part 'file' is not present")
+		return
+	}
+	defer file.Close()
+	upload := Upload{Filename: header.Filename}
+	if upload.Content, err = io.ReadAll(file); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var snapshot struct {
+		Services  []connectors.Service  `json:"services"`
+		Resources []connectors.Resource `json:"resources"`
+	}
+	if err := json.Unmarshal(upload.Content, &snapshot); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.imports = append(s.imports, upload)
+	for _, service := range snapshot.Services {
+		var resources []connectors.Resource
+		for _, resource := range snapshot.Resources {
+			if resource.ServiceID == service.ID {
+				resources = append(resources, resource)
+			}
+		}
+		service = s.addService(service)
+		for i := range resources {
+			resources[i].ServiceID = service.ID
+		}
+		s.resources[service.ID] = resources
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleSecret updates or deletes the secret identified by the path.
+func (s *Server) handleSecret(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := strings.TrimPrefix(r.URL.Path, "/api/secrets/")
+	for i, secret := range s.secrets {
+		if secret.ID != id {
+			continue
+		}
+		switch r.Method {
+		case http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&secret); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			secret.ID = id
+			s.secrets[i] = secret
+			writeJSON(w, http.StatusOK, secret)
+		case http.MethodDelete:
+			s.secrets = append(s.secrets[:i], s.secrets[i+1:]...)
+			w.WriteHeader(http.StatusOK)
+		default:
+			writeJSON(w, http.StatusOK, secret)
+		}
+		return
+	}
+	writeError(w, http.StatusNotFound, "Secret "+id+" does not exist")
+}
+
+func (s *Server) handleService(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref := strings.TrimPrefix(r.URL.Path, "/api/services/")
+	if id, ok := strings.CutSuffix(ref, "/metadata"); ok && r.Method == http.MethodPut {
+		service, found := s.findService(id)
+		if !found {
+			writeError(w, http.StatusNotFound, "Service "+id+" does not exist")
+			return
+		}
+		var metadata connectors.ServiceMetadata
+		if err := json.NewDecoder(r.Body).Decode(&metadata); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.metadata[service.ID] = metadata
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	service, found := s.findService(ref)
+	if !found {
+		writeError(w, http.StatusNotFound, "Service "+ref+" does not exist")
+		return
+	}
+	metadata := s.metadata[service.ID]
+	service.Metadata = &metadata
+	writeJSON(w, http.StatusOK, service)
+}
+
+// handleSearchServices selects services by name, ignoring case, and labels like Microcks does.
+func (s *Server) handleSearchServices(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query := r.URL.Query()
+	services := []connectors.Service{}
+	for _, service := range s.services {
+		if !strings.Contains(strings.ToLower(service.Name), strings.ToLower(query.Get("name"))) {
+			continue
+		}
+		matching := true
+		for param := range query {
+			if label, ok := strings.CutPrefix(param, "labels."); ok && s.metadata[service.ID].Labels[label] != query.Get(param) {
+				matching = false
+			}
+		}
+		if matching {
+			services = append(services, service)
+		}
+	}
+	writeJSON(w, http.StatusOK, services)
+}
+
+// handleSearchSecrets selects secrets by name, ignoring case, like Microcks does.
+func (s *Server) handleSearchSecrets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets := []connectors.Secret{}
+	for _, secret := range s.secrets {
+		if strings.Contains(strings.ToLower(secret.Name), strings.ToLower(r.URL.Query().Get("name"))) {
+			secrets = append(secrets, secret)
+		}
+	}
+	writeJSON(w, http.StatusOK, secrets)
+}
+
+func (s *Server) handleCreateTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	var request struct {
+		ServiceID    string `json:"serviceId"`
+		TestEndpoint string `json:"testEndpoint"`
+		RunnerType   string `json:"runnerType"`
+		Timeout      int64  `json:"timeout"`
+		SecretName   string `json:"secretName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	service, found := s.findService(request.ServiceID)
+	if !found {
+		writeError(w, http.StatusNotFound, "Service "+request.ServiceID+" does not exist")
+		return
+	}
+	script, scripted := s.scripts[service.Name+":"+service.Version+" "+request.TestEndpoint]
+	if !scripted {
+		script, scripted = s.scripts[service.Name+":"+service.Version]
+	}
+	if !scripted {
+		script = TestScript{Success: true}
+	}
+	test := &fakeTest{script: script, result: connectors.TestResult{
+		ID:             "test-" + strconv.Itoa(len(s.tests)+1),
+		Version:        1,
+		TestNumber:     int32(len(s.tests) + 1),
+		TestDate:       time.Now().UnixMilli(),
+		TestedEndpoint: request.TestEndpoint,
+		ServiceID:      service.ID,
+		RunnerType:     request.RunnerType,
+		Timeout:        request.Timeout,
+		InProgress:     true,
+		// Microcks always reports test cases, possibly empty.
+		TestCaseResults: []connectors.TestCaseResult{},
+	}}
+	if len(request.SecretName) > 0 {
+		test.result.SecretRef = &connectors.SecretRef{Name: request.SecretName}
+	}
+	s.tests = append(s.tests, test)
+	writeJSON(w, http.StatusCreated, test.result)
+}
+
+func (s *Server) handleTest(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := strings.TrimPrefix(r.URL.Path, "/api/tests/")
+	if serviceID, found := strings.CutPrefix(id, "service/"); found {
+		// Microcks lists the tests of a service, the most recent first.
+		var results []connectors.TestResult
+		for i := len(s.tests) - 1; i >= 0; i-- {
+			if s.tests[i].result.ServiceID == serviceID {
+				results = append(results, s.tests[i].result)
+			}
+		}
+		writePage(w, r, results)
+		return
+	}
+	id, testCaseID, messages := strings.Cut(id, "/messages/")
+	if !messages {
+		id, testCaseID, messages = strings.Cut(id, "/events/")
+	}
+	for _, test := range s.tests {
+		if test.result.ID != id {
+			continue
+		}
+		if messages {
+			s.handleTestCaseMessages(w, test, testCaseID)
+			return
+		}
+		if test.result.InProgress {
+			test.polls++
+			// Test cases complete one per poll while the test is in progress.
+			if completed := test.polls; completed < len(test.script.TestCaseResults) {
+				test.result.TestCaseResults = test.script.TestCaseResults[:completed]
+			}
+			if test.polls > test.script.InProgressPolls {
+				test.result.InProgress = false
+				test.result.Success = test.script.Success
+				if test.script.TestCaseResults != nil {
+					test.result.TestCaseResults = test.script.TestCaseResults
+				}
+				test.result.ElapsedTime = time.Now().UnixMilli() - test.result.TestDate
+			}
+		}
+		writeJSON(w, http.StatusOK, test.result)
+		return
+	}
+	writeError(w, http.StatusNotFound, "TestResult "+id+" does not exist")
+}
+
+// handleTestCaseMessages serves the scripted messages of a test case, whose identifier is made of the
+// test identifier, number and operation name.
+func (s *Server) handleTestCaseMessages(w http.ResponseWriter, test *fakeTest, testCaseID string) {
+	prefix := test.result.ID + "-" + strconv.Itoa(int(test.result.TestNumber)) + "-"
+	if !strings.HasPrefix(testCaseID, prefix) {
+		writeError(w, http.StatusNotFound, "TestCase "+testCaseID+" does not exist")
+		return
+	}
+	messages, ok := test.script.Messages[strings.TrimPrefix(testCaseID, prefix)]
+	if !ok {
+		messages = "[]"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, messages)
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	upload := Upload{}
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		if s.rejectGzip {
+			writeError(w, http.StatusUnsupportedMediaType, "Content-Encoding gzip is not supported")
+			return
+		}
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		body, upload.Compressed = gz, true
+	}
+
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	form, err := multipart.NewReader(body, params["boundary"]).ReadForm(32 << 20)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer form.RemoveAll()
+	files := form.File["file"]
+	if len(files) != 1 {
+		writeError(w, http.StatusBadRequest, "Required request part 'file' is not present")
+		return
+	}
+	file, err := files[0].Open()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	if upload.Content, err = io.ReadAll(file); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	upload.Filename = files[0].Filename
+	if values := form.Value["mainArtifact"]; len(values) > 0 {
+		upload.MainArtifact, _ = strconv.ParseBool(values[0])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads = append(s.uploads, upload)
+	serviceRef, found := s.artifacts[upload.Filename]
+	if !found {
+		http.Error(w, "Exception while parsing artifact "+upload.Filename, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, serviceRef)
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	upload := Upload{URL: r.PostForm.Get("url"), SecretName: r.PostForm.Get("secretName")}
+	if len(upload.URL) == 0 {
+		writeError(w, http.StatusBadRequest, "Required parameter 'url' is not present")
+		return
+	}
+	upload.MainArtifact, _ = strconv.ParseBool(r.PostForm.Get("mainArtifact"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads = append(s.uploads, upload)
+	serviceRef, found := s.artifacts[upload.URL]
+	if !found {
+		http.Error(w, "Exception while retrieving remote item "+upload.URL, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, serviceRef)
+}
+
+// writePage writes the page of items selected by page and size query parameters, with their total count.
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size <= 0 {
+		size = 20
+	}
+	start := page * size
+	if start > len(items) || start < 0 {
+		start = len(items)
+	}
+	end := start + size
+	if end > len(items) {
+		end = len(items)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+	writeJSON(w, http.StatusOK, append([]T{}, items[start:end]...))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error the way Spring Boot does in Microcks.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"status":  status,
+		"error":   http.StatusText(status),
+		"message": message,
+	})
+}
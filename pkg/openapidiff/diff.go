@@ -0,0 +1,361 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package openapidiff compares two versions of an OpenAPI (or Swagger 2) document and reports the
+// changes that may break their consumers: removed paths and operations, new required parameters,
+// removed or renamed required properties, narrowed enums and changed types.
+//
+//	report, err := openapidiff.Compare(stored, local)
+//	if err == nil && report.HasBreaking() {
+//		for _, change := range report.Breaking() {
+//			fmt.Println(change)
+//		}
+//	}
+//
+// Local references to components are followed, remote ones are ignored.
+package openapidiff
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity tells if a change may break consumers.
+type Severity string
+
+const (
+	Breaking    Severity = "breaking"
+	NonBreaking Severity = "non-breaking"
+)
+
+// Kinds of changes.
+const (
+	PathRemoved            = "path-removed"
+	PathAdded              = "path-added"
+	OperationRemoved       = "operation-removed"
+	OperationAdded         = "operation-added"
+	ParameterRemoved       = "parameter-removed"
+	ParameterAdded         = "parameter-added"
+	ParameterRequired      = "parameter-required"
+	PropertyRemoved        = "property-removed"
+	PropertyAdded          = "property-added"
+	PropertyRequired       = "property-required"
+	EnumNarrowed           = "enum-narrowed"
+	EnumWidened            = "enum-widened"
+	TypeChanged            = "type-changed"
+	RequestBodyRequired    = "request-body-required"
+	ResponseContentRemoved = "response-content-removed"
+)
+
+// Change is a difference between two versions of a document.
+type Change struct {
+	Kind     string   `json:"kind" yaml:"kind"`
+	Severity Severity `json:"severity" yaml:"severity"`
+	// Location is the operation and the part of it that changed, eg. "GET /pets/{id} response 200: tags[].name".
+	Location string `json:"location" yaml:"location"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Kind, c.Location, c.Message)
+}
+
+// Report lists the changes between two versions of a document, by location.
+type Report struct {
+	Changes []Change `json:"changes" yaml:"changes"`
+}
+
+// Breaking returns the changes that may break consumers.
+func (r *Report) Breaking() []Change {
+	return r.filter(Breaking)
+}
+
+// NonBreaking returns the changes that should not break consumers.
+func (r *Report) NonBreaking() []Change {
+	return r.filter(NonBreaking)
+}
+
+// HasBreaking tells if a change may break consumers.
+func (r *Report) HasBreaking() bool {
+	return len(r.Breaking()) > 0
+}
+
+func (r *Report) filter(severity Severity) []Change {
+	var changes []Change
+	for _, change := range r.Changes {
+		if change.Severity == severity {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func (r *Report) add(kind string, severity Severity, location string, format string, args ...interface{}) {
+	r.Changes = append(r.Changes, Change{Kind: kind, Severity: severity, Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+// methods are the operations of a path item, in reporting order.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// pathParameter matches the parameters of path templates, whose names do not change the path.
+var pathParameter = regexp.MustCompile(`\{[^}]*\}`)
+
+// IsOpenAPI tells if content is an OpenAPI or Swagger 2 document, in YAML or JSON.
+func IsOpenAPI(content []byte) bool {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return false
+	}
+	_, openapi := doc["openapi"]
+	_, swagger := doc["swagger"]
+	return openapi || swagger
+}
+
+// Compare reports the changes from base to revision, both OpenAPI or Swagger 2 documents in YAML or JSON.
+func Compare(base []byte, revision []byte) (*Report, error) {
+	baseDoc, err := parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse base document: %w", err)
+	}
+	revisionDoc, err := parse(revision)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse revision document: %w", err)
+	}
+
+	d := &differ{report: &Report{Changes: []Change{}}, base: baseDoc, revision: revisionDoc}
+	basePaths := normalizedPaths(baseDoc)
+	revisionPaths := normalizedPaths(revisionDoc)
+	for _, key := range sortedKeys(basePaths) {
+		path := basePaths[key]
+		revisionPath, found := revisionPaths[key]
+		if !found {
+			d.report.add(PathRemoved, Breaking, path.name, "path was removed")
+			continue
+		}
+		d.comparePath(path, revisionPath)
+	}
+	for _, key := range sortedKeys(revisionPaths) {
+		if _, found := basePaths[key]; !found {
+			d.report.add(PathAdded, NonBreaking, revisionPaths[key].name, "path was added")
+		}
+	}
+	return d.report, nil
+}
+
+func parse(content []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if _, ok := doc["openapi"]; !ok {
+		if _, ok := doc["swagger"]; !ok {
+			return nil, fmt.Errorf("not an OpenAPI document")
+		}
+	}
+	return doc, nil
+}
+
+// pathItem is a path of a document with its declared name.
+type pathItem struct {
+	name string
+	item map[string]interface{}
+}
+
+// normalizedPaths returns the paths of doc keyed by their template without parameter names.
+func normalizedPaths(doc map[string]interface{}) map[string]pathItem {
+	paths := map[string]pathItem{}
+	for name, item := range asMap(doc["paths"]) {
+		paths[pathParameter.ReplaceAllString(name, "{}")] = pathItem{name: name, item: asMap(item)}
+	}
+	return paths
+}
+
+// differ compares the parts of two documents, resolving their references.
+type differ struct {
+	report   *Report
+	base     map[string]interface{}
+	revision map[string]interface{}
+}
+
+func (d *differ) comparePath(base pathItem, revision pathItem) {
+	for _, method := range methods {
+		baseOperation, inBase := base.item[method]
+		revisionOperation, inRevision := revision.item[method]
+		location := strings.ToUpper(method) + " " + revision.name
+		switch {
+		case inBase && !inRevision:
+			d.report.add(OperationRemoved, Breaking, strings.ToUpper(method)+" "+base.name, "operation was removed")
+		case !inBase && inRevision:
+			d.report.add(OperationAdded, NonBreaking, location, "operation was added")
+		case inBase && inRevision:
+			d.compareOperation(location, base.item, asMap(baseOperation), revision.item, asMap(revisionOperation))
+		}
+	}
+}
+
+func (d *differ) compareOperation(location string, basePath, base, revisionPath, revision map[string]interface{}) {
+	baseParameters := d.parameters(d.base, basePath, base)
+	revisionParameters := d.parameters(d.revision, revisionPath, revision)
+	for _, key := range sortedKeys(baseParameters) {
+		parameter := baseParameters[key]
+		revisionParameter, found := revisionParameters[key]
+		if !found {
+			d.report.add(ParameterRemoved, NonBreaking, location, "%s parameter '%s' was removed", parameter["in"], parameter["name"])
+			continue
+		}
+		paramLocation := fmt.Sprintf("%s %s parameter '%s'", location, parameter["in"], parameter["name"])
+		if !isTrue(parameter["required"]) && isTrue(revisionParameter["required"]) {
+			d.report.add(ParameterRequired, Breaking, paramLocation, "parameter became required")
+		}
+		d.compareSchema(paramLocation, "", parameterSchema(parameter), parameterSchema(revisionParameter), true, map[string]bool{})
+	}
+	for _, key := range sortedKeys(revisionParameters) {
+		if _, found := baseParameters[key]; found {
+			continue
+		}
+		parameter := revisionParameters[key]
+		if isTrue(parameter["required"]) {
+			d.report.add(ParameterAdded, Breaking, location, "required %s parameter '%s' was added", parameter["in"], parameter["name"])
+		} else {
+			d.report.add(ParameterAdded, NonBreaking, location, "optional %s parameter '%s' was added", parameter["in"], parameter["name"])
+		}
+	}
+
+	// Request bodies of OpenAPI 3 documents, Swagger 2 ones being body parameters.
+	baseBody := asMap(d.resolve(d.base, base["requestBody"]))
+	revisionBody := asMap(d.resolve(d.revision, revision["requestBody"]))
+	if revisionBody != nil && isTrue(revisionBody["required"]) && (baseBody == nil || !isTrue(baseBody["required"])) {
+		d.report.add(RequestBodyRequired, Breaking, location+" request body", "request body became required")
+	}
+	if baseBody != nil && revisionBody != nil {
+		d.compareContents(location+" request body", asMap(baseBody["content"]), asMap(revisionBody["content"]), true)
+	}
+
+	baseResponses := asMap(base["responses"])
+	revisionResponses := asMap(revision["responses"])
+	for _, status := range sortedKeys(baseResponses) {
+		revisionResponse, found := revisionResponses[status]
+		if !found {
+			continue
+		}
+		baseResponse := asMap(d.resolve(d.base, baseResponses[status]))
+		response := asMap(d.resolve(d.revision, revisionResponse))
+		responseLocation := location + " response " + status
+		if baseSchema, found := baseResponse["schema"]; found {
+			// Swagger 2 response.
+			d.compareSchema(responseLocation, "", asMap(baseSchema), asMap(response["schema"]), false, map[string]bool{})
+			continue
+		}
+		d.compareContents(responseLocation, asMap(baseResponse["content"]), asMap(response["content"]), false)
+	}
+}
+
+// compareContents compares the schemas of the media types of request or response bodies.
+func (d *differ) compareContents(location string, base, revision map[string]interface{}, request bool) {
+	for _, mediaType := range sortedKeys(base) {
+		revisionMedia, found := revision[mediaType]
+		if !found {
+			if !request {
+				d.report.add(ResponseContentRemoved, Breaking, location, "'%s' content was removed", mediaType)
+			}
+			continue
+		}
+		mediaLocation := location
+		if len(base) > 1 {
+			mediaLocation += " (" + mediaType + ")"
+		}
+		d.compareSchema(mediaLocation, "", asMap(asMap(base[mediaType])["schema"]), asMap(asMap(revisionMedia)["schema"]), request, map[string]bool{})
+	}
+}
+
+// parameters returns the parameters of an operation, merged with the ones of its path, keyed by
+// location and name.
+func (d *differ) parameters(doc map[string]interface{}, path, operation map[string]interface{}) map[string]map[string]interface{} {
+	parameters := map[string]map[string]interface{}{}
+	for _, list := range []interface{}{path["parameters"], operation["parameters"]} {
+		items, _ := list.([]interface{})
+		for _, item := range items {
+			parameter := asMap(d.resolve(doc, item))
+			if parameter == nil {
+				continue
+			}
+			in, _ := parameter["in"].(string)
+			name, _ := parameter["name"].(string)
+			// Body parameters of Swagger 2 are the request body, whatever their name.
+			if in == "body" {
+				name = ""
+			}
+			parameters[in+":"+name] = parameter
+		}
+	}
+	return parameters
+}
+
+// parameterSchema returns the schema of a parameter, the parameter itself for Swagger 2 ones
+// declaring their type inline.
+func parameterSchema(parameter map[string]interface{}) map[string]interface{} {
+	if schema, ok := parameter["schema"]; ok {
+		return asMap(schema)
+	}
+	return parameter
+}
+
+// resolve follows the local reference of node, if any.
+func (d *differ) resolve(doc map[string]interface{}, node interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := asMap(node)["$ref"].(string)
+		if !ok {
+			return node
+		}
+		node = lookup(doc, ref)
+	}
+	return nil
+}
+
+// lookup returns the node of doc at the local reference ref, nil if not found.
+func lookup(doc map[string]interface{}, ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var node interface{} = doc
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		node = asMap(node)[token]
+	}
+	return node
+}
+
+func asMap(node interface{}) map[string]interface{} {
+	m, _ := node.(map[string]interface{})
+	return m
+}
+
+func isTrue(node interface{}) bool {
+	b, _ := node.(bool)
+	return b
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
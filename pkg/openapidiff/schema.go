@@ -0,0 +1,224 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package openapidiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// compareSchema compares the schemas at pointer of a request (sent by consumers) or of a response
+// (read by them). seen holds the pairs of references already compared, to stop on recursive schemas.
+func (d *differ) compareSchema(location string, pointer string, base, revision map[string]interface{}, request bool, seen map[string]bool) {
+	if base == nil || revision == nil {
+		return
+	}
+	baseRef, _ := base["$ref"].(string)
+	revisionRef, _ := revision["$ref"].(string)
+	if len(baseRef) > 0 || len(revisionRef) > 0 {
+		pair := baseRef + " " + revisionRef
+		if seen[pair] {
+			return
+		}
+		seen[pair] = true
+		defer delete(seen, pair)
+	}
+	base = d.flatten(d.base, asMap(d.resolve(d.base, base)))
+	revision = d.flatten(d.revision, asMap(d.resolve(d.revision, revision)))
+	if base == nil || revision == nil {
+		return
+	}
+
+	at := location
+	if len(pointer) > 0 {
+		at += ": " + pointer
+	}
+	baseType, revisionType := schemaType(base), schemaType(revision)
+	if len(baseType) > 0 && len(revisionType) > 0 && baseType != revisionType {
+		d.report.add(TypeChanged, Breaking, at, "type changed from %s to %s", baseType, revisionType)
+		return
+	}
+	d.compareEnums(at, base, revision, request)
+
+	baseProperties, revisionProperties := asMap(base["properties"]), asMap(revision["properties"])
+	baseRequired, revisionRequired := requiredSet(base), requiredSet(revision)
+	for _, name := range sortedKeys(baseProperties) {
+		property := join(pointer, name)
+		if _, found := revisionProperties[name]; !found {
+			if !baseRequired[name] {
+				d.report.add(PropertyRemoved, NonBreaking, location, "optional property '%s' was removed", property)
+				continue
+			}
+			if renamed := d.renamedProperty(name, baseProperties, revisionProperties, revisionRequired); len(renamed) > 0 {
+				d.report.add(PropertyRemoved, Breaking, location, "required property '%s' was removed, possibly renamed to '%s'", property, join(pointer, renamed))
+			} else {
+				d.report.add(PropertyRemoved, Breaking, location, "required property '%s' was removed", property)
+			}
+			continue
+		}
+		if request && !baseRequired[name] && revisionRequired[name] {
+			d.report.add(PropertyRequired, Breaking, location, "property '%s' became required", property)
+		}
+		d.compareSchema(location, property, asMap(baseProperties[name]), asMap(revisionProperties[name]), request, seen)
+	}
+	for _, name := range sortedKeys(revisionProperties) {
+		if _, found := baseProperties[name]; found {
+			continue
+		}
+		if request && revisionRequired[name] {
+			d.report.add(PropertyAdded, Breaking, location, "required property '%s' was added", join(pointer, name))
+		} else {
+			d.report.add(PropertyAdded, NonBreaking, location, "property '%s' was added", join(pointer, name))
+		}
+	}
+
+	if baseItems, ok := base["items"]; ok {
+		d.compareSchema(location, pointer+"[]", asMap(baseItems), asMap(revision["items"]), request, seen)
+	}
+}
+
+// compareEnums reports removed and added values of an enum. Consumers may send removed values in
+// requests, and may not expect added ones in responses.
+func (d *differ) compareEnums(at string, base, revision map[string]interface{}, request bool) {
+	baseValues, _ := base["enum"].([]interface{})
+	revisionValues, _ := revision["enum"].([]interface{})
+	if len(baseValues) == 0 && len(revisionValues) == 0 {
+		return
+	}
+	removed := missingValues(baseValues, revisionValues)
+	if len(revisionValues) == 0 {
+		removed = nil
+	}
+	added := missingValues(revisionValues, baseValues)
+	if len(baseValues) == 0 {
+		// Constraining a free value is narrowing it.
+		d.report.add(EnumNarrowed, severity(request), at, "values restricted to %s", strings.Join(added, ", "))
+		return
+	}
+	if len(removed) > 0 {
+		d.report.add(EnumNarrowed, severity(request), at, "values %s were removed", strings.Join(removed, ", "))
+	}
+	if len(added) > 0 {
+		d.report.add(EnumWidened, severity(!request), at, "values %s were added", strings.Join(added, ", "))
+	}
+}
+
+// severity returns Breaking if breaking is set.
+func severity(breaking bool) Severity {
+	if breaking {
+		return Breaking
+	}
+	return NonBreaking
+}
+
+// renamedProperty returns the name of a required property of revision, absent from base, having the
+// same type as the removed property name. It is empty if there is none, or several.
+func (d *differ) renamedProperty(name string, baseProperties, revisionProperties map[string]interface{}, revisionRequired map[string]bool) string {
+	removedType := schemaType(asMap(d.resolve(d.base, baseProperties[name])))
+	candidate := ""
+	for _, other := range sortedKeys(revisionProperties) {
+		if _, found := baseProperties[other]; found || !revisionRequired[other] {
+			continue
+		}
+		if schemaType(asMap(d.resolve(d.revision, revisionProperties[other]))) != removedType {
+			continue
+		}
+		if len(candidate) > 0 {
+			return ""
+		}
+		candidate = other
+	}
+	return candidate
+}
+
+// flatten merges the properties and required ones of the allOf parts of schema into a copy of it.
+func (d *differ) flatten(doc map[string]interface{}, schema map[string]interface{}) map[string]interface{} {
+	parts, _ := schema["allOf"].([]interface{})
+	if len(parts) == 0 {
+		return schema
+	}
+	merged := map[string]interface{}{}
+	properties := map[string]interface{}{}
+	var required []interface{}
+	for key, value := range schema {
+		merged[key] = value
+	}
+	for _, part := range parts {
+		part := d.flatten(doc, asMap(d.resolve(doc, part)))
+		for name, property := range asMap(part["properties"]) {
+			properties[name] = property
+		}
+		partRequired, _ := part["required"].([]interface{})
+		required = append(required, partRequired...)
+		if _, typed := merged["type"]; !typed && part["type"] != nil {
+			merged["type"] = part["type"]
+		}
+	}
+	for name, property := range asMap(schema["properties"]) {
+		properties[name] = property
+	}
+	ownRequired, _ := schema["required"].([]interface{})
+	merged["properties"] = properties
+	merged["required"] = append(required, ownRequired...)
+	delete(merged, "allOf")
+	return merged
+}
+
+// schemaType returns the type of schema, types of OpenAPI 3.1 being joined, empty if unset.
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			types = append(types, fmt.Sprint(item))
+		}
+		return strings.Join(types, "|")
+	}
+	return ""
+}
+
+func requiredSet(schema map[string]interface{}) map[string]bool {
+	set := map[string]bool{}
+	names, _ := schema["required"].([]interface{})
+	for _, name := range names {
+		set[fmt.Sprint(name)] = true
+	}
+	return set
+}
+
+// missingValues returns the values of from not in to, formatted.
+func missingValues(from []interface{}, to []interface{}) []string {
+	present := map[string]bool{}
+	for _, value := range to {
+		present[fmt.Sprint(value)] = true
+	}
+	var missing []string
+	for _, value := range from {
+		if !present[fmt.Sprint(value)] {
+			missing = append(missing, fmt.Sprintf("'%v'", value))
+		}
+	}
+	return missing
+}
+
+func join(pointer string, name string) string {
+	if len(pointer) == 0 {
+		return name
+	}
+	return pointer + "." + name
+}
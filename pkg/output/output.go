@@ -0,0 +1,152 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format represents an output format for command results.
+type Format string
+
+const (
+	// Text is the human readable format.
+	Text Format = "text"
+	// Wide is the human readable format with additional details, falling back to Text for results
+	// having no wide rendering.
+	Wide Format = "wide"
+	// JSON renders results as indented JSON documents.
+	JSON Format = "json"
+	// YAML renders results as YAML documents.
+	YAML Format = "yaml"
+	// Env renders results as KEY=value lines safe to eval in POSIX shells.
+	Env Format = "env"
+)
+
+// Formats lists the supported output formats.
+var Formats = []Format{Text, Wide, JSON, YAML, Env}
+
+// TextRenderer is implemented by results able to render themselves in human readable format.
+type TextRenderer interface {
+	RenderText(w io.Writer)
+}
+
+// WideRenderer is implemented by results able to render themselves in human readable format with
+// additional details.
+type WideRenderer interface {
+	RenderWide(w io.Writer)
+}
+
+// EnvRenderer is implemented by results able to render themselves as shell variables. Each result
+// defines a stable set of variables, returned as ordered name and value pairs.
+type EnvRenderer interface {
+	EnvVars() [][2]string
+}
+
+// ParseFormat checks and converts a format name.
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	if strings.HasPrefix(name, ExecPrefix) && len(strings.TrimSpace(name[len(ExecPrefix):])) > 0 {
+		return Format(name), nil
+	}
+	return "", fmt.Errorf("unsupported output format '%s', valid ones are: text, wide, json, yaml, env or exec:<plugin>", name)
+}
+
+// IsStructured tells if format is a machine readable one.
+func (f Format) IsStructured() bool {
+	return f != Text && f != Wide
+}
+
+// Render writes result to w using format. Results rendered in text format must implement TextRenderer.
+func Render(w io.Writer, format Format, result interface{}) error {
+	if plugin, ok := format.Plugin(); ok {
+		return renderPlugin(w, plugin, result)
+	}
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case YAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case Env:
+		renderer, ok := result.(EnvRenderer)
+		if !ok {
+			return fmt.Errorf("result of type %T cannot be rendered as env", result)
+		}
+		for _, variable := range renderer.EnvVars() {
+			if !validEnvName(variable[0]) {
+				return fmt.Errorf("invalid variable name '%s'", variable[0])
+			}
+			fmt.Fprintf(w, "%s=%s\n", variable[0], ShellQuote(variable[1]))
+		}
+		return nil
+	default:
+		if wide, ok := result.(WideRenderer); ok && format == Wide {
+			wide.RenderWide(w)
+			return nil
+		}
+		renderer, ok := result.(TextRenderer)
+		if !ok {
+			return fmt.Errorf("result of type %T cannot be rendered as text", result)
+		}
+		renderer.RenderText(w)
+		return nil
+	}
+}
+
+// ShellQuote quotes value so that a POSIX shell reads it back unchanged. Values made of safe characters
+// only are left as is, others are single quoted, closing and reopening quotes around escaped embedded ones.
+func ShellQuote(value string) string {
+	if len(value) == 0 {
+		return "''"
+	}
+	safe := true
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.,:/@%+", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// validEnvName tells if name is a valid POSIX shell variable name.
+func validEnvName(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return len(name) > 0
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package output
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"65f1d2c3e4b5a6978890abcd", "65f1d2c3e4b5a6978890abcd"},
+		{"http://localhost:8080/#/tests/65f1d2c3", "'http://localhost:8080/#/tests/65f1d2c3'"},
+		{"", "''"},
+		{"Beer Catalog API:0.9", "'Beer Catalog API:0.9'"},
+		{"O'Reilly's API", `'O'\''Reilly'\''s API'`},
+		{"$(rm -rf /) `id` ${HOME}", "'$(rm -rf /) `id` ${HOME}'"},
+		{`a\b"c`, `'a\b"c'`},
+		{"line 1\nline 2", "'line 1\nline 2'"},
+		{"*; ls | cat > x &", "'*; ls | cat > x &'"},
+		{"Pâtisserie", "'Pâtisserie'"},
+		{"''", `''\'''\'''`},
+	}
+	sh, _ := exec.LookPath("sh")
+	for _, test := range tests {
+		t.Run(test.want, func(t *testing.T) {
+			got := ShellQuote(test.value)
+			if got != test.want {
+				t.Errorf("ShellQuote(%q) = %s, want %s", test.value, got, test.want)
+			}
+			if len(sh) == 0 {
+				return
+			}
+			// The shell must read the value back unchanged.
+			out, err := exec.Command(sh, "-c", "V="+got+"; printf %s \"$V\"").Output()
+			if err != nil {
+				t.Fatalf("sh rejected %s: %v", got, err)
+			}
+			if string(out) != test.value {
+				t.Errorf("sh read %s as %q, want %q", got, out, test.value)
+			}
+		})
+	}
+}
+
+// envResult is a result rendered as variables.
+type envResult [][2]string
+
+func (r envResult) EnvVars() [][2]string {
+	return r
+}
+
+func TestRenderEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables envResult
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "quoted values",
+			variables: envResult{{"MICROCKS_TEST_ID", "65f1"}, {"MICROCKS_TEST_SERVICE", "Beer API:0.9"}, {"MICROCKS_TEST_SUCCESS", "true"}},
+			want:      "MICROCKS_TEST_ID=65f1\nMICROCKS_TEST_SERVICE='Beer API:0.9'\nMICROCKS_TEST_SUCCESS=true\n",
+		},
+		{name: "empty name", variables: envResult{{"", "x"}}, wantErr: true},
+		{name: "leading digit", variables: envResult{{"1_ID", "x"}}, wantErr: true},
+		{name: "injected command", variables: envResult{{"ID;rm", "x"}}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Render(&out, Env, test.variables)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && out.String() != test.want {
+				t.Errorf("Render() = %q, want %q", out.String(), test.want)
+			}
+		})
+	}
+}
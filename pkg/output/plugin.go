@@ -0,0 +1,118 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExecPrefix prefixes the formatter plugin of exec output formats (eg. exec:confluence).
+const ExecPrefix = "exec:"
+
+// PluginAPIVersion is the version of the contract between the CLI and formatter plugins, passed to them
+// as MICROCKS_PLUGIN_API_VERSION. It changes only if the input schema changes incompatibly.
+const PluginAPIVersion = "1"
+
+// PluginEnv holds the environment variables passed to formatter plugins in addition to the CLI ones
+// (eg. MICROCKS_COMMAND=test).
+var PluginEnv []string
+
+// PluginError reports a formatter plugin that failed or exited with a non-zero status.
+type PluginError struct {
+	Plugin   string
+	ExitCode int
+	Err      error
+}
+
+func (e *PluginError) Error() string {
+	if e.ExitCode > 0 {
+		return fmt.Sprintf("output plugin %s exited with status %d", e.Plugin, e.ExitCode)
+	}
+	return fmt.Sprintf("output plugin %s failed: %s", e.Plugin, e.Err)
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+// Plugin returns the formatter plugin of an exec format.
+func (f Format) Plugin() (string, bool) {
+	if !strings.HasPrefix(string(f), ExecPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(string(f)[len(ExecPrefix):]), true
+}
+
+// PluginsDir returns the directory where formatter plugins given by bare name are looked up first.
+func PluginsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".microcks", "plugins")
+}
+
+// lookupPlugin resolves the executable of plugin: bare names are looked up in PluginsDir, then in PATH.
+func lookupPlugin(plugin string) (string, error) {
+	if !strings.ContainsRune(plugin, filepath.Separator) && !strings.ContainsRune(plugin, '/') {
+		if dir := PluginsDir(); len(dir) > 0 {
+			path := filepath.Join(dir, plugin)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+	}
+	return exec.LookPath(plugin)
+}
+
+// renderPlugin pipes the JSON rendering of result to the stdin of plugin, streaming its stdout to w.
+// Plugin stderr is inherited so that its diagnostics reach the user.
+func renderPlugin(w io.Writer, plugin string, result interface{}) error {
+	path, err := lookupPlugin(plugin)
+	if err != nil {
+		return &PluginError{Plugin: plugin, Err: err}
+	}
+
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(path)
+	cmd.Stdin = &input
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "MICROCKS_PLUGIN_API_VERSION="+PluginAPIVersion)
+	cmd.Env = append(cmd.Env, PluginEnv...)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &PluginError{Plugin: plugin, ExitCode: exitErr.ExitCode(), Err: err}
+		}
+		return &PluginError{Plugin: plugin, Err: err}
+	}
+	return nil
+}
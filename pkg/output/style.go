@@ -0,0 +1,98 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package output
+
+import (
+	"io"
+	"os"
+)
+
+const (
+	styleReset  = "\033[0m"
+	styleBold   = "\033[1m"
+	styleRed    = "\033[31m"
+	styleGreen  = "\033[32m"
+	styleYellow = "\033[33m"
+)
+
+// NoColor disables styling whatever the terminal capabilities (set by --no-color).
+var NoColor = false
+
+// Palette applies terminal styles to text when enabled.
+type Palette struct {
+	enabled bool
+}
+
+// Styles returns the palette to use when writing to w. Styling is only enabled when w is a
+// terminal, NO_COLOR environment variable is not set and NoColor is false.
+func Styles(w io.Writer) Palette {
+	if NoColor || len(os.Getenv("NO_COLOR")) > 0 {
+		return Palette{}
+	}
+	return Palette{enabled: isTerminal(w)}
+}
+
+// Enabled tells if palette actually applies styles.
+func (p Palette) Enabled() bool {
+	return p.enabled
+}
+
+// Success styles a successful verdict.
+func (p Palette) Success(text string) string {
+	return p.apply(styleGreen, text)
+}
+
+// Failure styles a failed verdict or an error.
+func (p Palette) Failure(text string) string {
+	return p.apply(styleRed, text)
+}
+
+// Warning styles a warning.
+func (p Palette) Warning(text string) string {
+	return p.apply(styleYellow, text)
+}
+
+// Bold styles headers and highlighted values.
+func (p Palette) Bold(text string) string {
+	return p.apply(styleBold, text)
+}
+
+// Verdict styles text as success or failure depending on ok.
+func (p Palette) Verdict(ok bool, text string) string {
+	if ok {
+		return p.Success(text)
+	}
+	return p.Failure(text)
+}
+
+func (p Palette) apply(style string, text string) string {
+	if !p.enabled || len(text) == 0 {
+		return text
+	}
+	return style + text + styleReset
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
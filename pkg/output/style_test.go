@@ -0,0 +1,71 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package output
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStyles(t *testing.T) {
+	// Character devices are taken for terminals, like /dev/null is.
+	device, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skipf("cannot open %s: %v", os.DevNull, err)
+	}
+	defer device.Close()
+	if info, err := device.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		t.Skipf("%s is not a character device", os.DevNull)
+	}
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tests := []struct {
+		name     string
+		w        io.Writer
+		noColor  bool
+		envColor string
+		want     string
+	}{
+		{name: "terminal", w: device, want: "\033[32mpassed\033[0m \033[31mfailed\033[0m \033[33mwarning\033[0m \033[1mheader\033[0m"},
+		{name: "not a terminal", w: &bytes.Buffer{}, want: "passed failed warning header"},
+		{name: "regular file", w: file, want: "passed failed warning header"},
+		{name: "--no-color", w: device, noColor: true, want: "passed failed warning header"},
+		{name: "NO_COLOR", w: device, envColor: "1", want: "passed failed warning header"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", test.envColor)
+			NoColor = test.noColor
+			defer func() { NoColor = false }()
+
+			styles := Styles(test.w)
+			got := styles.Verdict(true, "passed") + " " + styles.Verdict(false, "failed") + " " + styles.Warning("warning") + " " + styles.Bold("header")
+			if got != test.want {
+				t.Errorf("styled text = %q, want %q", got, test.want)
+			}
+			if styles.Enabled() != (got != "passed failed warning header") {
+				t.Errorf("Enabled() = %v for %q", styles.Enabled(), got)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a response stored by the Cache middleware.
+type CacheEntry struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// StoredAt is when the response was received or last revalidated.
+	StoredAt time.Time `json:"storedAt"`
+}
+
+// CacheStore keeps the responses of the Cache middleware. Implementations must be safe for
+// concurrent use.
+type CacheStore interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+}
+
+type memoryCache struct {
+	mutex   sync.Mutex
+	entries map[string]*CacheEntry
+}
+
+// NewMemoryCache returns a CacheStore keeping entries in memory for the life of the process.
+func NewMemoryCache() CacheStore {
+	return &memoryCache{entries: map[string]*CacheEntry{}}
+}
+
+func (c *memoryCache) Get(key string) (*CacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, found := c.entries[key]
+	return entry, found
+}
+
+func (c *memoryCache) Set(key string, entry *CacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = entry
+}
+
+type diskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a CacheStore keeping entries as files of dir, created when needed. Entries
+// that cannot be read or written are ignored, caching being an optimization only.
+func NewDiskCache(dir string) CacheStore {
+	return &diskCache{dir: dir}
+}
+
+func (c *diskCache) Get(key string) (*CacheEntry, bool) {
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var entry CacheEntry
+	if json.Unmarshal(data, &entry) != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (c *diskCache) Set(key string, entry *CacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil || os.MkdirAll(c.dir, 0700) != nil {
+		return
+	}
+	// Write then rename so that concurrent processes never read a partial entry.
+	file, err := os.CreateTemp(c.dir, key+"-*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(file.Name(), filepath.Join(c.dir, key+".json")) != nil {
+		os.Remove(file.Name())
+	}
+}
+
+// Cache serves GET responses from store. Stored responses are returned without any request while
+// fresh according to their Cache-Control max-age, then revalidated using their ETag: a 304 Not
+// Modified answer is turned into the stored response, saving the download of its body. Only 200
+// responses with an ETag or a max-age, and without no-store, are stored.
+//
+// Entries are keyed by URL, Accept header and identity, so that requests authenticated differently
+// never share them. An empty identity means the Authorization header of requests. Requests with an
+// If-None-Match or Range header, asking for no-store, carrying secrets (see WithSecretBodies) or having
+// a streamed body (see WithStreamedBody) bypass the cache.
+func Cache(store CacheStore, identity string, logger *slog.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			requestControl := cacheControl(req.Header)
+			if req.Method != http.MethodGet || len(req.Header.Get("If-None-Match")) > 0 || len(req.Header.Get("Range")) > 0 || requestControl.has("no-store") || hasSecretBodies(req) || hasStreamedBody(req) {
+				return next.RoundTrip(req)
+			}
+			key := cacheKey(req, identity)
+			entry, found := store.Get(key)
+			if found && !requestControl.has("no-cache") && entry.fresh(time.Now()) {
+				logger.Debug("API response of "+RequestName(req)+" served from cache", "method", req.Method, "path", req.URL.Path)
+				return entry.response(req), nil
+			}
+
+			etag := ""
+			if found {
+				etag = entry.Header.Get("ETag")
+			}
+			if len(etag) > 0 {
+				// RoundTrippers must not modify the request they're given.
+				req = req.Clone(req.Context())
+				req.Header.Set("If-None-Match", etag)
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			if len(etag) > 0 && resp.StatusCode == http.StatusNotModified {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				entry = entry.revalidated(resp.Header, time.Now())
+				store.Set(key, entry)
+				logger.Debug("API response of "+RequestName(req)+" revalidated from cache", "method", req.Method, "path", req.URL.Path)
+				return entry.response(req), nil
+			}
+			if !cacheable(resp) {
+				return resp, nil
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			store.Set(key, &CacheEntry{StatusCode: resp.StatusCode, Header: resp.Header.Clone(), Body: body, StoredAt: time.Now()})
+			return resp, nil
+		})
+	}
+}
+
+// cacheKey identifies the response to req for identity, or for its Authorization header if empty.
+func cacheKey(req *http.Request, identity string) string {
+	if len(identity) == 0 {
+		identity = "Authorization: " + req.Header.Get("Authorization")
+	}
+	sum := sha256.Sum256([]byte(identity + "\n" + req.URL.String() + "\n" + req.Header.Get("Accept")))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheable tells if resp can be stored and reused.
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Vary") == "*" {
+		return false
+	}
+	control := cacheControl(resp.Header)
+	if control.has("no-store") {
+		return false
+	}
+	return len(resp.Header.Get("ETag")) > 0 || control.maxAge() > 0
+}
+
+// fresh tells if e can be used at now without being revalidated.
+func (e *CacheEntry) fresh(now time.Time) bool {
+	control := cacheControl(e.Header)
+	if control.has("no-cache") {
+		return false
+	}
+	return now.Before(e.StoredAt.Add(control.maxAge()))
+}
+
+// revalidated returns a copy of e updated with the headers of a 304 Not Modified response.
+func (e *CacheEntry) revalidated(header http.Header, now time.Time) *CacheEntry {
+	updated := *e
+	updated.Header = e.Header.Clone()
+	for _, name := range []string{"Cache-Control", "Date", "ETag", "Expires"} {
+		if values := header.Values(name); len(values) > 0 {
+			updated.Header[name] = values
+		}
+	}
+	updated.StoredAt = now
+	return &updated
+}
+
+// response builds the response to req from e.
+func (e *CacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheDirectives holds the lower-cased directives of a Cache-Control header.
+type cacheDirectives map[string]string
+
+func cacheControl(header http.Header) cacheDirectives {
+	directives := cacheDirectives{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if len(name) > 0 {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
+
+func (d cacheDirectives) has(name string) bool {
+	_, found := d[name]
+	return found
+}
+
+// maxAge returns the max-age directive, 0 if missing or invalid.
+func (d cacheDirectives) maxAge() time.Duration {
+	seconds, err := strconv.Atoi(d["max-age"])
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,366 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the values of sensitive fields in recorded bodies.
+const redacted = "REDACTED"
+
+// sensitiveFields are the JSON fields and form parameters whose values are never recorded. They're
+// also ignored when matching requests, as they change from one run to another.
+var sensitiveFields = map[string]bool{
+	"password":         true,
+	"token":            true,
+	"caCertPem":        true,
+	"clientSecret":     true,
+	"client_secret":    true,
+	"client_assertion": true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"id_token":         true,
+}
+
+// volatileFields are the JSON fields ignored when matching requests, along with timestamps.
+var volatileFields = map[string]bool{
+	"timestamp":  true,
+	"createdOn":  true,
+	"lastUpdate": true,
+}
+
+// Interaction is a request and its response recorded in a cassette.
+type Interaction struct {
+	Request  RecordedRequest  `yaml:"request"`
+	Response RecordedResponse `yaml:"response"`
+}
+
+// RecordedRequest identifies a recorded request. Headers are never recorded.
+type RecordedRequest struct {
+	Name   string `yaml:"name,omitempty"`
+	Method string `yaml:"method"`
+	// URL is the path and query of the request, so that cassettes can be replayed against any host.
+	URL string `yaml:"url"`
+	// BodyHash is the hash of the body, ignoring sensitive and volatile fields.
+	BodyHash string `yaml:"bodyHash,omitempty"`
+	// Body is the redacted body, only recorded when dumpable (see Describe).
+	Body string `yaml:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded response, whose body has sensitive fields redacted.
+type RecordedResponse struct {
+	StatusCode int         `yaml:"statusCode"`
+	Header     http.Header `yaml:"header,omitempty"`
+	Body       string      `yaml:"body,omitempty"`
+}
+
+// Cassette holds the API interactions recorded during a run, so that later runs can be replayed
+// without any network. It is safe for concurrent use.
+type Cassette struct {
+	path      string
+	replaying bool
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// cassetteFile is the content of cassette files.
+type cassetteFile struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// NewCassette returns an empty cassette recording interactions to the file at path, which is
+// written after each one so that interrupted runs are recorded too.
+func NewCassette(path string) *Cassette {
+	return &Cassette{path: path}
+}
+
+// LoadCassette reads the cassette file at path for replaying its interactions.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file cassetteFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot parse cassette %s: %w", path, err)
+	}
+	return &Cassette{path: path, replaying: true, interactions: file.Interactions, used: make([]bool, len(file.Interactions))}, nil
+}
+
+// Replaying tells if the cassette replays interactions rather than recording them.
+func (c *Cassette) Replaying() bool {
+	return c.replaying
+}
+
+// UnmatchedRequestError is returned when replaying a request that has no recorded interaction.
+type UnmatchedRequestError struct {
+	Cassette string
+	Method   string
+	URL      string
+}
+
+func (e *UnmatchedRequestError) Error() string {
+	return fmt.Sprintf("no interaction recorded in cassette %s for %s %s", e.Cassette, e.Method, e.URL)
+}
+
+// Record records the interactions of requests in cassette, or replays them if the cassette was
+// loaded: requests are then answered from the cassette, never sent. They're matched on method, path
+// and query, and the hash of their body ignoring sensitive fields, timestamps and multipart
+// boundaries. Identical requests, like polling ones, get the recorded responses in order, then the
+// last one again.
+//
+// It must be the innermost middleware so that replayed requests are logged and dumped as sent ones.
+func Record(cassette *Cassette) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req, body, err := readRequestBody(req)
+			if err != nil {
+				return nil, err
+			}
+			recorded := RecordedRequest{Name: RequestName(req), Method: req.Method, URL: req.URL.RequestURI(), BodyHash: requestHash(req, body)}
+			if cassette.replaying {
+				return cassette.replay(req, recorded)
+			}
+			if len(body) > 0 && dumpBody(req) {
+				recorded.Body = string(redactBody(req.Header.Get("Content-Type"), body))
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			respBody, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+			header := resp.Header.Clone()
+			header.Del("Set-Cookie")
+			// Redaction may change the length of bodies.
+			header.Del("Content-Length")
+			interaction := Interaction{Request: recorded, Response: RecordedResponse{
+				StatusCode: resp.StatusCode,
+				Header:     header,
+				Body:       string(redactBody(resp.Header.Get("Content-Type"), respBody)),
+			}}
+			if err := cassette.record(interaction); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		})
+	}
+}
+
+// replay answers req with the first unused interaction matching recorded, or the last matching one.
+func (c *Cassette) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	found := -1
+	for i, interaction := range c.interactions {
+		if interaction.Request.Method != recorded.Method || interaction.Request.URL != recorded.URL || interaction.Request.BodyHash != recorded.BodyHash {
+			continue
+		}
+		found = i
+		if !c.used[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, &UnmatchedRequestError{Cassette: c.path, Method: recorded.Method, URL: recorded.URL}
+	}
+	c.used[found] = true
+
+	response := c.interactions[found].Response
+	return &http.Response{
+		Status:        strconv.Itoa(response.StatusCode) + " " + http.StatusText(response.StatusCode),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// record appends interaction to the cassette and writes its file.
+func (c *Cassette) record(interaction Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cassetteFile{Interactions: c.interactions}); err != nil {
+		return err
+	}
+	// Write then rename so that the file is never left partial.
+	file, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("cannot write cassette %s: %w", c.path, err)
+	}
+	_, err = file.Write(buf.Bytes())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("cannot write cassette %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// readRequestBody reads the body of req, returning a copy of req whose body can still be sent.
+func readRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	// RoundTrippers must not modify the request they're given.
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return req, body, nil
+}
+
+// requestHash hashes the body of req, decompressed if gzip encoded.
+func requestHash(req *http.Request, body []byte) string {
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		if gz, err := gzip.NewReader(bytes.NewReader(body)); err == nil {
+			if raw, err := io.ReadAll(gz); err == nil {
+				body = raw
+			}
+		}
+	}
+	return bodyHash(req.Header.Get("Content-Type"), body)
+}
+
+// bodyHash hashes body of contentType, ignoring the values of sensitive fields, volatile JSON
+// fields, timestamps and multipart boundaries.
+func bodyHash(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	switch {
+	case isJSON(mediaType):
+		var value interface{}
+		if json.Unmarshal(body, &value) == nil {
+			// Encoding sorts the keys of objects.
+			body, _ = json.Marshal(normalizeJSON(value, true))
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		body = redactForm(body)
+	case strings.HasPrefix(mediaType, "multipart/") && len(params["boundary"]) > 0:
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("boundary"))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactBody returns body of contentType with the values of sensitive fields replaced.
+func redactBody(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case isJSON(mediaType):
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return body
+		}
+		redactedBody, err := json.Marshal(normalizeJSON(value, false))
+		if err != nil {
+			return body
+		}
+		return redactedBody
+	case mediaType == "application/x-www-form-urlencoded":
+		return redactForm(body)
+	}
+	return body
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// normalizeJSON replaces the values of sensitive fields in value and, when matching, the values of
+// volatile fields and timestamps.
+func normalizeJSON(value interface{}, matching bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			switch {
+			case sensitiveFields[key]:
+				v[key] = redacted
+			case matching && volatileFields[key]:
+				delete(v, key)
+			default:
+				v[key] = normalizeJSON(field, matching)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeJSON(item, matching)
+		}
+	case string:
+		if _, err := time.Parse(time.RFC3339, v); err == nil && matching {
+			return "timestamp"
+		}
+	}
+	return value
+}
+
+// redactForm replaces the values of sensitive parameters of a form encoded body.
+func redactForm(body []byte) []byte {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return body
+	}
+	for name := range form {
+		if sensitiveFields[name] {
+			form.Set(name, redacted)
+		}
+	}
+	return []byte(form.Encode())
+}
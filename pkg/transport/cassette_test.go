@@ -0,0 +1,199 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exchange is a request sent through a cassette and the body of its response.
+type exchange struct {
+	method      string
+	url         string
+	contentType string
+	body        string
+	response    string
+	// unmatched tells the request has no recorded interaction when replaying.
+	unmatched bool
+}
+
+func TestCassetteReplay(t *testing.T) {
+	const form = "application/x-www-form-urlencoded"
+	tests := []struct {
+		name     string
+		recorded []exchange
+		replayed []exchange
+	}{
+		{
+			name:     "polling in order then last response",
+			recorded: []exchange{{method: "GET", url: "/api/tests/1", response: "inProgress"}, {method: "GET", url: "/api/tests/1", response: "done"}},
+			replayed: []exchange{{method: "GET", url: "/api/tests/1", response: "inProgress"}, {method: "GET", url: "/api/tests/1", response: "done"}, {method: "GET", url: "/api/tests/1", response: "done"}},
+		},
+		{
+			name:     "other host",
+			recorded: []exchange{{method: "GET", url: "/api/services?page=0", response: "services"}},
+			replayed: []exchange{{method: "GET", url: "http://other:9090/api/services?page=0", response: "services"}},
+		},
+		{
+			name:     "volatile JSON fields",
+			recorded: []exchange{{method: "POST", url: "/api/tests", contentType: "application/json", body: `{"serviceId":"s","timestamp":1,"at":"2024-01-01T00:00:00Z"}`, response: "created"}},
+			replayed: []exchange{{method: "POST", url: "/api/tests", contentType: "application/json", body: `{"at":"2025-06-01T10:00:00Z","timestamp":2,"serviceId":"s"}`, response: "created"}},
+		},
+		{
+			name:     "secrets",
+			recorded: []exchange{{method: "POST", url: "/token", contentType: form, body: "grant_type=client_credentials&client_secret=a", response: "token"}},
+			replayed: []exchange{{method: "POST", url: "/token", contentType: form, body: "grant_type=client_credentials&client_secret=b", response: "token"}},
+		},
+		{
+			name:     "multipart boundaries",
+			recorded: []exchange{{method: "POST", url: "/api/artifact/upload", contentType: "multipart/form-data; boundary=abc", body: "--abc\r\nx\r\n--abc--", response: "Beer:0.9"}},
+			replayed: []exchange{{method: "POST", url: "/api/artifact/upload", contentType: "multipart/form-data; boundary=xyz", body: "--xyz\r\nx\r\n--xyz--", response: "Beer:0.9"}},
+		},
+		{
+			name:     "other body",
+			recorded: []exchange{{method: "POST", url: "/api/tests", contentType: "application/json", body: `{"serviceId":"s"}`, response: "created"}},
+			replayed: []exchange{{method: "POST", url: "/api/tests", contentType: "application/json", body: `{"serviceId":"t"}`, unmatched: true}},
+		},
+		{
+			name:     "other query",
+			recorded: []exchange{{method: "GET", url: "/api/services?page=0", response: "services"}},
+			replayed: []exchange{{method: "GET", url: "/api/services?page=1", unmatched: true}},
+		},
+		{
+			name:     "other method",
+			recorded: []exchange{{method: "GET", url: "/api/secrets/1", response: "secret"}},
+			replayed: []exchange{{method: "DELETE", url: "/api/secrets/1", unmatched: true}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cassette.yaml")
+			send := func(rt http.RoundTripper, e exchange) (string, error) {
+				t.Helper()
+				u := e.url
+				if strings.HasPrefix(u, "/") {
+					u = "http://microcks:8080" + u
+				}
+				req, _ := http.NewRequest(e.method, u, strings.NewReader(e.body))
+				if len(e.contentType) > 0 {
+					req.Header.Set("Content-Type", e.contentType)
+				}
+				resp, err := rt.RoundTrip(req)
+				if err != nil {
+					return "", err
+				}
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				return string(body), err
+			}
+
+			recorded := 0
+			recorder := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				response := test.recorded[recorded].response
+				recorded++
+				return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(response)), Request: req}, nil
+			}), Record(NewCassette(path)))
+			for _, e := range test.recorded {
+				if _, err := send(recorder, e); err != nil {
+					t.Fatalf("recording %s %s error = %v", e.method, e.url, err)
+				}
+			}
+
+			cassette, err := LoadCassette(path)
+			if err != nil {
+				t.Fatalf("LoadCassette() error = %v", err)
+			}
+			replayer := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				t.Errorf("replayed request %s %s was sent", req.Method, req.URL)
+				return nil, errors.New("sent")
+			}), Record(cassette))
+			for i, e := range test.replayed {
+				got, err := send(replayer, e)
+				var unmatched *UnmatchedRequestError
+				if e.unmatched != errors.As(err, &unmatched) {
+					t.Fatalf("replay #%d error = %v, want unmatched %v", i+1, err, e.unmatched)
+				}
+				if !e.unmatched && (err != nil || got != e.response) {
+					t.Errorf("replay #%d = %q, %v, want %q", i+1, got, err, e.response)
+				}
+			}
+		})
+	}
+}
+
+func TestCassetteRedaction(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		response     string
+		wantRedacted []string
+	}{
+		{
+			name:         "form secrets",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         "grant_type=client_credentials&client_secret=s3cr3t&client_assertion=eyJhbGci",
+			response:     `{"access_token":"eyJ0eXAi","refresh_token":"r3fr3sh","expires_in":300}`,
+			wantRedacted: []string{"s3cr3t", "eyJhbGci", "eyJ0eXAi", "r3fr3sh"},
+		},
+		{
+			name:         "JSON secrets",
+			contentType:  "application/json",
+			body:         `{"name":"github","password":"p4ssw0rd","token":"ghp_123","nested":{"caCertPem":"-----BEGIN"}}`,
+			response:     `{"id":"1","clientSecret":"c5"}`,
+			wantRedacted: []string{"p4ssw0rd", "ghp_123", "-----BEGIN", "c5"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cassette.yaml")
+			rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				header := http.Header{"Content-Type": {"application/json"}, "Set-Cookie": {"session=abc"}}
+				return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(strings.NewReader(test.response)), Request: req}, nil
+			}), Record(NewCassette(path)))
+			req, _ := http.NewRequest(http.MethodPost, "http://microcks:8080/api/secrets", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			req.Header.Set("Authorization", "Bearer b34r3r")
+			resp, err := rt.RoundTrip(Describe(req, "Microcks for creating secret", true))
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			// The caller still gets the actual response.
+			if body, _ := io.ReadAll(resp.Body); string(body) != test.response {
+				t.Errorf("response body = %s, want %s", body, test.response)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, secret := range append(test.wantRedacted, "b34r3r", "session=abc") {
+				if strings.Contains(string(content), secret) {
+					t.Errorf("cassette holds %q:\n%s", secret, content)
+				}
+			}
+			if !strings.Contains(string(content), redacted) {
+				t.Errorf("cassette has no redacted value:\n%s", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+)
+
+// Dump logs requests and responses at trace level when verbose is set or logger enables this level.
+// Bodies of requests described as not dumpable are omitted, and so are the ones of responses to requests
+// carrying secrets or having a streamed body.
+func Dump(logger *slog.Logger, verbose bool) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if !verbose && !logger.Enabled(req.Context(), LevelTrace) {
+				return next.RoundTrip(req)
+			}
+			name := RequestName(req)
+
+			// Dumping body replaces it, so work on a copy of request. Dump is done using a fake
+			// connection that must not be seen by tracing.
+			req = req.Clone(req.Context())
+			dumped := req.WithContext(context.Background())
+			dump, err := httputil.DumpRequestOut(dumped, dumpBody(req))
+			req.Body = dumped.Body
+			if err != nil {
+				logger.Warn("Got error while dumping request out", "error", err)
+			}
+			logger.Log(req.Context(), LevelTrace, fmt.Sprintf("Dumping request '%s':\n%s", name, dump))
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			dump, err = httputil.DumpResponse(resp, !hasSecretBodies(req) && !hasStreamedBody(req))
+			if err != nil {
+				logger.Warn("Got error while dumping response", "error", err)
+			}
+			logger.Log(req.Context(), LevelTrace, fmt.Sprintf("Dumping response '%s':\n%s", name, dump))
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter paces the requests sent by clients.
+type RateLimiter interface {
+	// Wait blocks until the request described by name may be sent. It returns an error, without
+	// waiting, if ctx is done before.
+	Wait(ctx context.Context, name string) error
+}
+
+// RateLimiterFunc adapts a function to the RateLimiter interface.
+type RateLimiterFunc func(ctx context.Context, name string) error
+
+// Wait implements RateLimiter for RateLimiterFunc.
+func (f RateLimiterFunc) Wait(ctx context.Context, name string) error {
+	return f(ctx, name)
+}
+
+// rateClock provides the time to TokenBucket, allowing tests to fake it.
+type rateClock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// TokenBucket is a RateLimiter allowing a number of requests per second, and bursts of requests above
+// it. It is safe for concurrent use: a TokenBucket shared by several clients paces all of them.
+type TokenBucket struct {
+	limiter *rate.Limiter
+	logger  *slog.Logger
+	// clock is nil for the system clock.
+	clock rateClock
+}
+
+// NewTokenBucket returns a TokenBucket allowing limit requests per second and bursts of burst requests,
+// at least one. Delayed requests are logged with logger at debug level.
+func NewTokenBucket(limit float64, burst int, logger *slog.Logger) *TokenBucket {
+	return &TokenBucket{limiter: rate.NewLimiter(rate.Limit(limit), max(burst, 1)), logger: logger}
+}
+
+// Wait implements RateLimiter for TokenBucket. Requests that could not be sent before the deadline of
+// ctx fail immediately with an error matching context.DeadlineExceeded.
+func (b *TokenBucket) Wait(ctx context.Context, name string) error {
+	now := b.now()
+	var err error
+	if b.clock == nil {
+		err = b.limiter.Wait(ctx)
+	} else {
+		err = b.waitOnClock(ctx, now)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return fmt.Errorf("request '%s' cannot be sent before deadline because of rate limit: %w", name, context.DeadlineExceeded)
+	}
+	if delay := b.now().Sub(now); delay >= time.Millisecond {
+		b.logger.Debug(fmt.Sprintf("Request '%s' delayed by rate limiter", name), "delay", delay.Round(time.Millisecond))
+	}
+	return nil
+}
+
+func (b *TokenBucket) now() time.Time {
+	if b.clock == nil {
+		return time.Now()
+	}
+	return b.clock.Now()
+}
+
+// waitOnClock does what rate.Limiter.Wait does, on a fake clock.
+func (b *TokenBucket) waitOnClock(ctx context.Context, now time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	reservation := b.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		reservation.CancelAt(now)
+		return fmt.Errorf("delay of %s exceeds deadline", delay)
+	}
+	if delay == 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		reservation.CancelAt(b.clock.Now())
+		return ctx.Err()
+	case <-b.clock.After(delay):
+		return nil
+	}
+}
+
+// RateLimit waits for limiter before sending every request.
+func RateLimit(limiter RateLimiter) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if err := limiter.Wait(req.Context(), RequestName(req)); err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
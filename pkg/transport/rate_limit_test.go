@@ -0,0 +1,156 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock whose time only moves forward when waited on.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestTokenBucketPacing(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  float64
+		burst  int
+		delays []time.Duration
+	}{
+		{"one per second", 1, 1, []time.Duration{0, time.Second, time.Second, time.Second}},
+		{"burst then paced", 5, 3, []time.Duration{0, 0, 0, 200 * time.Millisecond, 200 * time.Millisecond}},
+		{"burst below one", 10, 0, []time.Duration{0, 100 * time.Millisecond, 100 * time.Millisecond}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			bucket := NewTokenBucket(test.limit, test.burst, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			bucket.clock = clock
+			for i, want := range test.delays {
+				before := clock.Now()
+				if err := bucket.Wait(context.Background(), "test"); err != nil {
+					t.Fatalf("Wait() #%d error = %v", i, err)
+				}
+				if got := clock.Now().Sub(before); got != want {
+					t.Errorf("Wait() #%d delayed by %s, want %s", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenBucketContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{"deadline before delay", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), time.Minute)
+		}, context.DeadlineExceeded},
+		{"canceled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx, cancel
+		}, context.Canceled},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Now()}
+			bucket := NewTokenBucket(1.0/3600, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			bucket.clock = clock
+			if err := bucket.Wait(context.Background(), "first"); err != nil {
+				t.Fatalf("Wait() error = %v", err)
+			}
+
+			ctx, cancel := test.ctx()
+			defer cancel()
+			start := clock.Now()
+			if err := bucket.Wait(ctx, "second"); !errors.Is(err, test.want) {
+				t.Errorf("Wait() error = %v, want %v", err, test.want)
+			}
+			if waited := clock.Now().Sub(start); waited != 0 {
+				t.Errorf("Wait() waited %s before failing", waited)
+			}
+			// The failed request gave its reservation back.
+			if err := bucket.Wait(context.Background(), "third"); err != nil {
+				t.Fatalf("Wait() error = %v", err)
+			}
+			if waited := clock.Now().Sub(start); waited != time.Hour {
+				t.Errorf("next request waited %s, want 1h", waited)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	errLimited := errors.New("limited")
+	tests := []struct {
+		name     string
+		err      error
+		wantSent bool
+	}{
+		{"allowed", nil, true},
+		{"refused", errLimited, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var waited string
+			limiter := RateLimiterFunc(func(ctx context.Context, name string) error {
+				waited = name
+				return test.err
+			})
+			sent := false
+			rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				sent = true
+				return &http.Response{StatusCode: 200, Body: http.NoBody, Request: req}, nil
+			}), RateLimit(limiter))
+
+			req, _ := http.NewRequest("GET", "http://microcks/api/services", nil)
+			_, err := rt.RoundTrip(Describe(req, "Microcks for listing services", false))
+			if !errors.Is(err, test.err) || sent != test.wantSent {
+				t.Errorf("RoundTrip() error = %v, sent = %v, want %v, %v", err, sent, test.err, test.wantSent)
+			}
+			if waited != "Microcks for listing services" {
+				t.Errorf("limiter waited for %q", waited)
+			}
+		})
+	}
+}
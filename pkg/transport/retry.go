@@ -0,0 +1,171 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy defines how failed requests are retried. Its zero value disables retries of transient
+// failures, requests throttled by the server are retried anyway (see Retry).
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// Backoff is the delay before the first retry, doubled after each one. 0 means 500ms.
+	Backoff time.Duration
+}
+
+const (
+	// maxThrottledRetries is the number of retries of a request throttled with a 429 status.
+	maxThrottledRetries = 5
+	// maxRetryAfter is the longest delay asked by a Retry-After header the client accepts to wait.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// retryableStatus are the statuses of transient server failures.
+var retryableStatus = map[int]bool{
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// idempotentMethods are the methods whose requests may safely be sent again.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// Retry sends again idempotent requests failing with a connection error or a transient server status,
+// waiting for an exponential backoff between attempts. Idempotent requests throttled with a 429 status
+// are sent again too, other requests only when the response tells when to send them again with a
+// Retry-After header. The delay asked by a Retry-After response header always wins over the backoff,
+// unless it goes beyond the deadline of the request: the response is then returned as is. Requests whose
+// body cannot be rewound are never retried, neither are requests whose context is done.
+func Retry(policy RetryPolicy, logger *slog.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+			backoff := policy.Backoff
+			if backoff <= 0 {
+				backoff = 500 * time.Millisecond
+			}
+			retries, throttled := 0, 0
+			for attempt := 0; ; attempt++ {
+				attemptReq, err := rewind(req, attempt)
+				if err != nil {
+					return nil, err
+				}
+				resp, err := next.RoundTrip(attemptReq)
+
+				var delay, retryAfter time.Duration
+				var hasRetryAfter bool
+				if err == nil {
+					retryAfter, hasRetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+				}
+				switch {
+				case err == nil && resp.StatusCode == http.StatusTooManyRequests && throttled < maxThrottledRetries && (idempotentMethods[req.Method] || hasRetryAfter):
+					delay = backoff << throttled
+					throttled++
+				case idempotentMethods[req.Method] && retries < policy.MaxRetries && shouldRetry(resp, err):
+					delay = backoff << retries
+					retries++
+				default:
+					return resp, err
+				}
+				if hasRetryAfter {
+					delay = retryAfter
+				}
+				if !rewindable {
+					logger.Debug("Not retrying API request to "+RequestName(req)+", its body cannot be sent again", "attempt", attempt+1)
+					return resp, err
+				}
+				if deadline, ok := req.Context().Deadline(); delay > maxRetryAfter || (ok && time.Now().Add(delay).After(deadline)) {
+					logger.Debug("Not retrying API request to "+RequestName(req)+", the delay goes beyond the deadline", "wait", delay)
+					return resp, err
+				}
+
+				if err != nil {
+					logger.Debug("Retrying API request to "+RequestName(req), "attempt", attempt+1, "error", err, "wait", delay)
+				} else {
+					logger.Debug("Retrying API request to "+RequestName(req), "attempt", attempt+1, "status", resp.StatusCode, "wait", delay)
+					io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+					resp.Body.Close()
+				}
+
+				timer := time.NewTimer(delay)
+				select {
+				case <-req.Context().Done():
+					timer.Stop()
+					return nil, req.Context().Err()
+				case <-timer.C:
+				}
+			}
+		})
+	}
+}
+
+// parseRetryAfter returns the delay asked by the value of a Retry-After header, either a number of
+// seconds or an HTTP date. Dates in the past mean no delay.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		// Bounded to avoid overflows, longer delays are never waited for anyway.
+		return time.Duration(min(seconds, int64(24*time.Hour/time.Second))) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+// rewind returns req for the first attempt, a copy with a fresh body for the next ones.
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		var unmatchedErr *UnmatchedRequestError
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.As(err, &unmatchedErr)
+	}
+	return retryableStatus[resp.StatusCode]
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{" 5 ", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"99999999999999", 24 * time.Hour, true},
+		{"Fri, 01 Mar 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Friday, 01-Mar-24 12:01:00 GMT", time.Minute, true},
+		{"Fri Mar  1 12:00:10 2024", 10 * time.Second, true},
+		{"Fri, 01 Mar 2024 11:00:00 GMT", 0, true},
+		{"in a minute", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, ok := parseRetryAfter(test.value, now)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", test.value, got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestRetryThrottled(t *testing.T) {
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name         string
+		method       string
+		retryAfter   string
+		wantAttempts int
+	}{
+		{"idempotent with delta-seconds", http.MethodGet, "0", maxThrottledRetries + 1},
+		{"idempotent with HTTP-date", http.MethodGet, past, maxThrottledRetries + 1},
+		{"idempotent without Retry-After", http.MethodPut, "", maxThrottledRetries + 1},
+		{"non-idempotent with delta-seconds", http.MethodPost, "0", maxThrottledRetries + 1},
+		{"non-idempotent with HTTP-date", http.MethodPost, past, maxThrottledRetries + 1},
+		{"non-idempotent without Retry-After", http.MethodPost, "", 1},
+		{"non-idempotent with invalid Retry-After", http.MethodPost, "soon", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var bodies []string
+			rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				bodies = append(bodies, string(body))
+				header := http.Header{}
+				if len(test.retryAfter) > 0 {
+					header.Set("Retry-After", test.retryAfter)
+				}
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Header: header, Body: http.NoBody, Request: req}, nil
+			}), Retry(RetryPolicy{Backoff: time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			req, _ := http.NewRequest(test.method, "http://microcks/api/tests", strings.NewReader(`{"serviceId":"s"}`))
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if resp.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("RoundTrip() status = %d, want 429", resp.StatusCode)
+			}
+			if len(bodies) != test.wantAttempts {
+				t.Errorf("request sent %d times, want %d", len(bodies), test.wantAttempts)
+			}
+			for i, body := range bodies {
+				if body != `{"serviceId":"s"}` {
+					t.Errorf("attempt %d sent body %q", i+1, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryUnrewindableBody(t *testing.T) {
+	var logs bytes.Buffer
+	attempts := 0
+	rt := Chain(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		header := http.Header{"Retry-After": {"0"}}
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Header: header, Body: http.NoBody, Request: req}, nil
+	}), Retry(RetryPolicy{MaxRetries: 3, Backoff: time.Millisecond}, slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	// A body without GetBody, like a streamed upload, cannot be sent again.
+	req, _ := http.NewRequest(http.MethodPut, "http://microcks/api/services/1/metadata", io.NopCloser(strings.NewReader("{}")))
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("request sent %d times, want 1", attempts)
+	}
+	if !strings.Contains(logs.String(), "its body cannot be sent again") {
+		t.Errorf("no log of the skipped retry: %s", logs.String())
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package transport
+
+import (
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTiming holds the duration of the phases of an API request. Phases not happening,
+// like DNS or TLS on a reused connection, are zero.
+type RequestTiming struct {
+	// Name is the endpoint called, as named in logs.
+	Name    string
+	Method  string
+	Path    string
+	Status  int
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// TTFB is the time elapsed until the first response byte, from the request start.
+	TTFB  time.Duration
+	Total time.Duration
+}
+
+// requestTrace collects the phases of a traced request.
+type requestTrace struct {
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       RequestTiming
+}
+
+// Timing records the phases of every request and reports them to onTiming, with a debug log,
+// once the response body has been read or closed. Measure starts when the transport gets a
+// connection, so that waiting in middlewares like RateLimit is not accounted.
+func Timing(logger *slog.Logger, onTiming func(timing RequestTiming)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			t := &requestTrace{start: time.Now(), timing: RequestTiming{Name: RequestName(req), Method: req.Method, Path: req.URL.Path}}
+			trace := &httptrace.ClientTrace{
+				GetConn:  func(string) { t.start = time.Now() },
+				DNSStart: func(httptrace.DNSStartInfo) { t.dnsStart = time.Now() },
+				DNSDone:  func(httptrace.DNSDoneInfo) { t.timing.DNS = time.Since(t.dnsStart) },
+				ConnectStart: func(string, string) {
+					if t.connectStart.IsZero() {
+						t.connectStart = time.Now()
+					}
+				},
+				ConnectDone:          func(string, string, error) { t.timing.Connect = time.Since(t.connectStart) },
+				TLSHandshakeStart:    func() { t.tlsStart = time.Now() },
+				TLSHandshakeDone:     func(tls.ConnectionState, error) { t.timing.TLS = time.Since(t.tlsStart) },
+				GotFirstResponseByte: func() { t.timing.TTFB = time.Since(t.start) },
+			}
+			resp, err := next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+			if err != nil {
+				return nil, err
+			}
+			t.timing.Status = resp.StatusCode
+			resp.Body = &timedBody{ReadCloser: resp.Body, finish: func() {
+				t.timing.Total = time.Since(t.start)
+				logger.Debug("API request timing of "+t.timing.Name, "method", t.timing.Method, "path", t.timing.Path,
+					"dns", t.timing.DNS.Round(time.Microsecond), "connect", t.timing.Connect.Round(time.Microsecond),
+					"tls", t.timing.TLS.Round(time.Microsecond), "ttfb", t.timing.TTFB.Round(time.Microsecond),
+					"total", t.timing.Total.Round(time.Microsecond))
+				onTiming(t.timing)
+			}}
+			return resp, nil
+		})
+	}
+}
+
+// timedBody calls finish once, when the end of body is reached or when it's closed.
+type timedBody struct {
+	io.ReadCloser
+	once   sync.Once
+	finish func()
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.finish)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.once.Do(b.finish)
+	return b.ReadCloser.Close()
+}
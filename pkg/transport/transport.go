@@ -0,0 +1,162 @@
+/*
+ * Copyright The Microcks Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package transport provides the http.RoundTripper middlewares used by Microcks and Keycloak clients:
+// headers injection, caching, retries, verbose dumps, rate limiting, timing, logging, response size limit
+// and recording or replaying of interactions.
+// They can be composed using Chain on top of any base transport.
+package transport
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// LevelTrace is the log level of HTTP exchanges dumps, below slog.LevelDebug.
+const LevelTrace = slog.Level(-8)
+
+// Middleware wraps a RoundTripper to add a feature to it.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to the http.RoundTripper interface.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper for RoundTripperFunc.
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps base with middlewares, the first one being the outermost: it sees requests first
+// and responses last. Nil middlewares are skipped and a nil base means http.DefaultTransport.
+func Chain(base http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	rt := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			rt = middlewares[i](rt)
+		}
+	}
+	return rt
+}
+
+type requestInfo struct {
+	name     string
+	dumpBody bool
+}
+
+type requestInfoKey struct{}
+
+// Describe returns req annotated with the name of the endpoint it targets, used by middlewares
+// in their logs, and telling if its body may be dumped (not binary nor secret).
+func Describe(req *http.Request, name string, dumpBody bool) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), requestInfoKey{}, requestInfo{name: name, dumpBody: dumpBody}))
+}
+
+// RequestName returns the endpoint name set on req by Describe, its method and path otherwise.
+func RequestName(req *http.Request) string {
+	if info, ok := req.Context().Value(requestInfoKey{}).(requestInfo); ok {
+		return info.name
+	}
+	return req.Method + " " + req.URL.Path
+}
+
+func dumpBody(req *http.Request) bool {
+	info, ok := req.Context().Value(requestInfoKey{}).(requestInfo)
+	return (!ok || info.dumpBody) && !hasSecretBodies(req)
+}
+
+type secretBodiesKey struct{}
+
+// WithSecretBodies returns ctx marking the requests sent with it as carrying secrets, in their body or
+// in the one of their response. Neither is ever dumped.
+func WithSecretBodies(ctx context.Context) context.Context {
+	return context.WithValue(ctx, secretBodiesKey{}, true)
+}
+
+func hasSecretBodies(req *http.Request) bool {
+	secret, _ := req.Context().Value(secretBodiesKey{}).(bool)
+	return secret
+}
+
+type streamedBodyKey struct{}
+
+// WithStreamedBody returns ctx marking the requests sent with it as having a response body streamed
+// by the caller rather than read in memory. Such bodies are neither bounded by LimitBody nor cached.
+func WithStreamedBody(ctx context.Context) context.Context {
+	return context.WithValue(ctx, streamedBodyKey{}, true)
+}
+
+func hasStreamedBody(req *http.Request) bool {
+	streamed, _ := req.Context().Value(streamedBodyKey{}).(bool)
+	return streamed
+}
+
+// Headers sets headers on every request, replacing values set by the caller. Empty values are ignored.
+func Headers(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// RoundTrippers must not modify the request they're given.
+			req = req.Clone(req.Context())
+			for name, value := range headers {
+				if len(name) > 0 && len(value) > 0 {
+					req.Header.Set(name, value)
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Log logs a summary of every API exchange at debug level.
+func Log(logger *slog.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			logger.Debug("API request to "+RequestName(req), "method", req.Method, "path", req.URL.Path,
+				"status", resp.StatusCode, "duration", time.Since(start).Round(time.Millisecond))
+			return resp, nil
+		})
+	}
+}
+
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// LimitBody bounds response bodies to maxBytes+1 bytes, allowing readers to detect overflow
+// without loading unbounded content in memory, except for streamed ones (see WithStreamedBody).
+// It must be inside Dump as dumps read bodies.
+func LimitBody(maxBytes int64) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil || hasStreamedBody(req) {
+				return resp, err
+			}
+			resp.Body = limitedBody{io.LimitReader(resp.Body, maxBytes+1), resp.Body}
+			return resp, nil
+		})
+	}
+}
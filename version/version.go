@@ -15,6 +15,50 @@
  */
 package version
 
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata. Commit and BuildDate are injected at build time with:
+// -ldflags "-X github.com/microcks/microcks-cli/version.Commit=<sha> -X github.com/microcks/microcks-cli/version.BuildDate=<date>"
 var (
-	Version = "0.5.6"
+	Version   = "0.5.6"
+	Commit    = ""
+	BuildDate = ""
 )
+
+// GitCommit returns the git commit the binary was built from, falling back to the VCS information
+// recorded by go build or to "dev" when built from source.
+func GitCommit() string {
+	if len(Commit) > 0 {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "dev"
+}
+
+// Date returns the date the binary was built, or "dev" when built from source.
+func Date() string {
+	if len(BuildDate) > 0 {
+		return BuildDate
+	}
+	return "dev"
+}
+
+// GoVersion returns the Go version and platform the binary was built for.
+func GoVersion() string {
+	return fmt.Sprintf("%s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// UserAgent returns the User-Agent header value sent with API calls.
+func UserAgent() string {
+	return "microcks-cli/" + Version
+}